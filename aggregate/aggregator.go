@@ -0,0 +1,246 @@
+package aggregate
+
+import (
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sync"
+	"time"
+)
+
+// closeInterval is the interval to close the windows which have ended.
+const closeInterval = time.Second
+
+// definition is a ProductAggregation with the parsed durations.
+type definition struct {
+	*models.ProductAggregation
+	window, slide time.Duration
+	properties    map[models.ProductPropertyID]bool // nil means all numeric properties
+}
+
+func (d *definition) covers(propertyID models.ProductPropertyID) bool {
+	return d.properties == nil || d.properties[propertyID]
+}
+
+// NewAggregator returns an Aggregator publishing the aggregated values by the DataDriverClient.
+func NewAggregator(protocolID string, dc operations.DataDriverClient, lg *logger.Logger) *Aggregator {
+	return &Aggregator{
+		protocolID:  protocolID,
+		dc:          dc,
+		definitions: make(map[string][]*definition),
+		series:      make(map[string]map[string]*series),
+		now:         time.Now,
+		lg:          lg,
+	}
+}
+
+// Aggregator aggregates the properties of devices within the tumbling or sliding windows declared
+// by the products, and publishes the aggregated values once the windows end.
+//
+// The windows are keyed by the timestamps of the values, but they are closed by the processing time,
+// i.e. a window is published once the clock passes its end. So the values observed after their
+// windows have been published are dropped as late ones, e.g. the values replayed from the buffer
+// of the message bus after reconnecting, and the values of the devices whose clocks fall behind.
+type Aggregator struct {
+	protocolID string
+	dc         operations.DataDriverClient
+
+	mu          sync.Mutex
+	definitions map[string][]*definition      // product ID -> aggregations
+	series      map[string]map[string]*series // device ID -> aggregation ID -> series
+	now         func() time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lg *logger.Logger
+}
+
+// SetProduct loads the aggregations declared by the product, the pending windows of its devices are dropped.
+func (a *Aggregator) SetProduct(product *models.Product) error {
+	definitions := make([]*definition, 0, len(product.Aggregations))
+	for _, aggregation := range product.Aggregations {
+		window, slide, err := aggregation.Durations()
+		if err != nil {
+			return err
+		}
+		d := &definition{ProductAggregation: aggregation, window: window, slide: slide}
+		if len(aggregation.PropertyIDs) != 0 {
+			d.properties = make(map[models.ProductPropertyID]bool, len(aggregation.PropertyIDs))
+			for _, id := range aggregation.PropertyIDs {
+				d.properties[id] = true
+			}
+		}
+		definitions = append(definitions, d)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.definitions[product.ID] = definitions
+	a.dropSeries(product.ID)
+	return nil
+}
+
+// RemoveProduct unloads the aggregations declared by the product.
+func (a *Aggregator) RemoveProduct(productID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.definitions, productID)
+	a.dropSeries(productID)
+}
+
+// RemoveDevice drops the pending windows of the device.
+func (a *Aggregator) RemoveDevice(deviceID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.series, deviceID)
+}
+
+// Replaced returns whether the raw values of the property shouldn't be published,
+// because they are replaced by the aggregated values.
+func (a *Aggregator) Replaced(productID string, propertyID models.ProductPropertyID) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, d := range a.definitions[productID] {
+		if d.ReplaceRaw && d.covers(propertyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Observe puts the properties of the device into the windows, the properties
+// which are not numbers or whose quality is bad are ignored, and the late ones are dropped.
+func (a *Aggregator) Observe(productID, deviceID string, props map[models.ProductPropertyID]*models.DeviceData) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	definitions := a.definitions[productID]
+	if len(definitions) == 0 {
+		return
+	}
+	all, ok := a.series[deviceID]
+	if !ok {
+		all = make(map[string]*series)
+		a.series[deviceID] = all
+	}
+
+	now := a.now()
+	for id, prop := range props {
+		if prop == nil || prop.IsBad() {
+			continue
+		}
+		v, err := prop.NumericValue()
+		if err != nil {
+			continue
+		}
+		ts := prop.Ts
+		if ts.IsZero() {
+			ts = now
+		}
+		for _, d := range definitions {
+			if !d.covers(id) {
+				continue
+			}
+			s, ok := all[d.Id]
+			if !ok {
+				s = newSeries(d, productID, deviceID)
+				all[d.Id] = s
+			}
+			if s.add(id, v, ts) {
+				a.lg.Debugf("the property[%s] of the device[%s] at %s is too late for the aggregation[%s], "+
+					"it is dropped", id, deviceID, ts.Format(time.RFC3339), d.Id)
+			}
+		}
+	}
+}
+
+// Consume feeds the properties of the device subscribed by the DataManagerService, so that the
+// aggregation can be done by the manager as well. Call the returned function to stop consuming.
+func (a *Aggregator) Consume(ms operations.DataManagerService, productID, deviceID string) (func(), error) {
+	bus, stop, err := ms.SubscribeDeviceProps(a.protocolID, productID, deviceID, operations.TopicSingleLevelWildcard)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for v := range bus {
+			if props, ok := v.(map[models.ProductPropertyID]*models.DeviceData); ok {
+				a.Observe(productID, deviceID, props)
+			}
+		}
+	}()
+	return stop, nil
+}
+
+// Start starts to close the windows periodically.
+func (a *Aggregator) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.stop != nil {
+		return
+	}
+	a.stop = make(chan struct{})
+	a.wg.Add(1)
+	go a.loop(a.stop)
+}
+
+// Stop stops closing the windows, the pending windows are kept.
+func (a *Aggregator) Stop() {
+	a.mu.Lock()
+	if a.stop == nil {
+		a.mu.Unlock()
+		return
+	}
+	close(a.stop)
+	a.stop = nil
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+func (a *Aggregator) loop(stop <-chan struct{}) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(closeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.close()
+		}
+	}
+}
+
+// close publishes the windows which have ended.
+func (a *Aggregator) close() {
+	a.mu.Lock()
+	now := a.now()
+	var windows []*models.AggregateWindow
+	for _, all := range a.series {
+		for _, s := range all {
+			windows = append(windows, s.close(now)...)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, w := range windows {
+		if len(w.Values) == 0 {
+			continue
+		}
+		if err := a.dc.PublishAggregate(a.protocolID, w); err != nil {
+			a.lg.WithError(err).Errorf("fail to publish the aggregation[%s] of the device[%s]", w.AggregationID, w.DeviceID)
+		}
+	}
+}
+
+func (a *Aggregator) dropSeries(productID string) {
+	for deviceID, all := range a.series {
+		for id, s := range all {
+			if s.productID == productID {
+				delete(all, id)
+			}
+		}
+		if len(all) == 0 {
+			delete(a.series, deviceID)
+		}
+	}
+}
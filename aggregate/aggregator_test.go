@@ -0,0 +1,85 @@
+package aggregate
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	operations.DataDriverClient
+	windows []*models.AggregateWindow
+}
+
+func (r *recorder) PublishAggregate(protocolID string, window *models.AggregateWindow) error {
+	r.windows = append(r.windows, window)
+	return nil
+}
+
+func newAggregator(t *testing.T, aggregation *models.ProductAggregation) (*Aggregator, *recorder, *time.Time) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := new(recorder)
+	a := NewAggregator("protocol", rc, lg)
+	if err = a.SetProduct(&models.Product{ID: "product", Aggregations: []*models.ProductAggregation{aggregation}}); err != nil {
+		t.Fatalf("SetProduct() error = %v", err)
+	}
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	return a, rc, &now
+}
+
+func observe(a *Aggregator, v float64, ts time.Time) {
+	a.Observe("product", "device", map[models.ProductPropertyID]*models.DeviceData{
+		"temperature": {Name: "temperature", Type: models.PropertyValueTypeFloat, Value: v, Ts: ts},
+	})
+}
+
+func TestAggregator_Tumbling(t *testing.T) {
+	a, rc, now := newAggregator(t, &models.ProductAggregation{Id: "minutely", Window: "1m",
+		Functions: []models.AggregateFunction{models.AggregateFunctionMin, models.AggregateFunctionMax,
+			models.AggregateFunctionAvg, models.AggregateFunctionCount, models.AggregateFunctionLast}})
+	start := *now
+	observe(a, 20, start.Add(10*time.Second))
+	observe(a, 30, start.Add(20*time.Second))
+	observe(a, 10, start.Add(70*time.Second))
+
+	*now = start.Add(61 * time.Second)
+	a.close()
+	if len(rc.windows) != 1 {
+		t.Fatalf("the number of the closed windows = %d, want 1", len(rc.windows))
+	}
+	values := rc.windows[0].Values["temperature"]
+	if values["min"] != 20 || values["max"] != 30 || values["avg"] != 25 || values["count"] != 2 || values["last"] != 30 {
+		t.Errorf("the values of the window = %v", values)
+	}
+
+	// the value of the closed window is ignored
+	observe(a, 100, start.Add(30*time.Second))
+	*now = start.Add(121 * time.Second)
+	a.close()
+	if len(rc.windows) != 2 || rc.windows[1].Values["temperature"]["max"] != 10 {
+		t.Errorf("the second window = %v, want max 10", rc.windows[1:])
+	}
+}
+
+func TestAggregator_Sliding(t *testing.T) {
+	a, rc, now := newAggregator(t, &models.ProductAggregation{Id: "sliding", Window: "1m", Slide: "30s",
+		Functions: []models.AggregateFunction{models.AggregateFunctionCount}})
+	start := *now
+	observe(a, 1, start.Add(40*time.Second))
+
+	*now = start.Add(2 * time.Minute)
+	a.close()
+	if len(rc.windows) != 2 {
+		t.Fatalf("the number of the windows covering the value = %d, want 2", len(rc.windows))
+	}
+	if !rc.windows[0].Start.Equal(start) || !rc.windows[1].Start.Equal(start.Add(30*time.Second)) {
+		t.Errorf("the windows start at %s and %s", rc.windows[0].Start, rc.windows[1].Start)
+	}
+}
@@ -0,0 +1,33 @@
+package aggregate
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+)
+
+// NewDataDriverClient returns a DataDriverClient which aggregates the properties before they are published,
+// the raw values replaced by the aggregated values won't be published.
+func NewDataDriverClient(dc operations.DataDriverClient, aggregator *Aggregator) operations.DataDriverClient {
+	return &dataDriverClient{DataDriverClient: dc, aggregator: aggregator}
+}
+
+type dataDriverClient struct {
+	operations.DataDriverClient
+	aggregator *Aggregator
+}
+
+func (d *dataDriverClient) PublishDeviceProps(protocolID, productID, deviceID string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	d.aggregator.Observe(productID, deviceID, props)
+
+	raw := make(map[models.ProductPropertyID]*models.DeviceData, len(props))
+	for id, prop := range props {
+		if !d.aggregator.Replaced(productID, id) {
+			raw[id] = prop
+		}
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return d.DataDriverClient.PublishDeviceProps(protocolID, productID, deviceID, propertyID, raw)
+}
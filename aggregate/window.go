@@ -0,0 +1,130 @@
+package aggregate
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// accumulator aggregates the values of a property within a window.
+type accumulator struct {
+	min, max, sum float64
+	count         int
+	last          float64
+	lastTs        time.Time
+}
+
+func (a *accumulator) add(v float64, ts time.Time) {
+	if a.count == 0 {
+		a.min, a.max = v, v
+	} else {
+		a.min, a.max = math.Min(a.min, v), math.Max(a.max, v)
+	}
+	a.sum += v
+	a.count++
+	if !ts.Before(a.lastTs) {
+		a.last, a.lastTs = v, ts
+	}
+}
+
+func (a *accumulator) result(functions []models.AggregateFunction) map[models.AggregateFunction]float64 {
+	result := make(map[models.AggregateFunction]float64, len(functions))
+	for _, fn := range functions {
+		switch fn {
+		case models.AggregateFunctionMin:
+			result[fn] = a.min
+		case models.AggregateFunctionMax:
+			result[fn] = a.max
+		case models.AggregateFunctionAvg:
+			result[fn] = a.sum / float64(a.count)
+		case models.AggregateFunctionCount:
+			result[fn] = float64(a.count)
+		case models.AggregateFunctionLast:
+			result[fn] = a.last
+		}
+	}
+	return result
+}
+
+type window struct {
+	start, end time.Time
+	values     map[models.ProductPropertyID]*accumulator
+}
+
+// series holds the windows of an aggregation of a device which haven't been closed yet.
+type series struct {
+	definition *definition
+	productID  string
+	deviceID   string
+	windows    map[int64]*window // start in unix nanoseconds -> window
+	watermark  time.Time         // the windows ending before it have been closed, in the processing time
+}
+
+func newSeries(definition *definition, productID, deviceID string) *series {
+	return &series{
+		definition: definition,
+		productID:  productID,
+		deviceID:   deviceID,
+		windows:    make(map[int64]*window),
+	}
+}
+
+// add puts the value into all the windows covering the ts, i.e. the windows starting
+// at the multiples of the slide within (ts - window, ts], and returns whether it is too late
+// for any of them, i.e. the window has been closed before the value is observed.
+func (s *series) add(propertyID models.ProductPropertyID, v float64, ts time.Time) (late bool) {
+	for start := ts.Truncate(s.definition.slide); start.After(ts.Add(-s.definition.window)); start = start.Add(-s.definition.slide) {
+		end := start.Add(s.definition.window)
+		if !end.After(s.watermark) {
+			// the window has been closed, the value is too late
+			return true
+		}
+		w, ok := s.windows[start.UnixNano()]
+		if !ok {
+			w = &window{start: start, end: end, values: make(map[models.ProductPropertyID]*accumulator)}
+			s.windows[start.UnixNano()] = w
+		}
+		acc, ok := w.values[propertyID]
+		if !ok {
+			acc = new(accumulator)
+			w.values[propertyID] = acc
+		}
+		acc.add(v, ts)
+	}
+	return false
+}
+
+// close closes the windows ending before now, and returns their results in the order of the start.
+func (s *series) close(now time.Time) []*models.AggregateWindow {
+	var closed []*window
+	for key, w := range s.windows {
+		if !w.end.After(now) {
+			closed = append(closed, w)
+			delete(s.windows, key)
+		}
+	}
+	if now.After(s.watermark) {
+		s.watermark = now
+	}
+	sort.Slice(closed, func(i, j int) bool {
+		return closed[i].start.Before(closed[j].start)
+	})
+
+	results := make([]*models.AggregateWindow, 0, len(closed))
+	for _, w := range closed {
+		values := make(map[models.ProductPropertyID]map[models.AggregateFunction]float64, len(w.values))
+		for id, acc := range w.values {
+			values[id] = acc.result(s.definition.Functions)
+		}
+		results = append(results, &models.AggregateWindow{
+			AggregationID: s.definition.Id,
+			ProductID:     s.productID,
+			DeviceID:      s.deviceID,
+			Start:         w.start,
+			End:           w.end,
+			Values:        values,
+		})
+	}
+	return results
+}
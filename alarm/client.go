@@ -0,0 +1,23 @@
+package alarm
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+)
+
+// NewDataDriverClient returns a DataDriverClient which evaluates the alarms
+// on the properties before they are published.
+func NewDataDriverClient(dc operations.DataDriverClient, evaluator *Evaluator) operations.DataDriverClient {
+	return &dataDriverClient{DataDriverClient: dc, evaluator: evaluator}
+}
+
+type dataDriverClient struct {
+	operations.DataDriverClient
+	evaluator *Evaluator
+}
+
+func (d *dataDriverClient) PublishDeviceProps(protocolID, productID, deviceID string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	d.evaluator.Observe(productID, deviceID, props)
+	return d.DataDriverClient.PublishDeviceProps(protocolID, productID, deviceID, propertyID, props)
+}
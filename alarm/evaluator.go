@@ -0,0 +1,291 @@
+package alarm
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sync"
+	"time"
+)
+
+// NewEvaluator returns an Evaluator raising the alarms declared by the products of the protocol.
+func NewEvaluator(protocolID string, dc operations.DataDriverClient, lg *logger.Logger) *Evaluator {
+	return &Evaluator{
+		protocolID: protocolID,
+		dc:         dc,
+		products:   make(map[string][]*models.ProductAlarm),
+		alarms:     make(map[string]map[string]*models.Alarm),
+		now:        time.Now,
+		lg:         lg,
+	}
+}
+
+// Evaluator checks the properties of devices against the limits of ProductAlarm,
+// and publishes the alarms once they are raised, acknowledged or cleared.
+//
+// The lifecycle of an alarm is active -> acknowledged -> cleared(removed), or active -> cleared -> removed
+// after it is acknowledged. A cleared alarm raised again becomes active.
+type Evaluator struct {
+	protocolID string
+	dc         operations.DataDriverClient
+
+	mu       sync.Mutex
+	products map[string][]*models.ProductAlarm   // product ID -> alarms
+	alarms   map[string]map[string]*models.Alarm // device ID -> alarm ID -> alarm
+	now      func() time.Time
+
+	lg *logger.Logger
+}
+
+// Serve registers the handlers of alarm operations requested by the manager.
+func (e *Evaluator) Serve(ds operations.DataDriverService) error {
+	if err := ds.AlarmAckHandler(e.protocolID, e.Ack); err != nil {
+		return err
+	}
+	return ds.AlarmShelveHandler(e.protocolID, e.Shelve)
+}
+
+// SetProduct loads the alarms declared by the product, it replaces the alarms loaded before,
+// and drops the alarms of devices whose definitions are removed from the product.
+func (e *Evaluator) SetProduct(product *models.Product) error {
+	if err := product.Validate(); err != nil {
+		return err
+	}
+	definitions := make(map[string]bool, len(product.Alarms))
+	for _, definition := range product.Alarms {
+		definitions[definition.Id] = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.products[product.ID] = product.Alarms
+	for _, alarms := range e.alarms {
+		for id, alarm := range alarms {
+			if alarm.ProductID == product.ID && !definitions[id] {
+				delete(alarms, id)
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveProduct unloads the alarms declared by the product.
+func (e *Evaluator) RemoveProduct(productID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.products, productID)
+}
+
+// RemoveDevice drops the alarms of the device, it should be called after the device is deleted.
+func (e *Evaluator) RemoveDevice(deviceID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.alarms, deviceID)
+}
+
+// List returns copies of the alarms of the device which haven't been removed.
+func (e *Evaluator) List(productID, deviceID string) []*models.Alarm {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alarms := make([]*models.Alarm, 0, len(e.alarms[deviceID]))
+	for _, alarm := range e.alarms[deviceID] {
+		if alarm.ProductID != productID {
+			continue
+		}
+		cp := *alarm
+		alarms = append(alarms, &cp)
+	}
+	return alarms
+}
+
+// Observe evaluates the properties reported by the device, e.g. the properties published by watching.
+// The properties whose Type isn't a number are skipped, see models.DeviceData.NumericValue.
+func (e *Evaluator) Observe(productID, deviceID string, props map[models.ProductPropertyID]*models.DeviceData) {
+	e.mu.Lock()
+	var changes []*models.Alarm
+	for _, definition := range e.products[productID] {
+		prop, ok := props[definition.PropertyID]
+		if !ok || prop == nil || prop.IsBad() {
+			continue
+		}
+		value, err := prop.NumericValue()
+		if err != nil {
+			e.lg.WithError(err).Warnf("the property[%s] of the device[%s] is not a number", prop.Name, deviceID)
+			continue
+		}
+		if alarm := e.evaluate(definition, productID, deviceID, value); alarm != nil {
+			changes = append(changes, alarm)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, alarm := range changes {
+		e.publish(alarm)
+	}
+}
+
+// Ack acknowledges the alarm of the device, a cleared alarm will be removed after acknowledged.
+func (e *Evaluator) Ack(productID, deviceID, alarmID string) (*models.Alarm, error) {
+	e.mu.Lock()
+	alarm, err := e.alarm(productID, deviceID, alarmID)
+	if err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	switch alarm.State {
+	case models.AlarmStateActive:
+		alarm.State = models.AlarmStateAcknowledged
+		alarm.AckedAt = e.timestamp()
+	case models.AlarmStateCleared:
+		alarm.AckedAt = e.timestamp()
+		delete(e.alarms[deviceID], alarmID)
+	}
+	cp := *alarm
+	e.mu.Unlock()
+
+	e.publish(&cp)
+	return &cp, nil
+}
+
+// Shelve suppresses the publishing of the changes of the alarm of the device for the duration,
+// the current state of the alarm is published once the shelving expires.
+func (e *Evaluator) Shelve(productID, deviceID, alarmID string, shelve *operations.AlarmShelve) (*models.Alarm, error) {
+	if shelve.DurationSecond <= 0 {
+		return nil, errors.BadRequest.Error("the duration of shelving the alarm[%s] should be positive", alarmID)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alarm, err := e.alarm(productID, deviceID, alarmID)
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Duration(shelve.DurationSecond) * time.Second
+	until := e.now().Add(duration)
+	alarm.ShelvedUntil = &until
+	time.AfterFunc(duration, func() {
+		e.unshelve(alarm)
+	})
+	cp := *alarm
+	return &cp, nil
+}
+
+// unshelve publishes the current state of the alarm if its shelving has expired, so that
+// the changes suppressed while shelving, e.g. the alarm is cleared, are seen by the subscribers.
+func (e *Evaluator) unshelve(alarm *models.Alarm) {
+	e.mu.Lock()
+	if alarm.ShelvedUntil == nil || alarm.Shelved(e.now()) {
+		e.mu.Unlock()
+		return // not shelved, or shelved again
+	}
+	current, ok := e.alarms[alarm.DeviceID][alarm.AlarmID]
+	if ok && current != alarm {
+		e.mu.Unlock()
+		return // replaced by a new alarm raised after this one was removed
+	}
+	if !ok && (alarm.State != models.AlarmStateCleared || alarm.AckedAt == nil) {
+		e.mu.Unlock()
+		return // dropped with the device or the definition instead of being cleared and acknowledged
+	}
+	alarm.ShelvedUntil = nil
+	cp := *alarm
+	e.mu.Unlock()
+
+	e.publish(&cp)
+}
+
+// evaluate updates the state of the alarm by the value, and returns a copy of the alarm if its state changes.
+func (e *Evaluator) evaluate(definition *models.ProductAlarm, productID, deviceID string, value float64) *models.Alarm {
+	alarms, ok := e.alarms[deviceID]
+	if !ok {
+		alarms = make(map[string]*models.Alarm)
+		e.alarms[deviceID] = alarms
+	}
+	alarm, ok := alarms[definition.Id]
+
+	if ok && alarm.State != models.AlarmStateCleared {
+		if !cleared(definition, alarm.Limit, value) {
+			return nil
+		}
+		alarm.State = models.AlarmStateCleared
+		alarm.ClearedAt = e.timestamp()
+		alarm.Value = value
+		alarm.Message = fmt.Sprintf("%s: the value %v of %s is back to normal", definition.Name, value, definition.PropertyID)
+		if alarm.AckedAt != nil {
+			delete(alarms, definition.Id)
+		}
+		cp := *alarm
+		return &cp
+	}
+
+	limit, exceeded := exceeds(definition, value)
+	if !exceeded {
+		return nil
+	}
+	if !ok {
+		alarm = &models.Alarm{
+			AlarmID:    definition.Id,
+			ProductID:  productID,
+			DeviceID:   deviceID,
+			PropertyID: definition.PropertyID,
+			Severity:   definition.Severity,
+		}
+		alarms[definition.Id] = alarm
+	}
+	alarm.State = models.AlarmStateActive
+	alarm.Value = value
+	alarm.Limit = limit
+	alarm.RaisedAt = e.now()
+	alarm.AckedAt = nil
+	alarm.ClearedAt = nil
+	alarm.Message = fmt.Sprintf("%s: the value %v of %s exceeds the limit %v", definition.Name, value, definition.PropertyID, limit)
+	cp := *alarm
+	return &cp
+}
+
+// timestamp returns the current time to be recorded in the alarm.
+func (e *Evaluator) timestamp() *time.Time {
+	now := e.now()
+	return &now
+}
+
+func (e *Evaluator) publish(alarm *models.Alarm) {
+	if alarm.Shelved(e.now()) {
+		e.lg.Debugf("the alarm[%s] of the device[%s] is shelved, ignore its change", alarm.AlarmID, alarm.DeviceID)
+		return
+	}
+	if err := e.dc.PublishAlarm(e.protocolID, alarm); err != nil {
+		e.lg.WithError(err).Errorf("fail to publish the alarm[%s] of the device[%s]", alarm.AlarmID, alarm.DeviceID)
+	}
+}
+
+func (e *Evaluator) alarm(productID, deviceID, alarmID string) (*models.Alarm, error) {
+	alarm, ok := e.alarms[deviceID][alarmID]
+	if !ok {
+		return nil, errors.NotFound.Error("the alarm[%s] of the device[%s] is not found", alarmID, deviceID)
+	}
+	if alarm.ProductID != productID {
+		return nil, errors.BadRequest.Error("the device[%s] doesn't belong to the product[%s]", deviceID, productID)
+	}
+	return alarm, nil
+}
+
+// exceeds returns the limit exceeded by the value if any.
+func exceeds(definition *models.ProductAlarm, value float64) (float64, bool) {
+	if definition.High != nil && value > *definition.High {
+		return *definition.High, true
+	}
+	if definition.Low != nil && value < *definition.Low {
+		return *definition.Low, true
+	}
+	return 0, false
+}
+
+// cleared returns whether the value has gone back across the limit by the hysteresis.
+func cleared(definition *models.ProductAlarm, limit, value float64) bool {
+	if definition.High != nil && limit == *definition.High {
+		return value < limit-definition.Hysteresis
+	}
+	return value > limit+definition.Hysteresis
+}
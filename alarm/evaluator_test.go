@@ -0,0 +1,121 @@
+package alarm
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	operations.DataDriverClient
+	alarms []*models.Alarm
+}
+
+func (r *recorder) PublishAlarm(protocolID string, alarm *models.Alarm) error {
+	r.alarms = append(r.alarms, alarm)
+	return nil
+}
+
+func newEvaluator(t *testing.T) (*Evaluator, *recorder) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high := 80.0
+	rc := new(recorder)
+	e := NewEvaluator("protocol", rc, lg)
+	if err := e.SetProduct(newProduct(&models.ProductAlarm{
+		Id: "overheat", PropertyID: "temperature", Severity: models.AlarmSeverityMajor, High: &high, Hysteresis: 2,
+	})); err != nil {
+		t.Fatalf("SetProduct() error = %v", err)
+	}
+	return e, rc
+}
+
+func newProduct(alarms ...*models.ProductAlarm) *models.Product {
+	return &models.Product{ID: "product", Alarms: alarms, Properties: []*models.ProductProperty{
+		{Id: "temperature", FieldType: models.PropertyValueTypeFloat},
+	}}
+}
+
+func observe(e *Evaluator, value float64) {
+	e.Observe("product", "device", map[models.ProductPropertyID]*models.DeviceData{
+		"temperature": {Name: "temperature", Type: models.PropertyValueTypeFloat, Value: value},
+	})
+}
+
+func TestEvaluator_Hysteresis(t *testing.T) {
+	e, rc := newEvaluator(t)
+	observe(e, 85)
+	observe(e, 90)
+	if len(rc.alarms) != 1 || rc.alarms[0].State != models.AlarmStateActive {
+		t.Fatalf("alarms after exceeding the limit = %v, want one active alarm", rc.alarms)
+	}
+	observe(e, 79)
+	if len(rc.alarms) != 1 {
+		t.Fatalf("the alarm is cleared within the hysteresis")
+	}
+	observe(e, 77)
+	if len(rc.alarms) != 2 || rc.alarms[1].State != models.AlarmStateCleared {
+		t.Fatalf("alarms after dropping below the hysteresis = %v, want a cleared alarm", rc.alarms)
+	}
+	if len(e.List("product", "device")) != 1 {
+		t.Errorf("the cleared alarm is removed before acknowledged")
+	}
+	if _, err := e.Ack("product", "device", "overheat"); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if len(e.List("product", "device")) != 0 {
+		t.Errorf("the cleared alarm is kept after acknowledged")
+	}
+}
+
+func TestEvaluator_Shelve(t *testing.T) {
+	e, rc := newEvaluator(t)
+	observe(e, 85)
+	if _, err := e.Shelve("product", "device", "overheat", &operations.AlarmShelve{DurationSecond: 60}); err != nil {
+		t.Fatalf("Shelve() error = %v", err)
+	}
+	observe(e, 70)
+	if len(rc.alarms) != 1 {
+		t.Errorf("the change of the shelved alarm is published")
+	}
+	if alarms := e.List("product", "device"); len(alarms) != 1 || alarms[0].State != models.AlarmStateCleared {
+		t.Errorf("the state of the shelved alarm = %v, want cleared", alarms)
+	}
+
+	// the clear suppressed while shelving is published once the shelving expires
+	alarm := e.alarms["device"]["overheat"]
+	e.unshelve(alarm)
+	if len(rc.alarms) != 1 {
+		t.Errorf("the alarm is published before the shelving expires")
+	}
+	now := time.Now().Add(time.Minute + time.Second)
+	e.now = func() time.Time { return now }
+	e.unshelve(alarm)
+	if alarms := rc.alarms; len(alarms) != 2 || alarms[1].State != models.AlarmStateCleared || alarms[1].ShelvedUntil != nil {
+		t.Errorf("alarms after the shelving expires = %v, want the cleared alarm", alarms)
+	}
+}
+
+func TestEvaluator_SetProduct(t *testing.T) {
+	e, _ := newEvaluator(t)
+	observe(e, 85)
+	if err := e.SetProduct(newProduct(&models.ProductAlarm{
+		Id: "overheat", PropertyID: "humidity", Severity: models.AlarmSeverityMajor,
+	})); err == nil {
+		t.Errorf("SetProduct() the invalid product should be rejected")
+	}
+	if len(e.List("product", "device")) != 1 {
+		t.Errorf("the alarm is dropped by the invalid product")
+	}
+	if err := e.SetProduct(newProduct()); err != nil {
+		t.Fatalf("SetProduct() error = %v", err)
+	}
+	if alarms := e.List("product", "device"); len(alarms) != 0 {
+		t.Errorf("the alarms of the removed definition = %v, want none", alarms)
+	}
+}
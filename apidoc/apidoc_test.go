@@ -0,0 +1,135 @@
+package apidoc
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/models"
+	"testing"
+)
+
+func testProduct() *models.Product {
+	return &models.Product{
+		ID:       "meter",
+		Name:     "Meter",
+		Protocol: "modbus",
+		Properties: []*models.ProductProperty{
+			{Id: "voltage", Name: "Voltage", FieldType: models.PropertyValueTypeFloat},
+			{Id: "switch", Name: "Switch", FieldType: models.PropertyValueTypeBool, Writeable: true},
+		},
+		Events: []*models.ProductEvent{
+			{Id: "overload", Name: "Overload", Outs: []*models.ProductField{
+				{Id: "current", FieldType: models.PropertyValueTypeFloat},
+			}},
+		},
+		Methods: []*models.ProductMethod{
+			{Id: "reset", Name: "Reset", Ins: []*models.ProductField{
+				{Id: "delay", FieldType: models.PropertyValueTypeInt},
+			}, Outs: []*models.ProductField{
+				{Id: "ok", FieldType: models.PropertyValueTypeBool},
+			}},
+		},
+	}
+}
+
+func TestGenerateAsyncAPI(t *testing.T) {
+	doc := GenerateAsyncAPI(testProduct())
+
+	watch, ok := doc.Channels["DATA/v1/UP/modbus/meter/{device_id}/voltage/PROPS/{req_id}"]
+	if !ok {
+		t.Fatalf("the channel of watching voltage is missing")
+	}
+	if watch.Subscribe == nil || watch.Publish != nil {
+		t.Errorf("the channel of watching should only be subscribed")
+	}
+	if _, ok := watch.Parameters["device_id"]; !ok {
+		t.Errorf("the parameter device_id is missing")
+	}
+	value := watch.Subscribe.Message.Payload.Properties["voltage"].Properties["value"]
+	if value.Type != "number" {
+		t.Errorf("the type of the value of voltage should be number, got %s", value.Type)
+	}
+
+	if _, ok := doc.Channels["DATA/v1/DOWN/modbus/meter/{device_id}/voltage/WRITE/{req_id}"]; ok {
+		t.Errorf("the read-only property shouldn't be writeable")
+	}
+	if _, ok := doc.Channels["DATA/v1/DOWN/modbus/meter/{device_id}/switch/WRITE/{req_id}"]; !ok {
+		t.Errorf("the channel of writing switch is missing")
+	}
+	call, ok := doc.Channels["DATA/v1/DOWN/modbus/meter/{device_id}/reset/CALL/{req_id}"]
+	if !ok {
+		t.Fatalf("the channel of calling reset is missing")
+	}
+	if call.Publish.Message.Payload.Properties["delay"].Properties["value"].Type != "integer" {
+		t.Errorf("the type of the input delay should be integer")
+	}
+	if _, ok := doc.Channels["DATA/v1/UP-ERR/modbus/meter/{device_id}/reset/CALL/{req_id}"]; !ok {
+		t.Errorf("the channel of the errors of calling reset is missing")
+	}
+	if _, ok := doc.Channels["DATA/v1/UP/modbus/meter/{device_id}/overload/EVENT/{req_id}"]; !ok {
+		t.Errorf("the channel of the event overload is missing")
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("fail to marshal the document: %s", err.Error())
+	}
+
+	product := testProduct()
+	product.Topics = []*models.ProductTopic{{Topic: "plant/meters/{device_id}/{func_id}", OptType: "PROPS"}}
+	doc = GenerateAsyncAPI(product)
+	custom, ok := doc.Channels["plant/meters/{device_id}/voltage"]
+	if !ok {
+		t.Fatalf("the custom topic of watching voltage is missing")
+	}
+	if len(custom.Parameters) != 1 || custom.Parameters["device_id"] == nil {
+		t.Errorf("the custom topic should only have the parameter device_id")
+	}
+
+	// the functions sharing the custom topic without the func ID are the alternatives of the message
+	product = testProduct()
+	product.Properties = append(product.Properties,
+		&models.ProductProperty{Id: "current", Name: "Current", FieldType: models.PropertyValueTypeFloat})
+	product.Topics = []*models.ProductTopic{{Topic: "legacy/{device_id}/telemetry", OptType: "PROPS"}}
+	doc = GenerateAsyncAPI(product)
+	shared, ok := doc.Channels["legacy/{device_id}/telemetry"]
+	if !ok {
+		t.Fatalf("the custom topic shared by the properties is missing")
+	}
+	names := make([]string, 0)
+	for _, msg := range shared.Subscribe.Message.OneOf {
+		for name := range msg.Payload.Properties {
+			names = append(names, name)
+		}
+	}
+	if len(names) != 3 || names[0] != "voltage" || names[1] != "switch" || names[2] != "current" {
+		t.Errorf("the payloads of the shared topic = %v, want voltage, switch and current", names)
+	}
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	doc := GenerateOpenAPI(testProduct())
+
+	prefix := "/protocols/modbus/products/meter/devices/{device_id}"
+	if _, ok := doc.Paths[prefix+"/props/voltage"]["put"]; ok {
+		t.Errorf("the read-only property shouldn't be writeable")
+	}
+	write, ok := doc.Paths[prefix+"/props/switch"]["put"]
+	if !ok {
+		t.Fatalf("the path of writing switch is missing")
+	}
+	if _, ok := write.Responses["204"]; !ok {
+		t.Errorf("the response of writing should be 204")
+	}
+	if write.Parameters[0].Name != "device_id" || !write.Parameters[0].Required {
+		t.Errorf("the path parameter device_id is missing")
+	}
+	if _, ok := doc.Paths[prefix+"/methods/reset"]["post"]; !ok {
+		t.Errorf("the path of calling reset is missing")
+	}
+	if _, ok := doc.Paths[prefix+"/events/overload/stream"]["get"]; !ok {
+		t.Errorf("the path of streaming overload is missing")
+	}
+	if _, ok := doc.Paths["/protocols/modbus/status/stream"]["get"]; !ok {
+		t.Errorf("the path of streaming status is missing")
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Errorf("fail to marshal the document: %s", err.Error())
+	}
+}
@@ -0,0 +1,232 @@
+package apidoc
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/jsonschema"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"github.com/thingio/edge-device-std/version"
+	"sort"
+	"strings"
+)
+
+const AsyncAPIVersion = "2.2.0"
+
+// AsyncAPI is an AsyncAPI document, only the fields generated are defined.
+type AsyncAPI struct {
+	AsyncAPI string              `json:"asyncapi" yaml:"asyncapi"`
+	Info     *Info               `json:"info" yaml:"info"`
+	Channels map[string]*Channel `json:"channels" yaml:"channels"`
+}
+
+type Info struct {
+	Title       string `json:"title" yaml:"title"`
+	Version     string `json:"version" yaml:"version"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Channel is a topic of the message bus. Following the AsyncAPI, the Subscribe describes the messages
+// sent by the driver, and the Publish describes the messages received by the driver.
+type Channel struct {
+	Description string                `json:"description,omitempty" yaml:"description,omitempty"`
+	Parameters  map[string]*Parameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Subscribe   *Operation            `json:"subscribe,omitempty" yaml:"subscribe,omitempty"`
+	Publish     *Operation            `json:"publish,omitempty" yaml:"publish,omitempty"`
+}
+
+type Parameter struct {
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Schema      *jsonschema.Schema `json:"schema" yaml:"schema"`
+}
+
+type Operation struct {
+	OperationID string   `json:"operationId" yaml:"operationId"`
+	Summary     string   `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Message     *Message `json:"message" yaml:"message"`
+}
+
+// Message is the message of an operation, or the alternatives of the messages in the OneOf
+// if the functions share the channel, e.g. the custom topic without the placeholder of the func ID.
+type Message struct {
+	Name        string             `json:"name,omitempty" yaml:"name,omitempty"`
+	ContentType string             `json:"contentType,omitempty" yaml:"contentType,omitempty"`
+	Payload     *jsonschema.Schema `json:"payload,omitempty" yaml:"payload,omitempty"`
+	OneOf       []*Message         `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+}
+
+// parameters are the tags of topics which are not fixed by the product.
+var parameters = map[operations.TopicTagKey]string{
+	operations.TopicTagKeyDeviceID: "the ID of the device",
+	operations.TopicTagKeyReqID:    "the ID of the request, it's empty for the messages without a request",
+}
+
+// channel forms the topic of the category by operations.Schemas, the tags not specified become parameters.
+func channel(category operations.OperationCategory, ver version.Version,
+	tags map[operations.TopicTagKey]string) (string, map[string]*Parameter) {
+	levels := []string{string(category), string(ver)}
+	params := make(map[string]*Parameter)
+	for _, key := range operations.Schemas[category] {
+		if value, ok := tags[key]; ok {
+			levels = append(levels, value)
+			continue
+		}
+		levels = append(levels, fmt.Sprintf("{%s}", key))
+		params[string(key)] = &Parameter{Description: parameters[key], Schema: &jsonschema.Schema{Type: jsonschema.TypeString}}
+	}
+	return strings.Join(levels, operations.TopicLevelSeparator), params
+}
+
+// GenerateAsyncAPI generates the AsyncAPI document of the topics and payloads of the product,
+// the protocol of the product is used as the protocol ID of the topics.
+func GenerateAsyncAPI(product *models.Product) *AsyncAPI {
+	doc := &AsyncAPI{
+		AsyncAPI: AsyncAPIVersion,
+		Info: &Info{
+			Title:       fmt.Sprintf("%s (%s)", product.Name, product.ID),
+			Version:     string(version.DataVersion),
+			Description: product.Desc,
+		},
+		Channels: make(map[string]*Channel),
+	}
+	g := &asyncAPIGenerator{doc: doc, product: product}
+
+	g.add(operations.OperationModeUp, "-", operations.DataOperationTypeHealthCheck, "the status of the device",
+		&Operation{OperationID: "publishStatus", Message: &Message{Name: "DeviceStatus", Payload: statusSchema()}}, nil)
+	for _, property := range product.Properties {
+		g.addProperty(property)
+	}
+	for _, event := range product.Events {
+		payload := fieldsSchema(event.Outs)
+		g.add(operations.OperationModeUp, event.Id, operations.DataOperationTypeEvent, event.Name,
+			&Operation{OperationID: "publishEvent_" + event.Id, Summary: event.Desc, Message: newMessage(event.Id, payload)}, nil)
+	}
+	for _, method := range product.Methods {
+		g.addRequest(method.Id, operations.DataOperationTypeCall, method.Name, fieldsSchema(method.Ins), fieldsSchema(method.Outs))
+	}
+	return doc
+}
+
+type asyncAPIGenerator struct {
+	doc     *AsyncAPI
+	product *models.Product
+}
+
+func (g *asyncAPIGenerator) addProperty(property *models.ProductProperty) {
+	payload := propsSchema(property)
+	g.add(operations.OperationModeUp, property.Id, operations.DataOperationTypeWatch, property.Name,
+		&Operation{OperationID: "publishProps_" + property.Id, Summary: property.Desc, Message: newMessage(property.Id, payload)}, nil)
+	g.addRequest(property.Id, operations.DataOperationTypeRead, property.Name, nil, payload)
+	g.addRequest(property.Id, operations.DataOperationTypeHardRead, property.Name, nil, payload)
+	if property.Writeable {
+		g.addRequest(property.Id, operations.DataOperationTypeWrite, property.Name, payload, nil)
+	}
+}
+
+// addRequest adds the channels of the request, the response and the error of the operation.
+func (g *asyncAPIGenerator) addRequest(funcID models.ProductFuncID, optType operations.DataOperationType,
+	desc string, request, response *jsonschema.Schema) {
+	id := strings.ToLower(strings.Replace(string(optType), "-", "", -1)) + "_" + funcID
+	if request == nil {
+		request = &jsonschema.Schema{Description: "empty"}
+	}
+	if response == nil {
+		response = &jsonschema.Schema{Description: "empty"}
+	}
+	g.add(operations.OperationModeDown, funcID, optType, desc, nil,
+		&Operation{OperationID: "receive_" + id, Message: newMessage(funcID, request)})
+	g.add(operations.OperationModeUp, funcID, optType, desc,
+		&Operation{OperationID: "reply_" + id, Message: newMessage(funcID, response)}, nil)
+	g.add(operations.OperationModeUpErr, funcID, optType, desc,
+		&Operation{OperationID: "replyError_" + id, Message: &Message{Name: "Error", Payload: jsonschema.ForError()}}, nil)
+}
+
+func (g *asyncAPIGenerator) add(mode operations.OperationMode, funcID models.ProductFuncID,
+	optType operations.DataOperationType, desc string, subscribe, publish *Operation) {
+	topic, params := channel(operations.OperationCategoryData, version.DataVersion, map[operations.TopicTagKey]string{
+		operations.TopicTagKeyOptMode:    string(mode),
+		operations.TopicTagKeyProtocolID: g.product.Protocol,
+		operations.TopicTagKeyProductID:  g.product.ID,
+		operations.TopicTagKeyFuncID:     funcID,
+		operations.TopicTagKeyOptType:    string(optType),
+	})
+	if custom, ok := g.customTopic(mode, funcID, optType); ok {
+		topic = custom
+		params = map[string]*Parameter{string(operations.TopicTagKeyDeviceID): params[string(operations.TopicTagKeyDeviceID)]}
+	}
+	for _, op := range []*Operation{subscribe, publish} {
+		if op != nil && op.Message.ContentType == "" {
+			op.Message.ContentType = "application/json"
+		}
+	}
+	if existing, ok := g.doc.Channels[topic]; ok {
+		existing.Description += ", " + desc
+		existing.Subscribe = mergeOperation(existing.Subscribe, subscribe)
+		existing.Publish = mergeOperation(existing.Publish, publish)
+		return
+	}
+	g.doc.Channels[topic] = &Channel{Description: desc, Parameters: params, Subscribe: subscribe, Publish: publish}
+}
+
+// mergeOperation merges the message of the operation into the one of the existing operation on the same channel,
+// the messages become the alternatives of the OneOf.
+func mergeOperation(existing, op *Operation) *Operation {
+	if existing == nil {
+		return op
+	}
+	if op == nil {
+		return existing
+	}
+	messages := existing.Message.OneOf
+	if messages == nil {
+		messages = []*Message{existing.Message}
+	}
+	existing.Message = &Message{OneOf: append(messages, op.Message)}
+	return existing
+}
+
+// customTopic returns the custom topic declared by the product for the operations published by the driver.
+func (g *asyncAPIGenerator) customTopic(mode operations.OperationMode, funcID models.ProductFuncID,
+	optType operations.DataOperationType) (string, bool) {
+	if mode != operations.OperationModeUp || !operations.CustomizableOperationTypes[optType] {
+		return "", false
+	}
+	for _, topic := range g.product.Topics {
+		if topic.OptType == string(optType) {
+			return strings.Replace(topic.Topic, models.ProductTopicPlaceholderFuncID, funcID, -1), true
+		}
+	}
+	return "", false
+}
+
+func newMessage(name string, payload *jsonschema.Schema) *Message {
+	return &Message{Name: name, ContentType: "application/json", Payload: payload}
+}
+
+// propsSchema returns the schema of the properties of the operations on the property.
+func propsSchema(property *models.ProductProperty) *jsonschema.Schema {
+	data := jsonschema.ForDeviceData(property.FieldType)
+	data.Description = property.Desc
+	return jsonschema.NewObject(map[string]*jsonschema.Schema{property.Id: data})
+}
+
+// fieldsSchema returns the schema of the fields of the events or methods.
+func fieldsSchema(fields []*models.ProductField) *jsonschema.Schema {
+	properties := make(map[string]*jsonschema.Schema, len(fields))
+	required := make([]string, 0, len(fields))
+	for _, field := range fields {
+		data := jsonschema.ForDeviceData(field.FieldType)
+		data.Description = field.Desc
+		properties[field.Id] = data
+		required = append(required, field.Id)
+	}
+	sort.Strings(required)
+	return jsonschema.NewObject(properties, required...)
+}
+
+func statusSchema() *jsonschema.Schema {
+	return jsonschema.NewObject(map[string]*jsonschema.Schema{
+		"device":       {Type: jsonschema.TypeObject},
+		"state":        {Type: jsonschema.TypeString},
+		"state_detail": {Type: jsonschema.TypeString},
+	})
+}
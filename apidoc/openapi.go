@@ -0,0 +1,190 @@
+package apidoc
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/jsonschema"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/version"
+	"net/http"
+	"strings"
+)
+
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPI is an OpenAPI document, only the fields generated are defined.
+type OpenAPI struct {
+	OpenAPI    string                          `json:"openapi" yaml:"openapi"`
+	Info       *Info                           `json:"info" yaml:"info"`
+	Paths      map[string]map[string]*PathItem `json:"paths" yaml:"paths"` // path -> lower-case method -> operation
+	Components *Components                     `json:"components" yaml:"components"`
+}
+
+type Components struct {
+	Schemas map[string]*jsonschema.Schema `json:"schemas" yaml:"schemas"`
+}
+
+type PathItem struct {
+	OperationID string               `json:"operationId" yaml:"operationId"`
+	Summary     string               `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Parameters  []*PathParameter     `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *Body                `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses" yaml:"responses"`
+}
+
+type PathParameter struct {
+	Name        string             `json:"name" yaml:"name"`
+	In          string             `json:"in" yaml:"in"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool               `json:"required" yaml:"required"`
+	Schema      *jsonschema.Schema `json:"schema" yaml:"schema"`
+}
+
+type Body struct {
+	Required bool                  `json:"required,omitempty" yaml:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content" yaml:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description" yaml:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *jsonschema.Schema `json:"schema" yaml:"schema"`
+}
+
+const (
+	mediaTypeJSON        = "application/json"
+	mediaTypeEventStream = "text/event-stream"
+	errorSchemaRef       = "#/components/schemas/ErrorBody"
+)
+
+// GenerateOpenAPI generates the OpenAPI document of the routes of the gateway.Handler for the products,
+// the paths are concrete for every function of the products, and the device ID is a path parameter.
+func GenerateOpenAPI(products ...*models.Product) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info: &Info{
+			Title:   "Edge Device Gateway",
+			Version: string(version.DataVersion),
+		},
+		Paths: make(map[string]map[string]*PathItem),
+		Components: &Components{Schemas: map[string]*jsonschema.Schema{
+			"ErrorBody": jsonschema.NewObject(map[string]*jsonschema.Schema{
+				"code":    {Type: jsonschema.TypeInteger},
+				"type":    {Type: jsonschema.TypeString},
+				"message": {Type: jsonschema.TypeString},
+			}, "code", "type", "message"),
+		}},
+	}
+
+	protocols := make(map[string]bool)
+	for _, product := range products {
+		if !protocols[product.Protocol] {
+			protocols[product.Protocol] = true
+			doc.add(fmt.Sprintf("/protocols/%s/status/stream", product.Protocol), http.MethodGet, &PathItem{
+				OperationID: "streamStatus_" + product.Protocol,
+				Summary:     "stream the status of the devices",
+				Responses:   streamResponses(statusSchema()),
+			})
+		}
+		doc.addProduct(product)
+	}
+	return doc
+}
+
+func (doc *OpenAPI) addProduct(product *models.Product) {
+	prefix := fmt.Sprintf("/protocols/%s/products/%s/devices/{device_id}", product.Protocol, product.ID)
+	id := func(action, funcID string) string {
+		return fmt.Sprintf("%s_%s_%s", action, product.ID, funcID)
+	}
+
+	for _, property := range product.Properties {
+		path := fmt.Sprintf("%s/props/%s", prefix, property.Id)
+		payload := propsSchema(property)
+		doc.add(path, http.MethodGet, &PathItem{
+			OperationID: id("read", property.Id),
+			Summary:     property.Name,
+			Parameters: []*PathParameter{{
+				Name:        "hard",
+				In:          "query",
+				Description: "read the property from the device instead of the cache",
+				Schema:      &jsonschema.Schema{Type: jsonschema.TypeBoolean},
+			}},
+			Responses: responses(http.StatusOK, payload),
+		})
+		if property.Writeable {
+			doc.add(path, http.MethodPut, &PathItem{
+				OperationID: id("write", property.Id),
+				Summary:     property.Name,
+				RequestBody: jsonBody(payload),
+				Responses:   responses(http.StatusNoContent, nil),
+			})
+		}
+		doc.add(path+"/stream", http.MethodGet, &PathItem{
+			OperationID: id("streamProps", property.Id),
+			Summary:     property.Name,
+			Responses:   streamResponses(payload),
+		})
+	}
+	for _, event := range product.Events {
+		doc.add(fmt.Sprintf("%s/events/%s/stream", prefix, event.Id), http.MethodGet, &PathItem{
+			OperationID: id("streamEvent", event.Id),
+			Summary:     event.Name,
+			Responses:   streamResponses(fieldsSchema(event.Outs)),
+		})
+	}
+	for _, method := range product.Methods {
+		doc.add(fmt.Sprintf("%s/methods/%s", prefix, method.Id), http.MethodPost, &PathItem{
+			OperationID: id("call", method.Id),
+			Summary:     method.Name,
+			RequestBody: jsonBody(fieldsSchema(method.Ins)),
+			Responses:   responses(http.StatusOK, fieldsSchema(method.Outs)),
+		})
+	}
+}
+
+func (doc *OpenAPI) add(path, method string, item *PathItem) {
+	if strings.Contains(path, "{device_id}") {
+		item.Parameters = append([]*PathParameter{{
+			Name:     "device_id",
+			In:       "path",
+			Required: true,
+			Schema:   &jsonschema.Schema{Type: jsonschema.TypeString},
+		}}, item.Parameters...)
+	}
+	items, ok := doc.Paths[path]
+	if !ok {
+		items = make(map[string]*PathItem)
+		doc.Paths[path] = items
+	}
+	items[strings.ToLower(method)] = item
+}
+
+func jsonBody(schema *jsonschema.Schema) *Body {
+	return &Body{Required: true, Content: map[string]*MediaType{mediaTypeJSON: {Schema: schema}}}
+}
+
+// responses returns the response of the status with the schema, and the responses of the errors.
+func responses(status int, schema *jsonschema.Schema) map[string]*Response {
+	rsp := &Response{Description: http.StatusText(status)}
+	if schema != nil {
+		rsp.Content = map[string]*MediaType{mediaTypeJSON: {Schema: schema}}
+	}
+	return map[string]*Response{
+		fmt.Sprintf("%d", status): rsp,
+		"default": {
+			Description: "the error mapped by gateway.StatusOf",
+			Content:     map[string]*MediaType{mediaTypeJSON: {Schema: &jsonschema.Schema{Ref: errorSchemaRef}}},
+		},
+	}
+}
+
+// streamResponses returns the responses of the streams, whose messages are described by the schema,
+// the streams are served over WebSocket too if the request asks for upgrading.
+func streamResponses(schema *jsonschema.Schema) map[string]*Response {
+	rsps := responses(http.StatusOK, nil)
+	rsps["200"].Description = "the Server-Sent Events, or the WebSocket messages after upgrading"
+	rsps["200"].Content = map[string]*MediaType{mediaTypeEventStream: {Schema: schema}}
+	return rsps
+}
@@ -0,0 +1,71 @@
+// Command apidoc prints the AsyncAPI or OpenAPI document of the products defined by the JSON files, e.g.
+//
+//	apidoc -kind asyncapi product.json
+//	apidoc -kind openapi product1.json product2.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/thingio/edge-device-std/apidoc"
+	"github.com/thingio/edge-device-std/models"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	kind := flag.String("kind", "asyncapi", "the kind of the document, asyncapi or openapi")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: apidoc [-kind asyncapi|openapi] <product.json>...")
+		os.Exit(2)
+	}
+
+	products := make([]*models.Product, 0, flag.NArg())
+	for _, file := range flag.Args() {
+		product, err := load(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		products = append(products, product)
+	}
+
+	var doc interface{}
+	switch *kind {
+	case "asyncapi":
+		if len(products) != 1 {
+			fmt.Fprintln(os.Stderr, "the AsyncAPI document is generated for only one product")
+			os.Exit(2)
+		}
+		doc = apidoc.GenerateAsyncAPI(products[0])
+	case "openapi":
+		doc = apidoc.GenerateOpenAPI(products...)
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported kind: %s\n", *kind)
+		os.Exit(2)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func load(file string) (*models.Product, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	product := new(models.Product)
+	if err = json.Unmarshal(data, product); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal the product in %s: %s", file, err.Error())
+	}
+	if err = product.Validate(); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
@@ -0,0 +1,294 @@
+package cmdqueue
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sort"
+	"sync"
+	"time"
+)
+
+const expireCheckInterval = 10 * time.Second
+
+// NewQueue returns a Queue holding the commands of devices belonging to the protocol.
+func NewQueue(protocolID string, dc operations.DataDriverClient, store Store, lg *logger.Logger) (*Queue, error) {
+	q := &Queue{
+		protocolID: protocolID,
+		dc:         dc,
+		store:      store,
+		entries:    make(map[string][]*Entry),
+		twins:      make(map[string]models.DeviceTwin),
+		states:     make(map[string]models.State),
+		flushing:   make(map[string]*sync.Mutex),
+		stop:       make(chan struct{}),
+		lg:         lg,
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return nil, errors.Driver.Cause(err, "fail to load the queued commands")
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SubmittedAt.Before(entries[j].SubmittedAt)
+	})
+	for _, entry := range entries {
+		q.entries[entry.DeviceID] = append(q.entries[entry.DeviceID], entry)
+	}
+
+	q.wg.Add(1)
+	go q.expire()
+	return q, nil
+}
+
+// Queue persists the writes and calls submitted for devices which may be disconnected,
+// executes them in order once the devices are connected, and publishes their completions.
+type Queue struct {
+	protocolID string
+	dc         operations.DataDriverClient
+	store      Store
+
+	mu      sync.Mutex
+	entries map[string][]*Entry          // device ID -> entries in the submitted order
+	twins   map[string]models.DeviceTwin // device ID -> twin
+	states  map[string]models.State      // device ID -> state
+	// flushing are the locks guaranteeing that the commands of a device are not executed concurrently,
+	// while the commands of different devices are executed independently.
+	flushing map[string]*sync.Mutex // device ID -> lock
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lg *logger.Logger
+}
+
+// Serve registers the handler of commands queued by the manager.
+func (q *Queue) Serve(ds operations.DataDriverService) error {
+	return ds.QueueHandler(q.protocolID, q.Submit)
+}
+
+// Close stops checking the expired commands.
+func (q *Queue) Close() error {
+	close(q.stop)
+	q.wg.Wait()
+	return nil
+}
+
+// Attach binds the twin of the device, the queued commands will be executed through it.
+func (q *Queue) Attach(device *models.Device, twin models.DeviceTwin) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.twins[device.ID] = twin
+}
+
+// Detach unbinds the twin of the device, the queued commands are kept until they are expired.
+func (q *Queue) Detach(deviceID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.twins, deviceID)
+	delete(q.states, deviceID)
+}
+
+// Submit persists the command, it will be executed immediately if the device is connected.
+func (q *Queue) Submit(productID, deviceID string, funcID models.ProductFuncID, reqID string,
+	command *operations.QueuedCommand) (*operations.CommandAck, error) {
+	switch command.Kind {
+	case operations.QueuedCommandKindWrite, operations.QueuedCommandKindCall:
+	default:
+		return nil, errors.BadRequest.Error("unsupported kind of the queued command: %s", command.Kind)
+	}
+	if command.Expired(time.Now()) {
+		return nil, errors.BadRequest.Error("the command has been expired at %s", command.ExpireAt)
+	}
+
+	entry := &Entry{
+		ReqID:       reqID,
+		ProductID:   productID,
+		DeviceID:    deviceID,
+		FuncID:      funcID,
+		Command:     command,
+		SubmittedAt: time.Now(),
+	}
+	q.mu.Lock()
+	if err := q.store.Save(entry); err != nil {
+		q.mu.Unlock()
+		return nil, errors.Driver.Cause(err, "fail to save the queued command: %s", reqID)
+	}
+	q.entries[deviceID] = append(q.entries[deviceID], entry)
+	connected := q.states[deviceID] == models.DeviceStateConnected
+	q.mu.Unlock()
+
+	if connected {
+		go q.flush(deviceID)
+	}
+	return &operations.CommandAck{ReqID: reqID, Status: operations.CommandStatusQueued}, nil
+}
+
+// UpdateState records the state of the device, the queued commands will be executed
+// once the device becomes connected.
+func (q *Queue) UpdateState(deviceID string, state models.State) {
+	q.mu.Lock()
+	previous := q.states[deviceID]
+	q.states[deviceID] = state
+	q.mu.Unlock()
+
+	if state == models.DeviceStateConnected && previous != models.DeviceStateConnected {
+		go q.flush(deviceID)
+	}
+}
+
+// Len returns the number of commands queued for the device.
+func (q *Queue) Len(deviceID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries[deviceID])
+}
+
+// flushLock returns the lock of flushing the commands of the device.
+func (q *Queue) flushLock(deviceID string) *sync.Mutex {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lock, ok := q.flushing[deviceID]
+	if !ok {
+		lock = new(sync.Mutex)
+		q.flushing[deviceID] = lock
+	}
+	return lock
+}
+
+// flush executes the queued commands of the device in order,
+// it stops once the device is disconnected again.
+func (q *Queue) flush(deviceID string) {
+	lock := q.flushLock(deviceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	for {
+		q.mu.Lock()
+		entries, twin := q.entries[deviceID], q.twins[deviceID]
+		connected := q.states[deviceID] == models.DeviceStateConnected
+		if len(entries) == 0 || twin == nil || !connected {
+			q.mu.Unlock()
+			return
+		}
+		entry := entries[0]
+		// the entry being executed can't be expired by the expire
+		entry.executing = true
+		q.mu.Unlock()
+
+		completion := &operations.CommandCompletion{
+			ReqID:     entry.ReqID,
+			ProductID: entry.ProductID,
+			DeviceID:  entry.DeviceID,
+			FuncID:    entry.FuncID,
+			Kind:      entry.Command.Kind,
+		}
+		if entry.Command.Expired(time.Now()) {
+			completion.Status = operations.CommandStatusExpired
+		} else {
+			outs, err := q.execute(twin, entry)
+			if err != nil {
+				completion.Status = operations.CommandStatusFailed
+				completion.Error = errors.NewCommonEdgeErrorWrapper(err)
+			} else {
+				completion.Status = operations.CommandStatusSucceeded
+				completion.Outs = outs
+			}
+		}
+		q.complete(entry, completion)
+	}
+}
+
+func (q *Queue) execute(twin models.DeviceTwin, entry *Entry) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	switch entry.Command.Kind {
+	case operations.QueuedCommandKindWrite:
+		return nil, twin.Write(entry.FuncID, entry.Command.Values)
+	case operations.QueuedCommandKindCall:
+		return twin.Call(entry.FuncID, entry.Command.Values)
+	default:
+		return nil, errors.BadRequest.Error("unsupported kind of the queued command: %s", entry.Command.Kind)
+	}
+}
+
+// complete removes the entry from the queue and publishes its completion,
+// it is a no-op if the entry has been completed already.
+func (q *Queue) complete(entry *Entry, completion *operations.CommandCompletion) {
+	q.mu.Lock()
+	found := q.remove(entry)
+	q.mu.Unlock()
+	if found {
+		q.publish(completion)
+	}
+}
+
+// remove removes the entry from the queue and the store, and returns whether it is found,
+// the lock of the queue should be held.
+func (q *Queue) remove(entry *Entry) bool {
+	entries := q.entries[entry.DeviceID]
+	for i, e := range entries {
+		if e != entry {
+			continue
+		}
+		q.entries[entry.DeviceID] = append(entries[:i:i], entries[i+1:]...)
+		if len(q.entries[entry.DeviceID]) == 0 {
+			delete(q.entries, entry.DeviceID)
+		}
+		if err := q.store.Delete(entry.ReqID); err != nil {
+			q.lg.WithError(err).Errorf("fail to delete the queued command: %s", entry.ReqID)
+		}
+		return true
+	}
+	return false
+}
+
+func (q *Queue) publish(completion *operations.CommandCompletion) {
+	completion.CompletedAt = time.Now()
+	if err := q.dc.PublishCommandCompletion(q.protocolID, completion); err != nil {
+		q.lg.WithError(err).Errorf("fail to publish the completion of the queued command: %s", completion.ReqID)
+	}
+}
+
+// expire completes the expired commands of the disconnected devices periodically.
+func (q *Queue) expire() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(expireCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case now := <-ticker.C:
+			q.expireEntries(now)
+		}
+	}
+}
+
+// expireEntries completes the commands expired at the time, except the ones being executed,
+// whose completions will be published by the flush.
+func (q *Queue) expireEntries(now time.Time) {
+	expired := make([]*Entry, 0)
+	q.mu.Lock()
+	for _, entries := range q.entries {
+		for _, entry := range entries {
+			if !entry.executing && entry.Command.Expired(now) {
+				expired = append(expired, entry)
+			}
+		}
+	}
+	for _, entry := range expired {
+		q.remove(entry)
+	}
+	q.mu.Unlock()
+
+	for _, entry := range expired {
+		q.publish(&operations.CommandCompletion{
+			ReqID:     entry.ReqID,
+			ProductID: entry.ProductID,
+			DeviceID:  entry.DeviceID,
+			FuncID:    entry.FuncID,
+			Kind:      entry.Command.Kind,
+			Status:    operations.CommandStatusExpired,
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package cmdqueue
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/operations"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// twin executes the commands in order, the commands are blocked until the release is closed if it is not nil.
+type twin struct {
+	models.DeviceTwin
+
+	release chan struct{}
+
+	mu       sync.Mutex
+	executed []models.ProductFuncID
+}
+
+func (w *twin) wait(funcID models.ProductFuncID) {
+	if w.release != nil {
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.executed = append(w.executed, funcID)
+}
+
+func (w *twin) Write(propertyID models.ProductPropertyID, values map[models.ProductPropertyID]*models.DeviceData) error {
+	w.wait(propertyID)
+	return nil
+}
+
+func (w *twin) Call(methodID models.ProductMethodID,
+	ins map[models.ProductPropertyID]*models.DeviceData) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	w.wait(methodID)
+	return map[models.ProductPropertyID]*models.DeviceData{"done": {Name: "done", Type: models.PropertyValueTypeBool, Value: true}}, nil
+}
+
+// client records the command completions published.
+type client struct {
+	operations.DataDriverClient
+
+	mu          sync.Mutex
+	completions []*operations.CommandCompletion
+}
+
+func (c *client) PublishCommandCompletion(protocolID string, completion *operations.CommandCompletion) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completions = append(c.completions, completion)
+	return nil
+}
+
+func (c *client) published() []*operations.CommandCompletion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*operations.CommandCompletion(nil), c.completions...)
+}
+
+func newQueue(t *testing.T, dir string) (*Queue, *client) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := new(client)
+	q, err := NewQueue("protocol", dc, store, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = q.Close() })
+	return q, dc
+}
+
+// waitCompletions waits until n completions have been published.
+func waitCompletions(t *testing.T, dc *client, n int) []*operations.CommandCompletion {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if completions := dc.published(); len(completions) >= n {
+			return completions
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("the number of the completions = %d, want %d", len(dc.published()), n)
+	return nil
+}
+
+func submit(t *testing.T, q *Queue, deviceID string, funcID models.ProductFuncID, reqID string, command *operations.QueuedCommand) {
+	ack, err := q.Submit("product", deviceID, funcID, reqID, command)
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if ack.ReqID != reqID || ack.Status != operations.CommandStatusQueued {
+		t.Fatalf("Submit() ack = %+v", ack)
+	}
+}
+
+func TestQueue_Submit(t *testing.T) {
+	q, _ := newQueue(t, t.TempDir())
+	if _, err := q.Submit("product", "device", "reset", "r0", &operations.QueuedCommand{Kind: "read"}); err == nil {
+		t.Errorf("Submit() the unsupported kind error = nil")
+	}
+	if _, err := q.Submit("product", "device", "reset", "r0", &operations.QueuedCommand{
+		Kind: operations.QueuedCommandKindCall, ExpireAt: time.Now().Add(-time.Second)}); err == nil {
+		t.Errorf("Submit() the expired command error = nil")
+	}
+	submit(t, q, "device", "reset", "r1", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+	if q.Len("device") != 1 {
+		t.Errorf("Len() = %d, want 1", q.Len("device"))
+	}
+}
+
+func TestQueue_Flush(t *testing.T) {
+	dir := t.TempDir()
+	q, dc := newQueue(t, dir)
+	submit(t, q, "device", "power", "r1", &operations.QueuedCommand{Kind: operations.QueuedCommandKindWrite})
+	submit(t, q, "device", "reset", "r2", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+	if len(dc.published()) != 0 {
+		t.Fatalf("the commands are executed before the device is connected")
+	}
+
+	w := new(twin)
+	q.Attach(&models.Device{ID: "device"}, w)
+	q.UpdateState("device", models.DeviceStateConnected)
+	completions := waitCompletions(t, dc, 2)
+	if completions[0].ReqID != "r1" || completions[1].ReqID != "r2" {
+		t.Fatalf("the commands are completed in the order %s, %s", completions[0].ReqID, completions[1].ReqID)
+	}
+	for _, c := range completions {
+		if c.Status != operations.CommandStatusSucceeded {
+			t.Errorf("the status of %s = %s, want succeeded", c.ReqID, c.Status)
+		}
+	}
+	if completions[1].Outs["done"] == nil {
+		t.Errorf("the outs of the call are missing")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+fileSuffix)); len(files) != 0 {
+		t.Errorf("the completed commands are still persisted: %v", files)
+	}
+
+	// the command of a connected device is executed at once
+	submit(t, q, "device", "reset", "r3", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+	if completions = waitCompletions(t, dc, 3); completions[2].ReqID != "r3" {
+		t.Errorf("the command of the connected device isn't executed")
+	}
+}
+
+func TestQueue_FlushDevicesIndependently(t *testing.T) {
+	q, dc := newQueue(t, t.TempDir())
+	slow := &twin{release: make(chan struct{})}
+	q.Attach(&models.Device{ID: "slow"}, slow)
+	q.Attach(&models.Device{ID: "fast"}, new(twin))
+	q.UpdateState("slow", models.DeviceStateConnected)
+	q.UpdateState("fast", models.DeviceStateConnected)
+
+	submit(t, q, "slow", "reset", "r1", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall,
+		ExpireAt: time.Now().Add(time.Minute)})
+	submit(t, q, "fast", "reset", "r2", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+	if completions := waitCompletions(t, dc, 1); completions[0].ReqID != "r2" {
+		t.Fatalf("the fast device is blocked by the slow one")
+	}
+
+	// the command being executed is not expired
+	q.expireEntries(time.Now().Add(time.Hour))
+	if len(dc.published()) != 1 {
+		t.Fatalf("the command being executed is expired")
+	}
+	close(slow.release)
+	if completions := waitCompletions(t, dc, 2); completions[1].Status != operations.CommandStatusSucceeded {
+		t.Errorf("the status of the slow command = %s, want succeeded", completions[1].Status)
+	}
+}
+
+func TestQueue_Expire(t *testing.T) {
+	q, dc := newQueue(t, t.TempDir())
+	submit(t, q, "device", "reset", "r1", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall,
+		ExpireAt: time.Now().Add(time.Minute)})
+	submit(t, q, "device", "reset", "r2", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+
+	q.expireEntries(time.Now().Add(time.Hour))
+	completions := waitCompletions(t, dc, 1)
+	if completions[0].ReqID != "r1" || completions[0].Status != operations.CommandStatusExpired {
+		t.Errorf("the completion = %+v, want r1 expired", completions[0])
+	}
+	if q.Len("device") != 1 {
+		t.Errorf("Len() = %d, want the command never expiring", q.Len("device"))
+	}
+}
+
+func TestQueue_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := newQueue(t, dir)
+	submit(t, q, "device", "power", "r1", &operations.QueuedCommand{Kind: operations.QueuedCommandKindWrite})
+	submit(t, q, "device", "reset", "r2", &operations.QueuedCommand{Kind: operations.QueuedCommandKindCall})
+	if err := os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart the driver
+	restarted, dc := newQueue(t, dir)
+	if restarted.Len("device") != 2 {
+		t.Fatalf("Len() after restarting = %d, want 2", restarted.Len("device"))
+	}
+	w := new(twin)
+	restarted.Attach(&models.Device{ID: "device"}, w)
+	restarted.UpdateState("device", models.DeviceStateConnected)
+	completions := waitCompletions(t, dc, 2)
+	if completions[0].ReqID != "r1" || completions[1].ReqID != "r2" {
+		t.Errorf("the reloaded commands are completed in the order %s, %s", completions[0].ReqID, completions[1].ReqID)
+	}
+}
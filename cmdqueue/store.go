@@ -0,0 +1,91 @@
+package cmdqueue
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const fileSuffix = ".json"
+
+// Entry is a queued command waiting for the device to be connected.
+type Entry struct {
+	ReqID       string                    `json:"req_id"`
+	ProductID   string                    `json:"product_id"`
+	DeviceID    string                    `json:"device_id"`
+	FuncID      models.ProductFuncID      `json:"func_id"`
+	Command     *operations.QueuedCommand `json:"command"`
+	SubmittedAt time.Time                 `json:"submitted_at"`
+
+	executing bool // whether the command is being executed, guarded by the lock of the Queue
+}
+
+// Store persists the queued commands, so that they survive restarting the driver.
+type Store interface {
+	Load() ([]*Entry, error)
+	Save(entry *Entry) error
+	Delete(reqID string) error
+}
+
+// NewFileStore returns a Store which saves every queued command as a JSON file in the directory.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+func (f *fileStore) Load() ([]*Entry, error) {
+	infos, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), fileSuffix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(f.dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entry := new(Entry)
+		if err = json.Unmarshal(data, entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (f *fileStore) Save(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := f.path(entry.ReqID)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (f *fileStore) Delete(reqID string) error {
+	if err := os.Remove(f.path(reqID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileStore) path(reqID string) string {
+	return filepath.Join(f.dir, reqID+fileSuffix)
+}
@@ -0,0 +1,172 @@
+package compute
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"math"
+	"strings"
+	"time"
+)
+
+// NewCalculator returns a Calculator evaluating the computed properties of the product.
+func NewCalculator(product *models.Product) (*Calculator, error) {
+	if err := product.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Calculator{
+		properties: make(map[models.ProductPropertyID]*models.ProductProperty),
+		exprs:      make(map[models.ProductPropertyID]*models.Expression),
+	}
+	for _, property := range product.Properties {
+		if !property.Computed() {
+			continue
+		}
+		expr, err := models.ParseExpression(property.Expression)
+		if err != nil {
+			return nil, err
+		}
+		c.properties[property.Id] = property
+		c.exprs[property.Id] = expr
+	}
+
+	// sort the computed properties topologically, the product has been validated to be acyclic
+	visited := make(map[models.ProductPropertyID]bool)
+	var visit func(id models.ProductPropertyID)
+	visit = func(id models.ProductPropertyID) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		for _, input := range c.exprs[id].Inputs() {
+			if _, ok := c.exprs[input]; ok {
+				visit(input)
+			}
+		}
+		c.order = append(c.order, id)
+	}
+	for _, property := range product.Properties {
+		if property.Computed() {
+			visit(property.Id)
+		}
+	}
+	return c, nil
+}
+
+// Calculator evaluates the computed properties of a product in the order of their dependencies.
+type Calculator struct {
+	properties map[models.ProductPropertyID]*models.ProductProperty
+	exprs      map[models.ProductPropertyID]*models.Expression
+	order      []models.ProductPropertyID // the inputs are always in front of the computed properties
+}
+
+// Computed returns whether the property is a computed property.
+func (c *Calculator) Computed(propertyID models.ProductPropertyID) bool {
+	_, ok := c.exprs[propertyID]
+	return ok
+}
+
+// Empty returns whether there is no computed property.
+func (c *Calculator) Empty() bool {
+	return len(c.order) == 0
+}
+
+// Sources returns the properties read from the device which the computed property depends on, directly or not.
+func (c *Calculator) Sources(propertyID models.ProductPropertyID) []models.ProductPropertyID {
+	sources := make([]models.ProductPropertyID, 0)
+	seen := make(map[models.ProductPropertyID]bool)
+	var visit func(id models.ProductPropertyID)
+	visit = func(id models.ProductPropertyID) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		expr, ok := c.exprs[id]
+		if !ok {
+			sources = append(sources, id)
+			return
+		}
+		for _, input := range expr.Inputs() {
+			visit(input)
+		}
+	}
+	visit(propertyID)
+	return sources
+}
+
+// Evaluate evaluates the computed properties depending on the changed properties, directly or not.
+// The results are put back into the values, and returned as well. The computed properties whose inputs
+// are not all available are skipped, and the ones failed to evaluate are reported by the error.
+func (c *Calculator) Evaluate(values map[models.ProductPropertyID]*models.DeviceData,
+	changed []models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	affected := make(map[models.ProductPropertyID]bool, len(changed))
+	for _, id := range changed {
+		affected[id] = true
+	}
+
+	results := make(map[models.ProductPropertyID]*models.DeviceData)
+	var failures []string
+	for _, id := range c.order {
+		expr := c.exprs[id]
+		inputs := make(map[models.ProductPropertyID]float64, len(expr.Inputs()))
+		var dirty bool
+		var ts time.Time
+		quality := models.DataQualityGood
+		for _, input := range expr.Inputs() {
+			dirty = dirty || affected[input]
+			value, ok := values[input]
+			if !ok || value == nil {
+				continue
+			}
+			v, err := value.NumericValue()
+			if err != nil {
+				continue
+			}
+			inputs[input] = v
+			quality = models.WorseDataQuality(quality, value.GetQuality())
+			if value.Ts.After(ts) {
+				ts = value.Ts
+			}
+		}
+		if !dirty || len(inputs) != len(expr.Inputs()) {
+			continue
+		}
+
+		result, err := expr.Eval(inputs)
+		if err == nil {
+			var data *models.DeviceData
+			if data, err = newDeviceData(c.properties[id], result, ts); err == nil {
+				data.Quality = quality
+				values[id] = data
+				results[id] = data
+				affected[id] = true
+				continue
+			}
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", id, err.Error()))
+	}
+	if len(failures) != 0 {
+		return results, fmt.Errorf("fail to evaluate the computed properties, %s", strings.Join(failures, "; "))
+	}
+	return results, nil
+}
+
+// newDeviceData converts the result into the type of the property.
+func newDeviceData(property *models.ProductProperty, result float64, ts time.Time) (*models.DeviceData, error) {
+	var value interface{}
+	switch property.FieldType {
+	case models.PropertyValueTypeInt:
+		value = int64(math.Round(result))
+	case models.PropertyValueTypeUint:
+		if result < 0 {
+			return nil, fmt.Errorf("the result %v should not be negative", result)
+		}
+		value = uint64(math.Round(result))
+	default:
+		value = result
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return &models.DeviceData{Name: property.Id, Type: property.FieldType, Value: value, Ts: ts,
+		Source: models.DataSourceComputed}, nil
+}
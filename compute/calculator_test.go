@@ -0,0 +1,41 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"testing"
+)
+
+func TestCalculator_Evaluate(t *testing.T) {
+	calculator, err := NewCalculator(&models.Product{ID: "meter", Properties: []*models.ProductProperty{
+		{Id: "energy", FieldType: models.PropertyValueTypeInt, Expression: "power / 1000"},
+		{Id: "power", FieldType: models.PropertyValueTypeFloat, Expression: "voltage * current"},
+		{Id: "voltage", FieldType: models.PropertyValueTypeFloat},
+		{Id: "current", FieldType: models.PropertyValueTypeFloat},
+	}})
+	if err != nil {
+		t.Fatalf("NewCalculator() error = %v", err)
+	}
+
+	values := map[models.ProductPropertyID]*models.DeviceData{
+		"voltage": {Name: "voltage", Type: models.PropertyValueTypeFloat, Value: 220.0},
+	}
+	results, err := calculator.Evaluate(values, []models.ProductPropertyID{"voltage"})
+	if err != nil || len(results) != 0 {
+		t.Fatalf("Evaluate() without all inputs = %v, %v, want nothing", results, err)
+	}
+
+	values["current"] = &models.DeviceData{Name: "current", Type: models.PropertyValueTypeFloat, Value: 10.0}
+	results, err = calculator.Evaluate(values, []models.ProductPropertyID{"current"})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if v := results["power"].Value; v != 2200.0 {
+		t.Errorf("power = %v, want 2200", v)
+	}
+	if v := results["energy"].Value; v != int64(2) {
+		t.Errorf("energy = %v, want 2", v)
+	}
+	if sources := calculator.Sources("energy"); len(sources) != 2 {
+		t.Errorf("Sources(energy) = %v, want [voltage current]", sources)
+	}
+}
@@ -0,0 +1,35 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+)
+
+// NewDataDriverClient returns a DataDriverClient which publishes the computed properties
+// following the properties they depend on.
+func NewDataDriverClient(dc operations.DataDriverClient, engine *Engine) operations.DataDriverClient {
+	return &dataDriverClient{DataDriverClient: dc, engine: engine}
+}
+
+type dataDriverClient struct {
+	operations.DataDriverClient
+	engine *Engine
+}
+
+func (d *dataDriverClient) PublishDeviceProps(protocolID, productID, deviceID string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	if err := d.DataDriverClient.PublishDeviceProps(protocolID, productID, deviceID, propertyID, props); err != nil {
+		return err
+	}
+	computed := d.engine.Observe(productID, deviceID, props)
+	if len(computed) == 0 {
+		return nil
+	}
+	computedID := models.DeviceDataMultiPropsID
+	if len(computed) == 1 {
+		for id := range computed {
+			computedID = id
+		}
+	}
+	return d.DataDriverClient.PublishDeviceProps(protocolID, productID, deviceID, computedID, computed)
+}
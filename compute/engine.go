@@ -0,0 +1,113 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"sync"
+)
+
+// NewEngine returns an Engine evaluating the computed properties of devices.
+func NewEngine(lg *logger.Logger) *Engine {
+	return &Engine{
+		calculators: make(map[string]*Calculator),
+		products:    make(map[string]string),
+		values:      make(map[string]map[models.ProductPropertyID]*models.DeviceData),
+		lg:          lg,
+	}
+}
+
+// Engine keeps the latest values of the properties of devices, and re-evaluates the
+// computed properties once their inputs change.
+type Engine struct {
+	mu          sync.Mutex
+	calculators map[string]*Calculator                                     // product ID -> calculator
+	products    map[string]string                                          // device ID -> product ID
+	values      map[string]map[models.ProductPropertyID]*models.DeviceData // device ID -> latest values
+
+	lg *logger.Logger
+}
+
+// SetProduct loads the computed properties of the product, it replaces the ones loaded before.
+func (e *Engine) SetProduct(product *models.Product) error {
+	calculator, err := NewCalculator(product)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calculators[product.ID] = calculator
+	for deviceID, productID := range e.products {
+		if productID == product.ID {
+			delete(e.values, deviceID)
+		}
+	}
+	return nil
+}
+
+// RemoveProduct unloads the computed properties of the product, and drops the latest values of its devices.
+func (e *Engine) RemoveProduct(productID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.calculators, productID)
+	for deviceID, id := range e.products {
+		if id == productID {
+			delete(e.products, deviceID)
+			delete(e.values, deviceID)
+		}
+	}
+}
+
+// RemoveDevice drops the latest values of the device, it should be called after the device is deleted.
+func (e *Engine) RemoveDevice(deviceID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.products, deviceID)
+	delete(e.values, deviceID)
+}
+
+// Calculator returns the calculator of the product, or nil if the product has no computed property.
+func (e *Engine) Calculator(productID string) *Calculator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	calculator, ok := e.calculators[productID]
+	if !ok || calculator.Empty() {
+		return nil
+	}
+	return calculator
+}
+
+// Observe records the properties reported by the device, and returns the computed properties
+// re-evaluated because of them.
+func (e *Engine) Observe(productID, deviceID string,
+	props map[models.ProductPropertyID]*models.DeviceData) map[models.ProductPropertyID]*models.DeviceData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the latest values are dropped if the device has been moved from another product
+	if previous, ok := e.products[deviceID]; !ok || previous != productID {
+		e.products[deviceID] = productID
+		delete(e.values, deviceID)
+	}
+	calculator, ok := e.calculators[productID]
+	if !ok || calculator.Empty() {
+		return nil
+	}
+
+	values, ok := e.values[deviceID]
+	if !ok {
+		values = make(map[models.ProductPropertyID]*models.DeviceData)
+		e.values[deviceID] = values
+	}
+	changed := make([]models.ProductPropertyID, 0, len(props))
+	for id, prop := range props {
+		if calculator.Computed(id) {
+			continue
+		}
+		values[id] = prop
+		changed = append(changed, id)
+	}
+	results, err := calculator.Evaluate(values, changed)
+	if err != nil {
+		e.lg.WithError(err).Warnf("fail to evaluate the computed properties of the device[%s]", deviceID)
+	}
+	return results
+}
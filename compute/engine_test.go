@@ -0,0 +1,49 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"testing"
+)
+
+func TestEngine_Observe(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(lg)
+	for _, id := range []string{"meter", "meter-v2"} {
+		if err = engine.SetProduct(&models.Product{ID: id, Properties: []*models.ProductProperty{
+			{Id: "power", FieldType: models.PropertyValueTypeFloat, Expression: "voltage * current"},
+			{Id: "voltage", FieldType: models.PropertyValueTypeFloat},
+			{Id: "current", FieldType: models.PropertyValueTypeFloat},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	observe := func(productID string, property models.ProductPropertyID, value float64) *models.DeviceData {
+		return engine.Observe(productID, "m1", map[models.ProductPropertyID]*models.DeviceData{
+			property: {Name: property, Type: models.PropertyValueTypeFloat, Value: value},
+		})["power"]
+	}
+
+	if power := observe("meter", "voltage", 220); power != nil {
+		t.Errorf("the power without the current = %v, want none", power)
+	}
+	if power := observe("meter", "current", 10); power == nil || power.Value != 2200.0 {
+		t.Errorf("the power = %v, want 2200", power)
+	}
+	// the values observed under the previous product are dropped once the device is moved
+	if power := observe("meter-v2", "current", 5); power != nil {
+		t.Errorf("the power after moved = %v, want none", power)
+	}
+	if power := observe("meter-v2", "voltage", 230); power == nil || power.Value != 1150.0 {
+		t.Errorf("the power after moved = %v, want 1150", power)
+	}
+
+	engine.RemoveProduct("meter-v2")
+	if len(engine.values) != 0 || len(engine.products) != 0 {
+		t.Errorf("the values of the removed product = %v, want none", engine.values)
+	}
+}
@@ -0,0 +1,81 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/models"
+)
+
+// NewDeviceTwin decorates the DeviceTwin to serve the computed properties, which are
+// evaluated over the properties read from the real device. Reading all properties omits
+// the computed properties failed to evaluate rather than failing the whole read.
+func NewDeviceTwin(twin models.DeviceTwin, device *models.Device, engine *Engine) models.DeviceTwin {
+	return &computedDeviceTwin{DeviceTwin: twin, device: device, engine: engine}
+}
+
+type computedDeviceTwin struct {
+	models.DeviceTwin
+
+	device *models.Device
+	engine *Engine
+}
+
+func (t *computedDeviceTwin) Read(propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	calculator := t.engine.Calculator(t.device.ProductID)
+	if calculator == nil {
+		return t.DeviceTwin.Read(propertyID)
+	}
+
+	if propertyID == models.DeviceDataMultiPropsID {
+		props, err := t.DeviceTwin.Read(propertyID)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[models.ProductPropertyID]*models.DeviceData, len(props))
+		changed := make([]models.ProductPropertyID, 0, len(props))
+		for id, prop := range props {
+			values[id] = prop
+			changed = append(changed, id)
+		}
+		// the computed properties failed are omitted, the others are still read
+		if _, err = calculator.Evaluate(values, changed); err != nil {
+			t.engine.lg.WithError(err).Warnf("the computed properties failed are omitted from the properties "+
+				"read from the device[%s]", t.device.ID)
+		}
+		return values, nil
+	}
+	if !calculator.Computed(propertyID) {
+		return t.DeviceTwin.Read(propertyID)
+	}
+
+	sources := calculator.Sources(propertyID)
+	values := make(map[models.ProductPropertyID]*models.DeviceData, len(sources))
+	for _, source := range sources {
+		props, err := t.DeviceTwin.Read(source)
+		if err != nil {
+			return nil, err
+		}
+		for id, prop := range props {
+			values[id] = prop
+		}
+	}
+	// the other computed properties depending on the same sources may fail, only the requested one matters
+	_, err := calculator.Evaluate(values, sources)
+	value, ok := values[propertyID]
+	if !ok {
+		if err != nil {
+			return nil, errors.Driver.Cause(err, "fail to read the computed property[%s] of the device[%s]",
+				propertyID, t.device.ID)
+		}
+		return nil, errors.Driver.Error("the inputs of the computed property[%s] of the device[%s] are unavailable",
+			propertyID, t.device.ID)
+	}
+	return map[models.ProductPropertyID]*models.DeviceData{propertyID: value}, nil
+}
+
+func (t *computedDeviceTwin) Write(propertyID models.ProductPropertyID,
+	values map[models.ProductPropertyID]*models.DeviceData) error {
+	if calculator := t.engine.Calculator(t.device.ProductID); calculator != nil && calculator.Computed(propertyID) {
+		return errors.BadRequest.Error("the computed property[%s] of the device[%s] is read-only", propertyID, t.device.ID)
+	}
+	return t.DeviceTwin.Write(propertyID, values)
+}
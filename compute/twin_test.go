@@ -0,0 +1,59 @@
+package compute
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"testing"
+)
+
+// twin reads the properties of a meter.
+type twin struct {
+	models.DeviceTwin
+}
+
+func (t *twin) Read(propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	props := map[models.ProductPropertyID]*models.DeviceData{
+		"voltage": {Name: "voltage", Type: models.PropertyValueTypeFloat, Value: 220.0},
+		"current": {Name: "current", Type: models.PropertyValueTypeFloat, Value: 10.0},
+	}
+	if propertyID == models.DeviceDataMultiPropsID {
+		return props, nil
+	}
+	return map[models.ProductPropertyID]*models.DeviceData{propertyID: props[propertyID]}, nil
+}
+
+func TestDeviceTwin_Read(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine(lg)
+	if err = engine.SetProduct(&models.Product{ID: "meter", Properties: []*models.ProductProperty{
+		{Id: "power", FieldType: models.PropertyValueTypeFloat, Expression: "voltage * current"},
+		{Id: "drop", FieldType: models.PropertyValueTypeUint, Expression: "voltage - 230"}, // negative uint fails
+		{Id: "voltage", FieldType: models.PropertyValueTypeFloat},
+		{Id: "current", FieldType: models.PropertyValueTypeFloat},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	computed := NewDeviceTwin(new(twin), &models.Device{ID: "m1", ProductID: "meter"}, engine)
+
+	props, err := computed.Read(models.DeviceDataMultiPropsID)
+	if err != nil {
+		t.Fatalf("Read() all properties error = %v", err)
+	}
+	if props["voltage"] == nil || props["current"] == nil || props["power"] == nil || props["power"].Value != 2200.0 {
+		t.Errorf("Read() all properties = %v, want the raw ones and the power", props)
+	}
+	if _, ok := props["drop"]; ok {
+		t.Errorf("Read() all properties should omit the failed drop")
+	}
+
+	if props, err = computed.Read("power"); err != nil || props["power"].Value != 2200.0 {
+		t.Errorf("Read(power) = %v, %v, want 2200", props, err)
+	}
+	if _, err = computed.Read("drop"); err == nil {
+		t.Errorf("Read(drop) error = nil, want the failure")
+	}
+}
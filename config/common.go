@@ -7,12 +7,65 @@ type DriverOptions struct {
 	DeviceAutoReconnectIntervalSecond int  `json:"device_auto_reconnect_interval_second" yaml:"device_auto_reconnect_interval_second"`
 	// The number of retries for automatic reconnection of the device. If it is 0, there is no limit.
 	DeviceAutoReconnectMaxRetries int `json:"device_auto_reconnect_max_retries" yaml:"device_auto_reconnect_max_retries"`
+	// PublishBuffer buffers the device data published while the message bus is unreachable.
+	PublishBuffer PublishBufferOptions `json:"publish_buffer" yaml:"publish_buffer"`
+	// Shadow maintains the desired and reported states of devices.
+	Shadow ShadowOptions `json:"shadow" yaml:"shadow"`
+	// CommandQueue queues the writes and calls submitted while devices are disconnected.
+	CommandQueue CommandQueueOptions `json:"command_queue" yaml:"command_queue"`
+}
+
+type ShadowOptions struct {
+	// Enabled indicates whether to maintain the device shadows and reconcile the desired states.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Path is the directory where the device shadows are persisted.
+	Path string `json:"path" yaml:"path" default:"data/shadow"`
+}
+
+type CommandQueueOptions struct {
+	// Enabled indicates whether to accept the commands queued by the manager.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Path is the directory where the queued commands are persisted.
+	Path string `json:"path" yaml:"path" default:"data/commands"`
+}
+
+type PublishBufferOptions struct {
+	// Enabled indicates whether to buffer the device data published while the message bus is unreachable.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Path is the directory where the buffered messages are persisted.
+	Path string `json:"path" yaml:"path" default:"data/buffer"`
+	// MaxSizeMB is the maximum size of all buffered messages, the oldest ones will be dropped if exceeded.
+	// If it is 0, there is no limit.
+	MaxSizeMB int `json:"max_size_mb" yaml:"max_size_mb" default:"64"`
+	// MaxAgeSecond is the maximum age of a buffered message, the expired ones will be dropped.
+	// If it is 0, there is no limit.
+	MaxAgeSecond int `json:"max_age_second" yaml:"max_age_second" default:"86400"`
+	// ReplayIntervalMillisecond is the interval of checking the connectivity to replay the buffered messages.
+	ReplayIntervalMillisecond int `json:"replay_interval_millisecond" yaml:"replay_interval_millisecond" default:"1000"`
 }
 
 type ManagerOptions struct {
 	HTTP struct {
 		Port int `json:"port" yaml:"port"`
 	} `json:"http" yaml:"http"`
+	// Scheduler executes the scheduled and recurring operations.
+	Scheduler SchedulerOptions `json:"scheduler" yaml:"scheduler"`
+	// Recorder persists the data of the devices whose recording is set.
+	Recorder RecorderOptions `json:"recorder" yaml:"recorder"`
+}
+
+type SchedulerOptions struct {
+	// Enabled indicates whether to execute the scheduled operations.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Path is the file where the schedules and their last runs are persisted.
+	Path string `json:"path" yaml:"path" default:"data/schedules.json"`
+}
+
+type RecorderOptions struct {
+	// Enabled indicates whether to record the data of devices.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Path is the directory where the records are appended.
+	Path string `json:"path" yaml:"path" default:"data/records"`
 }
 
 type LogOptions struct {
@@ -30,3 +83,20 @@ type LogOptions struct {
 		Max int `yaml:"max" json:"max" default:"15" validate:"min=0"`
 	} `yaml:"backup" json:"backup"`
 }
+
+type TracingExporterType = string
+
+const (
+	TracingExporterTypeFile TracingExporterType = "file"
+)
+
+type TracingOptions struct {
+	// Enabled indicates whether to record spans and propagate the trace context across operations.
+	// The trace context is carried by the header frame of messages, which is only added if the message has
+	// any header, so the peers should be upgraded to decode the header frame before enabling it.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Exporter is the type of the exporter which the finished spans will be written to.
+	Exporter TracingExporterType `json:"exporter" yaml:"exporter" default:"file"`
+	// FilePath is the path of the file which the spans will be appended to if Exporter is file.
+	FilePath string `json:"file_path" yaml:"file_path"`
+}
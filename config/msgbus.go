@@ -20,8 +20,28 @@ const (
 )
 
 type MessageBusOptions struct {
-	Type MessageBusType        `json:"type" yaml:"type"`
-	MQTT MQTTMessageBusOptions `json:"mqtt" yaml:"mqtt"`
+	Type  MessageBusType        `json:"type" yaml:"type"`
+	MQTT  MQTTMessageBusOptions `json:"mqtt" yaml:"mqtt"`
+	Chunk ChunkOptions          `json:"chunk" yaml:"chunk"`
+	// Tracing propagates the trace context across the operations transferred by the message bus.
+	Tracing TracingOptions `json:"tracing" yaml:"tracing"`
+}
+
+type ChunkOptions struct {
+	// Enabled indicates whether to transfer the large messages in chunks.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// ThresholdKB is the size of payload above which the message will be split into chunks.
+	ThresholdKB int `json:"threshold_kb" yaml:"threshold_kb" default:"256"`
+	// ChunkSizeKB is the maximum size of a chunk.
+	ChunkSizeKB int `json:"chunk_size_kb" yaml:"chunk_size_kb" default:"256"`
+	// StallTimeoutMillisecond indicates how long to wait for the next chunk before requesting the missing ones.
+	StallTimeoutMillisecond int `json:"stall_timeout_millisecond" yaml:"stall_timeout_millisecond" default:"2000"`
+	// ReassemblyTimeoutSecond indicates how long to wait for all chunks of a message before dropping them.
+	ReassemblyTimeoutSecond int `json:"reassembly_timeout_second" yaml:"reassembly_timeout_second" default:"60"`
+	// MaxResumes is the maximum times of requesting the missing chunks of a message.
+	MaxResumes int `json:"max_resumes" yaml:"max_resumes" default:"3"`
+	// CallTimeoutMillisecond indicates the timeout of method call whose request or response may be chunked.
+	CallTimeoutMillisecond int `json:"call_timeout_millisecond" yaml:"call_timeout_millisecond" default:"30000"`
 }
 
 type MQTTMessageBusOptions struct {
@@ -11,6 +11,7 @@ var (
 	BadRequest       = NewType(http.StatusBadRequest, "BadRequest")
 	NotFound         = NewType(http.StatusNotFound, "BadRequest")
 	MethodNotAllowed = NewType(http.StatusMethodNotAllowed, "MethodNotAllowed")
+	Conflict         = NewType(http.StatusConflict, "Conflict")
 	Internal         = NewType(http.StatusInternalServerError, "Internal")
 	Unknown          = NewType(999, "Unknown")
 	Configuration    = NewType(100000, "Configuration")
@@ -0,0 +1,49 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/errors"
+	"net/http"
+)
+
+// ErrorBody is the JSON body of the responses of the failed requests.
+type ErrorBody struct {
+	Code    int    `json:"code"`    // the code of the ErrType
+	Type    string `json:"type"`    // the name of the ErrType
+	Message string `json:"message"` // the first level error message
+}
+
+// StatusOf maps the ErrType of the error into the HTTP status. The codes which are HTTP statuses
+// are used directly, and the others are mapped by the component failed.
+func StatusOf(err error) int {
+	code := errors.TypeOf(err).Code
+	if code >= 100 && code < 600 {
+		return code
+	}
+	switch code {
+	case errors.Driver.Code, errors.DeviceTwin.Code:
+		return http.StatusBadGateway
+	case errors.MessageBus.Code:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteError writes the error as an ErrorBody with the status mapped from its ErrType.
+func WriteError(w http.ResponseWriter, err error) {
+	tp := errors.TypeOf(err)
+	body := &ErrorBody{Code: tp.Code, Type: tp.Msg, Message: err.Error()}
+	if e, ok := err.(errors.EdgeError); ok {
+		body.Message = e.Message()
+	}
+	writeJSON(w, StatusOf(err), body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
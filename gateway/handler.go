@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewHandler returns an http.Handler serving the data of devices by the DataManagerClient,
+// and streaming the data by the DataManagerService. The routes are:
+//
+//	GET  /protocols/{protocol}/products/{product}/devices/{device}/props/{property}[?hard=true]
+//	PUT  /protocols/{protocol}/products/{product}/devices/{device}/props/{property}
+//	POST /protocols/{protocol}/products/{product}/devices/{device}/methods/{method}
+//	GET  /protocols/{protocol}/products/{product}/devices/{device}/props/{property}/stream
+//	GET  /protocols/{protocol}/products/{product}/devices/{device}/events/{event}/stream
+//	GET  /protocols/{protocol}/status/stream
+//
+// The streams are served over WebSocket if the request asks for upgrading, or Server-Sent Events otherwise.
+// Use operations.TopicSingleLevelWildcard as the path parameters of the streams to subscribe all of them.
+//
+// The WebSocket streams are only allowed from the same origin as the gateway, or the allowedOrigins,
+// e.g. https://console.example.com, and "*" allows any origin.
+func NewHandler(mc operations.DataManagerClient, ms operations.DataManagerService, lg *logger.Logger,
+	allowedOrigins ...string) http.Handler {
+	h := &handler{mc: mc, ms: ms, lg: lg, allowedOrigins: make(map[string]bool)}
+	for _, origin := range allowedOrigins {
+		h.allowedOrigins[origin] = true
+	}
+	return h
+}
+
+// maxBodyBytes is the maximum size of the bodies of the requests writing properties or calling methods.
+const maxBodyBytes = 1 << 20
+
+type handler struct {
+	mc operations.DataManagerClient
+	ms operations.DataManagerService
+	lg *logger.Logger
+
+	allowedOrigins map[string]bool
+}
+
+// target is the function of the device parsed from the path.
+type target struct {
+	protocolID string
+	productID  string
+	deviceID   string
+	kind       string // props, methods or events
+	funcID     models.ProductFuncID
+	stream     bool
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 4 && parts[0] == "protocols" && parts[2] == "status" && parts[3] == "stream" {
+		h.streamStatus(w, r, parts[1])
+		return
+	}
+	t, ok := parseTarget(parts)
+	if !ok {
+		WriteError(w, errors.NotFound.Error("the path %s is not found", r.URL.Path))
+		return
+	}
+
+	switch {
+	case t.stream && r.Method == http.MethodGet && t.kind == "props":
+		h.streamProps(w, r, t)
+	case t.stream && r.Method == http.MethodGet && t.kind == "events":
+		h.streamEvent(w, r, t)
+	case !t.stream && r.Method == http.MethodGet && t.kind == "props":
+		h.read(w, r, t)
+	case !t.stream && r.Method == http.MethodPut && t.kind == "props":
+		h.write(w, r, t)
+	case !t.stream && r.Method == http.MethodPost && t.kind == "methods":
+		h.call(w, r, t)
+	default:
+		WriteError(w, errors.MethodNotAllowed.Error("the method %s of the path %s is not allowed", r.Method, r.URL.Path))
+	}
+}
+
+// parseTarget parses protocols/{protocol}/products/{product}/devices/{device}/{kind}/{func}[/stream].
+func parseTarget(parts []string) (*target, bool) {
+	if len(parts) != 8 && len(parts) != 9 {
+		return nil, false
+	}
+	if parts[0] != "protocols" || parts[2] != "products" || parts[4] != "devices" {
+		return nil, false
+	}
+	switch parts[6] {
+	case "props", "methods", "events":
+	default:
+		return nil, false
+	}
+	t := &target{protocolID: parts[1], productID: parts[3], deviceID: parts[5], kind: parts[6], funcID: parts[7]}
+	if len(parts) == 9 {
+		if parts[8] != "stream" {
+			return nil, false
+		}
+		t.stream = true
+	}
+	return t, true
+}
+
+func (h *handler) read(w http.ResponseWriter, r *http.Request, t *target) {
+	hard, _ := strconv.ParseBool(r.URL.Query().Get("hard"))
+	var props map[models.ProductPropertyID]*models.DeviceData
+	var err error
+	if hard {
+		props, err = h.mc.HardRead(t.protocolID, t.productID, t.deviceID, t.funcID)
+	} else {
+		props, err = h.mc.Read(t.protocolID, t.productID, t.deviceID, t.funcID)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, props)
+}
+
+func (h *handler) write(w http.ResponseWriter, r *http.Request, t *target) {
+	props := make(map[models.ProductPropertyID]*models.DeviceData)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&props); err != nil {
+		WriteError(w, errors.BadRequest.Cause(err, "fail to decode the properties to write"))
+		return
+	}
+	if err := h.mc.Write(t.protocolID, t.productID, t.deviceID, t.funcID, props); err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) call(w http.ResponseWriter, r *http.Request, t *target) {
+	ins := make(map[string]*models.DeviceData)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&ins); err != nil {
+		WriteError(w, errors.BadRequest.Cause(err, "fail to decode the inputs of the method"))
+		return
+	}
+	outs, err := h.mc.Call(t.protocolID, t.productID, t.deviceID, t.funcID, ins)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, outs)
+}
+
+func (h *handler) streamProps(w http.ResponseWriter, r *http.Request, t *target) {
+	bus, stop, err := h.ms.SubscribeDeviceProps(t.protocolID, t.productID, t.deviceID, t.funcID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	h.stream(w, r, bus, stop)
+}
+
+func (h *handler) streamEvent(w http.ResponseWriter, r *http.Request, t *target) {
+	bus, stop, err := h.ms.SubscribeDeviceEvent(t.protocolID, t.productID, t.deviceID, t.funcID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	h.stream(w, r, bus, stop)
+}
+
+func (h *handler) streamStatus(w http.ResponseWriter, r *http.Request, protocolID string) {
+	if r.Method != http.MethodGet {
+		WriteError(w, errors.MethodNotAllowed.Error("the method %s of the path %s is not allowed", r.Method, r.URL.Path))
+		return
+	}
+	bus, stop, err := h.ms.SubscribeDeviceStatus(protocolID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	h.stream(w, r, bus, stop)
+}
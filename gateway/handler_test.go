@@ -0,0 +1,166 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeClient struct {
+	operations.DataManagerClient
+}
+
+func (f *fakeClient) Read(protocolID, productID, deviceID string,
+	propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	if deviceID != "device" {
+		return nil, errors.NotFound.Error("the device[%s] is not found", deviceID)
+	}
+	return map[models.ProductPropertyID]*models.DeviceData{
+		propertyID: {Name: propertyID, Type: models.PropertyValueTypeFloat, Value: 25.5},
+	}, nil
+}
+
+func (f *fakeClient) Write(protocolID, productID, deviceID string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	return errors.Driver.Error("the device[%s] is disconnected", deviceID)
+}
+
+type fakeService struct {
+	operations.DataManagerService
+}
+
+func (f *fakeService) SubscribeDeviceProps(protocolID, productID, deviceID string,
+	propertyID models.ProductPropertyID) (<-chan interface{}, func(), error) {
+	bus := make(chan interface{}, 2)
+	bus <- map[models.ProductPropertyID]*models.DeviceData{
+		"temperature": {Name: "temperature", Type: models.PropertyValueTypeFloat, Value: 25.5},
+	}
+	close(bus)
+	return bus, func() {}, nil
+}
+
+func newServer(t *testing.T, allowedOrigins ...string) *httptest.Server {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(NewHandler(&fakeClient{}, &fakeService{}, lg, allowedOrigins...))
+}
+
+func TestHandler_ReadWrite(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+	base := server.URL + "/protocols/protocol/products/product/devices/"
+
+	rsp, err := http.Get(base + "device/props/temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := make(map[models.ProductPropertyID]*models.DeviceData)
+	if err = json.NewDecoder(rsp.Body).Decode(&props); err != nil || rsp.StatusCode != http.StatusOK {
+		t.Fatalf("GET the property = %d, %v", rsp.StatusCode, err)
+	}
+	_ = rsp.Body.Close()
+	if props["temperature"].Value != 25.5 {
+		t.Errorf("the property = %v, want 25.5", props["temperature"])
+	}
+
+	tests := []struct {
+		method, path string
+		status, code int
+	}{
+		{http.MethodGet, "unknown/props/temperature", http.StatusNotFound, errors.NotFound.Code},
+		{http.MethodPut, "device/props/temperature", http.StatusBadGateway, errors.Driver.Code},
+		{http.MethodDelete, "device/props/temperature", http.StatusMethodNotAllowed, errors.MethodNotAllowed.Code},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, base+tt.path, strings.NewReader(`{}`))
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := new(ErrorBody)
+		_ = json.NewDecoder(rsp.Body).Decode(body)
+		_ = rsp.Body.Close()
+		if rsp.StatusCode != tt.status || body.Code != tt.code {
+			t.Errorf("%s %s = %d %+v, want %d with the code %d", tt.method, tt.path, rsp.StatusCode, body, tt.status, tt.code)
+		}
+	}
+
+	large := `{"temperature":{"name":"` + strings.Repeat("x", maxBodyBytes) + `"}}`
+	req, _ := http.NewRequest(http.MethodPut, base+"device/props/temperature", strings.NewReader(large))
+	if rsp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT the body larger than the limit = %d, want %d", rsp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_StreamOrigin(t *testing.T) {
+	server := newServer(t, "https://console.example.com")
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/protocols/protocol/products/product/devices/device/props/+/stream"
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{server.URL, true},
+		{"https://console.example.com", true},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if (err == nil) != tt.ok {
+			t.Errorf("dial the WebSocket from the origin %q, err = %v, want ok %v", tt.origin, err, tt.ok)
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+func TestHandler_Stream(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+	path := "/protocols/protocol/products/product/devices/device/props/+/stream"
+
+	rsp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	if ct := rsp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("the content type of the SSE = %s", ct)
+	}
+	line, err := bufio.NewReader(rsp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "temperature") {
+		t.Errorf("the first event of the SSE = %q, %v", line, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("fail to dial the WebSocket: %v", err)
+	}
+	defer conn.Close()
+	props := make(map[models.ProductPropertyID]*models.DeviceData)
+	if err = conn.ReadJSON(&props); err != nil || props["temperature"] == nil {
+		t.Errorf("the first message of the WebSocket = %v, %v", props, err)
+	}
+}
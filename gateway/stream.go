@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/thingio/edge-device-std/errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// heartbeatInterval is the interval to check whether the client of the stream is alive.
+const heartbeatInterval = 30 * time.Second
+
+// checkOrigin allows the WebSocket requests from the same origin, or the allowed origins, and the ones
+// without the Origin header which are not sent by browsers.
+func (h *handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if h.allowedOrigins["*"] || h.allowedOrigins[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// stream forwards the values of the bus to the client until the client leaves,
+// the subscription is stopped then.
+func (h *handler) stream(w http.ResponseWriter, r *http.Request, bus <-chan interface{}, stop func()) {
+	defer stop()
+	if websocket.IsWebSocketUpgrade(r) {
+		h.streamWebSocket(w, r, bus)
+	} else {
+		h.streamSSE(w, r, bus)
+	}
+}
+
+func (h *handler) streamWebSocket(w http.ResponseWriter, r *http.Request, bus <-chan interface{}) {
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.lg.WithError(err).Errorf("fail to upgrade the connection of %s", r.URL.Path)
+		return
+	}
+	defer conn.Close()
+
+	// the messages from the client are discarded, the reading detects the closing of the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+		case v, ok := <-bus:
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if v == nil {
+				continue
+			}
+			if err = conn.WriteJSON(v); err != nil {
+				h.lg.WithError(err).Warnf("fail to write the stream of %s", r.URL.Path)
+				return
+			}
+		}
+	}
+}
+
+func (h *handler) streamSSE(w http.ResponseWriter, r *http.Request, bus <-chan interface{}) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, errors.Internal.Error("the streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			// a comment line keeps the connection alive through the proxies
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case v, ok := <-bus:
+			if !ok {
+				return
+			}
+			if v == nil {
+				continue
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				h.lg.WithError(err).Warnf("fail to marshal the value of the stream of %s", r.URL.Path)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -2,12 +2,14 @@ module github.com/thingio/edge-device-std
 
 require (
 	github.com/eclipse/paho.mqtt.golang v1.3.5
+	github.com/gorilla/websocket v1.4.2
 	github.com/mitchellh/mapstructure v1.4.2
 	github.com/pkg/errors v0.8.1
 	github.com/rs/xid v1.3.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/viper v1.9.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 go 1.16
@@ -0,0 +1,67 @@
+package jsonschema
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+)
+
+// FromMethod converts the inputs and the outputs of the method into the JSON Schema documents.
+func FromMethod(method *models.ProductMethod) (ins, outs *Schema, err error) {
+	if ins, err = FromFields(method.Ins); err != nil {
+		return nil, nil, fmt.Errorf("fail to convert the inputs of the method %s: %s", method.Id, err.Error())
+	}
+	if outs, err = FromFields(method.Outs); err != nil {
+		return nil, nil, fmt.Errorf("fail to convert the outputs of the method %s: %s", method.Id, err.Error())
+	}
+	ins.Title, outs.Title = method.Name, method.Name
+	return ins, outs, nil
+}
+
+// FromFields converts the fields into a JSON Schema document of an object whose properties are all required,
+// the order of the fields is kept by the extension x-order.
+func FromFields(fields []*models.ProductField) (*Schema, error) {
+	schemas := make(map[string]*Schema, len(fields))
+	required := make([]string, 0, len(fields))
+	for i, field := range fields {
+		if _, ok := schemas[field.Id]; ok {
+			return nil, fmt.Errorf("the field %s is duplicated", field.Id)
+		}
+		schema := ForValueType(field.FieldType)
+		if schema.Type == "" {
+			return nil, fmt.Errorf("the type %s of the field %s is unsupported", field.FieldType, field.Id)
+		}
+		schema.Title = field.Name
+		schema.Description = field.Desc
+		schema.Order = i + 1
+		schemas[field.Id] = schema
+		required = append(required, field.Id)
+	}
+	schema := NewObject(schemas, required...)
+	schema.SchemaURI = Draft07
+	return schema, nil
+}
+
+// ToFields converts the JSON Schema document of an object into the fields,
+// the fields are sorted by the extension x-order, and then the IDs.
+func ToFields(schema *Schema) ([]*models.ProductField, error) {
+	if schema.Type != TypeObject {
+		return nil, fmt.Errorf("the type of the schema should be %s, got %s", TypeObject, schema.Type)
+	}
+	ids := schema.orderedProperties()
+
+	fields := make([]*models.ProductField, 0, len(ids))
+	for _, id := range ids {
+		field := schema.Properties[id]
+		fieldType, err := toValueType(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema of the field %s: %s", id, err.Error())
+		}
+		fields = append(fields, &models.ProductField{
+			Id:        id,
+			Name:      field.Title,
+			FieldType: fieldType,
+			Desc:      field.Description,
+		})
+	}
+	return fields, nil
+}
@@ -0,0 +1,311 @@
+package jsonschema
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	// rangeSeparator separates the options of Property.Range, e.g. "9600,19200,38400",
+	// or the bounds of the interval, e.g. "[0,100]", "[0,]".
+	rangeSeparator = ","
+	// multipleSeparator separates the values of Property.Default if Property.Multiple.
+	multipleSeparator = ","
+)
+
+// FromProtocol converts the DeviceProps and the AuxProps of the protocol into the JSON Schema documents.
+func FromProtocol(protocol *models.Protocol) (deviceProps, auxProps *Schema, err error) {
+	deviceProps, err = FromProperties(protocol.DeviceProps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to convert the device properties of the protocol %s: %s", protocol.ID, err.Error())
+	}
+	deviceProps.Title = protocol.Name
+	deviceProps.Description = protocol.Desc
+	auxProps, err = FromProperties(protocol.AuxProps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fail to convert the auxiliary properties of the protocol %s: %s", protocol.ID, err.Error())
+	}
+	auxProps.Title = protocol.Name
+	auxProps.Description = protocol.Desc
+	return deviceProps, auxProps, nil
+}
+
+// FromProperties converts the properties into a JSON Schema document of an object,
+// the order of the properties is kept by the extension x-order.
+func FromProperties(properties []*models.Property) (*Schema, error) {
+	schemas := make(map[string]*Schema, len(properties))
+	required := make([]string, 0)
+	for i, property := range properties {
+		if _, ok := schemas[property.Id]; ok {
+			return nil, fmt.Errorf("the property %s is duplicated", property.Id)
+		}
+		schema, err := FromProperty(property)
+		if err != nil {
+			return nil, err
+		}
+		schema.Order = i + 1
+		schemas[property.Id] = schema
+		if property.Required {
+			required = append(required, property.Id)
+		}
+	}
+	schema := NewObject(schemas, required...)
+	schema.SchemaURI = Draft07
+	return schema, nil
+}
+
+// FromProperty converts the property into a JSON Schema, the Required of the property
+// should be declared by the object containing it, see FromProperties.
+func FromProperty(property *models.Property) (*Schema, error) {
+	item := ForValueType(property.Type)
+	if item.Type == "" {
+		return nil, fmt.Errorf("the type %s of the property %s is unsupported", property.Type, property.Id)
+	}
+	if err := applyRange(item, property.Type, property.Range); err != nil {
+		return nil, fmt.Errorf("invalid range of the property %s: %s", property.Id, err.Error())
+	}
+
+	schema := item
+	if property.Multiple {
+		schema = &Schema{Type: TypeArray, Items: item}
+		if property.MaxLen > 0 {
+			maxLen := property.MaxLen
+			schema.MaxItems = &maxLen
+		}
+	}
+	schema.Title = property.Name
+	schema.Description = property.Desc
+	schema.UIStyle = property.UIStyle
+	schema.Precondition = property.Precondition
+
+	if property.Default != "" {
+		def, err := parseDefault(property.Type, property.Default, property.Multiple)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value of the property %s: %s", property.Id, err.Error())
+		}
+		schema.Default = def
+	}
+	return schema, nil
+}
+
+// ToProperties converts the JSON Schema document of an object into the properties,
+// the properties are sorted by the extension x-order, and then the IDs.
+func ToProperties(schema *Schema) ([]*models.Property, error) {
+	if schema.Type != TypeObject {
+		return nil, fmt.Errorf("the type of the schema should be %s, got %s", TypeObject, schema.Type)
+	}
+	required := make(map[string]bool, len(schema.Required))
+	for _, id := range schema.Required {
+		required[id] = true
+	}
+	ids := schema.orderedProperties()
+
+	properties := make([]*models.Property, 0, len(ids))
+	for _, id := range ids {
+		property, err := ToProperty(id, schema.Properties[id], required[id])
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, property)
+	}
+	return properties, nil
+}
+
+// ToProperty converts the JSON Schema of the property into a models.Property.
+func ToProperty(id string, schema *Schema, required bool) (*models.Property, error) {
+	property := &models.Property{
+		Id:           id,
+		Name:         schema.Title,
+		Desc:         schema.Description,
+		UIStyle:      schema.UIStyle,
+		Precondition: schema.Precondition,
+		Required:     required,
+	}
+
+	item := schema
+	if schema.Type == TypeArray {
+		if schema.Items == nil {
+			return nil, fmt.Errorf("the items of the property %s are undefined", id)
+		}
+		item = schema.Items
+		property.Multiple = true
+		if schema.MaxItems != nil {
+			property.MaxLen = *schema.MaxItems
+		}
+	}
+	valueType, err := toValueType(item)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema of the property %s: %s", id, err.Error())
+	}
+	property.Type = valueType
+	if property.Range, err = formatRange(item, valueType); err != nil {
+		return nil, fmt.Errorf("invalid range of the property %s: %s", id, err.Error())
+	}
+
+	if schema.Default != nil {
+		def, err := formatDefault(schema.Default, property.Multiple)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value of the property %s: %s", id, err.Error())
+		}
+		property.Default = def
+	}
+	return property, nil
+}
+
+// toValueType is the reverse of ForValueType.
+func toValueType(schema *Schema) (models.PropertyValueType, error) {
+	switch schema.Type {
+	case TypeInteger:
+		if schema.Format == "uint64" {
+			return models.PropertyValueTypeUint, nil
+		}
+		return models.PropertyValueTypeInt, nil
+	case TypeNumber:
+		return models.PropertyValueTypeFloat, nil
+	case TypeBoolean:
+		return models.PropertyValueTypeBool, nil
+	case TypeString:
+		return models.PropertyValueTypeString, nil
+	default:
+		return "", fmt.Errorf("the type %s is unsupported", schema.Type)
+	}
+}
+
+// applyRange sets the bounds or the options of the schema by the range.
+func applyRange(schema *Schema, valueType models.PropertyValueType, r string) error {
+	r = strings.TrimSpace(r)
+	if r == "" {
+		return nil
+	}
+	if strings.HasPrefix(r, "[") && strings.HasSuffix(r, "]") {
+		if schema.Type != TypeInteger && schema.Type != TypeNumber {
+			return fmt.Errorf("the interval %s is only available for numbers", r)
+		}
+		bounds := strings.Split(r[1:len(r)-1], rangeSeparator)
+		if len(bounds) != 2 {
+			return fmt.Errorf("the interval %s should be formed as [min,max]", r)
+		}
+		for i, bound := range bounds {
+			bound = strings.TrimSpace(bound)
+			if bound == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(bound, 64)
+			if err != nil {
+				return fmt.Errorf("invalid bound %s of the interval %s", bound, r)
+			}
+			if i == 0 {
+				schema.Minimum = &v
+			} else {
+				schema.Maximum = &v
+			}
+		}
+		return nil
+	}
+
+	options := strings.Split(r, rangeSeparator)
+	schema.Enum = make([]interface{}, 0, len(options))
+	for _, option := range options {
+		v, err := parseValue(valueType, strings.TrimSpace(option))
+		if err != nil {
+			return err
+		}
+		schema.Enum = append(schema.Enum, v)
+	}
+	return nil
+}
+
+// formatRange is the reverse of applyRange, the options containing the separator are rejected,
+// because they can't be told apart from the others once joined.
+func formatRange(schema *Schema, valueType models.PropertyValueType) (string, error) {
+	if len(schema.Enum) > 0 {
+		options := make([]string, len(schema.Enum))
+		for i, option := range schema.Enum {
+			options[i] = formatValue(option)
+			if strings.Contains(options[i], rangeSeparator) {
+				return "", fmt.Errorf("the option %q contains the separator %q", options[i], rangeSeparator)
+			}
+		}
+		return strings.Join(options, rangeSeparator), nil
+	}
+	minimum, maximum := schema.Minimum, schema.Maximum
+	if valueType == models.PropertyValueTypeUint && minimum != nil && *minimum == 0 {
+		minimum = nil // it's implied by the type, see ForValueType
+	}
+	if minimum == nil && maximum == nil {
+		return "", nil
+	}
+	bounds := make([]string, 2)
+	if minimum != nil {
+		bounds[0] = formatValue(*minimum)
+	}
+	if maximum != nil {
+		bounds[1] = formatValue(*maximum)
+	}
+	return "[" + strings.Join(bounds, rangeSeparator) + "]", nil
+}
+
+func parseDefault(valueType models.PropertyValueType, def string, multiple bool) (interface{}, error) {
+	if !multiple {
+		return parseValue(valueType, def)
+	}
+	values := strings.Split(def, multipleSeparator)
+	defs := make([]interface{}, len(values))
+	for i, value := range values {
+		v, err := parseValue(valueType, strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		defs[i] = v
+	}
+	return defs, nil
+}
+
+func formatDefault(def interface{}, multiple bool) (string, error) {
+	if !multiple {
+		return formatValue(def), nil
+	}
+	values, ok := def.([]interface{})
+	if !ok {
+		return "", fmt.Errorf("the default value %v should be an array", def)
+	}
+	defs := make([]string, len(values))
+	for i, value := range values {
+		defs[i] = formatValue(value)
+		if strings.Contains(defs[i], multipleSeparator) {
+			return "", fmt.Errorf("the value %q contains the separator %q", defs[i], multipleSeparator)
+		}
+	}
+	return strings.Join(defs, multipleSeparator), nil
+}
+
+// parseValue parses the value of the type, the numbers are parsed into float64 as encoding/json does.
+func parseValue(valueType models.PropertyValueType, value string) (interface{}, error) {
+	switch valueType {
+	case models.PropertyValueTypeInt:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return float64(v), err
+	case models.PropertyValueTypeUint:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return float64(v), err
+	case models.PropertyValueTypeFloat:
+		return strconv.ParseFloat(value, 64)
+	case models.PropertyValueTypeBool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
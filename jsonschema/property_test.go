@@ -0,0 +1,135 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/models"
+	"reflect"
+	"testing"
+)
+
+// roundTrip marshals and unmarshals the schema as the services out of Go do.
+func roundTrip(t *testing.T, schema *Schema) *Schema {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("fail to marshal the schema: %s", err.Error())
+	}
+	result := new(Schema)
+	if err = json.Unmarshal(data, result); err != nil {
+		t.Fatalf("fail to unmarshal the schema: %s", err.Error())
+	}
+	return result
+}
+
+func TestPropertiesRoundTrip(t *testing.T) {
+	protocol := &models.Protocol{
+		ID:   "modbus",
+		Name: "Modbus",
+		DeviceProps: []*models.Property{
+			{Id: "host", Name: "Host", Type: models.PropertyValueTypeString, UIStyle: "input", Required: true},
+			{Id: "port", Name: "Port", Type: models.PropertyValueTypeUint, Default: "502", Range: "[1,65535]", Required: true},
+			{Id: "baud_rate", Name: "Baud Rate", Type: models.PropertyValueTypeInt, UIStyle: "select",
+				Default: "9600", Range: "9600,19200,38400", Precondition: "mode=rtu"},
+			{Id: "timeout", Name: "Timeout", Desc: "in seconds", Type: models.PropertyValueTypeFloat, Default: "1.5", Range: "[0.1,]"},
+			{Id: "slaves", Name: "Slaves", Type: models.PropertyValueTypeUint, Multiple: true, MaxLen: 4, Default: "1,2"},
+			{Id: "debug", Name: "Debug", Type: models.PropertyValueTypeBool, Default: "false"},
+		},
+		AuxProps: []*models.Property{
+			{Id: "register", Name: "Register", Type: models.PropertyValueTypeString, Range: "coil,holding,input", Required: true},
+		},
+	}
+
+	deviceProps, auxProps, err := FromProtocol(protocol)
+	if err != nil {
+		t.Fatalf("fail to convert the protocol: %s", err.Error())
+	}
+	if port := deviceProps.Properties["port"]; *port.Minimum != 1 || *port.Maximum != 65535 {
+		t.Errorf("the bounds of the port should be [1,65535], got [%v,%v]", *port.Minimum, *port.Maximum)
+	}
+	if slaves := deviceProps.Properties["slaves"]; slaves.Type != TypeArray || *slaves.MaxItems != 4 {
+		t.Errorf("the slaves should be an array of at most 4 items")
+	}
+
+	for name, c := range map[string]struct {
+		schema *Schema
+		expect []*models.Property
+	}{
+		"device props": {deviceProps, protocol.DeviceProps},
+		"aux props":    {auxProps, protocol.AuxProps},
+	} {
+		properties, err := ToProperties(roundTrip(t, c.schema))
+		if err != nil {
+			t.Fatalf("fail to convert the %s back: %s", name, err.Error())
+		}
+		if len(properties) != len(c.expect) {
+			t.Fatalf("expect %d %s, got %d", len(c.expect), name, len(properties))
+		}
+		for i, property := range properties {
+			if !reflect.DeepEqual(property, c.expect[i]) {
+				t.Errorf("expect %+v, got %+v", c.expect[i], property)
+			}
+		}
+	}
+}
+
+func TestFromPropertyInvalid(t *testing.T) {
+	for _, property := range []*models.Property{
+		{Id: "a", Type: "complex"},
+		{Id: "b", Type: models.PropertyValueTypeInt, Default: "x"},
+		{Id: "c", Type: models.PropertyValueTypeString, Range: "[0,1]"},
+		{Id: "d", Type: models.PropertyValueTypeFloat, Range: "[0,1,2]"},
+		{Id: "e", Type: models.PropertyValueTypeUint, Range: "1,-1"},
+	} {
+		if _, err := FromProperty(property); err == nil {
+			t.Errorf("the property %s should be invalid", property.Id)
+		}
+	}
+}
+
+func TestToPropertyInvalid(t *testing.T) {
+	for id, schema := range map[string]*Schema{
+		"a": {Type: TypeObject},
+		"b": {Type: TypeArray},
+		"c": {Type: TypeString, Enum: []interface{}{"a", "b,c"}},
+		"d": {Type: TypeArray, Items: &Schema{Type: TypeString}, Default: []interface{}{"a,b"}},
+	} {
+		if _, err := ToProperty(id, schema, false); err == nil {
+			t.Errorf("the schema of the property %s should be invalid", id)
+		}
+	}
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	method := &models.ProductMethod{
+		Id:   "reset",
+		Name: "Reset",
+		Ins: []*models.ProductField{
+			{Id: "delay", Name: "Delay", FieldType: models.PropertyValueTypeInt, Desc: "in seconds"},
+			{Id: "force", Name: "Force", FieldType: models.PropertyValueTypeBool},
+		},
+		Outs: []*models.ProductField{
+			{Id: "result", Name: "Result", FieldType: models.PropertyValueTypeString},
+			{Id: "count", Name: "Count", FieldType: models.PropertyValueTypeUint},
+			{Id: "elapsed", Name: "Elapsed", FieldType: models.PropertyValueTypeFloat},
+		},
+	}
+
+	ins, outs, err := FromMethod(method)
+	if err != nil {
+		t.Fatalf("fail to convert the method: %s", err.Error())
+	}
+	if len(ins.Required) != 2 {
+		t.Errorf("all inputs should be required, got %v", ins.Required)
+	}
+	for _, c := range []struct {
+		schema *Schema
+		expect []*models.ProductField
+	}{{ins, method.Ins}, {outs, method.Outs}} {
+		fields, err := ToFields(roundTrip(t, c.schema))
+		if err != nil {
+			t.Fatalf("fail to convert the fields back: %s", err.Error())
+		}
+		if !reflect.DeepEqual(fields, c.expect) {
+			t.Errorf("expect %+v, got %+v", c.expect, fields)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package jsonschema
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"sort"
+)
+
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+
+	Draft07 = "http://json-schema.org/draft-07/schema#"
+)
+
+// Schema is a subset of JSON Schema (draft 7) used to describe the payloads and the properties.
+type Schema struct {
+	SchemaURI            string             `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty" yaml:"title,omitempty"`
+	Description          string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Type                 string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Format               string             `json:"format,omitempty" yaml:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty" yaml:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	MaxItems             *int64             `json:"maxItems,omitempty" yaml:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty" yaml:"required,omitempty"`
+
+	// the extensions keeping the fields of models.Property which JSON Schema can't express
+	UIStyle      string `json:"x-ui-style,omitempty" yaml:"x-ui-style,omitempty"`
+	Precondition string `json:"x-precondition,omitempty" yaml:"x-precondition,omitempty"`
+	Order        int    `json:"x-order,omitempty" yaml:"x-order,omitempty"` // the 1-based order among the properties
+}
+
+// NewObject returns an object schema with the properties.
+func NewObject(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: TypeObject, Properties: properties, Required: required}
+}
+
+// orderedProperties returns the names of the properties sorted by the extension x-order, and then the names.
+func (s *Schema) orderedProperties() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, oj := s.Properties[names[i]].Order, s.Properties[names[j]].Order
+		if oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ForValueType returns the schema of the values of the type.
+func ForValueType(valueType models.PropertyValueType) *Schema {
+	switch valueType {
+	case models.PropertyValueTypeInt:
+		return &Schema{Type: TypeInteger, Format: "int64"}
+	case models.PropertyValueTypeUint:
+		zero := 0.0
+		return &Schema{Type: TypeInteger, Format: "uint64", Minimum: &zero}
+	case models.PropertyValueTypeFloat:
+		return &Schema{Type: TypeNumber, Format: "double"}
+	case models.PropertyValueTypeBool:
+		return &Schema{Type: TypeBoolean}
+	case models.PropertyValueTypeString:
+		return &Schema{Type: TypeString}
+	default:
+		return &Schema{}
+	}
+}
+
+// ForDeviceData returns the schema of the DeviceData carrying the values of the type.
+func ForDeviceData(valueType models.PropertyValueType) *Schema {
+	return NewObject(map[string]*Schema{
+		"name":    {Type: TypeString},
+		"type":    {Type: TypeString, Enum: []interface{}{valueType}},
+		"value":   ForValueType(valueType),
+		"ts":      {Type: TypeString, Format: "date-time"},
+		"quality": {Type: TypeString, Description: "good, uncertain or bad with the sub-reason, good if absent"},
+		"source":  {Type: TypeString, Enum: []interface{}{models.DataSourceDevice, models.DataSourceCache, models.DataSourceComputed, models.DataSourceManual}},
+		"seq":     {Type: TypeInteger, Format: "uint64"},
+	}, "name", "type", "value")
+}
+
+// ForError returns the schema of the errors.CommonEdgeError.
+func ForError() *Schema {
+	return NewObject(map[string]*Schema{
+		"message": {Type: TypeString},
+		"type": NewObject(map[string]*Schema{
+			"code": {Type: TypeInteger},
+			"Msg":  {Type: TypeString},
+		}),
+	}, "message", "type")
+}
@@ -110,7 +110,7 @@ func (l Logger) Fatal(args ...interface{}) {
 	l.logger.Fatal(args...)
 }
 func (l Logger) Panic(args ...interface{}) {
-	l.logger.Panic(args)
+	l.logger.Panic(args...)
 }
 
 type logFormatter struct {
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type AggregateFunction = string
+
+const (
+	AggregateFunctionMin   AggregateFunction = "min"
+	AggregateFunctionMax   AggregateFunction = "max"
+	AggregateFunctionAvg   AggregateFunction = "avg"
+	AggregateFunctionCount AggregateFunction = "count"
+	AggregateFunctionLast  AggregateFunction = "last"
+)
+
+// ProductAggregation declares the windowed aggregation of the properties of the devices.
+type ProductAggregation struct {
+	Id string `json:"id"`
+	// PropertyIDs are the properties to aggregate, all numeric properties are aggregated if it is empty.
+	PropertyIDs []ProductPropertyID `json:"property_ids,omitempty"`
+	Functions   []AggregateFunction `json:"functions"`
+	// Window is the length of the window, e.g. "1m".
+	Window string `json:"window"`
+	// Slide is the step of the sliding window, e.g. "10s", the window is tumbling if it is empty.
+	Slide string `json:"slide,omitempty"`
+	// ReplaceRaw indicates whether to publish the aggregated values instead of the raw values.
+	ReplaceRaw bool `json:"replace_raw"`
+}
+
+// Durations returns the length and the step of the window.
+func (a *ProductAggregation) Durations() (window, slide time.Duration, err error) {
+	if window, err = time.ParseDuration(a.Window); err != nil {
+		return 0, 0, fmt.Errorf("invalid window of the aggregation %s: %s", a.Id, err.Error())
+	}
+	if window <= 0 {
+		return 0, 0, fmt.Errorf("the window of the aggregation %s should be positive", a.Id)
+	}
+	if a.Slide == "" {
+		return window, window, nil
+	}
+	if slide, err = time.ParseDuration(a.Slide); err != nil {
+		return 0, 0, fmt.Errorf("invalid slide of the aggregation %s: %s", a.Id, err.Error())
+	}
+	if slide <= 0 || slide > window {
+		return 0, 0, fmt.Errorf("the slide of the aggregation %s should be in (0, %s]", a.Id, a.Window)
+	}
+	return window, slide, nil
+}
+
+func (a *ProductAggregation) validate(properties map[ProductPropertyID]*ProductProperty) error {
+	if a.Id == "" {
+		return fmt.Errorf("the id of the aggregation is required")
+	}
+	for _, id := range a.PropertyIDs {
+		property, ok := properties[id]
+		if !ok {
+			return fmt.Errorf("the property %s of the aggregation %s is undefined", id, a.Id)
+		}
+		switch property.FieldType {
+		case PropertyValueTypeInt, PropertyValueTypeUint, PropertyValueTypeFloat:
+		default:
+			return fmt.Errorf("the property %s of the aggregation %s should be a number", id, a.Id)
+		}
+	}
+	if len(a.Functions) == 0 {
+		return fmt.Errorf("the functions of the aggregation %s are required", a.Id)
+	}
+	for _, fn := range a.Functions {
+		switch fn {
+		case AggregateFunctionMin, AggregateFunctionMax, AggregateFunctionAvg, AggregateFunctionCount, AggregateFunctionLast:
+		default:
+			return fmt.Errorf("unsupported function of the aggregation %s: %s", a.Id, fn)
+		}
+	}
+	_, _, err := a.Durations()
+	return err
+}
+
+// AggregateWindow is the aggregated values of the properties of a device within a window.
+type AggregateWindow struct {
+	AggregationID string                                              `json:"aggregation_id"`
+	ProductID     string                                              `json:"product_id"`
+	DeviceID      string                                              `json:"device_id"`
+	Start         time.Time                                           `json:"start"`
+	End           time.Time                                           `json:"end"`
+	Values        map[ProductPropertyID]map[AggregateFunction]float64 `json:"values"`
+}
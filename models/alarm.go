@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	AlarmSeverity = string
+	AlarmState    = string
+)
+
+const (
+	AlarmSeverityCritical AlarmSeverity = "critical"
+	AlarmSeverityMajor    AlarmSeverity = "major"
+	AlarmSeverityMinor    AlarmSeverity = "minor"
+	AlarmSeverityWarning  AlarmSeverity = "warning"
+
+	AlarmStateActive       AlarmState = "active"       // the condition is present and not acknowledged
+	AlarmStateAcknowledged AlarmState = "acknowledged" // the condition is present and acknowledged
+	AlarmStateCleared      AlarmState = "cleared"      // the condition is gone
+)
+
+// ProductAlarm declares an alarm raised when the value of a property exceeds the limits.
+type ProductAlarm struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Desc       string            `json:"desc"`
+	PropertyID ProductPropertyID `json:"property_id"`
+	Severity   AlarmSeverity     `json:"severity"`
+	High       *float64          `json:"high,omitempty"` // raised if the value is above the high limit
+	Low        *float64          `json:"low,omitempty"`  // raised if the value is below the low limit
+	// Hysteresis is the deadband to clear the alarm, e.g. the alarm raised above the high limit 80
+	// with the hysteresis 2 will be cleared only if the value drops below 78.
+	Hysteresis float64 `json:"hysteresis"`
+}
+
+func (a *ProductAlarm) validate(properties map[ProductPropertyID]*ProductProperty) error {
+	if a.Id == "" {
+		return fmt.Errorf("the id of the alarm is required")
+	}
+	property, ok := properties[a.PropertyID]
+	if !ok {
+		return fmt.Errorf("the property %s of the alarm %s is undefined", a.PropertyID, a.Id)
+	}
+	switch property.FieldType {
+	case PropertyValueTypeInt, PropertyValueTypeUint, PropertyValueTypeFloat:
+	default:
+		return fmt.Errorf("the property %s of the alarm %s should be a number", a.PropertyID, a.Id)
+	}
+	switch a.Severity {
+	case AlarmSeverityCritical, AlarmSeverityMajor, AlarmSeverityMinor, AlarmSeverityWarning:
+	default:
+		return fmt.Errorf("unsupported severity of the alarm %s: %s", a.Id, a.Severity)
+	}
+	if a.High == nil && a.Low == nil {
+		return fmt.Errorf("either the high or the low limit of the alarm %s is required", a.Id)
+	}
+	if a.High != nil && a.Low != nil && *a.Low >= *a.High {
+		return fmt.Errorf("the low limit of the alarm %s should be less than the high limit", a.Id)
+	}
+	if a.Hysteresis < 0 {
+		return fmt.Errorf("the hysteresis of the alarm %s should not be negative", a.Id)
+	}
+	return nil
+}
+
+// Alarm is the state of a ProductAlarm of a device.
+type Alarm struct {
+	AlarmID    string            `json:"alarm_id"`
+	ProductID  string            `json:"product_id"`
+	DeviceID   string            `json:"device_id"`
+	PropertyID ProductPropertyID `json:"property_id"`
+	Severity   AlarmSeverity     `json:"severity"`
+	State      AlarmState        `json:"state"`
+	Message    string            `json:"message"`
+	Value      float64           `json:"value"` // the value raising the alarm
+	Limit      float64           `json:"limit"` // the limit exceeded
+
+	RaisedAt     time.Time  `json:"raised_at"`
+	AckedAt      *time.Time `json:"acked_at,omitempty"`
+	ClearedAt    *time.Time `json:"cleared_at,omitempty"`
+	ShelvedUntil *time.Time `json:"shelved_until,omitempty"` // the changes won't be published before it
+}
+
+// Shelved returns whether the alarm is shelved at the moment.
+func (a *Alarm) Shelved(now time.Time) bool {
+	return a.ShelvedUntil != nil && now.Before(*a.ShelvedUntil)
+}
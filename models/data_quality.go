@@ -0,0 +1,68 @@
+package models
+
+import "strings"
+
+type (
+	DataQuality = string // the quality of the data, formed by <category>[_<sub-reason>]
+	DataSource  = string // where the data comes from
+)
+
+const (
+	DataQualityGood              DataQuality = "good"
+	DataQualityGoodLocalOverride DataQuality = "good_local_override" // the value has been overridden locally
+
+	DataQualityUncertain               DataQuality = "uncertain"
+	DataQualityUncertainLastUsable     DataQuality = "uncertain_last_usable"     // the last usable value, the device stops updating it
+	DataQualityUncertainSensorAccuracy DataQuality = "uncertain_sensor_accuracy" // the sensor is not accurate, e.g. not calibrated
+	DataQualityUncertainEUExceeded     DataQuality = "uncertain_eu_exceeded"     // the value is out of the engineering range, it may be clamped
+	DataQualityUncertainSubstitute     DataQuality = "uncertain_substitute"      // the value is substituted, e.g. by the default value
+
+	DataQualityBad               DataQuality = "bad"
+	DataQualityBadConfigError    DataQuality = "bad_config_error"     // the device or the product is misconfigured
+	DataQualityBadNotConnected   DataQuality = "bad_not_connected"    // the device is not connected
+	DataQualityBadDeviceFailure  DataQuality = "bad_device_failure"   // the device is failed
+	DataQualityBadSensorFailure  DataQuality = "bad_sensor_failure"   // the sensor is failed
+	DataQualityBadCommFailure    DataQuality = "bad_comm_failure"     // fail to communicate with the device
+	DataQualityBadOutOfService   DataQuality = "bad_out_of_service"   // the device is out of service
+	DataQualityBadLastKnownValue DataQuality = "bad_last_known_value" // the communication fails, it is the last known value
+)
+
+const (
+	DataSourceDevice   DataSource = "device"   // hard read from the real device
+	DataSourceCache    DataSource = "cache"    // served from the cache, e.g. the shadow or the latest published values
+	DataSourceComputed DataSource = "computed" // computed from other properties
+	DataSourceManual   DataSource = "manual"   // entered manually
+)
+
+// DataQualityCategory returns the category of the quality, i.e. DataQualityGood, DataQualityUncertain or DataQualityBad.
+// The quality not specified is regarded as good, so the data published by drivers without quality is still good.
+func DataQualityCategory(quality DataQuality) DataQuality {
+	switch {
+	case quality == "":
+		return DataQualityGood
+	case strings.HasPrefix(quality, DataQualityGood):
+		return DataQualityGood
+	case strings.HasPrefix(quality, DataQualityUncertain):
+		return DataQualityUncertain
+	default:
+		return DataQualityBad
+	}
+}
+
+// WorseDataQuality returns the worse one of the qualities, it's useful to derive the quality from multiple inputs.
+func WorseDataQuality(a, b DataQuality) DataQuality {
+	rank := func(q DataQuality) int {
+		switch DataQualityCategory(q) {
+		case DataQualityGood:
+			return 0
+		case DataQualityUncertain:
+			return 1
+		default:
+			return 2
+		}
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
@@ -18,6 +18,10 @@ type DeviceData struct {
 	Type  string      `json:"type"`  // the type of the raw value
 	Value interface{} `json:"value"` // raw value
 	Ts    time.Time   `json:"ts"`    // the timestamp of reading the raw value from the real device
+
+	Quality DataQuality `json:"quality,omitempty"` // the quality of the value, it's good if not specified
+	Source  DataSource  `json:"source,omitempty"`  // where the value comes from, it's the device if not specified
+	Seq     uint64      `json:"seq,omitempty"`     // the optional sequence number to detect the loss or disorder
 }
 
 func NewDeviceData(name string, valueType PropertyValueType, value interface{}) (*DeviceData, error) {
@@ -37,6 +41,55 @@ func (d *DeviceData) String() string {
 	return fmt.Sprintf("Data: %s, %s:%s", d.Name, d.Type, d.ValueToString())
 }
 
+// WithQuality sets the quality of the data and returns itself.
+func (d *DeviceData) WithQuality(quality DataQuality) *DeviceData {
+	d.Quality = quality
+	return d
+}
+
+// WithSource sets the source of the data and returns itself.
+func (d *DeviceData) WithSource(source DataSource) *DeviceData {
+	d.Source = source
+	return d
+}
+
+// WithSeq sets the sequence number of the data and returns itself.
+func (d *DeviceData) WithSeq(seq uint64) *DeviceData {
+	d.Seq = seq
+	return d
+}
+
+// GetQuality returns the quality of the data, DataQualityGood is returned if it is not specified.
+func (d *DeviceData) GetQuality() DataQuality {
+	if d.Quality == "" {
+		return DataQualityGood
+	}
+	return d.Quality
+}
+
+// GetSource returns the source of the data, DataSourceDevice is returned if it is not specified.
+func (d *DeviceData) GetSource() DataSource {
+	if d.Source == "" {
+		return DataSourceDevice
+	}
+	return d.Source
+}
+
+// IsGood returns whether the quality of the data is good.
+func (d *DeviceData) IsGood() bool {
+	return DataQualityCategory(d.Quality) == DataQualityGood
+}
+
+// IsUncertain returns whether the quality of the data is uncertain.
+func (d *DeviceData) IsUncertain() bool {
+	return DataQualityCategory(d.Quality) == DataQualityUncertain
+}
+
+// IsBad returns whether the quality of the data is bad.
+func (d *DeviceData) IsBad() bool {
+	return DataQualityCategory(d.Quality) == DataQualityBad
+}
+
 // ValueToString returns the string format of the Value.
 func (d *DeviceData) ValueToString() string {
 	return fmt.Sprintf("%v", d.Value)
@@ -96,6 +149,48 @@ func (d *DeviceData) FloatValue() (float64, error) {
 	}
 }
 
+// NumericValue returns the Value in float64 type whichever numeric type it is,
+// and returns errors if the Type is not PropertyValueTypeInt, PropertyValueTypeUint or PropertyValueTypeFloat.
+// The Type is checked before the Value, so the data of other types are rejected even if their Values hold
+// numbers, e.g. a string property carrying 1.5, they won't raise alarms or satisfy the numeric comparisons of rules.
+func (d *DeviceData) NumericValue() (float64, error) {
+	switch d.Type {
+	case PropertyValueTypeInt, PropertyValueTypeUint, PropertyValueTypeFloat:
+	default:
+		return 0, fmt.Errorf("the expecting type is a number, but the pre-defined type is %s", d.Type)
+	}
+
+	switch v := d.Value.(type) {
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("fail to parse value '%v' using type %s, raw type is %s",
+			d.Value, d.Type, reflect.TypeOf(d.Value))
+	}
+}
+
 // BoolValue returns the Value in string type, and returns errors if the Type is not PropertyValueTypeBool.
 func (d *DeviceData) BoolValue() (bool, error) {
 	var value bool
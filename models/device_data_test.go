@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDeviceData_JSON(t *testing.T) {
+	data, err := NewDeviceData("temperature", PropertyValueTypeInt, int64(25))
+	if err != nil {
+		t.Fatalf("NewDeviceData() error = %v", err)
+	}
+	data.WithQuality(DataQualityUncertainSubstitute).WithSource(DataSourceCache).WithSeq(42)
+	data.Ts = data.Ts.Truncate(time.Millisecond)
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	decoded := new(DeviceData)
+	if err = json.Unmarshal(bytes, decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Quality != data.Quality || decoded.Source != data.Source || decoded.Seq != data.Seq || !decoded.Ts.Equal(data.Ts) {
+		t.Errorf("Unmarshal() = %+v, want %+v", decoded, data)
+	}
+	if !decoded.IsUncertain() || decoded.IsGood() || decoded.IsBad() {
+		t.Errorf("the quality %s is not uncertain", decoded.Quality)
+	}
+}
+
+func TestDeviceData_DefaultMetadata(t *testing.T) {
+	decoded := new(DeviceData)
+	if err := json.Unmarshal([]byte(`{"name":"temperature","type":"float","value":25.5}`), decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.GetQuality() != DataQualityGood || decoded.GetSource() != DataSourceDevice || !decoded.IsGood() {
+		t.Errorf("the default quality and source = %s, %s, want good and device", decoded.GetQuality(), decoded.GetSource())
+	}
+	if q := WorseDataQuality(DataQualityUncertainLastUsable, DataQualityBadCommFailure); q != DataQualityBadCommFailure {
+		t.Errorf("WorseDataQuality() = %s, want %s", q, DataQualityBadCommFailure)
+	}
+}
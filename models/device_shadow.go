@@ -0,0 +1,131 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// DeviceShadow records the desired and reported states of a device's properties,
+// so that the values written while the device is offline can be applied after it is connected.
+type DeviceShadow struct {
+	ProductID string                                `json:"product_id"`
+	DeviceID  string                                `json:"device_id"`
+	Desired   map[ProductPropertyID]*DeviceData     `json:"desired"`  // the values expected by the manager
+	Reported  map[ProductPropertyID]*DeviceData     `json:"reported"` // the values reported by the real device
+	Metadata  map[ProductPropertyID]*ShadowMetadata `json:"metadata"`
+	Version   int64                                 `json:"version"` // increased on every change of the desired state
+	Ts        time.Time                             `json:"ts"`
+}
+
+// ShadowMetadata records when the desired and reported values of a property are changed.
+type ShadowMetadata struct {
+	DesiredVersion  int64     `json:"desired_version"`
+	DesiredTs       time.Time `json:"desired_ts"`
+	ReportedVersion int64     `json:"reported_version"` // the version of the desired state when it is reported
+	ReportedTs      time.Time `json:"reported_ts"`
+}
+
+func NewDeviceShadow(productID, deviceID string) *DeviceShadow {
+	return &DeviceShadow{
+		ProductID: productID,
+		DeviceID:  deviceID,
+		Desired:   make(map[ProductPropertyID]*DeviceData),
+		Reported:  make(map[ProductPropertyID]*DeviceData),
+		Metadata:  make(map[ProductPropertyID]*ShadowMetadata),
+		Ts:        time.Now(),
+	}
+}
+
+// PatchDesired merges the values into the desired state, a nil value removes the property from the desired state.
+// The version is kept if there is no value to merge.
+func (s *DeviceShadow) PatchDesired(values map[ProductPropertyID]*DeviceData) {
+	if len(values) == 0 {
+		return
+	}
+	now := time.Now()
+	s.Version++
+	s.Ts = now
+	for id, value := range values {
+		if value == nil {
+			delete(s.Desired, id)
+			if meta, ok := s.Metadata[id]; ok {
+				if _, reported := s.Reported[id]; reported {
+					meta.DesiredVersion, meta.DesiredTs = 0, time.Time{}
+				} else {
+					delete(s.Metadata, id)
+				}
+			}
+			continue
+		}
+		s.Desired[id] = value
+		meta := s.metadata(id)
+		meta.DesiredVersion = s.Version
+		meta.DesiredTs = now
+	}
+}
+
+// Report merges the values into the reported state.
+func (s *DeviceShadow) Report(values map[ProductPropertyID]*DeviceData) {
+	now := time.Now()
+	s.Ts = now
+	for id, value := range values {
+		if value == nil {
+			continue
+		}
+		s.Reported[id] = value
+		meta := s.metadata(id)
+		meta.ReportedVersion = s.Version
+		meta.ReportedTs = now
+	}
+}
+
+// Delta returns the desired values which are different from the reported ones.
+func (s *DeviceShadow) Delta() map[ProductPropertyID]*DeviceData {
+	delta := make(map[ProductPropertyID]*DeviceData)
+	for id, desired := range s.Desired {
+		reported, ok := s.Reported[id]
+		if !ok || !equalValues(desired, reported) {
+			delta[id] = desired
+		}
+	}
+	return delta
+}
+
+// equalValues compares the values by the type of the desired one, the numbers are compared numerically,
+// because they may be decoded as float64 from JSON, or carried as strings, e.g. "1" and "1.0".
+func equalValues(desired, reported *DeviceData) bool {
+	switch desired.Type {
+	case PropertyValueTypeInt, PropertyValueTypeUint, PropertyValueTypeFloat:
+		d, derr := numericValue(desired)
+		r, rerr := numericValue(reported)
+		if derr == nil && rerr == nil {
+			return d == r
+		}
+	case PropertyValueTypeBool:
+		d, derr := strconv.ParseBool(desired.ValueToString())
+		r, rerr := strconv.ParseBool(reported.ValueToString())
+		if derr == nil && rerr == nil {
+			return d == r
+		}
+	}
+	return desired.ValueToString() == reported.ValueToString()
+}
+
+// numericValue returns the number of the data in any numeric type, or in the string format.
+func numericValue(data *DeviceData) (float64, error) {
+	if s, ok := data.Value.(string); ok {
+		return strconv.ParseFloat(s, 64)
+	}
+	number := *data
+	number.Type = PropertyValueTypeFloat
+	return number.NumericValue()
+}
+
+func (s *DeviceShadow) metadata(id ProductPropertyID) *ShadowMetadata {
+	meta, ok := s.Metadata[id]
+	if !ok {
+		meta = new(ShadowMetadata)
+		s.Metadata[id] = meta
+	}
+	return meta
+}
@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestDeviceShadowDelta(t *testing.T) {
+	s := NewDeviceShadow("product", "device")
+	s.PatchDesired(map[ProductPropertyID]*DeviceData{
+		"count":   {Name: "count", Type: PropertyValueTypeInt, Value: int64(1)},
+		"ratio":   {Name: "ratio", Type: PropertyValueTypeFloat, Value: "1"},
+		"enabled": {Name: "enabled", Type: PropertyValueTypeBool, Value: true},
+		"mode":    {Name: "mode", Type: PropertyValueTypeString, Value: "auto"},
+	})
+	if len(s.Delta()) != 4 {
+		t.Fatalf("the delta before reporting = %v, want all desired values", s.Delta())
+	}
+
+	s.Report(map[ProductPropertyID]*DeviceData{
+		"count":   {Name: "count", Type: PropertyValueTypeInt, Value: float64(1)}, // decoded from JSON
+		"ratio":   {Name: "ratio", Type: PropertyValueTypeFloat, Value: "1.0"},
+		"enabled": {Name: "enabled", Type: PropertyValueTypeBool, Value: "true"},
+		"mode":    {Name: "mode", Type: PropertyValueTypeString, Value: "manual"},
+	})
+	delta := s.Delta()
+	if len(delta) != 1 || delta["mode"] == nil {
+		t.Errorf("the delta = %v, want only the mode", delta)
+	}
+	if s.Metadata["count"].ReportedVersion != s.Version {
+		t.Errorf("the reported version = %d, want %d", s.Metadata["count"].ReportedVersion, s.Version)
+	}
+}
+
+func TestDeviceShadowPatchNil(t *testing.T) {
+	s := NewDeviceShadow("product", "device")
+	s.PatchDesired(map[ProductPropertyID]*DeviceData{
+		"mode":  {Name: "mode", Type: PropertyValueTypeString, Value: "auto"},
+		"speed": {Name: "speed", Type: PropertyValueTypeInt, Value: 1},
+	})
+	s.Report(map[ProductPropertyID]*DeviceData{"speed": {Name: "speed", Type: PropertyValueTypeInt, Value: 2}})
+
+	s.PatchDesired(map[ProductPropertyID]*DeviceData{"mode": nil, "speed": nil})
+	s.PatchDesired(map[ProductPropertyID]*DeviceData{})
+	if s.Version != 2 {
+		t.Errorf("the version = %d, want 2 as the empty patch keeps it", s.Version)
+	}
+	if len(s.Desired) != 0 || len(s.Delta()) != 0 {
+		t.Errorf("the desired values = %v, want none", s.Desired)
+	}
+	if _, ok := s.Metadata["mode"]; ok {
+		t.Errorf("the metadata of the property never reported should be removed")
+	}
+	if meta := s.Metadata["speed"]; meta == nil || meta.DesiredVersion != 0 || !meta.DesiredTs.IsZero() || meta.ReportedTs.IsZero() {
+		t.Errorf("only the desired metadata of the reported property should be cleared, got %+v", meta)
+	}
+}
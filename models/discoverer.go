@@ -0,0 +1,12 @@
+package models
+
+import "context"
+
+// Discoverer is an optional interface which drivers can implement if their protocols support scanning
+// devices, e.g. broadcasting, scanning a subnet or enumerating a bus.
+type Discoverer interface {
+	// Discover scans devices using the specified parameters until the scan finishes or the ctx is done.
+	// Every candidate should be put into the found as soon as it is found, with the suggested
+	// DeviceProps and ProductID filled. The found will not be closed by the Discoverer.
+	Discover(ctx context.Context, params map[string]string, found chan<- *Device) error
+}
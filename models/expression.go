@@ -0,0 +1,404 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is an arithmetic expression over the properties of a device, e.g. "voltage * current".
+// It supports the numbers, the property IDs, the operators + - * / % ^, the parentheses and
+// the functions abs, sqrt, pow, min, max, round, floor and ceil.
+type Expression struct {
+	source string
+	root   exprNode
+	inputs []ProductPropertyID
+}
+
+// ParseExpression parses the source into an Expression.
+func ParseExpression(source string) (*Expression, error) {
+	p := &exprParser{source: source}
+	p.next()
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression '%s': %s", source, err.Error())
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, fmt.Errorf("invalid expression '%s': unexpected '%s' at %d", source, p.tok.text, p.tok.pos)
+	}
+
+	inputs := make([]ProductPropertyID, 0)
+	seen := make(map[ProductPropertyID]bool)
+	root.inputs(func(id ProductPropertyID) {
+		if !seen[id] {
+			seen[id] = true
+			inputs = append(inputs, id)
+		}
+	})
+	return &Expression{source: source, root: root, inputs: inputs}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Inputs returns the IDs of the properties referenced by the expression.
+func (e *Expression) Inputs() []ProductPropertyID {
+	return e.inputs
+}
+
+// Eval evaluates the expression with the values of the inputs.
+func (e *Expression) Eval(values map[ProductPropertyID]float64) (float64, error) {
+	v, err := e.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("the result of the expression '%s' is not a finite number", e.source)
+	}
+	return v, nil
+}
+
+type exprNode interface {
+	eval(values map[ProductPropertyID]float64) (float64, error)
+	inputs(visit func(id ProductPropertyID))
+}
+
+type (
+	numberNode float64
+	inputNode  ProductPropertyID
+	unaryNode  struct {
+		operand exprNode
+	}
+	binaryNode struct {
+		op          byte
+		left, right exprNode
+	}
+	callNode struct {
+		name string
+		args []exprNode
+	}
+)
+
+func (n numberNode) eval(map[ProductPropertyID]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n numberNode) inputs(func(id ProductPropertyID)) {}
+
+func (n inputNode) eval(values map[ProductPropertyID]float64) (float64, error) {
+	v, ok := values[ProductPropertyID(n)]
+	if !ok {
+		return 0, fmt.Errorf("the value of the property %s is missing", string(n))
+	}
+	return v, nil
+}
+
+func (n inputNode) inputs(visit func(id ProductPropertyID)) {
+	visit(ProductPropertyID(n))
+}
+
+func (n *unaryNode) eval(values map[ProductPropertyID]float64) (float64, error) {
+	v, err := n.operand.eval(values)
+	return -v, err
+}
+
+func (n *unaryNode) inputs(visit func(id ProductPropertyID)) {
+	n.operand.inputs(visit)
+}
+
+func (n *binaryNode) eval(values map[ProductPropertyID]float64) (float64, error) {
+	l, err := n.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	case '%':
+		if r == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	case '^':
+		return math.Pow(l, r), nil
+	default:
+		return 0, fmt.Errorf("unsupported operator %c", n.op)
+	}
+}
+
+func (n *binaryNode) inputs(visit func(id ProductPropertyID)) {
+	n.left.inputs(visit)
+	n.right.inputs(visit)
+}
+
+// exprFuncs are the functions supported by the expression, indexed by the name and the number of arguments.
+var exprFuncs = map[string]struct {
+	arity int // -1 means variadic with at least one argument
+	fn    func(args []float64) float64
+}{
+	"abs":   {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"sqrt":  {1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"round": {1, func(args []float64) float64 { return math.Round(args[0]) }},
+	"floor": {1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"ceil":  {1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"pow":   {2, func(args []float64) float64 { return math.Pow(args[0], args[1]) }},
+	"min": {-1, func(args []float64) float64 {
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Min(v, arg)
+		}
+		return v
+	}},
+	"max": {-1, func(args []float64) float64 {
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Max(v, arg)
+		}
+		return v
+	}},
+}
+
+func (n *callNode) eval(values map[ProductPropertyID]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return exprFuncs[n.name].fn(args), nil
+}
+
+func (n *callNode) inputs(visit func(id ProductPropertyID)) {
+	for _, arg := range n.args {
+		arg.inputs(visit)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprParser is a recursive descent parser of the grammar:
+//
+//	expr    = term {("+" | "-") term}
+//	term    = unary {("*" | "/" | "%") unary}
+//	unary   = "-" unary | power
+//	power   = primary ["^" unary]
+//	primary = number | ident | ident "(" expr {"," expr} ")" | "(" expr ")"
+type exprParser struct {
+	source string
+	offset int
+	tok    token
+}
+
+func (p *exprParser) next() {
+	for p.offset < len(p.source) && unicode.IsSpace(rune(p.source[p.offset])) {
+		p.offset++
+	}
+	start := p.offset
+	if start >= len(p.source) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.source[start]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.offset < len(p.source) && (isDigit(p.source[p.offset]) || p.source[p.offset] == '.') {
+			p.offset++
+		}
+		// the exponent, e.g. 1e-3
+		if p.offset < len(p.source) && (p.source[p.offset] == 'e' || p.source[p.offset] == 'E') {
+			p.offset++
+			if p.offset < len(p.source) && (p.source[p.offset] == '+' || p.source[p.offset] == '-') {
+				p.offset++
+			}
+			for p.offset < len(p.source) && isDigit(p.source[p.offset]) {
+				p.offset++
+			}
+		}
+		p.tok = token{kind: tokenNumber, text: p.source[start:p.offset], pos: start}
+	case isIdentStart(c):
+		for p.offset < len(p.source) && (isIdentStart(p.source[p.offset]) || isDigit(p.source[p.offset])) {
+			p.offset++
+		}
+		p.tok = token{kind: tokenIdent, text: p.source[start:p.offset], pos: start}
+	default:
+		p.offset++
+		p.tok = token{kind: tokenOperator, text: p.source[start:p.offset], pos: start}
+	}
+}
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("+", "-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("*", "/", "%") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOperator("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operand: operand}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *exprParser) parsePower() (exprNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator("^") {
+		return base, nil
+	}
+	p.next()
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{op: '^', left: base, right: exponent}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", tok.text, tok.pos)
+		}
+		p.next()
+		return numberNode(v), nil
+	case tokenIdent:
+		p.next()
+		if !p.isOperator("(") {
+			return inputNode(tok.text), nil
+		}
+		return p.parseCall(tok)
+	case tokenOperator:
+		if tok.text != "(" {
+			break
+		}
+		p.next()
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, fmt.Errorf("')' is expected at %d", p.tok.pos)
+		}
+		p.next()
+		return node, nil
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at %d", tok.text, tok.pos)
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	f, ok := exprFuncs[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function '%s' at %d", name.text, name.pos)
+	}
+	p.next() // skip "("
+	call := &callNode{name: strings.ToLower(name.text)}
+	for !p.isOperator(")") {
+		if len(call.args) > 0 {
+			if !p.isOperator(",") {
+				return nil, fmt.Errorf("',' or ')' is expected at %d", p.tok.pos)
+			}
+			p.next()
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.next() // skip ")"
+	if (f.arity < 0 && len(call.args) == 0) || (f.arity >= 0 && len(call.args) != f.arity) {
+		return nil, fmt.Errorf("wrong number of arguments of the function '%s' at %d", name.text, name.pos)
+	}
+	return call, nil
+}
+
+func (p *exprParser) isOperator(ops ...string) bool {
+	if p.tok.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
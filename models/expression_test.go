@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpression_Eval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"voltage * current", 22},
+		{"voltage - current * 2", 7},
+		{"(voltage - current) * 2", 18},
+		{"-current ^ 2", -4},
+		{"max(voltage, current, 3) + abs(-1.5e1)", 26},
+		{"voltage % 4 / 2", 1.5},
+	}
+	values := map[ProductPropertyID]float64{"voltage": 11, "current": 2}
+	for _, tt := range tests {
+		expr, err := ParseExpression(tt.source)
+		if err != nil {
+			t.Fatalf("ParseExpression(%s) error = %v", tt.source, err)
+		}
+		got, err := expr.Eval(values)
+		if err != nil {
+			t.Fatalf("Eval(%s) error = %v", tt.source, err)
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%s) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestParseExpression_Invalid(t *testing.T) {
+	for _, source := range []string{"", "voltage *", "(voltage", "pow(voltage)", "unknown(voltage)", "voltage current"} {
+		if _, err := ParseExpression(source); err == nil {
+			t.Errorf("ParseExpression(%s) error = nil, want an error", source)
+		}
+	}
+}
+
+func TestProduct_ValidateComputedProperties(t *testing.T) {
+	product := &Product{ID: "meter", Properties: []*ProductProperty{
+		{Id: "voltage", FieldType: PropertyValueTypeFloat},
+		{Id: "current", FieldType: PropertyValueTypeFloat},
+		{Id: "power", FieldType: PropertyValueTypeFloat, Expression: "voltage * current"},
+		{Id: "energy", FieldType: PropertyValueTypeFloat, Expression: "power / 1000"},
+	}}
+	if err := product.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	product.Properties[2].Expression = "energy * 1000"
+	err := product.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cyclic") {
+		t.Errorf("Validate() error = %v, want a cyclic dependency", err)
+	}
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type LabelOperator = string
+
+const (
+	LabelOperatorEquals       LabelOperator = "="
+	LabelOperatorNotEquals    LabelOperator = "!="
+	LabelOperatorIn           LabelOperator = "in"
+	LabelOperatorNotIn        LabelOperator = "notin"
+	LabelOperatorExists       LabelOperator = "exists"
+	LabelOperatorDoesNotExist LabelOperator = "!"
+)
+
+var (
+	labelPattern    = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_./-]*[A-Za-z0-9])?$`)
+	labelSetPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// LabelRequirement is a requirement on a label of devices.
+type LabelRequirement struct {
+	Key      string        `json:"key"`
+	Operator LabelOperator `json:"operator"`
+	Values   []string      `json:"values,omitempty"`
+}
+
+// Matches returns whether the labels meet the requirement. Like the negation, the != and notin
+// are met by the labels without the key.
+func (r *LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case LabelOperatorEquals:
+		return ok && value == r.Values[0]
+	case LabelOperatorNotEquals:
+		return !ok || value != r.Values[0]
+	case LabelOperatorIn:
+		return ok && r.contains(value)
+	case LabelOperatorNotIn:
+		return !ok || !r.contains(value)
+	case LabelOperatorExists:
+		return ok
+	case LabelOperatorDoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func (r *LabelRequirement) contains(value string) bool {
+	for _, v := range r.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *LabelRequirement) String() string {
+	switch r.Operator {
+	case LabelOperatorEquals, LabelOperatorNotEquals:
+		return r.Key + r.Operator + r.Values[0]
+	case LabelOperatorIn, LabelOperatorNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case LabelOperatorDoesNotExist:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+// LabelSelector selects the devices by their labels, it matches the labels meeting all its requirements.
+type LabelSelector struct {
+	Requirements []*LabelRequirement `json:"requirements"`
+}
+
+// ParseLabelSelector parses the selector formed by the requirements separated by commas, e.g.
+// "env=prod,tier!=db,region in (east,west),zone notin (z1),gpu,!deprecated".
+// The requirements are equality (= or ==), inequality (!=), set-based (in and notin),
+// existence (key) and negation (!key). The empty selector matches all devices.
+func ParseLabelSelector(selector string) (*LabelSelector, error) {
+	s := &LabelSelector{Requirements: make([]*LabelRequirement, 0)}
+	parts, err := splitLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		r, err := parseLabelRequirement(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector '%s': %s", selector, err.Error())
+		}
+		s.Requirements = append(s.Requirements, r)
+	}
+	return s, nil
+}
+
+// Matches returns whether the labels meet all the requirements of the selector.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.Requirements {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *LabelSelector) String() string {
+	requirements := make([]string, len(s.Requirements))
+	for i, r := range s.Requirements {
+		requirements[i] = r.String()
+	}
+	return strings.Join(requirements, ",")
+}
+
+// splitLabelSelector splits the selector by the commas which are not in the parentheses.
+func splitLabelSelector(selector string) ([]string, error) {
+	var parts []string
+	var depth, start int
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("invalid label selector '%s': unexpected ')' at %d", selector, i)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid label selector '%s': ')' is expected", selector)
+	}
+	if last := selector[start:]; strings.TrimSpace(last) != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts, nil
+}
+
+func parseLabelRequirement(requirement string) (*LabelRequirement, error) {
+	requirement = strings.TrimSpace(requirement)
+	r := &LabelRequirement{}
+	switch {
+	case requirement == "":
+		return nil, fmt.Errorf("empty requirement")
+	case strings.HasPrefix(requirement, "!") && !strings.Contains(requirement, "="):
+		r.Key, r.Operator = strings.TrimSpace(requirement[1:]), LabelOperatorDoesNotExist
+	case strings.Contains(requirement, "!="):
+		kv := strings.SplitN(requirement, "!=", 2)
+		r.Key, r.Operator, r.Values = strings.TrimSpace(kv[0]), LabelOperatorNotEquals, []string{strings.TrimSpace(kv[1])}
+	case strings.Contains(requirement, "="):
+		kv := strings.SplitN(strings.Replace(requirement, "==", "=", 1), "=", 2)
+		r.Key, r.Operator, r.Values = strings.TrimSpace(kv[0]), LabelOperatorEquals, []string{strings.TrimSpace(kv[1])}
+	default:
+		if m := labelSetPattern.FindStringSubmatch(requirement); m != nil {
+			r.Key, r.Operator = m[1], m[2]
+			for _, v := range strings.Split(m[3], ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					r.Values = append(r.Values, v)
+				}
+			}
+			if len(r.Values) == 0 {
+				return nil, fmt.Errorf("the values of the requirement '%s' are required", requirement)
+			}
+			sort.Strings(r.Values)
+		} else {
+			r.Key, r.Operator = requirement, LabelOperatorExists
+		}
+	}
+
+	if !labelPattern.MatchString(r.Key) {
+		return nil, fmt.Errorf("invalid key '%s' of the requirement '%s'", r.Key, requirement)
+	}
+	for _, v := range r.Values {
+		if v != "" && !labelPattern.MatchString(v) {
+			return nil, fmt.Errorf("invalid value '%s' of the requirement '%s'", v, requirement)
+		}
+	}
+	return r, nil
+}
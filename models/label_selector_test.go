@@ -0,0 +1,57 @@
+package models
+
+import "testing"
+
+func TestParseLabelSelector(t *testing.T) {
+	s, err := ParseLabelSelector("env==prod, tier!=db,region in (west, east),zone notin (z1),gpu,!deprecated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); got != "env=prod,tier!=db,region in (east,west),zone notin (z1),gpu,!deprecated" {
+		t.Errorf("unexpected selector parsed: %s", got)
+	}
+
+	cases := []struct {
+		labels  map[string]string
+		matches bool
+	}{
+		{map[string]string{"env": "prod", "region": "east", "gpu": ""}, true},
+		{map[string]string{"env": "prod", "region": "west", "gpu": "a100", "tier": "web", "zone": "z2"}, true},
+		{map[string]string{"env": "dev", "region": "east", "gpu": ""}, false},
+		{map[string]string{"env": "prod", "region": "east", "gpu": "", "tier": "db"}, false},
+		{map[string]string{"env": "prod", "region": "north", "gpu": ""}, false},
+		{map[string]string{"env": "prod", "region": "east", "gpu": "", "zone": "z1"}, false},
+		{map[string]string{"env": "prod", "region": "east"}, false},
+		{map[string]string{"env": "prod", "region": "east", "gpu": "", "deprecated": "true"}, false},
+		{nil, false},
+	}
+	for i, c := range cases {
+		if got := s.Matches(c.labels); got != c.matches {
+			t.Errorf("case %d: the selector should match %v, got %v", i, c.matches, got)
+		}
+	}
+
+	empty, err := ParseLabelSelector(" ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty.Matches(nil) {
+		t.Errorf("the empty selector should match all labels")
+	}
+}
+
+func TestParseLabelSelectorInvalid(t *testing.T) {
+	for _, selector := range []string{
+		"env=prod,",
+		"region in (east",
+		"region in ()",
+		"region) in (east",
+		"-env=prod",
+		"env=pr od",
+		"!",
+	} {
+		if _, err := ParseLabelSelector(selector); err == nil {
+			t.Errorf("the selector '%s' should be invalid", selector)
+		}
+	}
+}
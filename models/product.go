@@ -1,5 +1,11 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type (
 	ProductFuncID     = string        // product functionality ID
 	ProductPropertyID = ProductFuncID // product property's functionality ID
@@ -12,15 +18,91 @@ const (
 )
 
 type Product struct {
-	ID         string             `json:"id"`                    // 产品 ID
-	Name       string             `json:"name"`                  // 产品名称
-	Desc       string             `json:"desc"`                  // 产品描述
-	Protocol   string             `json:"protocol"`              // 产品协议
-	DataFormat string             `json:"data_format,omitempty"` // 数据格式
-	Properties []*ProductProperty `json:"properties,omitempty"`  // 属性功能列表
-	Events     []*ProductEvent    `json:"events,omitempty"`      // 事件功能列表
-	Methods    []*ProductMethod   `json:"methods,omitempty"`     // 方法功能列表
-	Topics     []*ProductTopic    `json:"topics,omitempty"`      // 各功能对应的消息主题
+	ID           string                `json:"id"`                     // 产品 ID
+	Name         string                `json:"name"`                   // 产品名称
+	Desc         string                `json:"desc"`                   // 产品描述
+	Protocol     string                `json:"protocol"`               // 产品协议
+	DataFormat   string                `json:"data_format,omitempty"`  // 数据格式
+	Properties   []*ProductProperty    `json:"properties,omitempty"`   // 属性功能列表
+	Events       []*ProductEvent       `json:"events,omitempty"`       // 事件功能列表
+	Methods      []*ProductMethod      `json:"methods,omitempty"`      // 方法功能列表
+	Topics       []*ProductTopic       `json:"topics,omitempty"`       // 各功能对应的消息主题
+	Alarms       []*ProductAlarm       `json:"alarms,omitempty"`       // 属性告警列表
+	Aggregations []*ProductAggregation `json:"aggregations,omitempty"` // 属性聚合列表
+	Bases        []string              `json:"bases,omitempty"`        // 继承的基础产品或混入模板的 ID 列表, 按顺序合并
+	Abstract     bool                  `json:"abstract,omitempty"`     // 是否为仅用于继承的模板, 不会下发至驱动
+	Version      uint64                `json:"version,omitempty"`      // 产品版本, 每次修改单调递增
+	ChangedAt    *time.Time            `json:"changed_at,omitempty"`   // 产品最近修改时间
+}
+
+// Validate checks whether the product is well-defined. The functions inherited from the bases are
+// not checked, so the product with bases should be validated after resolved, see ProductResolver,
+// or validated with its bases by ValidateProducts.
+func (p *Product) Validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("the id of the product is required")
+	}
+	if err := p.validateBases(); err != nil {
+		return err
+	}
+	properties := make(map[ProductPropertyID]*ProductProperty, len(p.Properties))
+	for _, property := range p.Properties {
+		if property.Id == "" {
+			return fmt.Errorf("the id of the property of the product %s is required", p.ID)
+		}
+		if _, ok := properties[property.Id]; ok {
+			return fmt.Errorf("duplicated property %s of the product %s", property.Id, p.ID)
+		}
+		properties[property.Id] = property
+	}
+	if err := validateComputedProperties(p.ID, properties); err != nil {
+		return err
+	}
+	topics := make(map[string]bool, len(p.Topics))
+	for _, topic := range p.Topics {
+		if topics[topic.OptType] {
+			return fmt.Errorf("duplicated topic of the operation type %s of the product %s", topic.OptType, p.ID)
+		}
+		topics[topic.OptType] = true
+		if err := topic.validate(); err != nil {
+			return fmt.Errorf("invalid topic of the product %s: %s", p.ID, err.Error())
+		}
+	}
+	alarms := make(map[string]bool, len(p.Alarms))
+	for _, alarm := range p.Alarms {
+		if alarms[alarm.Id] {
+			return fmt.Errorf("duplicated alarm %s of the product %s", alarm.Id, p.ID)
+		}
+		alarms[alarm.Id] = true
+		if err := alarm.validate(properties); err != nil {
+			return fmt.Errorf("invalid alarm of the product %s: %s", p.ID, err.Error())
+		}
+	}
+	aggregations := make(map[string]bool, len(p.Aggregations))
+	for _, aggregation := range p.Aggregations {
+		if aggregations[aggregation.Id] {
+			return fmt.Errorf("duplicated aggregation %s of the product %s", aggregation.Id, p.ID)
+		}
+		aggregations[aggregation.Id] = true
+		if err := aggregation.validate(properties); err != nil {
+			return fmt.Errorf("invalid aggregation of the product %s: %s", p.ID, err.Error())
+		}
+	}
+	return nil
+}
+
+func (p *Product) validateBases() error {
+	bases := make(map[string]bool, len(p.Bases))
+	for _, base := range p.Bases {
+		if base == "" || base == p.ID {
+			return fmt.Errorf("invalid base '%s' of the product %s", base, p.ID)
+		}
+		if bases[base] {
+			return fmt.Errorf("duplicated base %s of the product %s", base, p.ID)
+		}
+		bases[base] = true
+	}
+	return nil
 }
 
 type ProductProperty struct {
@@ -33,6 +115,14 @@ type ProductProperty struct {
 	ReportMode string            `json:"report_mode"`
 	Writeable  bool              `json:"writeable"`
 	AuxProps   map[string]string `json:"aux_props"`
+	// Expression declares a computed property evaluated over other properties of the same device,
+	// e.g. "voltage * current", see Expression for the syntax.
+	Expression string `json:"expression,omitempty"`
+}
+
+// Computed returns whether the property is computed by the Expression instead of being read from the device.
+func (p *ProductProperty) Computed() bool {
+	return p.Expression != ""
 }
 
 type ProductEvent struct {
@@ -52,6 +142,11 @@ type ProductMethod struct {
 	AuxProps map[string]string `json:"aux_props"`
 }
 
+// ProductTopic declares the template of the custom topic of the operation type, e.g. the topic
+// "legacy/meters/{device_id}/{func_id}" of the operation type PROPS, see ProductTopicPlaceholderDeviceID
+// and ProductTopicPlaceholderFuncID for the placeholders. The operations of all functions share the topic
+// without the placeholder of the func ID, and are told apart by their payloads, so the placeholder is
+// required by the topic of the events.
 type ProductTopic struct {
 	Topic   string `json:"topic"`
 	OptType string `json:"opt_type"`
@@ -64,3 +159,64 @@ type ProductField struct {
 	FieldType string `json:"field_type"`
 	Desc      string `json:"desc"`
 }
+
+// validateComputedProperties checks the expressions of the computed properties,
+// and makes sure that there is no cycle among their dependencies.
+func validateComputedProperties(productID string, properties map[ProductPropertyID]*ProductProperty) error {
+	inputs := make(map[ProductPropertyID][]ProductPropertyID)
+	for id, property := range properties {
+		if !property.Computed() {
+			continue
+		}
+		switch property.FieldType {
+		case PropertyValueTypeInt, PropertyValueTypeUint, PropertyValueTypeFloat:
+		default:
+			return fmt.Errorf("the computed property %s of the product %s should be a number", id, productID)
+		}
+		if property.Writeable {
+			return fmt.Errorf("the computed property %s of the product %s should not be writeable", id, productID)
+		}
+		expr, err := ParseExpression(property.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid computed property %s of the product %s: %s", id, productID, err.Error())
+		}
+		for _, input := range expr.Inputs() {
+			if _, ok := properties[input]; !ok {
+				return fmt.Errorf("the input %s of the computed property %s of the product %s is undefined",
+					input, id, productID)
+			}
+		}
+		inputs[id] = expr.Inputs()
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[ProductPropertyID]int)
+	var visit func(id ProductPropertyID, path []ProductPropertyID) error
+	visit = func(id ProductPropertyID, path []ProductPropertyID) error {
+		switch marks[id] {
+		case visiting:
+			return fmt.Errorf("cyclic dependency among the computed properties of the product %s: %s",
+				productID, strings.Join(append(path, id), " -> "))
+		case visited:
+			return nil
+		}
+		marks[id] = visiting
+		for _, input := range inputs[id] {
+			if err := visit(input, append(path, id)); err != nil {
+				return err
+			}
+		}
+		marks[id] = visited
+		return nil
+	}
+	for id := range inputs {
+		if err := visit(id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+type (
+	ProductChangeType = string // the type of the change of a function of the product
+	ProductFuncKind   = string // the kind of the function of the product
+)
+
+const (
+	ProductChangeTypeAdded    ProductChangeType = "added"
+	ProductChangeTypeRemoved  ProductChangeType = "removed"
+	ProductChangeTypeModified ProductChangeType = "modified"
+
+	ProductFuncKindProduct  ProductFuncKind = "product" // the fields of the product itself, e.g. the protocol
+	ProductFuncKindProperty ProductFuncKind = "property"
+	ProductFuncKindEvent    ProductFuncKind = "event"
+	ProductFuncKindMethod   ProductFuncKind = "method"
+)
+
+// ProductChange is a change of a function of the product.
+//
+// A change is breaking if the twins of the devices have to be rebuilt to apply it, or the clients
+// depending on the previous revision may fail, e.g. a removed property, or a changed type of a property.
+// The changes of the names and the descriptions, the added properties, events, methods
+// and outputs of methods, and the changed alarms and aggregations are non-breaking.
+type ProductChange struct {
+	Kind     ProductFuncKind   `json:"kind"`
+	FuncID   ProductFuncID     `json:"func_id,omitempty"`
+	Type     ProductChangeType `json:"type"`
+	Fields   []string          `json:"fields,omitempty"` // the modified fields, e.g. field_type, outs.current
+	Breaking bool              `json:"breaking"`
+}
+
+func (c *ProductChange) String() string {
+	if c.FuncID == "" {
+		return fmt.Sprintf("%s %s %v", c.Type, c.Kind, c.Fields)
+	}
+	return fmt.Sprintf("%s %s %s %v", c.Type, c.Kind, c.FuncID, c.Fields)
+}
+
+// ProductDiff is the structural difference between two revisions of a product.
+type ProductDiff struct {
+	ProductID   string           `json:"product_id"`
+	FromVersion uint64           `json:"from_version"`
+	ToVersion   uint64           `json:"to_version"`
+	Changes     []*ProductChange `json:"changes"`
+}
+
+// Empty returns whether there is no change.
+func (d *ProductDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Breaking returns whether any change is breaking, i.e. the twins of the devices have to be rebuilt.
+func (d *ProductDiff) Breaking() bool {
+	for _, change := range d.Changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed returns whether the functions of the kind are changed.
+func (d *ProductDiff) Changed(kind ProductFuncKind) bool {
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Revise sets the revision of the product as the next one of the previous revision, which may be nil.
+func (p *Product) Revise(previous *Product, now time.Time) {
+	p.Version = 1
+	if previous != nil {
+		p.Version = previous.Version + 1
+	}
+	p.ChangedAt = &now
+}
+
+// DiffProducts compares the next revision of the product with the previous one,
+// the revision of the next one should not be older than the previous one.
+func DiffProducts(previous, next *Product) (*ProductDiff, error) {
+	if previous.ID != next.ID {
+		return nil, fmt.Errorf("the products %s and %s are different", previous.ID, next.ID)
+	}
+	if next.Version < previous.Version {
+		return nil, fmt.Errorf("the revision %d of the product %s is older than the revision %d",
+			next.Version, next.ID, previous.Version)
+	}
+
+	d := &ProductDiff{ProductID: next.ID, FromVersion: previous.Version, ToVersion: next.Version}
+	d.diffProduct(previous, next)
+	d.diffProperties(previous.Properties, next.Properties)
+	d.diffEvents(previous.Events, next.Events)
+	d.diffMethods(previous.Methods, next.Methods)
+	return d, nil
+}
+
+func (d *ProductDiff) add(change *ProductChange) {
+	d.Changes = append(d.Changes, change)
+}
+
+func (d *ProductDiff) diffProduct(previous, next *Product) {
+	f := &fieldsDiff{}
+	f.compare("name", previous.Name, next.Name, false)
+	f.compare("desc", previous.Desc, next.Desc, false)
+	f.compare("protocol", previous.Protocol, next.Protocol, true)
+	f.compare("data_format", previous.DataFormat, next.DataFormat, true)
+	f.compare("topics", previous.Topics, next.Topics, true)
+	f.compare("alarms", previous.Alarms, next.Alarms, false)
+	f.compare("aggregations", previous.Aggregations, next.Aggregations, false)
+	if len(f.fields) > 0 {
+		d.add(&ProductChange{Kind: ProductFuncKindProduct, Type: ProductChangeTypeModified,
+			Fields: f.fields, Breaking: f.breaking})
+	}
+}
+
+func (d *ProductDiff) diffProperties(previous, next []*ProductProperty) {
+	prev := make(map[ProductPropertyID]*ProductProperty, len(previous))
+	for _, property := range previous {
+		prev[property.Id] = property
+	}
+	seen := make(map[ProductPropertyID]bool, len(next))
+	for _, property := range next {
+		seen[property.Id] = true
+		p, ok := prev[property.Id]
+		if !ok {
+			d.add(&ProductChange{Kind: ProductFuncKindProperty, FuncID: property.Id, Type: ProductChangeTypeAdded})
+			continue
+		}
+		f := &fieldsDiff{}
+		f.compare("name", p.Name, property.Name, false)
+		f.compare("desc", p.Desc, property.Desc, false)
+		f.compare("interval", p.Interval, property.Interval, true)
+		f.compare("unit", p.Unit, property.Unit, true)
+		f.compare("field_type", p.FieldType, property.FieldType, true)
+		f.compare("report_mode", p.ReportMode, property.ReportMode, true)
+		f.compare("writeable", p.Writeable, property.Writeable, true)
+		f.compare("aux_props", p.AuxProps, property.AuxProps, true)
+		f.compare("expression", p.Expression, property.Expression, true)
+		d.addModified(ProductFuncKindProperty, property.Id, f)
+	}
+	for _, property := range previous {
+		if !seen[property.Id] {
+			d.add(&ProductChange{Kind: ProductFuncKindProperty, FuncID: property.Id,
+				Type: ProductChangeTypeRemoved, Breaking: true})
+		}
+	}
+}
+
+func (d *ProductDiff) diffEvents(previous, next []*ProductEvent) {
+	prev := make(map[ProductEventID]*ProductEvent, len(previous))
+	for _, event := range previous {
+		prev[event.Id] = event
+	}
+	seen := make(map[ProductEventID]bool, len(next))
+	for _, event := range next {
+		seen[event.Id] = true
+		e, ok := prev[event.Id]
+		if !ok {
+			d.add(&ProductChange{Kind: ProductFuncKindEvent, FuncID: event.Id, Type: ProductChangeTypeAdded})
+			continue
+		}
+		f := &fieldsDiff{}
+		f.compare("name", e.Name, event.Name, false)
+		f.compare("desc", e.Desc, event.Desc, false)
+		f.compareFields("outs", e.Outs, event.Outs, false)
+		f.compare("aux_props", e.AuxProps, event.AuxProps, true)
+		d.addModified(ProductFuncKindEvent, event.Id, f)
+	}
+	for _, event := range previous {
+		if !seen[event.Id] {
+			d.add(&ProductChange{Kind: ProductFuncKindEvent, FuncID: event.Id,
+				Type: ProductChangeTypeRemoved, Breaking: true})
+		}
+	}
+}
+
+func (d *ProductDiff) diffMethods(previous, next []*ProductMethod) {
+	prev := make(map[ProductMethodID]*ProductMethod, len(previous))
+	for _, method := range previous {
+		prev[method.Id] = method
+	}
+	seen := make(map[ProductMethodID]bool, len(next))
+	for _, method := range next {
+		seen[method.Id] = true
+		m, ok := prev[method.Id]
+		if !ok {
+			d.add(&ProductChange{Kind: ProductFuncKindMethod, FuncID: method.Id, Type: ProductChangeTypeAdded})
+			continue
+		}
+		f := &fieldsDiff{}
+		f.compare("name", m.Name, method.Name, false)
+		f.compare("desc", m.Desc, method.Desc, false)
+		f.compareFields("ins", m.Ins, method.Ins, true)
+		f.compareFields("outs", m.Outs, method.Outs, false)
+		f.compare("aux_props", m.AuxProps, method.AuxProps, true)
+		d.addModified(ProductFuncKindMethod, method.Id, f)
+	}
+	for _, method := range previous {
+		if !seen[method.Id] {
+			d.add(&ProductChange{Kind: ProductFuncKindMethod, FuncID: method.Id,
+				Type: ProductChangeTypeRemoved, Breaking: true})
+		}
+	}
+}
+
+func (d *ProductDiff) addModified(kind ProductFuncKind, funcID ProductFuncID, f *fieldsDiff) {
+	if len(f.fields) == 0 {
+		return
+	}
+	d.add(&ProductChange{Kind: kind, FuncID: funcID, Type: ProductChangeTypeModified,
+		Fields: f.fields, Breaking: f.breaking})
+}
+
+// fieldsDiff collects the modified fields of a function.
+type fieldsDiff struct {
+	fields   []string
+	breaking bool
+}
+
+func (f *fieldsDiff) compare(name string, previous, next interface{}, breaking bool) {
+	if reflect.DeepEqual(previous, next) || (isEmpty(previous) && isEmpty(next)) {
+		return
+	}
+	f.fields = append(f.fields, name)
+	f.breaking = f.breaking || breaking
+}
+
+// compareFields compares the inputs or outputs, the removed and the retyped fields are always breaking,
+// and the added fields are breaking if addBreaking, e.g. the required inputs of methods.
+func (f *fieldsDiff) compareFields(name string, previous, next []*ProductField, addBreaking bool) {
+	prev := make(map[string]*ProductField, len(previous))
+	for _, field := range previous {
+		prev[field.Id] = field
+	}
+	var changed []string
+	seen := make(map[string]bool, len(next))
+	for _, field := range next {
+		seen[field.Id] = true
+		p, ok := prev[field.Id]
+		switch {
+		case !ok:
+			changed = append(changed, field.Id)
+			f.breaking = f.breaking || addBreaking
+		case p.FieldType != field.FieldType:
+			changed = append(changed, field.Id)
+			f.breaking = true
+		case p.Name != field.Name || p.Desc != field.Desc:
+			changed = append(changed, field.Id)
+		}
+	}
+	for _, field := range previous {
+		if !seen[field.Id] {
+			changed = append(changed, field.Id)
+			f.breaking = true
+		}
+	}
+	sort.Strings(changed)
+	for _, id := range changed {
+		f.fields = append(f.fields, name+"."+id)
+	}
+}
+
+// isEmpty returns whether the value is a nil or an empty map or slice, they are not different in JSON.
+func isEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRevision() *Product {
+	return &Product{
+		ID:       "meter",
+		Name:     "Meter",
+		Protocol: "modbus",
+		Properties: []*ProductProperty{
+			{Id: "voltage", Name: "Voltage", FieldType: PropertyValueTypeFloat, Unit: "V"},
+			{Id: "current", Name: "Current", FieldType: PropertyValueTypeFloat, Unit: "A"},
+		},
+		Events: []*ProductEvent{
+			{Id: "overload", Name: "Overload", Outs: []*ProductField{{Id: "current", FieldType: PropertyValueTypeFloat}}},
+		},
+		Methods: []*ProductMethod{
+			{Id: "reset", Name: "Reset", Ins: []*ProductField{{Id: "delay", FieldType: PropertyValueTypeInt}}},
+		},
+	}
+}
+
+func TestProductRevise(t *testing.T) {
+	now := time.Now()
+	first := testRevision()
+	first.Revise(nil, now)
+	if first.Version != 1 || first.ChangedAt == nil || !first.ChangedAt.Equal(now) {
+		t.Errorf("the first revision should be 1, got %d", first.Version)
+	}
+	second := testRevision()
+	second.Revise(first, now.Add(time.Minute))
+	if second.Version != 2 {
+		t.Errorf("the second revision should be 2, got %d", second.Version)
+	}
+	if _, err := DiffProducts(second, first); err == nil {
+		t.Errorf("the older revision should be rejected")
+	}
+	if data, _ := json.Marshal(testRevision()); strings.Contains(string(data), "changed_at") {
+		t.Errorf("the product never revised shouldn't carry the changed_at: %s", data)
+	}
+}
+
+func TestDiffProducts(t *testing.T) {
+	cases := []struct {
+		name     string
+		mutate   func(p *Product)
+		changes  []string
+		breaking bool
+	}{
+		{"unchanged", func(p *Product) {}, nil, false},
+		{"description", func(p *Product) {
+			p.Desc = "power meter"
+			p.Properties[0].Desc = "the voltage"
+			p.Properties[1].AuxProps = map[string]string{}
+		}, []string{"modified product [desc]", "modified property voltage [desc]"}, false},
+		{"added", func(p *Product) {
+			p.Properties = append(p.Properties, &ProductProperty{Id: "power", FieldType: PropertyValueTypeFloat})
+			p.Methods[0].Outs = []*ProductField{{Id: "ok", FieldType: PropertyValueTypeBool}}
+		}, []string{"added property power []", "modified method reset [outs.ok]"}, false},
+		{"alarms", func(p *Product) {
+			high := 250.0
+			p.Alarms = []*ProductAlarm{{Id: "high", PropertyID: "voltage", High: &high}}
+		}, []string{"modified product [alarms]"}, false},
+		{"retyped", func(p *Product) {
+			p.Properties[1].FieldType = PropertyValueTypeInt
+		}, []string{"modified property current [field_type]"}, true},
+		{"removed", func(p *Product) {
+			p.Properties = p.Properties[:1]
+			p.Events = nil
+		}, []string{"removed property current []", "removed event overload []"}, true},
+		{"inputs", func(p *Product) {
+			p.Methods[0].Ins = append(p.Methods[0].Ins, &ProductField{Id: "force", FieldType: PropertyValueTypeBool})
+		}, []string{"modified method reset [ins.force]"}, true},
+		{"outputs", func(p *Product) {
+			p.Events[0].Outs[0].Desc = "in ampere"
+		}, []string{"modified event overload [outs.current]"}, false},
+		{"topics", func(p *Product) {
+			p.Topics = []*ProductTopic{{Topic: "meters/{device_id}/props", OptType: "PROPS"}}
+		}, []string{"modified product [topics]"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			next := testRevision()
+			c.mutate(next)
+			diff, err := DiffProducts(testRevision(), next)
+			if err != nil {
+				t.Fatalf("fail to diff the products: %s", err.Error())
+			}
+			if len(diff.Changes) != len(c.changes) {
+				t.Fatalf("expect the changes %v, got %v", c.changes, diff.Changes)
+			}
+			for i, change := range diff.Changes {
+				if change.String() != c.changes[i] {
+					t.Errorf("expect the change %s, got %s", c.changes[i], change)
+				}
+			}
+			if diff.Breaking() != c.breaking {
+				t.Errorf("expect breaking %v, got %v", c.breaking, diff.Breaking())
+			}
+		})
+	}
+}
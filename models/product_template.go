@@ -0,0 +1,314 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ResolveProducts flattens the products with bases and drops the abstract ones, e.g. before sending them
+// in the initialization of the driver. The products without bases are returned as they are.
+func ResolveProducts(products []*Product) ([]*Product, error) {
+	r, err := NewProductResolver(products)
+	if err != nil {
+		return nil, err
+	}
+	return r.ResolveAll()
+}
+
+// ValidateProducts checks whether the products are well-defined, including the abstract ones,
+// and whether the bases of them can be merged without conflicts, see ProductResolver.
+func ValidateProducts(products []*Product) error {
+	r, err := NewProductResolver(products)
+	if err != nil {
+		return err
+	}
+	for _, id := range r.order {
+		if _, err = r.Resolve(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewProductResolver returns a ProductResolver of the products, which should include all the bases.
+func NewProductResolver(products []*Product) (*ProductResolver, error) {
+	r := &ProductResolver{
+		products: make(map[string]*Product, len(products)),
+		order:    make([]string, 0, len(products)),
+		resolved: make(map[string]*Product, len(products)),
+	}
+	for _, product := range products {
+		if _, ok := r.products[product.ID]; ok {
+			return nil, fmt.Errorf("duplicated product %s", product.ID)
+		}
+		r.products[product.ID] = product
+		r.order = append(r.order, product.ID)
+	}
+	return r, nil
+}
+
+// ProductResolver flattens the products by merging the properties, events, methods and topics of their bases.
+//
+// The bases are merged in order, and then the product itself. The functions are identified by their IDs,
+// and the topics are identified by their operation types. A function defined by the product overrides
+// the one inherited, but it can't change the field type of an inherited property. It's a conflict
+// if a function is inherited from several bases with different definitions, unless the product overrides it.
+// The bases should be of the same protocol as the product, or of no protocol, e.g. mixins.
+type ProductResolver struct {
+	products map[string]*Product
+	order    []string
+	resolved map[string]*Product
+}
+
+// ResolveAll returns all the flattened products which are not abstract, in the order of the products.
+func (r *ProductResolver) ResolveAll() ([]*Product, error) {
+	products := make([]*Product, 0, len(r.order))
+	for _, id := range r.order {
+		if r.products[id].Abstract {
+			continue
+		}
+		product, err := r.Resolve(id)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+// Derived returns the IDs of the products inheriting the base directly or indirectly, in the order of the products.
+func (r *ProductResolver) Derived(baseID string) []string {
+	derived := make([]string, 0)
+	for _, id := range r.order {
+		if r.inherits(id, baseID, make(map[string]bool)) {
+			derived = append(derived, id)
+		}
+	}
+	return derived
+}
+
+func (r *ProductResolver) inherits(productID, baseID string, visited map[string]bool) bool {
+	if visited[productID] {
+		return false
+	}
+	visited[productID] = true
+	product, ok := r.products[productID]
+	if !ok {
+		return false
+	}
+	for _, id := range product.Bases {
+		if id == baseID || r.inherits(id, baseID, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the flattened product, which has no bases and has been validated.
+func (r *ProductResolver) Resolve(productID string) (*Product, error) {
+	return r.resolve(productID, nil)
+}
+
+func (r *ProductResolver) resolve(productID string, path []string) (*Product, error) {
+	if product, ok := r.resolved[productID]; ok {
+		return product, nil
+	}
+	for _, id := range path {
+		if id == productID {
+			return nil, fmt.Errorf("cyclic bases of the products: %s", strings.Join(append(path, productID), " -> "))
+		}
+	}
+	product, ok := r.products[productID]
+	if !ok {
+		if len(path) == 0 {
+			return nil, fmt.Errorf("the product %s is not found", productID)
+		}
+		return nil, fmt.Errorf("the base %s of the product %s is not found", productID, path[len(path)-1])
+	}
+	if len(product.Bases) == 0 {
+		if err := product.Validate(); err != nil {
+			return nil, err
+		}
+		r.resolved[productID] = product
+		return product, nil
+	}
+	if err := product.validateBases(); err != nil {
+		return nil, err
+	}
+
+	bases := make([]*Product, len(product.Bases))
+	for i, id := range product.Bases {
+		base, err := r.resolve(id, append(path, productID))
+		if err != nil {
+			return nil, err
+		}
+		if base.Protocol != "" && product.Protocol != "" && base.Protocol != product.Protocol {
+			return nil, fmt.Errorf("the protocol %s of the base %s is different from the protocol %s of the product %s",
+				base.Protocol, id, product.Protocol, productID)
+		}
+		bases[i] = base
+	}
+
+	flattened, err := flatten(product, bases)
+	if err != nil {
+		return nil, err
+	}
+	if err = flattened.Validate(); err != nil {
+		return nil, err
+	}
+	r.resolved[productID] = flattened
+	return flattened, nil
+}
+
+func flatten(product *Product, bases []*Product) (*Product, error) {
+	flattened := *product
+	flattened.Bases = nil
+	flattened.Abstract = false
+
+	m := &merger{productID: product.ID, bases: product.Bases}
+	properties := make([][]namedFunc, len(bases))
+	events := make([][]namedFunc, len(bases))
+	methods := make([][]namedFunc, len(bases))
+	topics := make([][]namedFunc, len(bases))
+	for i, base := range bases {
+		properties[i] = namedProperties(base.Properties)
+		events[i] = namedEvents(base.Events)
+		methods[i] = namedMethods(base.Methods)
+		topics[i] = namedTopics(base.Topics)
+	}
+
+	merged, err := m.merge("property", properties, namedProperties(product.Properties), func(base, own interface{}) error {
+		if b, o := base.(*ProductProperty), own.(*ProductProperty); b.FieldType != o.FieldType {
+			return fmt.Errorf("the field type %s is different from the inherited %s", o.FieldType, b.FieldType)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	flattened.Properties = make([]*ProductProperty, len(merged))
+	for i, f := range merged {
+		flattened.Properties[i] = f.(*ProductProperty)
+	}
+
+	if merged, err = m.merge("event", events, namedEvents(product.Events), nil); err != nil {
+		return nil, err
+	}
+	flattened.Events = make([]*ProductEvent, len(merged))
+	for i, f := range merged {
+		flattened.Events[i] = f.(*ProductEvent)
+	}
+
+	if merged, err = m.merge("method", methods, namedMethods(product.Methods), nil); err != nil {
+		return nil, err
+	}
+	flattened.Methods = make([]*ProductMethod, len(merged))
+	for i, f := range merged {
+		flattened.Methods[i] = f.(*ProductMethod)
+	}
+
+	if merged, err = m.merge("topic", topics, namedTopics(product.Topics), nil); err != nil {
+		return nil, err
+	}
+	flattened.Topics = make([]*ProductTopic, len(merged))
+	for i, f := range merged {
+		flattened.Topics[i] = f.(*ProductTopic)
+	}
+	return &flattened, nil
+}
+
+// namedFunc is a function of a product identified by the name.
+type namedFunc struct {
+	name  string
+	value interface{}
+}
+
+func namedProperties(properties []*ProductProperty) []namedFunc {
+	fs := make([]namedFunc, len(properties))
+	for i, property := range properties {
+		fs[i] = namedFunc{name: property.Id, value: property}
+	}
+	return fs
+}
+
+func namedEvents(events []*ProductEvent) []namedFunc {
+	fs := make([]namedFunc, len(events))
+	for i, event := range events {
+		fs[i] = namedFunc{name: event.Id, value: event}
+	}
+	return fs
+}
+
+func namedMethods(methods []*ProductMethod) []namedFunc {
+	fs := make([]namedFunc, len(methods))
+	for i, method := range methods {
+		fs[i] = namedFunc{name: method.Id, value: method}
+	}
+	return fs
+}
+
+func namedTopics(topics []*ProductTopic) []namedFunc {
+	fs := make([]namedFunc, len(topics))
+	for i, topic := range topics {
+		fs[i] = namedFunc{name: topic.OptType, value: topic}
+	}
+	return fs
+}
+
+type merger struct {
+	productID string
+	bases     []string
+}
+
+// merge merges the functions of the bases and the product, the merged functions are in the order
+// of their first appearances. The override is checked by the compatible if any.
+func (m *merger) merge(kind string, bases [][]namedFunc, own []namedFunc,
+	compatible func(base, own interface{}) error) ([]interface{}, error) {
+	type inherited struct {
+		from  string
+		value interface{}
+	}
+	owns := make(map[string]interface{}, len(own))
+	for _, f := range own {
+		owns[f.name] = f.value
+	}
+
+	names := make([]string, 0)
+	values := make(map[string]inherited)
+	for i, fs := range bases {
+		for _, f := range fs {
+			previous, ok := values[f.name]
+			if !ok {
+				names = append(names, f.name)
+				values[f.name] = inherited{from: m.bases[i], value: f.value}
+				continue
+			}
+			if _, overridden := owns[f.name]; !overridden && !reflect.DeepEqual(previous.value, f.value) {
+				return nil, fmt.Errorf("the %s %s of the product %s is inherited from the bases %s and %s differently, "+
+					"override it to resolve the conflict", kind, f.name, m.productID, previous.from, m.bases[i])
+			}
+		}
+	}
+
+	merged := make([]interface{}, 0, len(names)+len(own))
+	for _, name := range names {
+		value := values[name].value
+		if o, ok := owns[name]; ok {
+			if compatible != nil {
+				if err := compatible(value, o); err != nil {
+					return nil, fmt.Errorf("the %s %s of the product %s can't override the base %s: %s",
+						kind, name, m.productID, values[name].from, err.Error())
+				}
+			}
+			value = o
+		}
+		merged = append(merged, value)
+	}
+	for _, f := range own {
+		if _, ok := values[f.name]; !ok {
+			merged = append(merged, f.value)
+		}
+	}
+	return merged, nil
+}
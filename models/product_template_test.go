@@ -0,0 +1,148 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func testTemplates() []*Product {
+	return []*Product{
+		{ID: "diagnostics", Abstract: true, Properties: []*ProductProperty{
+			{Id: "firmware", Name: "Firmware Version", FieldType: PropertyValueTypeString},
+			{Id: "uptime", Name: "Uptime", FieldType: PropertyValueTypeUint, Unit: "s"},
+		}, Methods: []*ProductMethod{{Id: "reboot", Name: "Reboot"}}},
+		{ID: "wireless", Abstract: true, Properties: []*ProductProperty{
+			{Id: "rssi", Name: "RSSI", FieldType: PropertyValueTypeInt, Unit: "dBm"},
+		}, Topics: []*ProductTopic{{Topic: "wireless/{device_id}/{func_id}", OptType: "PROPS"}}},
+		{ID: "meter", Protocol: "modbus", Bases: []string{"diagnostics", "wireless"}, Properties: []*ProductProperty{
+			{Id: "voltage", Name: "Voltage", FieldType: PropertyValueTypeFloat},
+			{Id: "uptime", Name: "Uptime", FieldType: PropertyValueTypeUint, Unit: "min"},
+		}, Alarms: []*ProductAlarm{{Id: "weak", PropertyID: "rssi", Severity: AlarmSeverityWarning, Low: new(float64)}}},
+		{ID: "switch", Protocol: "modbus"},
+	}
+}
+
+func TestResolveProducts(t *testing.T) {
+	products, err := ResolveProducts(testTemplates())
+	if err != nil {
+		t.Fatalf("fail to resolve the products: %s", err.Error())
+	}
+	if len(products) != 2 || products[0].ID != "meter" || products[1].ID != "switch" {
+		t.Fatalf("the abstract products should be dropped, got %d products", len(products))
+	}
+
+	meter := products[0]
+	if len(meter.Bases) != 0 {
+		t.Errorf("the flattened product shouldn't have bases")
+	}
+	var ids []string
+	for _, property := range meter.Properties {
+		ids = append(ids, property.Id)
+	}
+	if strings.Join(ids, ",") != "firmware,uptime,rssi,voltage" {
+		t.Errorf("unexpected properties %v", ids)
+	}
+	if meter.Properties[1].Unit != "min" {
+		t.Errorf("the uptime should be overridden by the product")
+	}
+	if len(meter.Methods) != 1 || meter.Methods[0].Id != "reboot" {
+		t.Errorf("the method reboot should be inherited")
+	}
+	if len(meter.Topics) != 1 || meter.Topics[0].OptType != "PROPS" {
+		t.Errorf("the topic should be inherited")
+	}
+}
+
+func TestResolveDiamond(t *testing.T) {
+	products := append(testTemplates(),
+		&Product{ID: "sensor", Abstract: true, Bases: []string{"diagnostics"}},
+		&Product{ID: "actuator", Abstract: true, Bases: []string{"diagnostics"}},
+		&Product{ID: "valve", Bases: []string{"sensor", "actuator"}},
+	)
+	r, err := NewProductResolver(products)
+	if err != nil {
+		t.Fatalf("fail to create the resolver: %s", err.Error())
+	}
+	valve, err := r.Resolve("valve")
+	if err != nil {
+		t.Fatalf("the functions inherited from the common base shouldn't conflict: %s", err.Error())
+	}
+	if len(valve.Properties) != 2 || len(valve.Methods) != 1 {
+		t.Errorf("unexpected functions of valve: %d properties, %d methods", len(valve.Properties), len(valve.Methods))
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	cases := map[string]struct {
+		extra  []*Product
+		expect string
+	}{
+		"missing base": {[]*Product{{ID: "a", Bases: []string{"b"}}}, "base b of the product a is not found"},
+		"cycle": {[]*Product{
+			{ID: "a", Bases: []string{"b"}},
+			{ID: "b", Bases: []string{"c"}},
+			{ID: "c", Bases: []string{"a"}},
+		}, "cyclic bases"},
+		"conflict": {[]*Product{
+			{ID: "cellular", Abstract: true, Properties: []*ProductProperty{
+				{Id: "rssi", Name: "Signal", FieldType: PropertyValueTypeInt},
+			}},
+			{ID: "router", Bases: []string{"wireless", "cellular"}},
+		}, "inherited from the bases wireless and cellular differently"},
+		"retyped": {[]*Product{{ID: "a", Bases: []string{"diagnostics"}, Properties: []*ProductProperty{
+			{Id: "uptime", FieldType: PropertyValueTypeFloat},
+		}}}, "can't override the base diagnostics"},
+		"protocol": {[]*Product{
+			{ID: "opc", Protocol: "opcua", Abstract: true},
+			{ID: "a", Protocol: "modbus", Bases: []string{"opc"}},
+		}, "protocol opcua of the base opc"},
+		"self": {[]*Product{{ID: "a", Bases: []string{"a"}}}, "invalid base"},
+		"invalid": {[]*Product{{ID: "a", Properties: []*ProductProperty{{Id: "x"}, {Id: "x"}}}},
+			"duplicated property x of the product a"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ResolveProducts(append(testTemplates(), c.extra...))
+			if err == nil || !strings.Contains(err.Error(), c.expect) {
+				t.Errorf("expect the error about '%s', got %v", c.expect, err)
+			}
+		})
+	}
+}
+
+func TestValidateProducts(t *testing.T) {
+	if err := ValidateProducts(testTemplates()); err != nil {
+		t.Fatalf("fail to validate the products: %s", err.Error())
+	}
+	// the conflict in an abstract product is not reported by ResolveProducts
+	products := append(testTemplates(),
+		&Product{ID: "cellular", Abstract: true, Properties: []*ProductProperty{
+			{Id: "rssi", Name: "Signal", FieldType: PropertyValueTypeInt},
+		}},
+		&Product{ID: "modem", Abstract: true, Bases: []string{"wireless", "cellular"}},
+	)
+	if _, err := ResolveProducts(products); err != nil {
+		t.Fatalf("fail to resolve the products: %s", err.Error())
+	}
+	if err := ValidateProducts(products); err == nil || !strings.Contains(err.Error(), "differently") {
+		t.Errorf("expect the conflict of the product modem, got %v", err)
+	}
+	if err := ValidateProducts([]*Product{{ID: "a", Properties: []*ProductProperty{{Id: "x"}, {Id: "x"}}}}); err == nil {
+		t.Errorf("expect the duplicated property of the product without bases")
+	}
+}
+
+func TestProductResolverDerived(t *testing.T) {
+	r, err := NewProductResolver(append(testTemplates(),
+		&Product{ID: "smart-meter", Bases: []string{"meter"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derived := r.Derived("diagnostics"); strings.Join(derived, ",") != "meter,smart-meter" {
+		t.Errorf("unexpected products derived from diagnostics: %v", derived)
+	}
+	if derived := r.Derived("switch"); len(derived) != 0 {
+		t.Errorf("unexpected products derived from switch: %v", derived)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// the placeholders of ProductTopic.Topic, each of them should occupy a whole level of the topic
+	ProductTopicPlaceholderDeviceID = "{device_id}"
+	ProductTopicPlaceholderFuncID   = "{func_id}"
+
+	productTopicSeparator = "/"
+)
+
+// Levels returns the levels of the topic template.
+func (t *ProductTopic) Levels() []string {
+	return strings.Split(t.Topic, productTopicSeparator)
+}
+
+// validate checks the topic template, e.g. "legacy/meters/{device_id}/{func_id}", which should contain
+// the placeholder of the device ID, and no wildcards.
+func (t *ProductTopic) validate() error {
+	if t.OptType == "" {
+		return fmt.Errorf("the operation type of the topic %s is required", t.Topic)
+	}
+	var device bool
+	for _, level := range t.Levels() {
+		switch {
+		case level == "":
+			return fmt.Errorf("the topic %s has empty levels", t.Topic)
+		case level == ProductTopicPlaceholderDeviceID:
+			if device {
+				return fmt.Errorf("the topic %s has more than one placeholder %s", t.Topic, level)
+			}
+			device = true
+		case level == ProductTopicPlaceholderFuncID:
+		case strings.ContainsAny(level, "+#{}"):
+			return fmt.Errorf("the level %s of the topic %s should be either a placeholder or a literal", level, t.Topic)
+		}
+	}
+	if !device {
+		return fmt.Errorf("the topic %s should contain the placeholder %s", t.Topic, ProductTopicPlaceholderDeviceID)
+	}
+	if strings.Count(t.Topic, ProductTopicPlaceholderFuncID) > 1 {
+		return fmt.Errorf("the topic %s has more than one placeholder %s", t.Topic, ProductTopicPlaceholderFuncID)
+	}
+	return nil
+}
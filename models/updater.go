@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type UpdateStage = string
+
+const (
+	UpdateStageDownloading UpdateStage = "downloading"
+	UpdateStageVerifying   UpdateStage = "verifying"
+	UpdateStageInstalling  UpdateStage = "installing"
+	UpdateStageRebooting   UpdateStage = "rebooting"
+)
+
+// Artifact is a firmware or configuration file to push to the device.
+type Artifact struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// URL is where the artifact can be downloaded from, it is ignored if the Data is carried.
+	URL    string `json:"url,omitempty"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Data is the content of the artifact transferred through the message bus,
+	// the chunking of the message bus should be enabled if the artifact is large, see config.ChunkOptions.
+	Data []byte `json:"data,omitempty"`
+}
+
+// Verify checks whether the content matches the size and checksum of the artifact.
+func (a *Artifact) Verify(content []byte) error {
+	if a.Size > 0 && int64(len(content)) != a.Size {
+		return fmt.Errorf("the size of the artifact %s is %d, expecting %d", a.Name, len(content), a.Size)
+	}
+	if a.SHA256 == "" {
+		return nil
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(a.SHA256) {
+		return fmt.Errorf("the checksum of the artifact %s mismatches", a.Name)
+	}
+	return nil
+}
+
+// UpdateProgressFunc reports the progress of an update, the percent is in [0, 100].
+type UpdateProgressFunc func(stage UpdateStage, percent int, detail string)
+
+// Updater is an optional interface which a DeviceTwin can implement if the device supports
+// updating its firmware or configuration files.
+type Updater interface {
+	// Update pushes the artifact to the device, and returns the version running on the device after updating.
+	// It should report the progress through the progress, and give up as soon as the ctx is done.
+	Update(ctx context.Context, artifact *Artifact, progress UpdateProgressFunc) (version string, err error)
+}
@@ -0,0 +1,153 @@
+package buffer
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReplayIntervalMillisecond = 1000
+)
+
+// NewBuffer returns a store-and-forward Buffer, the messages published through it will be persisted
+// into the directory while the message bus is unreachable, and replayed in order after reconnecting.
+func NewBuffer(mb bus.MessageBus, opts *config.PublishBufferOptions, lg *logger.Logger) (*Buffer, error) {
+	s, err := openStore(opts.Path)
+	if err != nil {
+		return nil, errors.MessageBus.Cause(err, "fail to open the publish buffer: %s", opts.Path)
+	}
+	interval := opts.ReplayIntervalMillisecond
+	if interval <= 0 {
+		interval = defaultReplayIntervalMillisecond
+	}
+	b := &Buffer{
+		mb:       mb,
+		store:    s,
+		maxBytes: int64(opts.MaxSizeMB) * 1024 * 1024,
+		maxAge:   time.Duration(opts.MaxAgeSecond) * time.Second,
+		interval: time.Millisecond * time.Duration(interval),
+		stop:     make(chan struct{}),
+		lg:       lg,
+	}
+	if n := s.len(); n > 0 {
+		lg.Infof("%d messages are left in the publish buffer, they will be replayed after connected", n)
+	}
+
+	b.wg.Add(1)
+	go b.replay()
+	return b, nil
+}
+
+type Buffer struct {
+	mb    bus.MessageBus
+	store *store
+
+	maxBytes int64
+	maxAge   time.Duration
+	interval time.Duration
+
+	// publishMu guarantees the order of messages between publishing directly and replaying.
+	publishMu sync.Mutex
+	stop      chan struct{}
+	wg        sync.WaitGroup
+
+	lg *logger.Logger
+}
+
+// Publish publishes the message directly if the message bus is connected and there is no message
+// waiting to be replayed, otherwise the message will be buffered.
+func (b *Buffer) Publish(msg *message.Message) error {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	if b.mb.IsConnected() && b.store.len() == 0 {
+		if err := b.mb.Publish(msg); err == nil {
+			return nil
+		} else {
+			b.lg.WithError(err).Warnf("fail to publish the message %s, it will be buffered", msg)
+		}
+	}
+	return b.enqueue(msg)
+}
+
+// Len returns the number of messages waiting to be replayed.
+func (b *Buffer) Len() int {
+	return b.store.len()
+}
+
+// Close stops replaying, the messages left will be replayed after the Buffer is opened again.
+func (b *Buffer) Close() error {
+	close(b.stop)
+	b.wg.Wait()
+	return nil
+}
+
+func (b *Buffer) enqueue(msg *message.Message) error {
+	if _, err := b.store.append(msg); err != nil {
+		return errors.MessageBus.Cause(err, "fail to buffer the message: %s", msg)
+	}
+	if dropped, err := b.store.evict(b.maxBytes, b.maxAge); err != nil {
+		b.lg.WithError(err).Errorf("fail to drop the oldest messages of the publish buffer")
+	} else if dropped > 0 {
+		b.lg.Warnf("the publish buffer is full, %d oldest messages are dropped", dropped)
+	}
+	return nil
+}
+
+func (b *Buffer) replay() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if _, err := b.store.evict(b.maxBytes, b.maxAge); err != nil {
+				b.lg.WithError(err).Errorf("fail to drop the expired messages of the publish buffer")
+			}
+			if b.store.len() == 0 || !b.mb.IsConnected() {
+				continue
+			}
+			b.drain()
+		}
+	}
+}
+
+// drain replays the buffered messages in order until the buffer is empty or a publishing fails.
+func (b *Buffer) drain() {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	replayed := 0
+	for {
+		select {
+		case <-b.stop:
+			return
+		default:
+		}
+
+		r := b.store.peek()
+		if r == nil {
+			break
+		}
+		if err := b.mb.Publish(r.message()); err != nil {
+			b.lg.WithError(err).Warnf("fail to replay the buffered message %s, it will be retried later", r.Topic)
+			break
+		}
+		if err := b.store.remove(r); err != nil {
+			b.lg.WithError(err).Errorf("fail to remove the replayed message: %s", r.Topic)
+			break
+		}
+		replayed++
+	}
+	if replayed > 0 {
+		b.lg.Infof("%d buffered messages have been replayed", replayed)
+	}
+}
@@ -0,0 +1,127 @@
+package buffer
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMessageBus struct {
+	mu        sync.Mutex
+	connected bool
+	published []*message.Message
+}
+
+func (f *fakeMessageBus) setConnected(connected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+}
+
+func (f *fakeMessageBus) topics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	topics := make([]string, len(f.published))
+	for i, msg := range f.published {
+		topics[i] = msg.Topic
+	}
+	return topics
+}
+
+func (f *fakeMessageBus) IsConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *fakeMessageBus) Connect() error    { return nil }
+func (f *fakeMessageBus) Disconnect() error { return nil }
+
+func (f *fakeMessageBus) Publish(msg *message.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.connected {
+		return fmt.Errorf("not connected")
+	}
+	f.published = append(f.published, msg)
+	return nil
+}
+
+func (f *fakeMessageBus) Subscribe(handler message.Handler, topics ...string) error { return nil }
+func (f *fakeMessageBus) Unsubscribe(topics ...string) error                        { return nil }
+func (f *fakeMessageBus) Call(request *message.Message, rspTpc, errTpc string) (*message.Message, error) {
+	return nil, nil
+}
+
+func newTestBuffer(t *testing.T, mb *fakeMessageBus, dir string) *Buffer {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	b, err := NewBuffer(mb, &config.PublishBufferOptions{Path: dir, ReplayIntervalMillisecond: 10}, lg)
+	if err != nil {
+		t.Fatalf("NewBuffer() error = %v", err)
+	}
+	return b
+}
+
+func TestBuffer_ReplayInOrder(t *testing.T) {
+	dir := t.TempDir()
+	mb := &fakeMessageBus{}
+	b := newTestBuffer(t, mb, dir)
+	for i := 0; i < 3; i++ {
+		if err := b.Publish(&message.Message{Topic: fmt.Sprintf("t%d", i), Payload: []byte("{}")}); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	if b.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", b.Len())
+	}
+	_ = b.Close()
+
+	// the buffered messages should survive restarting
+	b = newTestBuffer(t, mb, dir)
+	defer b.Close()
+	if b.Len() != 3 {
+		t.Fatalf("Len() after reopening = %d, want 3", b.Len())
+	}
+	mb.setConnected(true)
+	if err := b.Publish(&message.Message{Topic: "t3", Payload: []byte("{}")}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for b.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := fmt.Sprint(mb.topics())
+	if want := "[t0 t1 t2 t3]"; got != want {
+		t.Errorf("published topics = %s, want %s", got, want)
+	}
+}
+
+func TestStore_Evict(t *testing.T) {
+	s, err := openStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("openStore() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = s.append(&message.Message{Topic: fmt.Sprintf("t%d", i), Payload: []byte("{}")}); err != nil {
+			t.Fatalf("append() error = %v", err)
+		}
+	}
+	oldest := s.peek()
+	if dropped, _ := s.evict(s.size-oldest.size, 0); dropped != 1 {
+		t.Errorf("evict() by size dropped = %d, want 1", dropped)
+	}
+	if r := s.peek(); r.Topic != "t1" {
+		t.Errorf("the oldest record after evicting = %s, want t1", r.Topic)
+	}
+	if dropped, _ := s.evict(0, time.Nanosecond); dropped != 2 {
+		t.Errorf("evict() by age dropped = %d, want 2", dropped)
+	}
+}
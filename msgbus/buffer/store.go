@@ -0,0 +1,170 @@
+package buffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const recordSuffix = ".msg"
+
+// record is the persisted form of a message waiting to be published.
+type record struct {
+	Seq      uint64            `json:"seq"`
+	Enqueued time.Time         `json:"enqueued"`
+	Topic    string            `json:"topic"`
+	Header   map[string]string `json:"header,omitempty"`
+	Payload  []byte            `json:"payload"`
+
+	size int64 // the size of the file
+}
+
+func (r *record) message() *message.Message {
+	return &message.Message{Topic: r.Topic, Payload: r.Payload, Header: r.Header}
+}
+
+// store persists the records into a directory, one file per record, named by its sequence number,
+// so that the order of records can be recovered after restarting.
+type store struct {
+	mu      sync.Mutex
+	dir     string
+	records []*record // ordered by the sequence number
+	size    int64     // the total size of all records
+	nextSeq uint64
+}
+
+func openStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &store{dir: dir, nextSeq: 1}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), recordSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		r := new(record)
+		if err = json.Unmarshal(data, r); err != nil {
+			// the record may be broken due to a power failure while writing
+			_ = os.Remove(path)
+			continue
+		}
+		r.size = info.Size()
+		s.records = append(s.records, r)
+		s.size += r.size
+	}
+	sort.Slice(s.records, func(i, j int) bool {
+		return s.records[i].Seq < s.records[j].Seq
+	})
+	if n := len(s.records); n > 0 {
+		s.nextSeq = s.records[n-1].Seq + 1
+	}
+	return s, nil
+}
+
+func (s *store) append(msg *message.Message) (*record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &record{
+		Seq:      s.nextSeq,
+		Enqueued: time.Now(),
+		Topic:    msg.Topic,
+		Header:   msg.Header,
+		Payload:  msg.Payload,
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	tmp := s.path(r.Seq) + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp, s.path(r.Seq)); err != nil {
+		return nil, err
+	}
+	r.size = int64(len(data))
+	s.nextSeq++
+	s.records = append(s.records, r)
+	s.size += r.size
+	return r, nil
+}
+
+// peek returns the oldest record without removing it.
+func (s *store) peek() *record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) == 0 {
+		return nil
+	}
+	return s.records[0]
+}
+
+// remove deletes the oldest record if it is the specified one.
+func (s *store) remove(r *record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) == 0 || s.records[0] != r {
+		return nil
+	}
+	return s.removeOldest()
+}
+
+// evict drops the oldest records until both the size and the age of records are in the bounds,
+// it returns the number of dropped records.
+func (s *store) evict(maxBytes int64, maxAge time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped := 0
+	for len(s.records) > 0 {
+		oldest := s.records[0]
+		oversize := maxBytes > 0 && s.size > maxBytes
+		expired := maxAge > 0 && time.Since(oldest.Enqueued) > maxAge
+		if !oversize && !expired {
+			break
+		}
+		if err := s.removeOldest(); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+func (s *store) removeOldest() error {
+	oldest := s.records[0]
+	if err := os.Remove(s.path(oldest.Seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.records = s.records[1:]
+	s.size -= oldest.size
+	return nil
+}
+
+func (s *store) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, recordSuffix))
+}
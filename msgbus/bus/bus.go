@@ -0,0 +1,21 @@
+package bus
+
+import "github.com/thingio/edge-device-std/msgbus/message"
+
+// MessageBus encapsulates all common manipulations based on MQTT.
+// It is declared apart from msgbus, so that the decorators wired by msgbus.NewMessageBus can depend on it.
+type MessageBus interface {
+	IsConnected() bool
+
+	Connect() error
+
+	Disconnect() error
+
+	Publish(o *message.Message) error
+
+	Subscribe(handler message.Handler, topics ...string) error
+
+	Unsubscribe(topics ...string) error
+
+	Call(request *message.Message, rspTpc, errTpc string) (response *message.Message, err error)
+}
@@ -0,0 +1,324 @@
+package chunk
+
+import (
+	"encoding/json"
+	"github.com/rs/xid"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChunkSizeKB               = 256
+	defaultStallTimeoutMillisecond   = 2000
+	defaultReassemblyTimeoutSecond   = 60
+	defaultMaxResumes                = 3
+	defaultCallTimeoutMillisecond    = 30000
+	defaultRetainTransferSecondRatio = 2 // retain the transfers for twice of the reassembly timeout
+)
+
+// NewMessageBus decorates the MessageBus to transfer large messages in chunks.
+// A message whose payload exceeds the threshold will be split into chunks before publishing,
+// and the chunks will be reassembled into the original message before handling,
+// so the property values, event payloads and method outputs of any size can be transferred transparently.
+// Both of the peers should use the decorated MessageBus.
+func NewMessageBus(mb bus.MessageBus, opts *config.ChunkOptions, lg *logger.Logger) (bus.MessageBus, error) {
+	c := &chunkedMessageBus{
+		MessageBus:        mb,
+		id:                xid.New().String(),
+		threshold:         opts.ThresholdKB * 1024,
+		chunkSize:         opts.ChunkSizeKB * 1024,
+		stallTimeout:      time.Millisecond * time.Duration(opts.StallTimeoutMillisecond),
+		reassemblyTimeout: time.Second * time.Duration(opts.ReassemblyTimeoutSecond),
+		maxResumes:        opts.MaxResumes,
+		callTimeout:       time.Millisecond * time.Duration(opts.CallTimeoutMillisecond),
+		receivers:         make(map[string]*receiver),
+		lg:                lg,
+	}
+	if c.chunkSize <= 0 {
+		c.chunkSize = defaultChunkSizeKB * 1024
+	}
+	if c.threshold <= 0 {
+		c.threshold = c.chunkSize
+	}
+	if c.stallTimeout <= 0 {
+		c.stallTimeout = defaultStallTimeoutMillisecond * time.Millisecond
+	}
+	if c.reassemblyTimeout <= 0 {
+		c.reassemblyTimeout = defaultReassemblyTimeoutSecond * time.Second
+	}
+	if c.maxResumes <= 0 {
+		c.maxResumes = defaultMaxResumes
+	}
+	if c.callTimeout <= 0 {
+		c.callTimeout = defaultCallTimeoutMillisecond * time.Millisecond
+	}
+	c.sender = newSender(c.reassemblyTimeout * defaultRetainTransferSecondRatio)
+
+	if err := mb.Subscribe(c.handleResume, TopicResumePrefix+"+"); err != nil {
+		return nil, errors.MessageBus.Cause(err, "fail to subscribe the resume requests of chunks")
+	}
+	if err := mb.Subscribe(c.handleResend, TopicResendPrefix+c.id+"/+"); err != nil {
+		return nil, errors.MessageBus.Cause(err, "fail to subscribe the resent chunks")
+	}
+	c.stop = make(chan struct{})
+	go c.watch(c.stop)
+	return c, nil
+}
+
+type chunkedMessageBus struct {
+	bus.MessageBus
+	id string // the ID addressing the chunks resent to this peer
+
+	threshold         int
+	chunkSize         int
+	stallTimeout      time.Duration
+	reassemblyTimeout time.Duration
+	maxResumes        int
+	callTimeout       time.Duration
+
+	sender *sender
+	// receivers are the receivers of the subscriptions indexed by the topics, each subscription reassembles
+	// the chunks delivered to it independently, so the overlapping subscriptions all get the whole message.
+	receiversMu sync.Mutex
+	receivers   map[string]*receiver
+
+	stopMu sync.Mutex
+	stop   chan struct{}
+
+	lg *logger.Logger
+}
+
+func (c *chunkedMessageBus) Connect() error {
+	if err := c.MessageBus.Connect(); err != nil {
+		return err
+	}
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+		go c.watch(c.stop)
+	}
+	return nil
+}
+
+func (c *chunkedMessageBus) Disconnect() error {
+	c.stopMu.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.stopMu.Unlock()
+	return c.MessageBus.Disconnect()
+}
+
+func (c *chunkedMessageBus) Publish(msg *message.Message) error {
+	if len(msg.Payload) <= c.threshold {
+		return c.MessageBus.Publish(msg)
+	}
+
+	chunks := c.sender.split(xid.New().String(), msg, c.chunkSize)
+	c.lg.Debugf("split the message %s into %d chunks", msg, len(chunks))
+	for _, chunk := range chunks {
+		if err := c.MessageBus.Publish(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *chunkedMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	r := newReceiver(xid.New().String(), handler)
+	if err := c.MessageBus.Subscribe(func(msg *message.Message) {
+		h, ok, err := parseHeader(msg)
+		if err != nil {
+			c.lg.WithError(err).Errorf("fail to parse the chunk received from the topic: %s", msg.Topic)
+			return
+		}
+		if !ok {
+			handler(msg)
+			return
+		}
+
+		whole, err := r.receive(h, msg)
+		if err != nil {
+			c.lg.WithError(err).Warnf("fail to reassemble the chunk received from the topic: %s", msg.Topic)
+			return
+		}
+		if whole != nil {
+			handler(whole)
+		}
+	}, topics...); err != nil {
+		return err
+	}
+
+	c.receiversMu.Lock()
+	defer c.receiversMu.Unlock()
+	for _, topic := range topics {
+		c.receivers[topic] = r
+	}
+	return nil
+}
+
+func (c *chunkedMessageBus) Unsubscribe(topics ...string) error {
+	c.receiversMu.Lock()
+	for _, topic := range topics {
+		delete(c.receivers, topic)
+	}
+	c.receiversMu.Unlock()
+	return c.MessageBus.Unsubscribe(topics...)
+}
+
+// subscribedReceivers returns the distinct receivers of the subscriptions.
+func (c *chunkedMessageBus) subscribedReceivers() []*receiver {
+	c.receiversMu.Lock()
+	defer c.receiversMu.Unlock()
+	seen := make(map[*receiver]bool, len(c.receivers))
+	receivers := make([]*receiver, 0, len(c.receivers))
+	for _, r := range c.receivers {
+		if !seen[r] {
+			seen[r] = true
+			receivers = append(receivers, r)
+		}
+	}
+	return receivers
+}
+
+// Call is re-implemented based on the decorated Publish and Subscribe,
+// because both of the request and the response may be transferred in chunks.
+func (c *chunkedMessageBus) Call(request *message.Message, rspTpc, errTpc string) (response *message.Message, err error) {
+	ch := make(chan *message.Message, 1)
+	if err = c.Subscribe(func(msg *message.Message) {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}, rspTpc); err != nil {
+		return
+	}
+	defer func() { _ = c.Unsubscribe(rspTpc) }()
+	errCh := make(chan *message.Message, 1)
+	if err = c.Subscribe(func(msg *message.Message) {
+		select {
+		case errCh <- msg:
+		default:
+		}
+	}, errTpc); err != nil {
+		return
+	}
+	defer func() { _ = c.Unsubscribe(errTpc) }()
+
+	if err = c.Publish(request); err != nil {
+		return
+	}
+	timer := time.NewTimer(c.callTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case msg := <-errCh:
+		return nil, errors.Unmarshal(msg.Payload)
+	case <-timer.C:
+		return nil, errors.MessageBus.Error("call timeout: %dms", c.callTimeout/time.Millisecond)
+	}
+}
+
+func (c *chunkedMessageBus) handleResume(msg *message.Message) {
+	req := new(resumeRequest)
+	if err := msg.Unmarshal(req); err != nil {
+		c.lg.WithError(err).Errorf("fail to unmarshal the resume request: %s", msg.Topic)
+		return
+	}
+	if req.ID == "" {
+		req.ID = strings.TrimPrefix(msg.Topic, TopicResumePrefix)
+	}
+	chunks := c.sender.missing(req)
+	if len(chunks) == 0 {
+		return // the transfer is not sent by this peer, or it has expired
+	}
+	c.lg.Debugf("resend %d missing chunks of %s", len(chunks), req.ID)
+	for _, chunk := range chunks {
+		if req.Reply != "" {
+			// only the requester receives the resent chunk, instead of all subscriptions of the message
+			chunk = &message.Message{Topic: req.Reply, Header: chunk.Header, Payload: chunk.Payload}
+		}
+		if err := c.MessageBus.Publish(chunk); err != nil {
+			c.lg.WithError(err).Errorf("fail to resend the missing chunk of %s", req.ID)
+			return
+		}
+	}
+}
+
+// handleResend collects the missing chunks resent to the receiver addressed by the topic.
+func (c *chunkedMessageBus) handleResend(msg *message.Message) {
+	id := strings.TrimPrefix(msg.Topic, TopicResendPrefix+c.id+"/")
+	var r *receiver
+	for _, candidate := range c.subscribedReceivers() {
+		if candidate.id == id {
+			r = candidate
+			break
+		}
+	}
+	if r == nil {
+		return // the subscription has been unsubscribed
+	}
+	h, ok, err := parseHeader(msg)
+	if err != nil {
+		c.lg.WithError(err).Errorf("fail to parse the chunk resent to the topic: %s", msg.Topic)
+		return
+	}
+	if !ok {
+		return // not a chunk
+	}
+	whole, err := r.receiveResent(h, msg)
+	if err != nil {
+		c.lg.WithError(err).Warnf("fail to reassemble the chunk resent to the topic: %s", msg.Topic)
+		return
+	}
+	if whole != nil {
+		r.handler(whole)
+	}
+}
+
+// watch requests the missing chunks of the stalled transfers periodically.
+func (c *chunkedMessageBus) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.stallTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.sender.expire()
+			for _, r := range c.subscribedReceivers() {
+				c.resume(r)
+			}
+		}
+	}
+}
+
+// resume requests the missing chunks of the stalled transfers of the receiver, and drops the timeout ones.
+func (c *chunkedMessageBus) resume(r *receiver) {
+	requests, dropped := r.check(c.stallTimeout, c.reassemblyTimeout, c.maxResumes)
+	for _, id := range dropped {
+		c.lg.Warnf("the chunks of %s can't be reassembled in time, they are dropped", id)
+	}
+	for _, req := range requests {
+		req.Reply = TopicResendPrefix + c.id + "/" + r.id
+		payload, err := json.Marshal(req)
+		if err != nil {
+			continue
+		}
+		if err = c.MessageBus.Publish(&message.Message{
+			Topic:   TopicResumePrefix + req.ID,
+			Payload: payload,
+		}); err != nil {
+			c.lg.WithError(err).Errorf("fail to request the missing chunks of %s", req.ID)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package chunk
+
+import (
+	"bytes"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitAndReassemble(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	msg := &message.Message{Topic: "DATA/v1/UP/p/p/d/image/PROPS/", Payload: payload}
+	msg.SetHeader("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	s := newSender(time.Minute)
+	chunks := s.split("id", msg, 64)
+	if len(chunks) != 16 {
+		t.Fatalf("split() chunks = %d, want 16", len(chunks))
+	}
+
+	r := newReceiver("r", nil)
+	var whole *message.Message
+	// receive all chunks except the 3rd one in reverse order
+	for seq := len(chunks) - 1; seq >= 0; seq-- {
+		if seq == 2 {
+			continue
+		}
+		h, ok, err := parseHeader(chunks[seq])
+		if !ok || err != nil {
+			t.Fatalf("parseHeader() ok = %v, error = %v", ok, err)
+		}
+		if whole, err = r.receive(h, chunks[seq]); err != nil || whole != nil {
+			t.Fatalf("receive() whole = %v, error = %v, want neither", whole, err)
+		}
+	}
+
+	requests, dropped := r.check(0, time.Minute, 3)
+	if len(dropped) != 0 || len(requests) != 1 {
+		t.Fatalf("check() requests = %d, dropped = %d, want 1 and 0", len(requests), len(dropped))
+	}
+	missing := s.missing(requests[0])
+	if len(missing) != 1 || missing[0] != chunks[2] {
+		t.Fatalf("missing() = %v, want the 3rd chunk", missing)
+	}
+
+	h, _, _ := parseHeader(missing[0])
+	whole, err := r.receive(h, missing[0])
+	if err != nil || whole == nil {
+		t.Fatalf("receive() the last chunk whole = %v, error = %v", whole, err)
+	}
+	if !bytes.Equal(whole.Payload, payload) {
+		t.Errorf("the reassembled payload mismatches")
+	}
+	if whole.GetHeader("traceparent") != msg.GetHeader("traceparent") || whole.GetHeader(HeaderChunkID) != "" {
+		t.Errorf("the reassembled header = %v, want the original one", whole.Header)
+	}
+
+	// the chunk duplicated after reassembled doesn't start a stale assembly
+	h, _, _ = parseHeader(chunks[0])
+	if whole, err = r.receive(h, chunks[0]); err != nil || whole != nil || len(r.assemblies) != 0 {
+		t.Errorf("receive() the duplicated chunk whole = %v, error = %v, assemblies = %d, want none",
+			whole, err, len(r.assemblies))
+	}
+}
+
+func TestReceiveBrokenChunk(t *testing.T) {
+	s := newSender(time.Minute)
+	chunks := s.split("id", &message.Message{Topic: "t", Payload: []byte("0123456789")}, 4)
+	h, _, _ := parseHeader(chunks[0])
+	broken := &message.Message{Topic: "t", Payload: []byte("xxxx"), Header: chunks[0].Header}
+	if _, err := newReceiver("r", nil).receive(h, broken); err == nil {
+		t.Errorf("receive() a broken chunk error = nil, want an error")
+	}
+}
+
+// fakeMessageBus delivers the messages to all subscriptions whose topic filters match, supporting "+" only.
+type fakeMessageBus struct {
+	bus.MessageBus
+
+	mu       sync.Mutex
+	handlers map[string]message.Handler
+	drop     func(msg *message.Message) bool // drops the message published if it returns true
+}
+
+func (f *fakeMessageBus) Disconnect() error {
+	return nil
+}
+
+func (f *fakeMessageBus) Publish(msg *message.Message) error {
+	f.mu.Lock()
+	if f.drop != nil && f.drop(msg) {
+		f.mu.Unlock()
+		return nil
+	}
+	var handlers []message.Handler
+	for filter, handler := range f.handlers {
+		if match(filter, msg.Topic) {
+			handlers = append(handlers, handler)
+		}
+	}
+	f.mu.Unlock()
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (f *fakeMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, topic := range topics {
+		f.handlers[topic] = handler
+	}
+	return nil
+}
+
+func (f *fakeMessageBus) Unsubscribe(topics ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, topic := range topics {
+		delete(f.handlers, topic)
+	}
+	return nil
+}
+
+func match(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	if len(fs) != len(ts) {
+		return false
+	}
+	for i := range fs {
+		if fs[i] != "+" && fs[i] != ts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOverlappingSubscriptions(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb, err := NewMessageBus(&fakeMessageBus{handlers: make(map[string]message.Handler)},
+		&config.ChunkOptions{ThresholdKB: 1, ChunkSizeKB: 1}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mb.Disconnect() }()
+
+	var exact, wildcard []*message.Message
+	if err = mb.Subscribe(func(msg *message.Message) { exact = append(exact, msg) }, "DATA/v1/UP/p/d/image"); err != nil {
+		t.Fatal(err)
+	}
+	if err = mb.Subscribe(func(msg *message.Message) { wildcard = append(wildcard, msg) }, "DATA/v1/UP/+/+/+"); err != nil {
+		t.Fatal(err)
+	}
+	payload := bytes.Repeat([]byte("0123456789"), 500)
+	if err = mb.Publish(&message.Message{Topic: "DATA/v1/UP/p/d/image", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	for name, received := range map[string][]*message.Message{"exact": exact, "wildcard": wildcard} {
+		if len(received) != 1 || !bytes.Equal(received[0].Payload, payload) {
+			t.Errorf("the %s subscription should receive the whole message once, got %d messages", name, len(received))
+		}
+	}
+
+	if err = mb.Unsubscribe("DATA/v1/UP/+/+/+"); err != nil {
+		t.Fatal(err)
+	}
+	if receivers := mb.(*chunkedMessageBus).subscribedReceivers(); len(receivers) != 1 {
+		t.Errorf("the receiver of the unsubscribed topic should be released, got %d receivers", len(receivers))
+	}
+}
+
+func TestResend(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resent []string
+	dropped := false
+	fake := &fakeMessageBus{handlers: make(map[string]message.Handler), drop: func(msg *message.Message) bool {
+		if strings.HasPrefix(msg.Topic, TopicResendPrefix) {
+			resent = append(resent, msg.Topic)
+		}
+		// drop the 2nd chunk published on the original topic once
+		if msg.Topic == "DATA/v1/UP/p/d/image" && msg.GetHeader(HeaderChunkSeq) == "1" && !dropped {
+			dropped = true
+			return true
+		}
+		return false
+	}}
+	mb, err := NewMessageBus(fake, &config.ChunkOptions{ThresholdKB: 1, ChunkSizeKB: 1, StallTimeoutMillisecond: 20}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mb.Disconnect() }()
+
+	received := make(chan *message.Message, 10)
+	if err = mb.Subscribe(func(msg *message.Message) { received <- msg }, "DATA/v1/UP/p/d/image"); err != nil {
+		t.Fatal(err)
+	}
+	payload := bytes.Repeat([]byte("0123456789"), 500)
+	if err = mb.Publish(&message.Message{Topic: "DATA/v1/UP/p/d/image", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		if msg.Topic != "DATA/v1/UP/p/d/image" || !bytes.Equal(msg.Payload, payload) {
+			t.Errorf("the reassembled message is on %s with %d bytes, want the original one", msg.Topic, len(msg.Payload))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the missing chunk is not resent")
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(resent) != 1 {
+		t.Errorf("the chunks resent = %v, want one addressed to the receiver", resent)
+	}
+}
@@ -0,0 +1,74 @@
+package chunk
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strconv"
+)
+
+const (
+	// The chunks of a large message are published on the topic of the message itself,
+	// and described by the following headers, so that they can be received by the same subscriptions.
+	HeaderChunkID     = "chunk-id"     // the ID of the transfer which the chunk belongs to
+	HeaderChunkSeq    = "chunk-seq"    // the sequence number of the chunk, starting from 0
+	HeaderChunkTotal  = "chunk-total"  // the number of chunks of the transfer
+	HeaderChunkCRC32  = "chunk-crc32"  // the CRC32 checksum of the chunk
+	HeaderChunkSHA256 = "chunk-sha256" // the SHA256 checksum of the whole payload
+
+	// TopicResumePrefix is the prefix of topics used by receivers to request the missing chunks,
+	// formed by CHUNK/<ver>/RESUME/<chunk-id>.
+	TopicResumePrefix = "CHUNK/v1/RESUME/"
+	// TopicResendPrefix is the prefix of topics used by senders to resend the missing chunks to the receiver
+	// requesting them, formed by CHUNK/<ver>/RESEND/<bus-id>/<receiver-id>.
+	TopicResendPrefix = "CHUNK/v1/RESEND/"
+)
+
+// header describes a chunk.
+type header struct {
+	id     string
+	seq    int
+	total  int
+	crc32  uint32
+	sha256 string
+}
+
+func (h *header) apply(msg *message.Message) {
+	msg.SetHeader(HeaderChunkID, h.id)
+	msg.SetHeader(HeaderChunkSeq, strconv.Itoa(h.seq))
+	msg.SetHeader(HeaderChunkTotal, strconv.Itoa(h.total))
+	msg.SetHeader(HeaderChunkCRC32, strconv.FormatUint(uint64(h.crc32), 16))
+	msg.SetHeader(HeaderChunkSHA256, h.sha256)
+}
+
+// parseHeader returns the header of the chunk, ok will be false if the message is not a chunk.
+func parseHeader(msg *message.Message) (h *header, ok bool, err error) {
+	id := msg.GetHeader(HeaderChunkID)
+	if id == "" {
+		return nil, false, nil
+	}
+	h = &header{id: id, sha256: msg.GetHeader(HeaderChunkSHA256)}
+	if h.seq, err = strconv.Atoi(msg.GetHeader(HeaderChunkSeq)); err != nil {
+		return nil, true, fmt.Errorf("invalid sequence number of the chunk %s: %s", id, err.Error())
+	}
+	if h.total, err = strconv.Atoi(msg.GetHeader(HeaderChunkTotal)); err != nil || h.total <= 0 {
+		return nil, true, fmt.Errorf("invalid total number of the chunk %s", id)
+	}
+	if h.seq < 0 || h.seq >= h.total {
+		return nil, true, fmt.Errorf("the sequence number %d of the chunk %s is out of range [0, %d)", h.seq, id, h.total)
+	}
+	crc, err := strconv.ParseUint(msg.GetHeader(HeaderChunkCRC32), 16, 32)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid checksum of the chunk %s: %s", id, err.Error())
+	}
+	h.crc32 = uint32(crc)
+	return h, true, nil
+}
+
+// resumeRequest is sent by the receiver to ask the sender for the missing chunks.
+type resumeRequest struct {
+	ID      string `json:"id"`
+	Missing []int  `json:"missing"`
+	// Reply is the topic to resend the missing chunks to, they are resent on the topic of the message
+	// if it's empty, e.g. requested by the peers of earlier versions.
+	Reply string `json:"reply,omitempty"`
+}
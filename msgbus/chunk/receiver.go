@@ -0,0 +1,156 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+// assembly collects the chunks of a transfer until all of them have been received.
+type assembly struct {
+	topic    string
+	header   map[string]string // the original header of the message
+	sha256   string
+	chunks   [][]byte
+	received int
+
+	startedAt time.Time
+	updatedAt time.Time
+	resumes   int
+}
+
+func (a *assembly) missing() []int {
+	seqs := make([]int, 0, len(a.chunks)-a.received)
+	for seq, c := range a.chunks {
+		if c == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	return seqs
+}
+
+// receiver reassembles the chunks delivered to a subscription, and hands the whole messages to the handler.
+type receiver struct {
+	id      string
+	handler message.Handler
+
+	mu         sync.Mutex
+	assemblies map[string]*assembly // chunk ID -> assembly
+	finished   map[string]time.Time // chunk ID -> when the assembly is completed or dropped
+}
+
+func newReceiver(id string, handler message.Handler) *receiver {
+	return &receiver{
+		id:         id,
+		handler:    handler,
+		assemblies: make(map[string]*assembly),
+		finished:   make(map[string]time.Time),
+	}
+}
+
+// receive collects the chunk, and returns the reassembled message once all chunks have been received.
+func (r *receiver) receive(h *header, chunk *message.Message) (*message.Message, error) {
+	return r.collect(h, chunk, true)
+}
+
+// receiveResent collects the chunk resent to the receiver, it is ignored if the assembly doesn't exist,
+// because the topic of the message is only known from the chunks received on the original topic.
+func (r *receiver) receiveResent(h *header, chunk *message.Message) (*message.Message, error) {
+	return r.collect(h, chunk, false)
+}
+
+func (r *receiver) collect(h *header, chunk *message.Message, create bool) (*message.Message, error) {
+	if crc32.ChecksumIEEE(chunk.Payload) != h.crc32 {
+		// the broken chunk is ignored, it will be requested again as a missing one
+		return nil, fmt.Errorf("the checksum of the chunk %d of %s mismatches", h.seq, h.id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.finished[h.id]; ok {
+		return nil, nil // duplicated after the assembly is finished
+	}
+	a, ok := r.assemblies[h.id]
+	if !ok {
+		if !create {
+			return nil, nil
+		}
+		a = &assembly{
+			topic:     chunk.Topic,
+			header:    make(map[string]string),
+			sha256:    h.sha256,
+			chunks:    make([][]byte, h.total),
+			startedAt: time.Now(),
+		}
+		for k, v := range chunk.Header {
+			a.header[k] = v
+		}
+		for _, k := range []string{HeaderChunkID, HeaderChunkSeq, HeaderChunkTotal, HeaderChunkCRC32, HeaderChunkSHA256} {
+			delete(a.header, k)
+		}
+		r.assemblies[h.id] = a
+	}
+	if h.total != len(a.chunks) {
+		return nil, fmt.Errorf("the total number %d of the chunk %s mismatches, expecting %d", h.total, h.id, len(a.chunks))
+	}
+	a.updatedAt = time.Now()
+	if a.chunks[h.seq] != nil { // duplicated
+		return nil, nil
+	}
+	a.chunks[h.seq] = chunk.Payload
+	a.received++
+	if a.received < len(a.chunks) {
+		return nil, nil
+	}
+
+	delete(r.assemblies, h.id)
+	r.finished[h.id] = time.Now()
+	size := 0
+	for _, c := range a.chunks {
+		size += len(c)
+	}
+	payload := make([]byte, 0, size)
+	for _, c := range a.chunks {
+		payload = append(payload, c...)
+	}
+	sum := sha256.Sum256(payload)
+	if hex.EncodeToString(sum[:]) != a.sha256 {
+		return nil, fmt.Errorf("the checksum of the reassembled message %s mismatches", h.id)
+	}
+	msg := &message.Message{Topic: a.topic, Payload: payload}
+	if len(a.header) > 0 {
+		msg.Header = a.header
+	}
+	return msg, nil
+}
+
+// check returns the resume requests of the stalled assemblies, and drops the timeout ones.
+// The finished assemblies are remembered as long as the senders retain their transfers,
+// so that the chunks duplicated by the resending won't start the assemblies again.
+func (r *receiver) check(stall, timeout time.Duration, maxResumes int) (requests []*resumeRequest, dropped []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, finishedAt := range r.finished {
+		if time.Since(finishedAt) > timeout*defaultRetainTransferSecondRatio {
+			delete(r.finished, id)
+		}
+	}
+	for id, a := range r.assemblies {
+		if time.Since(a.startedAt) > timeout || a.resumes >= maxResumes && time.Since(a.updatedAt) > stall {
+			delete(r.assemblies, id)
+			r.finished[id] = time.Now()
+			dropped = append(dropped, id)
+			continue
+		}
+		if time.Since(a.updatedAt) > stall {
+			a.resumes++
+			a.updatedAt = time.Now()
+			requests = append(requests, &resumeRequest{ID: id, Missing: a.missing()})
+		}
+	}
+	return
+}
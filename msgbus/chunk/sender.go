@@ -0,0 +1,86 @@
+package chunk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"hash/crc32"
+	"sync"
+	"time"
+)
+
+// transfer is a large message which has been split into chunks,
+// it will be retained for a while to serve the resume requests.
+type transfer struct {
+	chunks []*message.Message
+	sentAt time.Time
+}
+
+type sender struct {
+	mu        sync.Mutex
+	transfers map[string]*transfer // chunk ID -> transfer
+	retain    time.Duration
+}
+
+func newSender(retain time.Duration) *sender {
+	return &sender{transfers: make(map[string]*transfer), retain: retain}
+}
+
+// split splits the payload of the message into chunks whose size is not larger than chunkSize.
+func (s *sender) split(id string, msg *message.Message, chunkSize int) []*message.Message {
+	sum := sha256.Sum256(msg.Payload)
+	total := (len(msg.Payload) + chunkSize - 1) / chunkSize
+	chunks := make([]*message.Message, 0, total)
+	for seq := 0; seq < total; seq++ {
+		begin, end := seq*chunkSize, (seq+1)*chunkSize
+		if end > len(msg.Payload) {
+			end = len(msg.Payload)
+		}
+		payload := msg.Payload[begin:end]
+		chunk := &message.Message{Topic: msg.Topic, Payload: payload}
+		for k, v := range msg.Header {
+			chunk.SetHeader(k, v)
+		}
+		(&header{
+			id:     id,
+			seq:    seq,
+			total:  total,
+			crc32:  crc32.ChecksumIEEE(payload),
+			sha256: hex.EncodeToString(sum[:]),
+		}).apply(chunk)
+		chunks = append(chunks, chunk)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfers[id] = &transfer{chunks: chunks, sentAt: time.Now()}
+	return chunks
+}
+
+// missing returns the chunks requested by the receiver if the transfer is still retained.
+func (s *sender) missing(req *resumeRequest) []*message.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transfers[req.ID]
+	if !ok {
+		return nil
+	}
+	chunks := make([]*message.Message, 0, len(req.Missing))
+	for _, seq := range req.Missing {
+		if seq >= 0 && seq < len(t.chunks) {
+			chunks = append(chunks, t.chunks[seq])
+		}
+	}
+	return chunks
+}
+
+// expire forgets the transfers sent before the retention.
+func (s *sender) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.transfers {
+		if time.Since(t.sentAt) > s.retain {
+			delete(s.transfers, id)
+		}
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/thingio/edge-device-std/config"
 	"github.com/thingio/edge-device-std/logger"
-	"github.com/thingio/edge-device-std/msgbus/message"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/chunk"
 	"github.com/thingio/edge-device-std/msgbus/mqtt"
+	"github.com/thingio/edge-device-std/tracing"
 )
 
+// NewMessageBus creates the MessageBus of the type and connects it, the MessageBus is decorated
+// to transfer large messages in chunks if the chunking is enabled, and then to propagate
+// the trace context if the tracing is enabled, see tracing.TracerOf.
 func NewMessageBus(opts *config.MessageBusOptions, lg *logger.Logger) (MessageBus, error) {
 	var mb MessageBus
 	switch opts.Type {
@@ -29,22 +34,23 @@ func NewMessageBus(opts *config.MessageBusOptions, lg *logger.Logger) (MessageBu
 	if err := mb.Connect(); err != nil {
 		return nil, errors.Wrap(err, "fail to connect to the message bus")
 	}
+
+	if opts.Chunk.Enabled {
+		cmb, err := chunk.NewMessageBus(mb, &opts.Chunk, lg)
+		if err != nil {
+			return nil, errors.Wrap(err, "fail to initialize the chunked message bus")
+		}
+		mb = cmb
+	}
+	if opts.Tracing.Enabled {
+		tracer, err := tracing.NewTracer(&opts.Tracing, lg)
+		if err != nil {
+			return nil, errors.Wrap(err, "fail to initialize the tracer")
+		}
+		mb = tracing.NewMessageBus(mb, tracer)
+	}
 	return mb, nil
 }
 
 // MessageBus encapsulates all common manipulations based on MQTT.
-type MessageBus interface {
-	IsConnected() bool
-
-	Connect() error
-
-	Disconnect() error
-
-	Publish(o *message.Message) error
-
-	Subscribe(handler message.Handler, topics ...string) error
-
-	Unsubscribe(topics ...string) error
-
-	Call(request *message.Message, rspTpc, errTpc string) (response *message.Message, err error)
-}
+type MessageBus = bus.MessageBus
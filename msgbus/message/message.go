@@ -1,6 +1,8 @@
 package message
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 )
@@ -11,6 +13,11 @@ type Handler func(msg *Message)
 type Message struct {
 	Topic   string
 	Payload []byte
+
+	// Header carries optional metadata along with the payload, e.g. the trace context.
+	// It will be encoded into the frame only if it is not empty, so the peers without
+	// any header are still compatible with each other.
+	Header map[string]string
 }
 
 func (m *Message) String() string {
@@ -20,3 +27,69 @@ func (m *Message) String() string {
 func (m *Message) Unmarshal(v interface{}) error {
 	return json.Unmarshal(m.Payload, v)
 }
+
+// GetHeader returns the value of the specified header key, or an empty string if it doesn't exist.
+func (m *Message) GetHeader(key string) string {
+	if m.Header == nil {
+		return ""
+	}
+	return m.Header[key]
+}
+
+// SetHeader sets the value of the specified header key.
+func (m *Message) SetHeader(key, value string) {
+	if m.Header == nil {
+		m.Header = make(map[string]string)
+	}
+	m.Header[key] = value
+}
+
+// headerMagic is the prefix of a frame with header, a valid JSON payload never starts with it.
+var headerMagic = []byte{0x00, 'E', 'D', 'H'}
+
+// Encode returns the frame which will be transferred by the MQ.
+// The frame is formed by <magic>/<length of the header>/<header>/<payload> if the header is not empty,
+// otherwise it is the payload itself.
+func (m *Message) Encode() ([]byte, error) {
+	if len(m.Header) == 0 {
+		return m.Payload, nil
+	}
+
+	header, err := json.Marshal(m.Header)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 0, len(headerMagic)+4+len(header)+len(m.Payload))
+	frame = append(frame, headerMagic...)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(header)))
+	frame = append(frame, size...)
+	frame = append(frame, header...)
+	frame = append(frame, m.Payload...)
+	return frame, nil
+}
+
+// Decode parses the frame received from the MQ into a Message.
+func Decode(topic string, frame []byte) (*Message, error) {
+	msg := &Message{Topic: topic, Payload: frame}
+	if !bytes.HasPrefix(frame, headerMagic) {
+		return msg, nil
+	}
+
+	offset := len(headerMagic)
+	if len(frame) < offset+4 {
+		return nil, fmt.Errorf("invalid frame of the topic %s: the length of the header is missing", topic)
+	}
+	size := int(binary.BigEndian.Uint32(frame[offset : offset+4]))
+	offset += 4
+	if len(frame) < offset+size {
+		return nil, fmt.Errorf("invalid frame of the topic %s: the header is truncated", topic)
+	}
+	header := make(map[string]string)
+	if err := json.Unmarshal(frame[offset:offset+size], &header); err != nil {
+		return nil, fmt.Errorf("invalid frame of the topic %s: %s", topic, err.Error())
+	}
+	msg.Header = header
+	msg.Payload = frame[offset+size:]
+	return msg, nil
+}
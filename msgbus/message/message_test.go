@@ -0,0 +1,35 @@
+package message
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	payload := []byte(`{"temperature":20}`)
+
+	// the message without any header is encoded as the payload itself, so the peers not knowing the header frame
+	// are still able to decode it
+	frame, err := (&Message{Topic: "topic", Payload: payload, Header: map[string]string{}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, payload) {
+		t.Errorf("the frame without any header = %q, want the payload", frame)
+	}
+
+	frame, err = (&Message{Topic: "topic", Payload: payload, Header: map[string]string{"traceparent": "00-01"}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Decode("topic", frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Payload, payload) || msg.GetHeader("traceparent") != "00-01" {
+		t.Errorf("the decoded message = %+v, want the payload with the header", msg)
+	}
+	if msg, err = Decode("topic", payload); err != nil || msg.Header != nil || !bytes.Equal(msg.Payload, payload) {
+		t.Errorf("the payload without the header frame is decoded as %+v, %v", msg, err)
+	}
+}
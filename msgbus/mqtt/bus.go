@@ -7,6 +7,7 @@ import (
 	"github.com/thingio/edge-device-std/logger"
 	"github.com/thingio/edge-device-std/msgbus/message"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -30,6 +31,9 @@ type MessageBus struct {
 	callTimeout  time.Duration
 	qos          int
 
+	// routes are resubscribed after reconnected, they are guarded by the mutex
+	// because the subscriptions are changed concurrently, e.g. by the concurrent Calls.
+	mu     sync.Mutex
 	routes map[string]message.Handler // topic -> handler
 
 	logger *logger.Logger
@@ -57,21 +61,29 @@ func (mb *MessageBus) Disconnect() error {
 
 func (mb *MessageBus) Publish(msg *message.Message) error {
 	mb.logger.Debugf("send message: %s", msg)
-	token := mb.client.Publish(msg.Topic, byte(mb.qos), false, msg.Payload)
+	frame, err := msg.Encode()
+	if err != nil {
+		return errors.MessageBus.Cause(err, "fail to encode the message: %s", msg)
+	}
+	token := mb.client.Publish(msg.Topic, byte(mb.qos), false, frame)
 	return mb.handleToken(token)
 }
 
 func (mb *MessageBus) Subscribe(handler message.Handler, topics ...string) error {
 	filters := make(map[string]byte)
+	mb.mu.Lock()
 	for _, topic := range topics {
 		mb.routes[topic] = handler
 		filters[topic] = byte(mb.qos)
 	}
+	mb.mu.Unlock()
 	callback := func(mc mqtt.Client, msg mqtt.Message) {
-		go handler(&message.Message{
-			Topic:   msg.Topic(),
-			Payload: msg.Payload(),
-		})
+		m, err := message.Decode(msg.Topic(), msg.Payload())
+		if err != nil {
+			mb.logger.WithError(err).Errorf("fail to decode the message received from the topic: %s", msg.Topic())
+			return
+		}
+		go handler(m)
 	}
 
 	token := mb.client.SubscribeMultiple(filters, callback)
@@ -79,9 +91,11 @@ func (mb *MessageBus) Subscribe(handler message.Handler, topics ...string) error
 }
 
 func (mb *MessageBus) Unsubscribe(topics ...string) error {
+	mb.mu.Lock()
 	for _, topic := range topics {
 		delete(mb.routes, topic)
 	}
+	mb.mu.Unlock()
 
 	token := mb.client.Unsubscribe(topics...)
 	return mb.handleToken(token)
@@ -170,7 +184,13 @@ func (mb *MessageBus) onConnect(mc mqtt.Client) {
 	reader := mc.OptionsReader()
 	mb.logger.Infof("the connection with %s for the message bus has been established.", reader.Servers()[0].String())
 
+	mb.mu.Lock()
+	routes := make(map[string]message.Handler, len(mb.routes))
 	for tpc, hdl := range mb.routes {
+		routes[tpc] = hdl
+	}
+	mb.mu.Unlock()
+	for tpc, hdl := range routes {
 		if err := mb.Subscribe(hdl, tpc); err != nil {
 			mb.logger.WithError(err).Errorf("fail to resubscribe the topic: %s", tpc)
 		}
@@ -0,0 +1,143 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeMessageBus delivers the messages to the handlers whose topic filters match, supporting "+" only.
+type fakeMessageBus struct {
+	bus.MessageBus
+
+	mu        sync.Mutex
+	published []string
+	handlers  map[string]message.Handler
+}
+
+func (f *fakeMessageBus) Publish(msg *message.Message) error {
+	f.mu.Lock()
+	f.published = append(f.published, msg.Topic)
+	var handlers []message.Handler
+	for filter, handler := range f.handlers {
+		if match(filter, msg.Topic) {
+			handlers = append(handlers, handler)
+		}
+	}
+	f.mu.Unlock()
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (f *fakeMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, topic := range topics {
+		f.handlers[topic] = handler
+	}
+	return nil
+}
+
+func (f *fakeMessageBus) Unsubscribe(topics ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, topic := range topics {
+		delete(f.handlers, topic)
+	}
+	return nil
+}
+
+func match(filter, topic string) bool {
+	fs, ts := strings.Split(filter, TopicLevelSeparator), strings.Split(topic, TopicLevelSeparator)
+	if len(fs) != len(ts) {
+		return false
+	}
+	for i := range fs {
+		if fs[i] != TopicSingleLevelWildcard && fs[i] != ts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Call subscribes the response and the error before publishing the request, like the MQTT message bus.
+func (f *fakeMessageBus) Call(request *message.Message, rspTpc, errTpc string) (*message.Message, error) {
+	ch := make(chan *message.Message, 1)
+	errCh := make(chan *message.Message, 1)
+	if err := f.Subscribe(func(msg *message.Message) { ch <- msg }, rspTpc); err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Unsubscribe(rspTpc) }()
+	if err := f.Subscribe(func(msg *message.Message) { errCh <- msg }, errTpc); err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Unsubscribe(errTpc) }()
+
+	if err := f.Publish(request); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case msg := <-errCh:
+		return nil, errors.Unmarshal(msg.Payload)
+	case <-time.After(time.Second):
+		return nil, errors.MessageBus.Error("call timeout")
+	}
+}
+
+// asyncMessageBus delivers the messages of each subscription in order by its own goroutine like the MQTT
+// message bus, so that a handler being blocked doesn't block the others.
+type asyncMessageBus struct {
+	bus.MessageBus
+
+	mu     sync.Mutex
+	queues map[string]chan *message.Message // topic filter -> the messages to be handled
+}
+
+func newAsyncMessageBus() *asyncMessageBus {
+	return &asyncMessageBus{queues: make(map[string]chan *message.Message)}
+}
+
+func (a *asyncMessageBus) Publish(msg *message.Message) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for filter, queue := range a.queues {
+		if match(filter, msg.Topic) {
+			queue <- msg
+		}
+	}
+	return nil
+}
+
+func (a *asyncMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, topic := range topics {
+		queue := make(chan *message.Message, 1000)
+		a.queues[topic] = queue
+		go func() {
+			for msg := range queue {
+				handler(msg)
+			}
+		}()
+	}
+	return nil
+}
+
+func (a *asyncMessageBus) Unsubscribe(topics ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, topic := range topics {
+		if queue, ok := a.queues[topic]; ok {
+			close(queue)
+			delete(a.queues, topic)
+		}
+	}
+	return nil
+}
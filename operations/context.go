@@ -0,0 +1,27 @@
+package operations
+
+import "context"
+
+type headerContextKey struct{}
+
+// NewHeaderContext returns a copy of the parent carrying the header of the message being handled.
+func NewHeaderContext(parent context.Context, header map[string]string) context.Context {
+	return context.WithValue(parent, headerContextKey{}, header)
+}
+
+// HeaderFromContext returns the header of the message being handled, it is nil if the ctx doesn't carry any.
+func HeaderFromContext(ctx context.Context) map[string]string {
+	header, _ := ctx.Value(headerContextKey{}).(map[string]string)
+	return header
+}
+
+func copyHeader(header map[string]string) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(header))
+	for k, v := range header {
+		copied[k] = v
+	}
+	return copied
+}
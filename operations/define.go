@@ -1,13 +1,236 @@
 package operations
 
-import "github.com/thingio/edge-device-std/models"
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/models"
+	"time"
+)
 
 type DriverInitialization struct {
 	Products []*models.Product `json:"products"`
 	Devices  []*models.Device  `json:"devices"`
 }
 
-type ProductMutation = models.Product
+// ProductMutation is the product updated, it is encoded as the product itself with the Diff,
+// so that it can be decoded as a models.Product by the drivers of earlier versions.
+type ProductMutation struct {
+	*models.Product
+	// Diff is the difference from the revision sent before, it is nil if the product is new.
+	Diff *models.ProductDiff `json:"diff,omitempty"`
+}
+
 type DeviceMutation = models.Device
 type DeviceStatus = models.DeviceStatus
 type DriverStatus = models.DriverStatus
+type DeviceShadow = models.DeviceShadow
+type Alarm = models.Alarm
+type AggregateWindow = models.AggregateWindow
+
+// BatchReadItem indicates a property of a device to read in a batch.
+type BatchReadItem struct {
+	ProductID  string                   `json:"product_id"`
+	DeviceID   string                   `json:"device_id"`
+	PropertyID models.ProductPropertyID `json:"property_id"`
+	// HardRead indicates whether to read the property from the real device instead of the device twin.
+	HardRead bool `json:"hard_read"`
+}
+
+// BatchReadResult is the result of a BatchReadItem, either Props or Error will be filled.
+type BatchReadResult struct {
+	ProductID  string                                          `json:"product_id"`
+	DeviceID   string                                          `json:"device_id"`
+	PropertyID models.ProductPropertyID                        `json:"property_id"`
+	Props      map[models.ProductPropertyID]*models.DeviceData `json:"props,omitempty"`
+	Error      *errors.CommonEdgeError                         `json:"error,omitempty"`
+}
+
+// NewBatchReadResult returns the result of the item, the err will be wrapped as an EdgeError if it is not nil.
+func NewBatchReadResult(item *BatchReadItem, props map[models.ProductPropertyID]*models.DeviceData,
+	err error) *BatchReadResult {
+	result := &BatchReadResult{
+		ProductID:  item.ProductID,
+		DeviceID:   item.DeviceID,
+		PropertyID: item.PropertyID,
+	}
+	if err != nil {
+		result.Error = errors.NewCommonEdgeErrorWrapper(err)
+	} else {
+		result.Props = props
+	}
+	return result
+}
+
+// DefaultFanOutConcurrency is the maximum number of the devices operated at the same time in a fan-out operation.
+const DefaultFanOutConcurrency = 16
+
+// FanOutOptions are the options of the fan-out operations against the devices selected by the labels.
+type FanOutOptions struct {
+	// Concurrency is the maximum number of the devices operated at the same time,
+	// if it is 0, the DefaultFanOutConcurrency is used.
+	Concurrency int `json:"concurrency"`
+	// HardRead indicates whether to read the property from the real devices instead of the device twins.
+	HardRead bool `json:"hard_read"`
+	// Devices lists the devices to be selected, if it is nil, the devices sent to the drivers by the
+	// MetaManagerClient of the same ManagerClient are selected.
+	Devices DeviceLister `json:"-"`
+}
+
+// FanOutResult is the result of a fan-out operation on a device, either Data or Error will be filled,
+// the Data is the properties read or the outputs of the method called, and it is empty for the writes.
+type FanOutResult struct {
+	ProtocolID string                        `json:"protocol_id"`
+	ProductID  string                        `json:"product_id"`
+	DeviceID   string                        `json:"device_id"`
+	Data       map[string]*models.DeviceData `json:"data,omitempty"`
+	Error      *errors.CommonEdgeError       `json:"error,omitempty"`
+}
+
+// DiscoveryRequest is sent by the manager to ask the driver to scan devices.
+type DiscoveryRequest struct {
+	// Params are the protocol-specific parameters of scanning, e.g. the subnet or the broadcast port.
+	Params map[string]string `json:"params"`
+	// TimeoutSecond is the maximum duration of scanning, if it is 0, the scan runs until it finishes or is canceled.
+	TimeoutSecond int `json:"timeout_second"`
+}
+
+// DiscoveryEvent is streamed back by the driver during scanning, the last one is marked with Done,
+// and carries the Error if the scan failed.
+type DiscoveryEvent struct {
+	Device *models.Device          `json:"device,omitempty"`
+	Done   bool                    `json:"done"`
+	Error  *errors.CommonEdgeError `json:"error,omitempty"`
+}
+
+// ShadowPatch is sent by the manager to change the desired state of a device shadow.
+type ShadowPatch struct {
+	// Desired will be merged into the desired state, a null value removes the property from the desired state.
+	Desired map[models.ProductPropertyID]*models.DeviceData `json:"desired"`
+	// Version is the version of the shadow which the patch is based on, the patch will be rejected if
+	// the shadow has been changed since then. If it is 0, the patch will be applied without checking.
+	Version int64 `json:"version"`
+}
+
+type (
+	QueuedCommandKind = string
+	CommandStatus     = string
+)
+
+const (
+	QueuedCommandKindWrite QueuedCommandKind = "write" // write the property
+	QueuedCommandKindCall  QueuedCommandKind = "call"  // call the method
+
+	CommandStatusQueued    CommandStatus = "queued"    // waiting for the device to be connected
+	CommandStatusSucceeded CommandStatus = "succeeded" // executed successfully
+	CommandStatusFailed    CommandStatus = "failed"    // executed but failed
+	CommandStatusExpired   CommandStatus = "expired"   // not executed before expiring
+)
+
+// QueuedCommand is a write or call which will be executed once the device is connected.
+type QueuedCommand struct {
+	Kind QueuedCommandKind `json:"kind"`
+	// Values are the properties to write, or the ins of the method to call.
+	Values map[models.ProductPropertyID]*models.DeviceData `json:"values"`
+	// ExpireAt is the deadline of executing the command, the command will never expire if it is zero.
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+func (c *QueuedCommand) Expired(now time.Time) bool {
+	return !c.ExpireAt.IsZero() && now.After(c.ExpireAt)
+}
+
+// CommandAck indicates that the driver has accepted a QueuedCommand.
+type CommandAck struct {
+	ReqID  string        `json:"req_id"`
+	Status CommandStatus `json:"status"`
+}
+
+// CommandCompletion is published by the driver when a QueuedCommand is executed or expired.
+type CommandCompletion struct {
+	ReqID       string                                          `json:"req_id"`
+	ProductID   string                                          `json:"product_id"`
+	DeviceID    string                                          `json:"device_id"`
+	FuncID      models.ProductFuncID                            `json:"func_id"`
+	Kind        QueuedCommandKind                               `json:"kind"`
+	Status      CommandStatus                                   `json:"status"`
+	Outs        map[models.ProductPropertyID]*models.DeviceData `json:"outs,omitempty"` // the outs of the method
+	Error       *errors.CommonEdgeError                         `json:"error,omitempty"`
+	CompletedAt time.Time                                       `json:"completed_at"`
+}
+
+type OTAStatus = string
+
+const (
+	OTAStatusSucceeded OTAStatus = "succeeded"
+	OTAStatusFailed    OTAStatus = "failed"
+	OTAStatusCanceled  OTAStatus = "canceled"
+)
+
+// OTARequest is sent by the manager to start updating a device.
+type OTARequest struct {
+	Artifact *models.Artifact `json:"artifact"`
+	// TimeoutSecond is the maximum duration of updating, if it is 0, the update runs until it finishes or is canceled.
+	TimeoutSecond int `json:"timeout_second"`
+}
+
+// OTAAck indicates that the driver has started updating the device, the ReqID identifies the update.
+type OTAAck struct {
+	ReqID string `json:"req_id"`
+}
+
+// OTAProgress is published by the driver while updating the device.
+type OTAProgress struct {
+	ReqID     string             `json:"req_id"`
+	ProductID string             `json:"product_id"`
+	DeviceID  string             `json:"device_id"`
+	Stage     models.UpdateStage `json:"stage"`
+	Percent   int                `json:"percent"`
+	Detail    string             `json:"detail,omitempty"`
+	Ts        time.Time          `json:"ts"`
+}
+
+// OTAResult is published by the driver when the update is finished.
+type OTAResult struct {
+	ReqID     string                  `json:"req_id"`
+	ProductID string                  `json:"product_id"`
+	DeviceID  string                  `json:"device_id"`
+	Status    OTAStatus               `json:"status"`
+	Version   string                  `json:"version,omitempty"` // the version running on the device after updating
+	Error     *errors.CommonEdgeError `json:"error,omitempty"`
+	Ts        time.Time               `json:"ts"`
+}
+
+// AlarmShelve is sent by the manager to suppress the changes of an alarm for a while.
+type AlarmShelve struct {
+	DurationSecond int `json:"duration_second"`
+}
+
+// HistoryQuery selects the recorded data of a device.
+type HistoryQuery struct {
+	// PropertyIDs selects the properties or the fields of the event, all are selected if it is empty.
+	PropertyIDs []models.ProductPropertyID `json:"property_ids,omitempty"`
+	// EventID selects the fields of the event, the properties are selected if it is empty.
+	EventID models.ProductEventID `json:"event_id,omitempty"`
+	Start   time.Time             `json:"start"`
+	End     time.Time             `json:"end"`
+	// Interval is the downsampling interval, e.g. "1m", the raw data is returned if it is empty.
+	Interval string `json:"interval,omitempty"`
+	// Aggregation is the function to aggregate the data within each interval, it's avg by default.
+	Aggregation models.AggregateFunction `json:"aggregation,omitempty"`
+	// Limit is the maximum number of points in a page.
+	Limit int `json:"limit,omitempty"`
+	// Cursor is the NextCursor of the previous page, the first page is returned if it is empty.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// HistoryPoint is a recorded or downsampled value of a property.
+type HistoryPoint struct {
+	PropertyID models.ProductPropertyID `json:"property_id"`
+	Data       *models.DeviceData       `json:"data"`
+}
+
+// HistoryResult is a page of the points in the order of their timestamps.
+type HistoryResult struct {
+	Points []*HistoryPoint `json:"points"`
+	// NextCursor is used to query the next page, it is empty if there are no more points.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/models"
+	"sort"
+	"sync"
+)
+
+// DeviceLister lists the devices operated by the fan-out operations of the DataManagerClient.
+type DeviceLister interface {
+	// ListDevices returns the devices of the protocol, or all protocols if the protocolID is the
+	// TopicSingleLevelWildcard, keyed by their protocol IDs.
+	ListDevices(protocolID string) (devices map[string][]*models.Device, err error)
+}
+
+// newDeviceRegistry returns an empty deviceRegistry.
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{devices: make(map[string]map[string]*models.Device)}
+}
+
+// deviceRegistry keeps the devices sent to the drivers by the MetaManagerClient, it is the default
+// DeviceLister of the fan-out operations of the DataManagerClient created along with the MetaManagerClient.
+// Only the protocols initialized by the MetaManagerClient are known by the registry.
+type deviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[string]map[string]*models.Device // protocol ID -> device ID -> device
+}
+
+// selectedDevice is a device selected with its protocol.
+type selectedDevice struct {
+	protocolID string
+	device     *models.Device
+}
+
+// init replaces the devices of the protocol.
+func (r *deviceRegistry) init(protocolID string, devices []*models.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[protocolID] = make(map[string]*models.Device, len(devices))
+	for _, device := range devices {
+		r.devices[protocolID][device.ID] = device
+	}
+}
+
+func (r *deviceRegistry) set(protocolID string, device *models.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// the device may be moved from another protocol
+	for _, devices := range r.devices {
+		delete(devices, device.ID)
+	}
+	if _, ok := r.devices[protocolID]; !ok {
+		r.devices[protocolID] = make(map[string]*models.Device)
+	}
+	r.devices[protocolID][device.ID] = device
+}
+
+func (r *deviceRegistry) delete(protocolID, deviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.devices[protocolID], deviceID)
+}
+
+// deleteProduct deletes the devices of the product of the protocol.
+func (r *deviceRegistry) deleteProduct(protocolID, productID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, device := range r.devices[protocolID] {
+		if device.ProductID == productID {
+			delete(r.devices[protocolID], id)
+		}
+	}
+}
+
+func (r *deviceRegistry) ListDevices(protocolID string) (map[string][]*models.Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	listed := make(map[string][]*models.Device)
+	for pid, devices := range r.devices {
+		if protocolID != TopicSingleLevelWildcard && protocolID != pid {
+			continue
+		}
+		listed[pid] = make([]*models.Device, 0, len(devices))
+		for _, device := range devices {
+			listed[pid] = append(listed[pid], device)
+		}
+	}
+	if len(listed) == 0 {
+		return nil, errors.BadRequest.Error("the devices of the protocol %s are unknown, initialize the driver "+
+			"by the MetaManagerClient of the same ManagerClient, or list them by FanOutOptions.Devices", protocolID)
+	}
+	return listed, nil
+}
+
+// selectDevices returns the devices listed by the lister whose labels match the selector,
+// ordered by the protocol IDs and the device IDs.
+func selectDevices(lister DeviceLister, protocolID string, selector *models.LabelSelector) ([]*selectedDevice, error) {
+	listed, err := lister.ListDevices(protocolID)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]*selectedDevice, 0)
+	for pid, devices := range listed {
+		for _, device := range devices {
+			if selector.Matches(device.DeviceLabels) {
+				selected = append(selected, &selectedDevice{protocolID: pid, device: device})
+			}
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].protocolID != selected[j].protocolID {
+			return selected[i].protocolID < selected[j].protocolID
+		}
+		return selected[i].device.ID < selected[j].device.ID
+	})
+	return selected, nil
+}
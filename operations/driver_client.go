@@ -3,15 +3,24 @@ package operations
 import (
 	"github.com/thingio/edge-device-std/logger"
 	"github.com/thingio/edge-device-std/models"
-	bus "github.com/thingio/edge-device-std/msgbus"
+	"github.com/thingio/edge-device-std/msgbus/buffer"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
 )
 
 func NewDriverClient(mb bus.MessageBus, lg *logger.Logger) (DriverClient, error) {
+	return NewBufferedDriverClient(mb, nil, lg)
+}
+
+// NewBufferedDriverClient returns a DriverClient whose device properties and events will be published
+// through the Buffer, so that they won't be lost while the message bus is unreachable.
+// The Buffer is optional, it works as same as NewDriverClient if the Buffer is nil.
+func NewBufferedDriverClient(mb bus.MessageBus, buf *buffer.Buffer, lg *logger.Logger) (DriverClient, error) {
 	mdc, err := newMetaDriverClient(mb, lg)
 	if err != nil {
 		return nil, err
 	}
-	ddc, err := newDataDriverClient(mb, lg)
+	ddc, err := newDataDriverClient(mb, buf, lg)
 	if err != nil {
 		return nil, err
 	}
@@ -64,22 +73,37 @@ type (
 			props map[models.ProductPropertyID]*models.DeviceData) error
 		PublishDeviceEvent(protocolID, productID, deviceID string, eventID models.ProductEventID,
 			props map[models.ProductPropertyID]*models.DeviceData) error
+		// PublishShadowDelta publishes the desired values which haven't been applied to the device yet.
+		PublishShadowDelta(protocolID, productID, deviceID string, delta map[models.ProductPropertyID]*models.DeviceData) error
+		// PublishCommandCompletion publishes the result of a queued command.
+		PublishCommandCompletion(protocolID string, completion *CommandCompletion) error
+		// PublishAlarm publishes the change of the alarm.
+		PublishAlarm(protocolID string, alarm *models.Alarm) error
+		// PublishAggregate publishes the aggregated values of the properties within the window.
+		PublishAggregate(protocolID string, window *models.AggregateWindow) error
+
+		// SetProductTopics loads the custom topics declared by the product, the operations of the product
+		// will be published to them instead of the topics formed by the Schemas, see TopicMapping.
+		SetProductTopics(product *models.Product) error
+		RemoveProductTopics(productID string)
 	}
 	dataDriverClient struct {
-		mb bus.MessageBus
-		lg *logger.Logger
+		mb     bus.MessageBus
+		buf    *buffer.Buffer
+		topics *TopicMapping
+		lg     *logger.Logger
 	}
 )
 
-func newDataDriverClient(mb bus.MessageBus, lg *logger.Logger) (DataDriverClient, error) {
-	return &dataDriverClient{mb: mb, lg: lg}, nil
+func newDataDriverClient(mb bus.MessageBus, buf *buffer.Buffer, lg *logger.Logger) (DataDriverClient, error) {
+	return &dataDriverClient{mb: mb, buf: buf, topics: NewTopicMapping(), lg: lg}, nil
 }
 
 func (d *dataDriverClient) PublishDeviceStatus(protocolID, productID, deviceID string, status *models.DeviceStatus) error {
 	o := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, "-",
 		DataOperationTypeHealthCheck, EmptyReqID())
 	o.SetValue(status)
-	msg, err := o.ToMessage()
+	msg, err := d.toMessage(o)
 	if err != nil {
 		return err
 	}
@@ -91,11 +115,11 @@ func (d *dataDriverClient) PublishDeviceProps(protocolID, productID, deviceID st
 	o := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, propertyID,
 		DataOperationTypeWatch, EmptyReqID())
 	o.SetValue(props)
-	msg, err := o.ToMessage()
+	msg, err := d.toMessage(o)
 	if err != nil {
 		return err
 	}
-	return d.mb.Publish(msg)
+	return d.publishBuffered(msg)
 }
 
 func (d *dataDriverClient) PublishDeviceEvent(protocolID, productID, deviceID string, eventID models.ProductEventID,
@@ -103,9 +127,83 @@ func (d *dataDriverClient) PublishDeviceEvent(protocolID, productID, deviceID st
 	o := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, eventID,
 		DataOperationTypeEvent, EmptyReqID())
 	o.SetValue(props)
-	msg, err := o.ToMessage()
+	msg, err := d.toMessage(o)
+	if err != nil {
+		return err
+	}
+	return d.publishBuffered(msg)
+}
+
+func (d *dataDriverClient) PublishShadowDelta(protocolID, productID, deviceID string,
+	delta map[models.ProductPropertyID]*models.DeviceData) error {
+	o := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, "-",
+		DataOperationTypeShadowDelta, EmptyReqID())
+	o.SetValue(delta)
+	msg, err := d.toMessage(o)
 	if err != nil {
 		return err
 	}
 	return d.mb.Publish(msg)
 }
+
+func (d *dataDriverClient) PublishCommandCompletion(protocolID string, completion *CommandCompletion) error {
+	o := NewDataOperation(OperationModeUp, protocolID, completion.ProductID, completion.DeviceID, completion.FuncID,
+		DataOperationTypeComplete, completion.ReqID)
+	o.SetValue(completion)
+	msg, err := d.toMessage(o)
+	if err != nil {
+		return err
+	}
+	return d.publishBuffered(msg)
+}
+
+func (d *dataDriverClient) PublishAlarm(protocolID string, alarm *models.Alarm) error {
+	o := NewDataOperation(OperationModeUp, protocolID, alarm.ProductID, alarm.DeviceID, alarm.AlarmID,
+		DataOperationTypeAlarm, EmptyReqID())
+	o.SetValue(alarm)
+	msg, err := d.toMessage(o)
+	if err != nil {
+		return err
+	}
+	return d.publishBuffered(msg)
+}
+
+func (d *dataDriverClient) PublishAggregate(protocolID string, window *models.AggregateWindow) error {
+	o := NewDataOperation(OperationModeUp, protocolID, window.ProductID, window.DeviceID, window.AggregationID,
+		DataOperationTypeAggregate, EmptyReqID())
+	o.SetValue(window)
+	msg, err := d.toMessage(o)
+	if err != nil {
+		return err
+	}
+	return d.publishBuffered(msg)
+}
+
+func (d *dataDriverClient) SetProductTopics(product *models.Product) error {
+	return d.topics.SetProduct(product)
+}
+
+func (d *dataDriverClient) RemoveProductTopics(productID string) {
+	d.topics.RemoveProduct(productID)
+}
+
+// toMessage converts the operation into a message, whose topic is the custom one of the product if declared.
+func (d *dataDriverClient) toMessage(o *DataOperation) (*message.Message, error) {
+	msg, err := o.ToMessage()
+	if err != nil {
+		return nil, err
+	}
+	if template, ok := d.topics.template(o.productID, o.optType); ok {
+		msg.Topic = template.filter(o.deviceID, o.funcID)
+	}
+	return msg, nil
+}
+
+// publishBuffered publishes the message through the buffer if it is enabled,
+// the original timestamps of the device data are kept in the payload while being buffered.
+func (d *dataDriverClient) publishBuffered(msg *message.Message) error {
+	if d.buf == nil {
+		return d.mb.Publish(msg)
+	}
+	return d.buf.Publish(msg)
+}
@@ -1,11 +1,14 @@
 package operations
 
 import (
+	"context"
 	"github.com/thingio/edge-device-std/errors"
 	"github.com/thingio/edge-device-std/logger"
 	"github.com/thingio/edge-device-std/models"
-	bus "github.com/thingio/edge-device-std/msgbus"
+	"github.com/thingio/edge-device-std/msgbus/bus"
 	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+	"time"
 )
 
 func NewDriverService(mb bus.MessageBus, lg *logger.Logger) (DriverService, error) {
@@ -39,16 +42,38 @@ type (
 		InitializeDriverHandler(protocolID string, handler func(products []*models.Product, devices []*models.Device) error) error
 
 		MutateProductHandler(protocolID string, u func(product *models.Product) error, d func(productID string) error) error
+		// MutateProductDiffHandler is like the MutateProductHandler, but the difference from the revision
+		// sent before is also handed to the u, e.g. to rebuild the twins only if the diff is breaking.
+		// The diff is nil if the product is new, or it is sent by the manager of an earlier version.
+		MutateProductDiffHandler(protocolID string, u func(product *models.Product, diff *models.ProductDiff) error,
+			d func(productID string) error) error
 		MutateDeviceHandler(protocolID string, u func(device *models.Device) error, d func(deviceID string) error) error
+
+		// DiscoverHandler serves the scans requested by the manager using the discoverer,
+		// it should only be registered if the driver implements models.Discoverer.
+		DiscoverHandler(protocolID string, discoverer models.Discoverer) error
 	}
 	metaDriverService struct {
 		mb bus.MessageBus
 		lg *logger.Logger
+
+		scans    map[string]context.CancelFunc // reqID -> cancel the running scan
+		canceled map[string]time.Time          // reqID -> when the scan not started yet was canceled
+		scansMu  sync.Mutex
 	}
 )
 
+// pendingCancelTTL is how long the cancel received before its scan is kept,
+// the scan requested after then will not be canceled.
+const pendingCancelTTL = time.Minute
+
 func newMetaDriverService(mb bus.MessageBus, lg *logger.Logger) (MetaDriverService, error) {
-	return &metaDriverService{mb: mb, lg: lg}, nil
+	return &metaDriverService{
+		mb:       mb,
+		lg:       lg,
+		scans:    make(map[string]context.CancelFunc),
+		canceled: make(map[string]time.Time),
+	}, nil
 }
 
 func (m *metaDriverService) InitializeDriverHandler(protocolID string,
@@ -64,6 +89,13 @@ func (m *metaDriverService) InitializeDriverHandler(protocolID string,
 
 func (m *metaDriverService) MutateProductHandler(protocolID string,
 	u func(product *models.Product) error, d func(productID string) error) error {
+	return m.MutateProductDiffHandler(protocolID, func(product *models.Product, diff *models.ProductDiff) error {
+		return u(product)
+	}, d)
+}
+
+func (m *metaDriverService) MutateProductDiffHandler(protocolID string,
+	u func(product *models.Product, diff *models.ProductDiff) error, d func(productID string) error) error {
 	return m.metaHandler(protocolID, MetaOperationTypeProductMutation, func(o *MetaOperation) error {
 		if len(o.payload) == 0 { // delete the product if the payload is empty
 			return d(o.reqID)
@@ -73,7 +105,10 @@ func (m *metaDriverService) MutateProductHandler(protocolID string,
 		if err := o.Unmarshal(v); err != nil {
 			return err
 		}
-		return u(v)
+		if v.Product == nil {
+			return errors.BadRequest.Error("the product of the mutation is required")
+		}
+		return u(v.Product, v.Diff)
 	})
 }
 
@@ -92,6 +127,85 @@ func (m *metaDriverService) MutateDeviceHandler(protocolID string,
 	})
 }
 
+func (m *metaDriverService) DiscoverHandler(protocolID string, discoverer models.Discoverer) error {
+	if err := m.metaHandler(protocolID, MetaOperationTypeDiscoverCancel, func(o *MetaOperation) error {
+		m.scansMu.Lock()
+		defer m.scansMu.Unlock()
+		if cancel, ok := m.scans[o.reqID]; ok {
+			cancel()
+			return nil
+		}
+		// the cancel may be received before the scan, it is recorded to cancel the scan once it is requested
+		now := time.Now()
+		for reqID, ts := range m.canceled {
+			if now.Sub(ts) > pendingCancelTTL {
+				delete(m.canceled, reqID)
+			}
+		}
+		m.canceled[o.reqID] = now
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return m.metaHandler(protocolID, MetaOperationTypeDiscover, func(o *MetaOperation) error {
+		request := new(DiscoveryRequest)
+		if err := o.Unmarshal(request); err != nil {
+			return err
+		}
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if request.TimeoutSecond > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), time.Duration(request.TimeoutSecond)*time.Second)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		m.scansMu.Lock()
+		if _, ok := m.canceled[o.reqID]; ok {
+			delete(m.canceled, o.reqID)
+			cancel()
+		}
+		m.scans[o.reqID] = cancel
+		m.scansMu.Unlock()
+		defer func() {
+			m.scansMu.Lock()
+			delete(m.scans, o.reqID)
+			m.scansMu.Unlock()
+			cancel()
+		}()
+
+		found := make(chan *models.Device)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			for device := range found {
+				if err := m.publishDiscoveryEvent(o, &DiscoveryEvent{Device: device}); err != nil {
+					m.lg.WithError(err).Errorf("fail to publish the discovered device: %s", device.ID)
+				}
+			}
+		}()
+		err := discoverer.Discover(ctx, request.Params, found)
+		close(found)
+		<-forwarded
+
+		done := &DiscoveryEvent{Done: true}
+		if err != nil && ctx.Err() != context.Canceled {
+			done.Error = errors.NewCommonEdgeErrorWrapper(err)
+		}
+		return m.publishDiscoveryEvent(o, done)
+	})
+}
+
+func (m *metaDriverService) publishDiscoveryEvent(request *MetaOperation, event *DiscoveryEvent) error {
+	o := NewMetaOperation(OperationModeUp, request.protocolID, MetaOperationTypeDiscover, request.reqID)
+	o.SetValue(event)
+	msg, err := o.ToMessage()
+	if err != nil {
+		return err
+	}
+	return m.mb.Publish(msg)
+}
+
 func (m *metaDriverService) metaHandler(protocolID string, optType MetaOperationType,
 	handler func(o *MetaOperation) error) error {
 	schema := NewMetaOperation(OperationModeDown, protocolID, optType, TopicSingleLevelWildcard)
@@ -122,41 +236,116 @@ type (
 			propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error) error
 		CallHandler(protocolID string, handler func(productID, deviceID string, methodID models.ProductMethodID,
 			ins map[string]*models.DeviceData) (outs map[string]*models.DeviceData, err error)) error
+
+		// The context handlers are the variants of the handlers above, whose handler is passed a ctx carrying
+		// the header of the request, see HeaderFromContext, e.g. the trace context to correlate the calls
+		// with the real device. Only one of the variants should be registered for an operation.
+
+		ReadContextHandler(protocolID string, handler func(ctx context.Context, productID, deviceID string,
+			propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error
+		HardReadContextHandler(protocolID string, handler func(ctx context.Context, productID, deviceID string,
+			propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error
+		WriteContextHandler(protocolID string, handler func(ctx context.Context, productID, deviceID string,
+			propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error) error
+		CallContextHandler(protocolID string, handler func(ctx context.Context, productID, deviceID string,
+			methodID models.ProductMethodID, ins map[string]*models.DeviceData) (outs map[string]*models.DeviceData, err error)) error
+
+		// BatchReadHandler handles the reads of properties across devices in one request,
+		// the handler should return a result for each item, and the failure of an item should
+		// be recorded in its result rather than failing the whole batch.
+		BatchReadHandler(protocolID string, handler func(ctx context.Context, items []*BatchReadItem) ([]*BatchReadResult, error)) error
+
+		ShadowGetHandler(protocolID string, handler func(productID, deviceID string) (*models.DeviceShadow, error)) error
+		ShadowPatchHandler(protocolID string, handler func(productID, deviceID string,
+			patch *ShadowPatch) (*models.DeviceShadow, error)) error
+
+		// QueueHandler accepts the commands queued by the manager, the reqID identifies the command,
+		// and should be used to publish its CommandCompletion.
+		QueueHandler(protocolID string, handler func(productID, deviceID string, funcID models.ProductFuncID,
+			reqID string, command *QueuedCommand) (*CommandAck, error)) error
+
+		// OTAHandler serves the updates requested by the manager, the handler should return the twin of
+		// the device if it implements models.Updater. The progress and result of updates are published
+		// asynchronously, and only one update is allowed to run for a device at the same time.
+		OTAHandler(protocolID string, handler func(productID, deviceID string) (models.Updater, error)) error
+
+		AlarmAckHandler(protocolID string, handler func(productID, deviceID, alarmID string) (*models.Alarm, error)) error
+		AlarmShelveHandler(protocolID string, handler func(productID, deviceID, alarmID string,
+			shelve *AlarmShelve) (*models.Alarm, error)) error
 	}
 	dataDriverService struct {
 		mb bus.MessageBus
 		lg *logger.Logger
+
+		updates   map[string]*otaTask // reqID -> the running update
+		updatesMu sync.Mutex
+	}
+	otaTask struct {
+		deviceID string
+		cancel   context.CancelFunc
 	}
 )
 
 func newDataDriverService(mb bus.MessageBus, lg *logger.Logger) (DataDriverService, error) {
-	return &dataDriverService{mb: mb, lg: lg}, nil
+	return &dataDriverService{mb: mb, lg: lg, updates: make(map[string]*otaTask)}, nil
 }
 
 func (d *dataDriverService) ReadHandler(protocolID string, handler func(productID string, deviceID string,
+	propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error {
+	return d.ReadContextHandler(protocolID, func(_ context.Context, productID, deviceID string,
+		propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+		return handler(productID, deviceID, propertyID)
+	})
+}
+
+func (d *dataDriverService) HardReadHandler(protocolID string, handler func(productID string, deviceID string,
+	propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error {
+	return d.HardReadContextHandler(protocolID, func(_ context.Context, productID, deviceID string,
+		propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+		return handler(productID, deviceID, propertyID)
+	})
+}
+
+func (d *dataDriverService) WriteHandler(protocolID string, handler func(productID string, deviceID string,
+	propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error) error {
+	return d.WriteContextHandler(protocolID, func(_ context.Context, productID, deviceID string,
+		propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error {
+		return handler(productID, deviceID, propertyID, props)
+	})
+}
+
+func (d *dataDriverService) CallHandler(protocolID string, handler func(productID string, deviceID string, methodID models.ProductMethodID,
+	ins map[string]*models.DeviceData) (outs map[string]*models.DeviceData, err error)) error {
+	return d.CallContextHandler(protocolID, func(_ context.Context, productID, deviceID string,
+		methodID models.ProductMethodID, ins map[string]*models.DeviceData) (map[string]*models.DeviceData, error) {
+		return handler(productID, deviceID, methodID, ins)
+	})
+}
+
+func (d *dataDriverService) ReadContextHandler(protocolID string, handler func(ctx context.Context, productID string, deviceID string,
 	propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error {
 	return d.dataHandler(protocolID, DataOperationTypeRead,
-		func(o *DataOperation) (outs map[string]*models.DeviceData, err error) {
+		func(o *DataOperation) (outs interface{}, err error) {
 			productID, deviceID, propertyID := o.productID, o.deviceID, o.funcID
-			return handler(productID, deviceID, propertyID)
+			return handler(o.context(), productID, deviceID, propertyID)
 		},
 	)
 }
 
-func (d *dataDriverService) HardReadHandler(protocolID string, handler func(productID string, deviceID string,
+func (d *dataDriverService) HardReadContextHandler(protocolID string, handler func(ctx context.Context, productID string, deviceID string,
 	propertyID models.ProductPropertyID) (props map[models.ProductPropertyID]*models.DeviceData, err error)) error {
 	return d.dataHandler(protocolID, DataOperationTypeHardRead,
-		func(o *DataOperation) (outs map[string]*models.DeviceData, err error) {
+		func(o *DataOperation) (outs interface{}, err error) {
 			productID, deviceID, propertyID := o.productID, o.deviceID, o.funcID
-			return handler(productID, deviceID, propertyID)
+			return handler(o.context(), productID, deviceID, propertyID)
 		},
 	)
 }
 
-func (d *dataDriverService) WriteHandler(protocolID string, handler func(productID string, deviceID string,
+func (d *dataDriverService) WriteContextHandler(protocolID string, handler func(ctx context.Context, productID string, deviceID string,
 	propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error) error {
 	return d.dataHandler(protocolID, DataOperationTypeWrite,
-		func(o *DataOperation) (outs map[string]*models.DeviceData, err error) {
+		func(o *DataOperation) (outs interface{}, err error) {
 			productID, deviceID, propertyID := o.productID, o.deviceID, o.funcID
 			props := make(map[models.ProductPropertyID]*models.DeviceData)
 			if err = o.Unmarshal(&props); err != nil {
@@ -164,15 +353,16 @@ func (d *dataDriverService) WriteHandler(protocolID string, handler func(product
 					"from the device[%s]", propertyID, deviceID)
 				return
 			}
-			return map[models.ProductPropertyID]*models.DeviceData{}, handler(productID, deviceID, propertyID, props)
+			return map[models.ProductPropertyID]*models.DeviceData{}, handler(o.context(), productID, deviceID, propertyID, props)
 		},
 	)
 }
 
-func (d *dataDriverService) CallHandler(protocolID string, handler func(productID string, deviceID string, methodID models.ProductMethodID,
+func (d *dataDriverService) CallContextHandler(protocolID string, handler func(ctx context.Context, productID string, deviceID string,
+	methodID models.ProductMethodID,
 	ins map[string]*models.DeviceData) (outs map[string]*models.DeviceData, err error)) error {
 	return d.dataHandler(protocolID, DataOperationTypeCall,
-		func(o *DataOperation) (outs map[string]*models.DeviceData, err error) {
+		func(o *DataOperation) (outs interface{}, err error) {
 			productID, deviceID, methodID := o.productID, o.deviceID, o.funcID
 			ins := make(map[string]*models.DeviceData)
 			if err = o.Unmarshal(&ins); err != nil {
@@ -180,13 +370,214 @@ func (d *dataDriverService) CallHandler(protocolID string, handler func(productI
 					"of the device[%s]", methodID, deviceID)
 				return
 			}
-			return handler(productID, deviceID, methodID, ins)
+			return handler(o.context(), productID, deviceID, methodID, ins)
 		},
 	)
 }
 
+func (d *dataDriverService) BatchReadHandler(protocolID string,
+	handler func(ctx context.Context, items []*BatchReadItem) ([]*BatchReadResult, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeBatchRead,
+		func(o *DataOperation) (outs interface{}, err error) {
+			items := make([]*BatchReadItem, 0)
+			if err = o.Unmarshal(&items); err != nil {
+				d.lg.WithError(err).Errorf("fail to unmarshal the items of the batch read")
+				return
+			}
+			return handler(o.context(), items)
+		},
+	)
+}
+
+func (d *dataDriverService) ShadowGetHandler(protocolID string,
+	handler func(productID, deviceID string) (*models.DeviceShadow, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeShadowGet,
+		func(o *DataOperation) (outs interface{}, err error) {
+			return handler(o.productID, o.deviceID)
+		},
+	)
+}
+
+func (d *dataDriverService) ShadowPatchHandler(protocolID string,
+	handler func(productID, deviceID string, patch *ShadowPatch) (*models.DeviceShadow, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeShadowPatch,
+		func(o *DataOperation) (outs interface{}, err error) {
+			patch := new(ShadowPatch)
+			if err = o.Unmarshal(patch); err != nil {
+				d.lg.WithError(err).Errorf("fail to unmarshal the shadow patch of the device[%s]", o.deviceID)
+				return
+			}
+			return handler(o.productID, o.deviceID, patch)
+		},
+	)
+}
+
+func (d *dataDriverService) QueueHandler(protocolID string, handler func(productID, deviceID string,
+	funcID models.ProductFuncID, reqID string, command *QueuedCommand) (*CommandAck, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeQueue,
+		func(o *DataOperation) (outs interface{}, err error) {
+			command := new(QueuedCommand)
+			if err = o.Unmarshal(command); err != nil {
+				d.lg.WithError(err).Errorf("fail to unmarshal the queued command of the device[%s]", o.deviceID)
+				return
+			}
+			return handler(o.productID, o.deviceID, o.funcID, o.reqID, command)
+		},
+	)
+}
+
+func (d *dataDriverService) OTAHandler(protocolID string,
+	handler func(productID, deviceID string) (models.Updater, error)) error {
+	if err := d.dataHandler(protocolID, DataOperationTypeOTACancel,
+		func(o *DataOperation) (outs interface{}, err error) {
+			ack := new(OTAAck)
+			if err = o.Unmarshal(ack); err != nil {
+				return
+			}
+			d.updatesMu.Lock()
+			defer d.updatesMu.Unlock()
+			task, ok := d.updates[ack.ReqID]
+			if !ok {
+				return nil, errors.NotFound.Error("the update %s is not running", ack.ReqID)
+			}
+			task.cancel()
+			return map[string]interface{}{}, nil
+		},
+	); err != nil {
+		return err
+	}
+
+	return d.dataHandler(protocolID, DataOperationTypeOTAStart,
+		func(o *DataOperation) (outs interface{}, err error) {
+			request := new(OTARequest)
+			if err = o.Unmarshal(request); err != nil {
+				d.lg.WithError(err).Errorf("fail to unmarshal the OTA request of the device[%s]", o.deviceID)
+				return
+			}
+			if request.Artifact == nil {
+				return nil, errors.BadRequest.Error("the artifact of the OTA request is required")
+			}
+			if len(request.Artifact.Data) > 0 {
+				if err = request.Artifact.Verify(request.Artifact.Data); err != nil {
+					return nil, errors.BadRequest.Cause(err, "")
+				}
+			}
+			updater, err := handler(o.productID, o.deviceID)
+			if err != nil {
+				return nil, err
+			}
+
+			var ctx context.Context
+			var cancel context.CancelFunc
+			if request.TimeoutSecond > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), time.Duration(request.TimeoutSecond)*time.Second)
+			} else {
+				ctx, cancel = context.WithCancel(context.Background())
+			}
+			d.updatesMu.Lock()
+			if _, ok := d.updates[o.reqID]; ok {
+				d.updatesMu.Unlock()
+				cancel()
+				return nil, errors.Conflict.Error("the update %s is running", o.reqID)
+			}
+			for reqID, task := range d.updates {
+				if task.deviceID == o.deviceID {
+					d.updatesMu.Unlock()
+					cancel()
+					return nil, errors.Conflict.Error("the device[%s] is being updated by %s", o.deviceID, reqID)
+				}
+			}
+			d.updates[o.reqID] = &otaTask{deviceID: o.deviceID, cancel: cancel}
+			d.updatesMu.Unlock()
+
+			go d.update(ctx, o, updater, request.Artifact)
+			return &OTAAck{ReqID: o.reqID}, nil
+		},
+	)
+}
+
+func (d *dataDriverService) update(ctx context.Context, request *DataOperation, updater models.Updater,
+	artifact *models.Artifact) {
+	defer func() {
+		d.updatesMu.Lock()
+		if task, ok := d.updates[request.reqID]; ok {
+			task.cancel()
+			delete(d.updates, request.reqID)
+		}
+		d.updatesMu.Unlock()
+	}()
+
+	version, err := updater.Update(ctx, artifact, func(stage models.UpdateStage, percent int, detail string) {
+		d.publish(request, DataOperationTypeOTAProgress, &OTAProgress{
+			ReqID:     request.reqID,
+			ProductID: request.productID,
+			DeviceID:  request.deviceID,
+			Stage:     stage,
+			Percent:   percent,
+			Detail:    detail,
+			Ts:        time.Now(),
+		})
+	})
+	result := &OTAResult{
+		ReqID:     request.reqID,
+		ProductID: request.productID,
+		DeviceID:  request.deviceID,
+		Ts:        time.Now(),
+	}
+	switch {
+	case err == nil:
+		result.Status = OTAStatusSucceeded
+		result.Version = version
+	case ctx.Err() == context.Canceled:
+		result.Status = OTAStatusCanceled
+	default:
+		result.Status = OTAStatusFailed
+		result.Error = errors.NewCommonEdgeErrorWrapper(err)
+	}
+	d.publish(request, DataOperationTypeOTAResult, result)
+}
+
+func (d *dataDriverService) AlarmAckHandler(protocolID string,
+	handler func(productID, deviceID, alarmID string) (*models.Alarm, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeAlarmAck,
+		func(o *DataOperation) (outs interface{}, err error) {
+			return handler(o.productID, o.deviceID, o.funcID)
+		},
+	)
+}
+
+func (d *dataDriverService) AlarmShelveHandler(protocolID string,
+	handler func(productID, deviceID, alarmID string, shelve *AlarmShelve) (*models.Alarm, error)) error {
+	return d.dataHandler(protocolID, DataOperationTypeAlarmShelve,
+		func(o *DataOperation) (outs interface{}, err error) {
+			shelve := new(AlarmShelve)
+			if err = o.Unmarshal(shelve); err != nil {
+				d.lg.WithError(err).Errorf("fail to unmarshal the shelve of the alarm[%s]", o.funcID)
+				return
+			}
+			return handler(o.productID, o.deviceID, o.funcID, shelve)
+		},
+	)
+}
+
+// publish publishes the value as an UP operation correlated with the request.
+func (d *dataDriverService) publish(request *DataOperation, optType DataOperationType, value interface{}) {
+	o := NewDataOperation(OperationModeUp, request.protocolID, request.productID, request.deviceID,
+		request.funcID, optType, request.reqID)
+	o.header = request.header
+	o.SetValue(value)
+	msg, err := o.ToMessage()
+	if err != nil {
+		d.lg.WithError(err).Errorf("fail to parse the message of the %s operation", optType)
+		return
+	}
+	if err = d.mb.Publish(msg); err != nil {
+		d.lg.WithError(err).Errorf("fail to publish the %s operation of the device[%s]", optType, request.deviceID)
+	}
+}
+
 func (d *dataDriverService) dataHandler(protocolID string, optType DataOperationType,
-	handler func(o *DataOperation) (outs map[string]*models.DeviceData, err error)) error {
+	handler func(o *DataOperation) (outs interface{}, err error)) error {
 	schema := NewDataOperation(OperationModeDown, protocolID,
 		TopicSingleLevelWildcard, TopicSingleLevelWildcard, TopicSingleLevelWildcard,
 		optType, TopicSingleLevelWildcard)
@@ -194,10 +585,11 @@ func (d *dataDriverService) dataHandler(protocolID string, optType DataOperation
 	if err := d.mb.Subscribe(func(msg *message.Message) {
 		var err error
 		var request, response *DataOperation
-		var outs map[string]*models.DeviceData
+		var outs interface{}
 		defer func() {
 			response = NewDataOperation(OperationModeUp, request.protocolID, request.productID, request.deviceID,
 				request.funcID, optType, request.reqID)
+			response.header = request.header
 			if err != nil {
 				response.optMode = OperationModeUpErr
 				response.SetValue(errors.NewCommonEdgeErrorWrapper(err))
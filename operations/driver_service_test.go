@@ -0,0 +1,370 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// updater reports a progress, then finishes the update at once or after the block is closed.
+type updater struct {
+	block chan struct{}
+}
+
+func (u *updater) Update(ctx context.Context, artifact *models.Artifact, progress models.UpdateProgressFunc) (string, error) {
+	progress(models.UpdateStageInstalling, 50, "")
+	if u.block != nil {
+		select {
+		case <-u.block:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return artifact.Version, nil
+}
+
+func next(t *testing.T, ch <-chan interface{}) interface{} {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatalf("nothing is received")
+		return nil
+	}
+}
+
+func newOTA(t *testing.T, u models.Updater) (DataManagerClient, DataManagerService) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ds, err := newDataDriverService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ds.OTAHandler("modbus", func(productID, deviceID string) (models.Updater, error) {
+		return u, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newDataManagerClient(mb, newDeviceRegistry(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newDataManagerService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mc, ms
+}
+
+// subscribeOTA subscribes the progress and the result of the update before starting it.
+func subscribeOTA(t *testing.T, ms DataManagerService, reqID string) (progress, result <-chan interface{}) {
+	progress, stopProgress, err := ms.SubscribeOTAProgress("modbus", reqID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopProgress)
+	result, stopResult, err := ms.SubscribeOTAResult("modbus", reqID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopResult)
+	return progress, result
+}
+
+func TestOTA(t *testing.T) {
+	mc, ms := newOTA(t, new(updater))
+	reqID := NewReqID()
+	progress, result := subscribeOTA(t, ms, reqID)
+
+	artifact := &models.Artifact{Name: "firmware", Version: "2.0", Data: []byte("firmware")}
+	ack, err := mc.StartOTA("modbus", "meter", "m1", reqID, &OTARequest{Artifact: artifact})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.ReqID != reqID {
+		t.Errorf("the reqID of the ack = %s, want %s", ack.ReqID, reqID)
+	}
+	// the update finishes before the ack is returned, its progress and result are still received
+	if p := next(t, progress).(*OTAProgress); p.Percent != 50 || p.DeviceID != "m1" {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+	if r := next(t, result).(*OTAResult); r.Status != OTAStatusSucceeded || r.Version != "2.0" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+
+	if _, err = mc.StartOTA("modbus", "meter", "m1", "", &OTARequest{Artifact: artifact}); err == nil {
+		t.Errorf("the update without the reqID should be rejected")
+	}
+}
+
+func TestOTACancel(t *testing.T) {
+	u := &updater{block: make(chan struct{})}
+	defer close(u.block)
+	mc, ms := newOTA(t, u)
+	reqID := NewReqID()
+	_, result := subscribeOTA(t, ms, reqID)
+
+	request := &OTARequest{Artifact: &models.Artifact{Name: "firmware", Version: "2.0", URL: "http://firmware"}}
+	if _, err := mc.StartOTA("modbus", "meter", "m1", reqID, request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mc.StartOTA("modbus", "meter", "m1", reqID, request); err == nil {
+		t.Errorf("the running update should conflict")
+	}
+	if err := mc.CancelOTA("modbus", "meter", "m1", reqID); err != nil {
+		t.Fatal(err)
+	}
+	if r := next(t, result).(*OTAResult); r.Status != OTAStatusCanceled {
+		t.Errorf("the status of the canceled update = %s", r.Status)
+	}
+}
+
+// discoverer finds the devices, then waits until the scan is canceled if it blocks,
+// the error returned by the scan is sent to the returned.
+type discoverer struct {
+	devices  int
+	block    bool
+	returned chan error
+}
+
+func (d *discoverer) Discover(ctx context.Context, params map[string]string, found chan<- *models.Device) (err error) {
+	defer func() { d.returned <- err }()
+	for i := 0; i < d.devices; i++ {
+		select {
+		case found <- &models.Device{ID: strconv.Itoa(i)}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if d.block {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return fmt.Errorf("the scan is not canceled")
+		}
+	}
+	return nil
+}
+
+func newDiscovery(t *testing.T, mb bus.MessageBus, d models.Discoverer) MetaManagerClient {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := newMetaDriverService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ds.DiscoverHandler("modbus", d); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newMetaManagerClient(mb, newDeviceRegistry(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mc
+}
+
+func TestDiscover(t *testing.T) {
+	d := &discoverer{devices: 150, returned: make(chan error, 1)}
+	mc := newDiscovery(t, newAsyncMessageBus(), d)
+	events, cancel, err := mc.Discover(context.Background(), "modbus", &DiscoveryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// the events more than the buffer are not dropped while they are not consumed in time
+	time.Sleep(100 * time.Millisecond)
+	var found int
+	var done *DiscoveryEvent
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if found != d.devices || done == nil || done.Error != nil {
+					t.Errorf("found %d devices with the done %+v, want %d", found, done, d.devices)
+				}
+				return
+			}
+			if event.Done {
+				done = event
+			} else {
+				found++
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("the events are not closed")
+		}
+	}
+}
+
+func TestDiscoverCancel(t *testing.T) {
+	d := &discoverer{devices: 1, block: true, returned: make(chan error, 1)}
+	mc := newDiscovery(t, newAsyncMessageBus(), d)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, _, err := mc.Discover(ctx, "modbus", &DiscoveryRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := <-events; event.Device == nil {
+		t.Fatalf("the first event = %+v, want the device", event)
+	}
+
+	cancel()
+	if err = <-d.returned; err != context.Canceled {
+		t.Errorf("the scan returns %v, want canceled", err)
+	}
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the events are not closed after canceled")
+	}
+}
+
+func TestDiscoverCancelBeforeScan(t *testing.T) {
+	d := &discoverer{block: true, returned: make(chan error, 1)}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	newDiscovery(t, mb, d)
+	done := make(chan *DiscoveryEvent, 1)
+	rspTpc := NewMetaOperation(OperationModeUp, "modbus", MetaOperationTypeDiscover, "r1").Topic().String()
+	if err := mb.Subscribe(func(msg *message.Message) {
+		event := new(DiscoveryEvent)
+		if err := msg.Unmarshal(event); err == nil && event.Done {
+			done <- event
+		}
+	}, rspTpc); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cancel is received before the scan
+	for _, o := range []*MetaOperation{
+		NewMetaOperation(OperationModeDown, "modbus", MetaOperationTypeDiscoverCancel, "r1"),
+		NewMetaOperation(OperationModeDown, "modbus", MetaOperationTypeDiscover, "r1"),
+	} {
+		o.SetValue(&DiscoveryRequest{})
+		msg, err := o.ToMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mb.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-d.returned; err != context.Canceled {
+		t.Errorf("the scan returns %v, want canceled", err)
+	}
+	if event := <-done; event.Error != nil {
+		t.Errorf("the canceled scan is done with %v", event.Error)
+	}
+}
+
+func TestBatchRead(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ds, err := newDataDriverService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ds.BatchReadHandler("modbus", func(ctx context.Context, items []*BatchReadItem) ([]*BatchReadResult, error) {
+		if len(items) == 0 {
+			return nil, errors.BadRequest.Error("no items to read")
+		}
+		results := make([]*BatchReadResult, 0, len(items))
+		for _, item := range items {
+			if item.DeviceID != "m1" {
+				results = append(results, NewBatchReadResult(item, nil,
+					errors.NotFound.Error("the device[%s] is not found", item.DeviceID)))
+				continue
+			}
+			results = append(results, NewBatchReadResult(item, map[models.ProductPropertyID]*models.DeviceData{
+				item.PropertyID: {Name: item.PropertyID, Type: models.PropertyValueTypeFloat, Value: 220.0},
+			}, nil))
+		}
+		return results, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newDataManagerClient(mb, newDeviceRegistry(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := mc.BatchRead("modbus", []*BatchReadItem{
+		{ProductID: "meter", DeviceID: "m1", PropertyID: "voltage"},
+		{ProductID: "meter", DeviceID: "m2", PropertyID: "voltage", HardRead: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("the results = %v, want one for each item", results)
+	}
+	if ok := results[0]; ok.DeviceID != "m1" || ok.Error != nil || ok.Props["voltage"] == nil ||
+		ok.Props["voltage"].Value != 220.0 {
+		t.Errorf("the result of the device m1 = %+v, want the voltage", ok)
+	}
+	if failed := results[1]; failed.DeviceID != "m2" || failed.Props != nil || failed.Error == nil ||
+		failed.Error.Type().Code != errors.NotFound.Code {
+		t.Errorf("the result of the device m2 = %+v, want not found", failed)
+	}
+
+	// the failure of the whole batch is returned as the error of the call
+	if _, err = mc.BatchRead("modbus", nil); err == nil {
+		t.Errorf("the failure of the batch should be returned")
+	}
+}
+
+func TestMutateProduct(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ds, err := newMetaDriverService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diffs []*models.ProductDiff
+	if err = ds.MutateProductDiffHandler("modbus", func(product *models.Product, diff *models.ProductDiff) error {
+		diffs = append(diffs, diff)
+		return nil
+	}, func(productID string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	mc, err := newMetaManagerClient(mb, newDeviceRegistry(), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fieldType := range []string{models.PropertyValueTypeFloat, models.PropertyValueTypeString} {
+		if err = mc.UpdateProduct("modbus", &models.Product{ID: "meter", Properties: []*models.ProductProperty{
+			{Id: "voltage", FieldType: fieldType},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(diffs) != 2 || diffs[0] != nil {
+		t.Fatalf("the diffs = %v, want none for the new product", diffs)
+	}
+	if diffs[1] == nil || diffs[1].FromVersion != 1 || diffs[1].ToVersion != 2 || !diffs[1].Breaking() {
+		t.Errorf("the diff of retyping the voltage = %+v, want a breaking one from 1 to 2", diffs[1])
+	}
+}
@@ -0,0 +1,53 @@
+package operations
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+)
+
+func NewHistoryClient(mb bus.MessageBus, lg *logger.Logger) (HistoryClient, error) {
+	return &historyClient{mb: mb, lg: lg}, nil
+}
+
+type (
+	// HistoryClient is used by the manager to query the recorded data of devices.
+	HistoryClient interface {
+		QueryHistory(productID, deviceID string, query *HistoryQuery) (result *HistoryResult, err error)
+	}
+	historyClient struct {
+		mb bus.MessageBus
+		lg *logger.Logger
+	}
+)
+
+func (h *historyClient) QueryHistory(productID, deviceID string, query *HistoryQuery) (*HistoryResult, error) {
+	result := new(HistoryResult)
+	if err := h.call(productID, deviceID, HistoryOperationTypeQuery, query, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (h *historyClient) call(productID, deviceID string, optType HistoryOperationType,
+	value interface{}, result interface{}) error {
+	reqID := NewReqID()
+	request := NewHistoryOperation(OperationModeDown, productID, deviceID, optType, reqID)
+	request.SetValue(value)
+	reqMsg, err := request.ToMessage()
+	if err != nil {
+		return err
+	}
+	rspTpc := NewHistoryOperation(OperationModeUp, productID, deviceID, optType, reqID).Topic().String()
+	errTpc := NewHistoryOperation(OperationModeUpErr, productID, deviceID, optType, reqID).Topic().String()
+	rspMsg, err := h.mb.Call(reqMsg, rspTpc, errTpc)
+	if err != nil {
+		return errors.NewCommonEdgeErrorWrapper(err)
+	}
+	if err = rspMsg.Unmarshal(result); err != nil {
+		return errors.NewCommonEdgeError(errors.Internal,
+			fmt.Sprintf("fail to unmarshal the payload of the response"), err)
+	}
+	return nil
+}
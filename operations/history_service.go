@@ -0,0 +1,62 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+)
+
+func NewHistoryService(mb bus.MessageBus, lg *logger.Logger) (HistoryService, error) {
+	return &historyService{mb: mb, lg: lg}, nil
+}
+
+type (
+	// HistoryService is used by the component recording the data of devices to serve the queries.
+	HistoryService interface {
+		QueryHandler(handler func(productID, deviceID string, query *HistoryQuery) (*HistoryResult, error)) error
+	}
+	historyService struct {
+		mb bus.MessageBus
+		lg *logger.Logger
+	}
+)
+
+func (h *historyService) QueryHandler(handler func(productID, deviceID string, query *HistoryQuery) (*HistoryResult, error)) error {
+	return h.historyHandler(HistoryOperationTypeQuery, func(o *HistoryOperation) (interface{}, error) {
+		query := new(HistoryQuery)
+		if err := o.Unmarshal(query); err != nil {
+			return nil, errors.BadRequest.Cause(err, "fail to unmarshal the history query")
+		}
+		return handler(o.productID, o.deviceID, query)
+	})
+}
+
+func (h *historyService) historyHandler(optType HistoryOperationType,
+	handler func(o *HistoryOperation) (outs interface{}, err error)) error {
+	schema := NewHistoryOperation(OperationModeDown, TopicSingleLevelWildcard, TopicSingleLevelWildcard,
+		optType, TopicSingleLevelWildcard)
+	topic := schema.Topic().String()
+	return h.mb.Subscribe(func(msg *message.Message) {
+		request, err := ParseHistoryOperation(msg)
+		if err != nil {
+			h.lg.WithError(err).Errorf("fail to parse the history operation")
+			return
+		}
+		outs, err := handler(request)
+
+		response := NewHistoryOperation(OperationModeUp, request.productID, request.deviceID, optType, request.reqID)
+		if err != nil {
+			response.optMode = OperationModeUpErr
+			response.SetValue(errors.NewCommonEdgeErrorWrapper(err))
+		} else {
+			response.SetValue(outs)
+		}
+		rspMsg, err := response.ToMessage()
+		if err != nil {
+			h.lg.WithError(err).Errorf("fail to parse the message of the response")
+			return
+		}
+		_ = h.mb.Publish(rspMsg)
+	}, topic)
+}
@@ -1,25 +1,35 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"github.com/thingio/edge-device-std/errors"
 	"github.com/thingio/edge-device-std/logger"
 	"github.com/thingio/edge-device-std/models"
-	bus "github.com/thingio/edge-device-std/msgbus"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+	"time"
 )
 
 func NewManagerClient(mb bus.MessageBus, lg *logger.Logger) (ManagerClient, error) {
-	mmc, err := newMetaManagerClient(mb, lg)
+	devices := newDeviceRegistry()
+	mmc, err := newMetaManagerClient(mb, devices, lg)
 	if err != nil {
 		return nil, err
 	}
-	dmc, err := newDataManagerClient(mb, lg)
+	dmc, err := newDataManagerClient(mb, devices, lg)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := NewHistoryClient(mb, lg)
 	if err != nil {
 		return nil, err
 	}
 	return &managerClient{
 		mmc,
 		dmc,
+		hc,
 	}, nil
 }
 
@@ -27,38 +37,67 @@ type (
 	ManagerClient interface {
 		MetaManagerClient
 		DataManagerClient
+		HistoryClient
 	}
 	managerClient struct {
 		MetaManagerClient
 		DataManagerClient
+		HistoryClient
 	}
 )
 
 type (
 	MetaManagerClient interface {
+		// InitDriver sends the products and the devices to the driver, the products are flattened
+		// by models.ResolveProducts, so the bases of them should be included.
 		InitDriver(protocolID string, products []*models.Product, devices []*models.Device) error
 
+		// UpdateProduct sends the flattened product to the driver, together with the products inheriting it,
+		// which are flattened again. The abstract products are not sent. The bases are looked up from the
+		// products sent by InitDriver and UpdateProduct, and the conflicts between them are returned as
+		// BadRequest errors, which can be checked beforehand by models.ValidateProducts.
 		UpdateProduct(protocolID string, product *models.Product) error
+		// DeleteProduct deletes the product from the driver, the product inherited by others can't be deleted.
 		DeleteProduct(protocolID string, productID string) error
 
 		UpdateDevice(protocolID string, device *models.Device) error
 		DeleteDevice(protocolID string, deviceID string) error
+
+		// Discover asks the driver to scan devices, the candidates are streamed back through the events,
+		// which will be closed after the event marked with Done is received or the scan is canceled by
+		// the cancel or the ctx. The events are never dropped, receiving them is blocked until they are
+		// consumed, so the events should be consumed until closed or the scan should be canceled.
+		Discover(ctx context.Context, protocolID string, request *DiscoveryRequest) (
+			events <-chan *DiscoveryEvent, cancel func(), err error)
 	}
 	metaManagerClient struct {
-		mb bus.MessageBus
-		lg *logger.Logger
+		mb      bus.MessageBus
+		devices *deviceRegistry
+		lg      *logger.Logger
+
+		// products are the products of the protocols before flattened, to flatten the derived products again
+		// once their bases are changed, the mutations of the products are serialized by the productsMu.
+		products   map[string][]*models.Product // protocol ID -> products
+		productsMu sync.Mutex
 	}
 )
 
-func newMetaManagerClient(mb bus.MessageBus, lg *logger.Logger) (MetaManagerClient, error) {
-	return &metaManagerClient{mb: mb, lg: lg}, nil
+func newMetaManagerClient(mb bus.MessageBus, devices *deviceRegistry, lg *logger.Logger) (MetaManagerClient, error) {
+	return &metaManagerClient{mb: mb, devices: devices, lg: lg, products: make(map[string][]*models.Product)}, nil
 }
 
 func (m *metaManagerClient) InitDriver(protocolID string, products []*models.Product, devices []*models.Device) error {
+	m.productsMu.Lock()
+	defer m.productsMu.Unlock()
+	resolved, err := models.ResolveProducts(products)
+	if err != nil {
+		return errors.BadRequest.Cause(err, "fail to resolve the products")
+	}
+
 	o := NewMetaOperation(OperationModeDown, protocolID,
 		MetaOperationTypeDriverInit, EmptyReqID())
 	o.SetValue(&DriverInitialization{
-		Products: products,
+		Products: resolved,
 		Devices:  devices,
 	})
 
@@ -66,30 +105,119 @@ func (m *metaManagerClient) InitDriver(protocolID string, products []*models.Pro
 	if err != nil {
 		return err
 	}
-	return m.mb.Publish(msg)
+	if err = m.mb.Publish(msg); err != nil {
+		return err
+	}
+	m.products[protocolID] = append([]*models.Product(nil), products...)
+	m.devices.init(protocolID, devices)
+	return nil
 }
 
 func (m *metaManagerClient) UpdateProduct(protocolID string, product *models.Product) error {
-	o := NewMetaOperation(OperationModeDown, protocolID,
-		MetaOperationTypeProductMutation, product.ID)
-	o.SetValue(product)
+	m.productsMu.Lock()
+	defer m.productsMu.Unlock()
 
-	msg, err := o.ToMessage()
+	previous, err := models.NewProductResolver(m.products[protocolID])
 	if err != nil {
-		return err
+		return errors.Internal.Cause(err, "fail to resolve the products of the protocol %s", protocolID)
+	}
+	// the product is revised on a copy, which is written back once the mutations are sent
+	revised := *product
+	products := make([]*models.Product, 0, len(m.products[protocolID])+1)
+	replaced := false
+	for _, p := range m.products[protocolID] {
+		if p.ID == product.ID {
+			revised.Revise(p, time.Now())
+			p, replaced = &revised, true
+		}
+		products = append(products, p)
+	}
+	if !replaced {
+		revised.Revise(nil, time.Now())
+		products = append(products, &revised)
 	}
-	return m.mb.Publish(msg)
+	r, err := models.NewProductResolver(products)
+	if err != nil {
+		return errors.BadRequest.Cause(err, "fail to resolve the product %s", product.ID)
+	}
+	abstract := make(map[string]bool, len(products))
+	for _, p := range products {
+		abstract[p.ID] = p.Abstract
+	}
+	resolved := make([]*models.Product, 0)
+	for _, id := range append([]string{product.ID}, r.Derived(product.ID)...) {
+		if abstract[id] {
+			continue
+		}
+		p, err := r.Resolve(id)
+		if err != nil {
+			return errors.BadRequest.Cause(err, "fail to resolve the product %s", id)
+		}
+		resolved = append(resolved, p)
+	}
+
+	mutations := make([]*ProductMutation, 0, len(resolved))
+	for _, p := range resolved {
+		mutation := &ProductMutation{Product: p}
+		if before, err := previous.Resolve(p.ID); err == nil {
+			if mutation.Diff, err = models.DiffProducts(before, p); err != nil {
+				return errors.BadRequest.Cause(err, "fail to diff the product %s", p.ID)
+			}
+		}
+		mutations = append(mutations, mutation)
+	}
+	for _, mutation := range mutations {
+		o := NewMetaOperation(OperationModeDown, protocolID,
+			MetaOperationTypeProductMutation, mutation.ID)
+		o.SetValue(mutation)
+		msg, err := o.ToMessage()
+		if err != nil {
+			return err
+		}
+		if err = m.mb.Publish(msg); err != nil {
+			return err
+		}
+	}
+	m.products[protocolID] = products
+	*product = revised
+	return nil
 }
 
 func (m *metaManagerClient) DeleteProduct(protocolID string, productID string) error {
-	o := NewMetaOperation(OperationModeDown, protocolID,
-		MetaOperationTypeProductMutation, productID)
+	m.productsMu.Lock()
+	defer m.productsMu.Unlock()
 
-	msg, err := o.ToMessage()
+	products := make([]*models.Product, 0, len(m.products[protocolID]))
+	abstract := false
+	for _, p := range m.products[protocolID] {
+		if p.ID == productID {
+			abstract = p.Abstract
+			continue
+		}
+		products = append(products, p)
+	}
+	r, err := models.NewProductResolver(m.products[protocolID])
 	if err != nil {
-		return err
+		return errors.Internal.Cause(err, "fail to resolve the products of the protocol %s", protocolID)
+	}
+	if derived := r.Derived(productID); len(derived) > 0 {
+		return errors.BadRequest.Error("the product %s is inherited by the products %v", productID, derived)
+	}
+
+	if !abstract {
+		o := NewMetaOperation(OperationModeDown, protocolID,
+			MetaOperationTypeProductMutation, productID)
+		msg, err := o.ToMessage()
+		if err != nil {
+			return err
+		}
+		if err = m.mb.Publish(msg); err != nil {
+			return err
+		}
 	}
-	return m.mb.Publish(msg)
+	m.products[protocolID] = products
+	m.devices.deleteProduct(protocolID, productID)
+	return nil
 }
 
 func (m *metaManagerClient) UpdateDevice(protocolID string, device *models.Device) error {
@@ -101,10 +229,14 @@ func (m *metaManagerClient) UpdateDevice(protocolID string, device *models.Devic
 	if err != nil {
 		return err
 	}
-	return m.mb.Publish(msg)
+	if err = m.mb.Publish(msg); err != nil {
+		return err
+	}
+	m.devices.set(protocolID, device)
+	return nil
 }
 
-func (m metaManagerClient) DeleteDevice(protocolID string, deviceID string) error {
+func (m *metaManagerClient) DeleteDevice(protocolID string, deviceID string) error {
 	o := NewMetaOperation(OperationModeDown, protocolID,
 		MetaOperationTypeDeviceMutation, deviceID)
 
@@ -112,7 +244,94 @@ func (m metaManagerClient) DeleteDevice(protocolID string, deviceID string) erro
 	if err != nil {
 		return err
 	}
-	return m.mb.Publish(msg)
+	if err = m.mb.Publish(msg); err != nil {
+		return err
+	}
+	m.devices.delete(protocolID, deviceID)
+	return nil
+}
+
+func (m *metaManagerClient) Discover(ctx context.Context, protocolID string,
+	request *DiscoveryRequest) (<-chan *DiscoveryEvent, func(), error) {
+	reqID := NewReqID()
+	rspTpc := NewMetaOperation(OperationModeUp, protocolID, MetaOperationTypeDiscover, reqID).Topic().String()
+
+	events := make(chan *DiscoveryEvent, 100)
+	stopped := make(chan struct{})  // closed once the events are no longer consumed
+	finished := make(chan struct{}) // closed once the events are closed
+	var stopOnce, closeOnce sync.Once
+	var sendMu sync.Mutex // serializes sending the events and closing them
+	var closed bool
+	finish := func() {
+		closeOnce.Do(func() {
+			_ = m.mb.Unsubscribe(rspTpc)
+			sendMu.Lock()
+			defer sendMu.Unlock()
+			closed = true
+			close(events)
+			close(finished)
+		})
+	}
+	if err := m.mb.Subscribe(func(msg *message.Message) {
+		o, err := ParseMetaOperation(msg)
+		if err != nil {
+			m.lg.WithError(err).Errorf("fail to parse the meta operation: %s", msg.Topic)
+			return
+		}
+		event := new(DiscoveryEvent)
+		if err = o.Unmarshal(event); err != nil {
+			m.lg.WithError(err).Errorf("fail to unmarshal the discovery event: %s", msg.Topic)
+			return
+		}
+
+		// block until the event is consumed rather than dropping it, so the Done is always delivered
+		sendMu.Lock()
+		if !closed {
+			select {
+			case events <- event:
+			case <-stopped:
+			}
+		}
+		sendMu.Unlock()
+		if event.Done {
+			finish()
+		}
+	}, rspTpc); err != nil {
+		return nil, nil, err
+	}
+
+	o := NewMetaOperation(OperationModeDown, protocolID, MetaOperationTypeDiscover, reqID)
+	o.SetValue(request)
+	msg, err := o.ToMessage()
+	if err == nil {
+		err = m.mb.Publish(msg)
+	}
+	if err != nil {
+		close(stopped)
+		finish()
+		return nil, nil, err
+	}
+
+	cancel := func() {
+		stopOnce.Do(func() {
+			close(stopped)
+			o := NewMetaOperation(OperationModeDown, protocolID, MetaOperationTypeDiscoverCancel, reqID)
+			if msg, err := o.ToMessage(); err == nil {
+				if err = m.mb.Publish(msg); err != nil {
+					m.lg.WithError(err).Errorf("fail to cancel the discovery: %s", reqID)
+				}
+			}
+		})
+		finish()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-finished:
+		}
+	}()
+	return events, cancel, nil
 }
 
 type (
@@ -125,15 +344,59 @@ type (
 			propertyID models.ProductPropertyID, props map[models.ProductPropertyID]*models.DeviceData) error
 		Call(protocolID, productID, deviceID string, methodID models.ProductMethodID,
 			ins map[string]*models.DeviceData) (outs map[string]*models.DeviceData, err error)
+		// BatchRead reads properties across devices of the same protocol in one request,
+		// the results are in the same order as the items, and each of them carries its own error.
+		BatchRead(protocolID string, items []*BatchReadItem) (results []*BatchReadResult, err error)
+
+		// GetShadow returns the desired and reported states of the device.
+		GetShadow(protocolID, productID, deviceID string) (shadow *models.DeviceShadow, err error)
+		// PatchShadow changes the desired state of the device, the desired values will be applied
+		// by the driver once the device is connected.
+		PatchShadow(protocolID, productID, deviceID string, patch *ShadowPatch) (shadow *models.DeviceShadow, err error)
+
+		// SubmitCommand queues the write or call in the driver, it will be executed once the device is connected,
+		// and its result will be delivered by a CommandCompletion with the reqID. The reqID should be generated
+		// by NewReqID and subscribed by DataManagerService.SubscribeCommandCompletion before submitting,
+		// because the command of a connected device may be completed before the ack is returned.
+		SubmitCommand(protocolID, productID, deviceID string, funcID models.ProductFuncID, reqID string,
+			command *QueuedCommand) (ack *CommandAck, err error)
+
+		// StartOTA starts updating the device with the artifact, the progress and result of the update are
+		// delivered with the reqID. The reqID should be generated by NewReqID and subscribed by
+		// DataManagerService.SubscribeOTAProgress and DataManagerService.SubscribeOTAResult before starting,
+		// because the update runs in the driver once it is accepted, even before the ack is returned.
+		StartOTA(protocolID, productID, deviceID, reqID string, request *OTARequest) (ack *OTAAck, err error)
+		// CancelOTA cancels the update identified by the reqID.
+		CancelOTA(protocolID, productID, deviceID, reqID string) error
+
+		// AckAlarm acknowledges the alarm of the device, a cleared alarm will be removed after acknowledged.
+		AckAlarm(protocolID, productID, deviceID, alarmID string) (alarm *models.Alarm, err error)
+		// ShelveAlarm suppresses the changes of the alarm of the device for the duration.
+		ShelveAlarm(protocolID, productID, deviceID, alarmID string, shelve *AlarmShelve) (alarm *models.Alarm, err error)
+
+		// ReadSelected reads the property of the devices whose labels match the selector, see models.ParseLabelSelector.
+		// The devices are listed by opts.Devices, or they are the ones sent to the drivers by the MetaManagerClient
+		// of the same ManagerClient, of the protocol, or all protocols if the protocolID is the TopicSingleLevelWildcard.
+		// The protocols not known are rejected. The results are ordered by the protocols and the devices,
+		// and each of them carries its own error. The opts may be nil.
+		ReadSelected(protocolID, selector string, propertyID models.ProductPropertyID,
+			opts *FanOutOptions) (results []*FanOutResult, err error)
+		// WriteSelected writes the property of the devices whose labels match the selector, like ReadSelected.
+		WriteSelected(protocolID, selector string, propertyID models.ProductPropertyID,
+			props map[models.ProductPropertyID]*models.DeviceData, opts *FanOutOptions) (results []*FanOutResult, err error)
+		// CallSelected calls the method of the devices whose labels match the selector, like ReadSelected.
+		CallSelected(protocolID, selector string, methodID models.ProductMethodID,
+			ins map[string]*models.DeviceData, opts *FanOutOptions) (results []*FanOutResult, err error)
 	}
 	dataManagerClient struct {
-		mb bus.MessageBus
-		lg *logger.Logger
+		mb      bus.MessageBus
+		devices *deviceRegistry
+		lg      *logger.Logger
 	}
 )
 
-func newDataManagerClient(mb bus.MessageBus, lg *logger.Logger) (DataManagerClient, error) {
-	return &dataManagerClient{mb: mb, lg: lg}, nil
+func newDataManagerClient(mb bus.MessageBus, devices *deviceRegistry, lg *logger.Logger) (DataManagerClient, error) {
+	return &dataManagerClient{mb: mb, devices: devices, lg: lg}, nil
 }
 
 func (d *dataManagerClient) Read(protocolID, productID, deviceID string,
@@ -223,3 +486,180 @@ func (d *dataManagerClient) Call(protocolID, productID, deviceID string, methodI
 	}
 	return outs, nil
 }
+
+func (d *dataManagerClient) BatchRead(protocolID string, items []*BatchReadItem) (results []*BatchReadResult, err error) {
+	results = make([]*BatchReadResult, 0, len(items))
+	if err = d.call(protocolID, "-", "-", "-", DataOperationTypeBatchRead, items, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (d *dataManagerClient) GetShadow(protocolID, productID, deviceID string) (shadow *models.DeviceShadow, err error) {
+	shadow = new(models.DeviceShadow)
+	if err = d.call(protocolID, productID, deviceID, "-", DataOperationTypeShadowGet, nil, shadow); err != nil {
+		return nil, err
+	}
+	return shadow, nil
+}
+
+func (d *dataManagerClient) PatchShadow(protocolID, productID, deviceID string,
+	patch *ShadowPatch) (shadow *models.DeviceShadow, err error) {
+	shadow = new(models.DeviceShadow)
+	if err = d.call(protocolID, productID, deviceID, "-", DataOperationTypeShadowPatch, patch, shadow); err != nil {
+		return nil, err
+	}
+	return shadow, nil
+}
+
+func (d *dataManagerClient) SubmitCommand(protocolID, productID, deviceID string, funcID models.ProductFuncID,
+	reqID string, command *QueuedCommand) (ack *CommandAck, err error) {
+	if reqID == "" {
+		return nil, errors.BadRequest.Error("the reqID of the queued command is required")
+	}
+	ack = new(CommandAck)
+	if err = d.callWithReqID(protocolID, productID, deviceID, funcID, DataOperationTypeQueue, reqID,
+		command, ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+func (d *dataManagerClient) StartOTA(protocolID, productID, deviceID, reqID string,
+	request *OTARequest) (ack *OTAAck, err error) {
+	if reqID == "" {
+		return nil, errors.BadRequest.Error("the reqID of the update is required")
+	}
+	ack = new(OTAAck)
+	if err = d.callWithReqID(protocolID, productID, deviceID, "-", DataOperationTypeOTAStart, reqID,
+		request, ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+func (d *dataManagerClient) CancelOTA(protocolID, productID, deviceID, reqID string) error {
+	return d.call(protocolID, productID, deviceID, "-", DataOperationTypeOTACancel,
+		&OTAAck{ReqID: reqID}, &map[string]interface{}{})
+}
+
+func (d *dataManagerClient) AckAlarm(protocolID, productID, deviceID, alarmID string) (alarm *models.Alarm, err error) {
+	alarm = new(models.Alarm)
+	if err = d.call(protocolID, productID, deviceID, alarmID, DataOperationTypeAlarmAck, nil, alarm); err != nil {
+		return nil, err
+	}
+	return alarm, nil
+}
+
+func (d *dataManagerClient) ShelveAlarm(protocolID, productID, deviceID, alarmID string,
+	shelve *AlarmShelve) (alarm *models.Alarm, err error) {
+	alarm = new(models.Alarm)
+	if err = d.call(protocolID, productID, deviceID, alarmID, DataOperationTypeAlarmShelve, shelve, alarm); err != nil {
+		return nil, err
+	}
+	return alarm, nil
+}
+
+func (d *dataManagerClient) ReadSelected(protocolID, selector string, propertyID models.ProductPropertyID,
+	opts *FanOutOptions) (results []*FanOutResult, err error) {
+	return d.fanOut(protocolID, selector, opts, func(protocolID, productID, deviceID string) (map[string]*models.DeviceData, error) {
+		if opts != nil && opts.HardRead {
+			return d.HardRead(protocolID, productID, deviceID, propertyID)
+		}
+		return d.Read(protocolID, productID, deviceID, propertyID)
+	})
+}
+
+func (d *dataManagerClient) WriteSelected(protocolID, selector string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData, opts *FanOutOptions) (results []*FanOutResult, err error) {
+	return d.fanOut(protocolID, selector, opts, func(protocolID, productID, deviceID string) (map[string]*models.DeviceData, error) {
+		return nil, d.Write(protocolID, productID, deviceID, propertyID, props)
+	})
+}
+
+func (d *dataManagerClient) CallSelected(protocolID, selector string, methodID models.ProductMethodID,
+	ins map[string]*models.DeviceData, opts *FanOutOptions) (results []*FanOutResult, err error) {
+	return d.fanOut(protocolID, selector, opts, func(protocolID, productID, deviceID string) (map[string]*models.DeviceData, error) {
+		return d.Call(protocolID, productID, deviceID, methodID, ins)
+	})
+}
+
+// fanOut operates the devices selected by the selector concurrently, at most opts.Concurrency at the same time.
+func (d *dataManagerClient) fanOut(protocolID, selector string, opts *FanOutOptions,
+	operate func(protocolID, productID, deviceID string) (map[string]*models.DeviceData, error)) ([]*FanOutResult, error) {
+	s, err := models.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, errors.BadRequest.Cause(err, "fail to parse the label selector")
+	}
+	concurrency := DefaultFanOutConcurrency
+	if opts != nil && opts.Concurrency != 0 {
+		if opts.Concurrency < 0 {
+			return nil, errors.BadRequest.Error("the concurrency %d must be positive", opts.Concurrency)
+		}
+		concurrency = opts.Concurrency
+	}
+
+	var lister DeviceLister = d.devices
+	if opts != nil && opts.Devices != nil {
+		lister = opts.Devices
+	}
+	devices, err := selectDevices(lister, protocolID, s)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*FanOutResult, len(devices))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, selected := range devices {
+		results[i] = &FanOutResult{
+			ProtocolID: selected.protocolID,
+			ProductID:  selected.device.ProductID,
+			DeviceID:   selected.device.ID,
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *FanOutResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			data, err := operate(result.ProtocolID, result.ProductID, result.DeviceID)
+			if err != nil {
+				result.Error = errors.NewCommonEdgeErrorWrapper(err)
+				return
+			}
+			result.Data = data
+		}(results[i])
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// call sends the request carrying the value to the driver, then waits for the response and unmarshal it into the result.
+func (d *dataManagerClient) call(protocolID, productID, deviceID string, funcID models.ProductFuncID,
+	optType DataOperationType, value interface{}, result interface{}) error {
+	return d.callWithReqID(protocolID, productID, deviceID, funcID, optType, NewReqID(), value, result)
+}
+
+// callWithReqID is the call with the reqID chosen by the caller, so that the messages correlated by
+// the reqID can be subscribed before sending the request.
+func (d *dataManagerClient) callWithReqID(protocolID, productID, deviceID string, funcID models.ProductFuncID,
+	optType DataOperationType, reqID string, value interface{}, result interface{}) error {
+	request := NewDataOperation(OperationModeDown, protocolID, productID, deviceID, funcID, optType, reqID)
+	request.SetValue(value)
+	reqMsg, err := request.ToMessage()
+	if err != nil {
+		return err
+	}
+	rspTpc := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, funcID, optType, reqID).Topic().String()
+	errTpc := NewDataOperation(OperationModeUpErr, protocolID, productID, deviceID, funcID, optType, reqID).Topic().String()
+	rspMsg, err := d.mb.Call(reqMsg, rspTpc, errTpc)
+	if err != nil {
+		return errors.NewCommonEdgeErrorWrapper(err)
+	}
+	if err = rspMsg.Unmarshal(result); err != nil {
+		return errors.NewCommonEdgeError(errors.Internal,
+			fmt.Sprintf("fail to unmarshal the payload of the response"), err)
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+	"testing"
+	"time"
+)
+
+// callingMessageBus replies the reads of the devices with their IDs, and fails the devices in the failures.
+type callingMessageBus struct {
+	bus.MessageBus
+
+	failures map[string]bool
+
+	mu       sync.Mutex
+	running  int
+	maxCalls int
+	called   []string
+	reqIDs   []string
+}
+
+func (c *callingMessageBus) Publish(*message.Message) error {
+	return nil
+}
+
+func (c *callingMessageBus) Call(request *message.Message, _, _ string) (*message.Message, error) {
+	topic, err := ParseTopic(request)
+	if err != nil {
+		return nil, err
+	}
+	protocolID, _ := topic.TagValue(TopicTagKeyProtocolID)
+	deviceID, _ := topic.TagValue(TopicTagKeyDeviceID)
+	reqID, _ := topic.TagValue(TopicTagKeyReqID)
+
+	c.mu.Lock()
+	c.running++
+	if c.running > c.maxCalls {
+		c.maxCalls = c.running
+	}
+	c.called = append(c.called, protocolID+"/"+deviceID)
+	c.reqIDs = append(c.reqIDs, reqID)
+	c.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+
+	if c.failures[deviceID] {
+		return nil, fmt.Errorf("the device %s is offline", deviceID)
+	}
+	id, _ := models.NewDeviceData("id", models.PropertyValueTypeString, deviceID)
+	payload, err := json.Marshal(map[string]*models.DeviceData{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	return &message.Message{Payload: payload}, nil
+}
+
+func TestFanOut(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &callingMessageBus{failures: map[string]bool{"m3": true}}
+	mc, err := NewManagerClient(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meter := &models.Product{ID: "meter", Protocol: "modbus"}
+	if err = mc.InitDriver("modbus", []*models.Product{meter}, []*models.Device{
+		{ID: "m1", ProductID: "meter", DeviceLabels: map[string]string{"site": "a"}},
+		{ID: "m2", ProductID: "meter", DeviceLabels: map[string]string{"site": "b"}},
+		{ID: "m3", ProductID: "meter", DeviceLabels: map[string]string{"site": "a"}},
+		{ID: "m4", ProductID: "meter"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mc.UpdateDevice("opcua", &models.Device{ID: "o1", ProductID: "valve",
+		DeviceLabels: map[string]string{"site": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = mc.DeleteDevice("modbus", "m4"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := mc.ReadSelected("modbus", "site=a", "id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].DeviceID != "m1" || results[1].DeviceID != "m3" {
+		t.Fatalf("the devices m1 and m3 should be read, got %+v", results)
+	}
+	if results[0].Error != nil || results[0].Data["id"].Value != "m1" {
+		t.Errorf("unexpected result of m1: %+v", results[0])
+	}
+	if results[1].Error == nil || results[1].Data != nil {
+		t.Errorf("the read of m3 should fail, got %+v", results[1])
+	}
+
+	mb.maxCalls = 0
+	results, err = mc.WriteSelected(TopicSingleLevelWildcard, "site in (a,b),site!=b", "id", nil,
+		&FanOutOptions{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var devices []string
+	for _, result := range results {
+		devices = append(devices, result.ProtocolID+"/"+result.DeviceID)
+	}
+	if fmt.Sprint(devices) != "[modbus/m1 modbus/m3 opcua/o1]" {
+		t.Errorf("unexpected devices written: %v", devices)
+	}
+	if mb.maxCalls != 1 {
+		t.Errorf("the devices should be operated one by one, got %d at the same time", mb.maxCalls)
+	}
+
+	results, err = mc.CallSelected(TopicSingleLevelWildcard, "!site", "reset", nil, nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("no device should be called, got %+v, err: %v", results, err)
+	}
+	if _, err = mc.ReadSelected("modbus", "site in (a", "id", nil); err == nil {
+		t.Errorf("the invalid selector should be rejected")
+	}
+	if _, err = mc.ReadSelected("modbus", "site=a", "id", &FanOutOptions{Concurrency: -1}); err == nil {
+		t.Errorf("the negative concurrency should be rejected")
+	}
+
+	// the device moved to another protocol is only selected under the new one
+	if err = mc.UpdateDevice("opcua", &models.Device{ID: "m1", ProductID: "valve",
+		DeviceLabels: map[string]string{"site": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if results, err = mc.ReadSelected(TopicSingleLevelWildcard, "site=a", "id", nil); err != nil {
+		t.Fatal(err)
+	}
+	devices = nil
+	for _, result := range results {
+		devices = append(devices, result.ProtocolID+"/"+result.DeviceID)
+	}
+	if fmt.Sprint(devices) != "[modbus/m3 opcua/m1 opcua/o1]" {
+		t.Errorf("unexpected devices read after moved: %v", devices)
+	}
+}
+
+// lister lists the devices of a protocol managed by another ManagerClient.
+type lister map[string][]*models.Device
+
+func (l lister) ListDevices(protocolID string) (map[string][]*models.Device, error) {
+	return map[string][]*models.Device{protocolID: l[protocolID]}, nil
+}
+
+func TestFanOutDevices(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := NewManagerClient(new(callingMessageBus), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mc.ReadSelected("modbus", "site=a", "id", nil); err == nil {
+		t.Errorf("the protocol never initialized should be rejected")
+	}
+
+	devices := lister{"modbus": {{ID: "m1", ProductID: "meter", DeviceLabels: map[string]string{"site": "a"}}}}
+	results, err := mc.ReadSelected("modbus", "site=a", "id", &FanOutOptions{Devices: devices})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Error != nil || results[0].Data["id"].Value != "m1" {
+		t.Errorf("the device listed should be read, got %+v", results)
+	}
+}
+
+func TestSubmitCommand(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := new(callingMessageBus)
+	mc, err := NewManagerClient(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := &QueuedCommand{Kind: QueuedCommandKindCall}
+	if _, err = mc.SubmitCommand("modbus", "meter", "m1", "reset", "", command); err == nil {
+		t.Errorf("the command without the reqID should be rejected")
+	}
+	reqID := NewReqID()
+	if _, err = mc.SubmitCommand("modbus", "meter", "m1", "reset", reqID, command); err != nil {
+		t.Fatal(err)
+	}
+	if len(mb.reqIDs) != 1 || mb.reqIDs[0] != reqID {
+		t.Errorf("the command should be submitted with the reqID %s, got %v", reqID, mb.reqIDs)
+	}
+}
+
+func TestUpdateProductTemplates(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	mc, err := NewManagerClient(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mutations []*ProductMutation
+	filter := NewMetaOperation(OperationModeDown, "modbus", MetaOperationTypeProductMutation, TopicSingleLevelWildcard)
+	if err = mb.Subscribe(func(msg *message.Message) {
+		mutation := &ProductMutation{Product: new(models.Product)}
+		if len(msg.Payload) > 0 {
+			if err := msg.Unmarshal(mutation); err != nil {
+				t.Errorf("fail to unmarshal the product: %s", err.Error())
+			}
+		}
+		mutations = append(mutations, mutation)
+	}, filter.Topic().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics := &models.Product{ID: "diagnostics", Abstract: true, Properties: []*models.ProductProperty{
+		{Id: "uptime", FieldType: models.PropertyValueTypeUint},
+	}}
+	meter := &models.Product{ID: "meter", Protocol: "modbus", Bases: []string{"diagnostics"}}
+	if err = mc.InitDriver("modbus", []*models.Product{diagnostics, meter}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := &models.Product{ID: "meter", Protocol: "modbus", Bases: []string{"diagnostics"},
+		Properties: []*models.ProductProperty{{Id: "voltage", FieldType: models.PropertyValueTypeFloat}}}
+	if err = mc.UpdateProduct("modbus", updated); err != nil {
+		t.Fatal(err)
+	}
+	if len(mutations) != 1 || len(mutations[0].Bases) != 0 || len(mutations[0].Properties) != 2 {
+		t.Fatalf("the flattened meter should be sent, got %+v", mutations)
+	}
+	// the product is revised, and the diff from the revision sent before is sent with it
+	if updated.Version != 1 || updated.ChangedAt == nil || mutations[0].Version != 1 {
+		t.Errorf("the revision of the updated meter = %d, sent %d, want 1", updated.Version, mutations[0].Version)
+	}
+	if diff := mutations[0].Diff; diff == nil || len(diff.Changes) != 1 || diff.Changes[0].FuncID != "voltage" ||
+		diff.Changes[0].Type != models.ProductChangeTypeAdded || diff.Breaking() {
+		t.Errorf("the diff of the meter = %+v, want the voltage added", mutations[0].Diff)
+	}
+
+	// the abstract base isn't sent, but the meter inheriting it is flattened again
+	mutations = nil
+	if err = mc.UpdateProduct("modbus", &models.Product{ID: "diagnostics", Abstract: true, Properties: []*models.ProductProperty{
+		{Id: "uptime", FieldType: models.PropertyValueTypeUint}, {Id: "firmware", FieldType: models.PropertyValueTypeString},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mutations) != 1 || mutations[0].ID != "meter" || len(mutations[0].Properties) != 3 {
+		t.Fatalf("only the meter flattened again should be sent, got %+v", mutations)
+	}
+	if diff := mutations[0].Diff; diff == nil || len(diff.Changes) != 1 || diff.Changes[0].FuncID != "firmware" {
+		t.Errorf("the diff of the meter flattened again = %+v, want the firmware added", mutations[0].Diff)
+	}
+
+	mutations = nil
+	if err = mc.UpdateProduct("modbus", &models.Product{ID: "diagnostics", Abstract: true, Properties: []*models.ProductProperty{
+		{Id: "voltage", FieldType: models.PropertyValueTypeInt},
+	}}); err == nil {
+		t.Errorf("the base retyping the property of the meter should be rejected")
+	}
+	if err = mc.DeleteProduct("modbus", "diagnostics"); err == nil {
+		t.Errorf("the base inherited by the meter shouldn't be deleted")
+	}
+	if len(mutations) != 0 {
+		t.Errorf("nothing should be sent for the rejected mutations, got %+v", mutations)
+	}
+	if err = mc.DeleteProduct("modbus", "meter"); err != nil {
+		t.Fatal(err)
+	}
+	if err = mc.DeleteProduct("modbus", "diagnostics"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mutations) != 1 {
+		t.Errorf("only the deletion of the meter should be sent, got %d mutations", len(mutations))
+	}
+}
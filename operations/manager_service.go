@@ -3,7 +3,7 @@ package operations
 import (
 	"github.com/thingio/edge-device-std/logger"
 	"github.com/thingio/edge-device-std/models"
-	bus "github.com/thingio/edge-device-std/msgbus"
+	"github.com/thingio/edge-device-std/msgbus/bus"
 	"github.com/thingio/edge-device-std/msgbus/message"
 )
 
@@ -38,13 +38,14 @@ type (
 		SubscribeDriverStatus() (bus <-chan interface{}, stop func(), err error)
 	}
 	metaManagerService struct {
-		mb bus.MessageBus
-		lg *logger.Logger
+		mb   bus.MessageBus
+		subs *subscriptions
+		lg   *logger.Logger
 	}
 )
 
 func newMetaManagerService(mb bus.MessageBus, lg *logger.Logger) (MetaManagerService, error) {
-	return &metaManagerService{mb: mb, lg: lg}, nil
+	return &metaManagerService{mb: mb, subs: newSubscriptions(mb, lg), lg: lg}, nil
 }
 
 func (m *metaManagerService) SubscribeDriverStatus() (<-chan interface{}, func(), error) {
@@ -60,7 +61,7 @@ func (m *metaManagerService) SubscribeDriverStatus() (<-chan interface{}, func()
 func (m *metaManagerService) subscribe(optType MetaOperationType,
 	parser func(o *MetaOperation) (interface{}, error)) (<-chan interface{}, func(), error) {
 	schema := NewMetaOperation(OperationModeUp, TopicSingleLevelWildcard, optType, TopicSingleLevelWildcard)
-	return subscribe(m.mb, m.lg, schema.Topic().String(), func(msg *message.Message) (interface{}, error) {
+	return m.subs.subscribe(schema.Topic().String(), func(msg *message.Message) (interface{}, error) {
 		o, err := ParseMetaOperation(msg)
 		if err != nil {
 			return nil, err
@@ -70,19 +71,50 @@ func (m *metaManagerService) subscribe(optType MetaOperationType,
 }
 
 type (
+	// DataManagerService subscribes the operations published by the drivers. The subscriptions of the same topic
+	// share one handler of the MessageBus, which keeps only one handler for a topic, so the consumers subscribing
+	// the same devices, e.g. the gateway, the recorder and the aggregator, should share one DataManagerService.
+	// The channel of a subscription is closed once it is stopped.
 	DataManagerService interface {
 		SubscribeDeviceStatus(protocolID string) (<-chan interface{}, func(), error)
 		SubscribeDeviceProps(protocolID, productID, deviceID string, propertyID models.ProductPropertyID) (<-chan interface{}, func(), error)
 		SubscribeDeviceEvent(protocolID, productID, deviceID string, eventID models.ProductEventID) (<-chan interface{}, func(), error)
+		SubscribeShadowDelta(protocolID, productID, deviceID string) (<-chan interface{}, func(), error)
+		// SubscribeCommandCompletion subscribes the completion of the queued command identified by the reqID,
+		// use TopicSingleLevelWildcard as the reqID to subscribe all completions of the protocol.
+		SubscribeCommandCompletion(protocolID, reqID string) (<-chan interface{}, func(), error)
+		// SubscribeOTAProgress and SubscribeOTAResult subscribe the progress and result of the update
+		// identified by the reqID, use TopicSingleLevelWildcard as the reqID to subscribe all updates of the protocol.
+		SubscribeOTAProgress(protocolID, reqID string) (<-chan interface{}, func(), error)
+		SubscribeOTAResult(protocolID, reqID string) (<-chan interface{}, func(), error)
+		SubscribeAlarm(protocolID, productID, deviceID, alarmID string) (<-chan interface{}, func(), error)
+		SubscribeAggregate(protocolID, productID, deviceID, aggregationID string) (<-chan interface{}, func(), error)
+
+		// SetProductTopics loads the custom topics declared by the product, the subscriptions made after it
+		// will follow the custom topics of the product as well, see TopicMapping. The subscriptions made
+		// before it never receive the operations published to the custom topics, they should be made again,
+		// so the topics should be set before subscribing, e.g. along with InitDriver and UpdateProduct.
+		SetProductTopics(product *models.Product) error
+		RemoveProductTopics(productID string)
 	}
 	dataManagerService struct {
-		mb bus.MessageBus
-		lg *logger.Logger
+		mb     bus.MessageBus
+		subs   *subscriptions
+		topics *TopicMapping
+		lg     *logger.Logger
 	}
 )
 
 func newDataManagerService(mb bus.MessageBus, lg *logger.Logger) (DataManagerService, error) {
-	return &dataManagerService{mb: mb, lg: lg}, nil
+	return &dataManagerService{mb: mb, subs: newSubscriptions(mb, lg), topics: NewTopicMapping(), lg: lg}, nil
+}
+
+func (d *dataManagerService) SetProductTopics(product *models.Product) error {
+	return d.topics.SetProduct(product)
+}
+
+func (d *dataManagerService) RemoveProductTopics(productID string) {
+	d.topics.RemoveProduct(productID)
 }
 
 func (d *dataManagerService) SubscribeDeviceStatus(protocolID string) (<-chan interface{}, func(), error) {
@@ -121,10 +153,78 @@ func (d *dataManagerService) SubscribeDeviceEvent(protocolID, productID, deviceI
 	)
 }
 
-func (d *dataManagerService) subscribe(protocolID, productID, deviceID string, funcID models.ProductEventID,
-	optType DataOperationType, parser func(o *DataOperation) (interface{}, error)) (<-chan interface{}, func(), error) {
-	schema := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, funcID, optType, TopicSingleLevelWildcard)
-	return subscribe(d.mb, d.lg, schema.Topic().String(),
+func (d *dataManagerService) SubscribeShadowDelta(protocolID, productID, deviceID string) (<-chan interface{}, func(), error) {
+	return d.subscribe(protocolID, productID, deviceID, TopicSingleLevelWildcard, DataOperationTypeShadowDelta,
+		func(o *DataOperation) (interface{}, error) {
+			delta := make(map[models.ProductPropertyID]*models.DeviceData)
+			if err := o.Unmarshal(&delta); err != nil {
+				return nil, err
+			}
+			return delta, nil
+		},
+	)
+}
+
+func (d *dataManagerService) SubscribeCommandCompletion(protocolID, reqID string) (<-chan interface{}, func(), error) {
+	return d.subscribeByReqID(protocolID, reqID, DataOperationTypeComplete, func(o *DataOperation) (interface{}, error) {
+		completion := new(CommandCompletion)
+		if err := o.Unmarshal(completion); err != nil {
+			return nil, err
+		}
+		return completion, nil
+	})
+}
+
+func (d *dataManagerService) SubscribeOTAProgress(protocolID, reqID string) (<-chan interface{}, func(), error) {
+	return d.subscribeByReqID(protocolID, reqID, DataOperationTypeOTAProgress, func(o *DataOperation) (interface{}, error) {
+		progress := new(OTAProgress)
+		if err := o.Unmarshal(progress); err != nil {
+			return nil, err
+		}
+		return progress, nil
+	})
+}
+
+func (d *dataManagerService) SubscribeOTAResult(protocolID, reqID string) (<-chan interface{}, func(), error) {
+	return d.subscribeByReqID(protocolID, reqID, DataOperationTypeOTAResult, func(o *DataOperation) (interface{}, error) {
+		result := new(OTAResult)
+		if err := o.Unmarshal(result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
+
+func (d *dataManagerService) SubscribeAlarm(protocolID, productID, deviceID, alarmID string) (<-chan interface{}, func(), error) {
+	return d.subscribe(protocolID, productID, deviceID, alarmID, DataOperationTypeAlarm,
+		func(o *DataOperation) (interface{}, error) {
+			alarm := new(Alarm)
+			if err := o.Unmarshal(alarm); err != nil {
+				return nil, err
+			}
+			return alarm, nil
+		},
+	)
+}
+
+func (d *dataManagerService) SubscribeAggregate(protocolID, productID, deviceID, aggregationID string) (<-chan interface{}, func(), error) {
+	return d.subscribe(protocolID, productID, deviceID, aggregationID, DataOperationTypeAggregate,
+		func(o *DataOperation) (interface{}, error) {
+			window := new(AggregateWindow)
+			if err := o.Unmarshal(window); err != nil {
+				return nil, err
+			}
+			return window, nil
+		},
+	)
+}
+
+// subscribeByReqID subscribes the operations identified by the reqID across all devices of the protocol.
+func (d *dataManagerService) subscribeByReqID(protocolID, reqID string, optType DataOperationType,
+	parser func(o *DataOperation) (interface{}, error)) (<-chan interface{}, func(), error) {
+	schema := NewDataOperation(OperationModeUp, protocolID, TopicSingleLevelWildcard, TopicSingleLevelWildcard,
+		TopicSingleLevelWildcard, optType, reqID)
+	return d.subs.subscribe(schema.Topic().String(),
 		func(msg *message.Message) (interface{}, error) {
 			o, err := ParseDataOperation(msg)
 			if err != nil {
@@ -135,21 +235,63 @@ func (d *dataManagerService) subscribe(protocolID, productID, deviceID string, f
 	)
 }
 
-func subscribe(mb bus.MessageBus, lg *logger.Logger, topic string,
-	parser func(msg *message.Message) (interface{}, error)) (<-chan interface{}, func(), error) {
-	buffer := make(chan interface{}, 1000)
-	if err := mb.Subscribe(func(msg *message.Message) {
-		v, err := parser(msg)
-		if err != nil {
-			lg.WithError(err).Errorf("fail to parse the payload of the data operation")
+// subscribe subscribes the operations by the topic formed by the Schemas, and the custom topics of the products,
+// the topic formed by the Schemas is skipped if the product is specified and has a custom topic.
+func (d *dataManagerService) subscribe(protocolID, productID, deviceID string, funcID models.ProductEventID,
+	optType DataOperationType, parser func(o *DataOperation) (interface{}, error)) (<-chan interface{}, func(), error) {
+	parsers := make(map[string]func(msg *message.Message) (interface{}, error))
+	templates := d.topics.filters(protocolID, productID, optType)
+	var match func(v interface{}) bool
+	for _, template := range templates {
+		template := template
+		parsers[template.filter(deviceID, funcID)] = func(msg *message.Message) (interface{}, error) {
+			deviceID, funcID, err := template.parse(msg.Topic)
+			if err != nil {
+				return nil, err
+			}
+			protocolID := protocolID
+			if template.protocolID != "" {
+				protocolID = template.protocolID
+			}
+			o := NewDataOperation(OperationModeUp, protocolID, template.productID, deviceID, funcID, optType, EmptyReqID())
+			o.payload = msg.Payload
+			return parser(o)
+		}
+		// the operations of all functions are received from the topic without the func ID,
+		// so the ones of the function subscribed are told apart by the values
+		if !template.hasFuncID() && funcID != TopicSingleLevelWildcard {
+			match = func(v interface{}) bool {
+				return matchFunc(v, funcID)
+			}
+		}
+	}
+	if productID == TopicSingleLevelWildcard || len(templates) == 0 {
+		schema := NewDataOperation(OperationModeUp, protocolID, productID, deviceID, funcID, optType, TopicSingleLevelWildcard)
+		parsers[schema.Topic().String()] = func(msg *message.Message) (interface{}, error) {
+			o, err := ParseDataOperation(msg)
+			if err != nil {
+				return nil, err
+			}
+			return parser(o)
 		}
-		buffer <- v
-	}, topic); err != nil {
-		return nil, nil, err
 	}
-	return buffer, func() {
-		_ = mb.Unsubscribe(topic)
+	return d.subs.subscribeTopics(parsers, match)
+}
 
-		close(buffer)
-	}, nil
+// matchFunc returns whether the value parsed from the data operation belongs to the function.
+func matchFunc(v interface{}, funcID models.ProductFuncID) bool {
+	switch v := v.(type) {
+	case map[models.ProductPropertyID]*models.DeviceData:
+		if funcID == models.DeviceDataMultiPropsID {
+			return true
+		}
+		_, ok := v[funcID]
+		return ok
+	case *Alarm:
+		return v.AlarmID == funcID
+	case *AggregateWindow:
+		return v.AggregationID == funcID
+	default:
+		return true
+	}
 }
@@ -17,8 +17,9 @@ type (
 )
 
 const (
-	OperationCategoryMeta OperationCategory = "META"
-	OperationCategoryData OperationCategory = "DATA"
+	OperationCategoryMeta    OperationCategory = "META"
+	OperationCategoryData    OperationCategory = "DATA"
+	OperationCategoryHistory OperationCategory = "HISTORY"
 
 	OperationModeUp    OperationMode = "UP"
 	OperationModeUpErr OperationMode = "UP-ERR"
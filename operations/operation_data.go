@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/thingio/edge-device-std/models"
 	"github.com/thingio/edge-device-std/msgbus/message"
@@ -13,13 +14,27 @@ type (
 )
 
 const (
-	DataOperationTypeHealthCheck DataOperationType = "STATUS"    // Device Health Check
-	DataOperationTypeRead        DataOperationType = "READ"      // Device Property Soft Read
-	DataOperationTypeHardRead    DataOperationType = "HARD-READ" // Device Property Hard Read
-	DataOperationTypeWrite       DataOperationType = "WRITE"     // Device Property Write
-	DataOperationTypeWatch       DataOperationType = "PROPS"     // Device Property Watch
-	DataOperationTypeEvent       DataOperationType = "EVENT"     // Device Event
-	DataOperationTypeCall        DataOperationType = "CALL"      // Device Method
+	DataOperationTypeHealthCheck DataOperationType = "STATUS"       // Device Health Check
+	DataOperationTypeRead        DataOperationType = "READ"         // Device Property Soft Read
+	DataOperationTypeHardRead    DataOperationType = "HARD-READ"    // Device Property Hard Read
+	DataOperationTypeWrite       DataOperationType = "WRITE"        // Device Property Write
+	DataOperationTypeWatch       DataOperationType = "PROPS"        // Device Property Watch
+	DataOperationTypeEvent       DataOperationType = "EVENT"        // Device Event
+	DataOperationTypeCall        DataOperationType = "CALL"         // Device Method
+	DataOperationTypeBatchRead   DataOperationType = "BATCH-READ"   // Device Property Batch Read
+	DataOperationTypeShadowGet   DataOperationType = "SHADOW-GET"   // Device Shadow Get
+	DataOperationTypeShadowPatch DataOperationType = "SHADOW-PATCH" // Device Shadow Desired State Patch
+	DataOperationTypeShadowDelta DataOperationType = "SHADOW-DELTA" // Device Shadow Delta
+	DataOperationTypeQueue       DataOperationType = "QUEUE"        // Device Queued Write or Call
+	DataOperationTypeComplete    DataOperationType = "COMPLETE"     // Device Queued Command Completion
+	DataOperationTypeOTAStart    DataOperationType = "OTA-START"    // Device OTA Update Start
+	DataOperationTypeOTACancel   DataOperationType = "OTA-CANCEL"   // Device OTA Update Cancel
+	DataOperationTypeOTAProgress DataOperationType = "OTA-PROGRESS" // Device OTA Update Progress
+	DataOperationTypeOTAResult   DataOperationType = "OTA-RESULT"   // Device OTA Update Result
+	DataOperationTypeAlarm       DataOperationType = "ALARM"        // Device Alarm
+	DataOperationTypeAlarmAck    DataOperationType = "ALARM-ACK"    // Device Alarm Acknowledge
+	DataOperationTypeAlarmShelve DataOperationType = "ALARM-SHELVE" // Device Alarm Shelve
+	DataOperationTypeAggregate   DataOperationType = "AGGREGATE"    // Device Property Aggregation
 
 	DeviceDataReportModePeriodical DevicePropertyReportMode = "periodical" // report device data at intervals, e.g. 5s, 1m, 0.5h
 	DeviceDataReportModeOnChange   DevicePropertyReportMode = "onchange"   // report device data on change
@@ -31,6 +46,10 @@ type DataOperation struct {
 	productID string
 	deviceID  string
 	funcID    models.ProductFuncID
+
+	// header is the header of the message, the responses and the operations published for
+	// a request inherit the header of the request, e.g. the trace context.
+	header map[string]string
 }
 
 func (o *DataOperation) Topic() Topic {
@@ -58,6 +77,7 @@ func (o *DataOperation) ToMessage() (*message.Message, error) {
 	return &message.Message{
 		Topic:   o.Topic().String(),
 		Payload: payload,
+		Header:  copyHeader(o.header),
 	}, nil
 }
 
@@ -86,5 +106,11 @@ func ParseDataOperation(msg *message.Message) (*DataOperation, error) {
 	tags := topic.TagValues()
 	o := NewDataOperation(OperationMode(tags[0]), tags[1], tags[2], tags[3], tags[4], OperationType(tags[5]), tags[6])
 	o.payload = msg.Payload
+	o.header = copyHeader(msg.Header)
 	return o, nil
 }
+
+// context returns the context passed to the handler of the operation.
+func (o *DataOperation) context() context.Context {
+	return NewHeaderContext(context.Background(), o.header)
+}
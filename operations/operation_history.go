@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"github.com/thingio/edge-device-std/version"
+)
+
+type (
+	HistoryOperationType = OperationType
+)
+
+const (
+	HistoryOperationTypeQuery HistoryOperationType = "QUERY" // Query Recorded Device Data
+)
+
+// HistoryOperation is the operation on the recorded data of devices, which is served by
+// the component recording the data, e.g. the accessor, instead of the driver.
+type HistoryOperation struct {
+	operation
+
+	productID string
+	deviceID  string
+}
+
+func (o *HistoryOperation) Topic() Topic {
+	return &commonTopic{
+		category: OperationCategoryHistory,
+		version:  o.ver,
+		tags: map[TopicTagKey]string{
+			TopicTagKeyOptMode:   string(o.optMode),
+			TopicTagKeyProductID: o.productID,
+			TopicTagKeyDeviceID:  o.deviceID,
+			TopicTagKeyOptType:   string(o.optType),
+			TopicTagKeyReqID:     o.reqID,
+		},
+	}
+}
+
+func (o *HistoryOperation) ToMessage() (*message.Message, error) {
+	payload, err := json.Marshal(o.value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &message.Message{
+		Topic:   o.Topic().String(),
+		Payload: payload,
+	}, nil
+}
+
+func NewHistoryOperation(optMode OperationMode, productID, deviceID string,
+	optType HistoryOperationType, reqID string) *HistoryOperation {
+	return &HistoryOperation{
+		operation: operation{
+			optCategory: OperationCategoryHistory,
+			ver:         version.HistoryVersion,
+			optMode:     optMode,
+			optType:     optType,
+			reqID:       reqID,
+		},
+		productID: productID,
+		deviceID:  deviceID,
+	}
+}
+
+func ParseHistoryOperation(msg *message.Message) (*HistoryOperation, error) {
+	topic, err := ParseTopic(msg)
+	if err != nil {
+		return nil, err
+	}
+	tags := topic.TagValues()
+	o := NewHistoryOperation(OperationMode(tags[0]), tags[1], tags[2], OperationType(tags[3]), tags[4])
+	o.payload = msg.Payload
+	return o, nil
+}
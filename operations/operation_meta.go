@@ -15,6 +15,8 @@ const (
 	MetaOperationTypeDeviceMutation    MetaOperationType = "DEVICE"
 	MetaOperationTypeDriverInit        MetaOperationType = "INIT"
 	MetaOperationTypeDriverHealthCheck MetaOperationType = "STATUS"
+	MetaOperationTypeDiscover          MetaOperationType = "DISCOVER"
+	MetaOperationTypeDiscoverCancel    MetaOperationType = "DISCOVER-CANCEL"
 )
 
 type MetaOperation struct {
@@ -34,6 +34,8 @@ var (
 		OperationCategoryMeta: {TopicTagKeyOptMode, TopicTagKeyProtocolID, TopicTagKeyOptType, TopicTagKeyReqID},
 		OperationCategoryData: {TopicTagKeyOptMode, TopicTagKeyProtocolID, TopicTagKeyProductID, TopicTagKeyDeviceID,
 			TopicTagKeyFuncID, TopicTagKeyOptType, TopicTagKeyReqID},
+		OperationCategoryHistory: {TopicTagKeyOptMode, TopicTagKeyProductID, TopicTagKeyDeviceID,
+			TopicTagKeyOptType, TopicTagKeyReqID},
 	}
 )
 
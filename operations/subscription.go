@@ -0,0 +1,127 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"sync"
+)
+
+// subscriptionBufferSize is the size of the channel of each subscriber.
+const subscriptionBufferSize = 1000
+
+// subscriptions shares one handler of the MessageBus for each topic among its subscribers, because the MessageBus
+// keeps only one handler for a topic, the handler subscribed later replaces the former one, and unsubscribing
+// the topic stops all of them. The topic is unsubscribed from the MessageBus once its last subscriber stops.
+type subscriptions struct {
+	mb bus.MessageBus
+	lg *logger.Logger
+
+	mu     sync.Mutex
+	topics map[string]map[*subscriber]struct{} // topic -> subscribers
+}
+
+// subscriber receives the values parsed from the messages of the topics it subscribes.
+type subscriber struct {
+	bus   chan interface{}
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup           // the values being sent to the bus
+	match func(v interface{}) bool // the values not matched are skipped, all values are received if it's nil
+}
+
+func newSubscriptions(mb bus.MessageBus, lg *logger.Logger) *subscriptions {
+	return &subscriptions{mb: mb, lg: lg, topics: make(map[string]map[*subscriber]struct{})}
+}
+
+func (s *subscriptions) subscribe(topic string,
+	parser func(msg *message.Message) (interface{}, error)) (<-chan interface{}, func(), error) {
+	return s.subscribeTopics(map[string]func(msg *message.Message) (interface{}, error){topic: parser}, nil)
+}
+
+// subscribeTopics subscribes the topics into one channel, the messages of each topic are parsed by its parser.
+// The parser of a topic is decided by its first subscriber, the subscribers of the same topic should parse
+// its messages in the same way, and tell apart the values they want by the match, which may be nil.
+func (s *subscriptions) subscribeTopics(parsers map[string]func(msg *message.Message) (interface{}, error),
+	match func(v interface{}) bool) (<-chan interface{}, func(), error) {
+	sub := &subscriber{bus: make(chan interface{}, subscriptionBufferSize), done: make(chan struct{}), match: match}
+	topics := make([]string, 0, len(parsers))
+	for topic, parser := range parsers {
+		if err := s.add(topic, parser, sub); err != nil {
+			s.stop(sub, topics)
+			return nil, nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return sub.bus, func() {
+		s.stop(sub, topics)
+	}, nil
+}
+
+func (s *subscriptions) add(topic string, parser func(msg *message.Message) (interface{}, error), sub *subscriber) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if subscribers, ok := s.topics[topic]; ok {
+		subscribers[sub] = struct{}{}
+		return nil
+	}
+	if err := s.mb.Subscribe(func(msg *message.Message) {
+		v, err := parser(msg)
+		if err != nil {
+			s.lg.WithError(err).Errorf("fail to parse the payload of the data operation")
+		}
+		s.publish(topic, v)
+	}, topic); err != nil {
+		return err
+	}
+	s.topics[topic] = map[*subscriber]struct{}{sub: {}}
+	return nil
+}
+
+// publish sends the value to the subscribers of the topic, the sending to a subscriber is given up once it stops,
+// so that its channel can be closed without any sending left.
+func (s *subscriptions) publish(topic string, v interface{}) {
+	s.mu.Lock()
+	subscribers := make([]*subscriber, 0, len(s.topics[topic]))
+	for sub := range s.topics[topic] {
+		if sub.match != nil && !sub.match(v) {
+			continue
+		}
+		sub.wg.Add(1)
+		subscribers = append(subscribers, sub)
+	}
+	s.mu.Unlock()
+
+	for _, sub := range subscribers {
+		select {
+		case sub.bus <- v:
+		case <-sub.done:
+		}
+		sub.wg.Done()
+	}
+}
+
+// stop removes the subscriber from the topics, and closes its channel after the values being sent are given up.
+func (s *subscriptions) stop(sub *subscriber, topics []string) {
+	s.mu.Lock()
+	for _, topic := range topics {
+		subscribers := s.topics[topic]
+		if _, ok := subscribers[sub]; !ok {
+			continue
+		}
+		delete(subscribers, sub)
+		if len(subscribers) == 0 {
+			delete(s.topics, topic)
+			if err := s.mb.Unsubscribe(topic); err != nil {
+				s.lg.WithError(err).Errorf("fail to unsubscribe the topic: %s", topic)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sub.once.Do(func() {
+		close(sub.done)
+		sub.wg.Wait()
+		close(sub.bus)
+	})
+}
@@ -0,0 +1,102 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"testing"
+	"time"
+)
+
+func publishProps(t *testing.T, mb *fakeMessageBus, value interface{}) {
+	if err := mb.Publish(newPropsMessage(t, value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newPropsMessage(t *testing.T, value interface{}) *message.Message {
+	o := NewDataOperation(OperationModeUp, "modbus", "meter", "m1", "temperature", DataOperationTypeWatch, EmptyReqID())
+	o.SetValue(map[models.ProductPropertyID]*models.DeviceData{
+		"temperature": {Name: "temperature", Type: models.PropertyValueTypeFloat, Value: value},
+	})
+	msg, err := o.ToMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestSubscriptions(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ms, err := newDataManagerService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscriptions of the same topic share one handler of the bus
+	first, stopFirst, err := ms.SubscribeDeviceProps("modbus", "meter", "m1", "temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, stopSecond, err := ms.SubscribeDeviceProps("modbus", "meter", "m1", "temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishProps(t, mb, 20.0)
+	if next(t, first) == nil || next(t, second) == nil {
+		t.Fatalf("the subscribers of the same topic should all receive the props")
+	}
+
+	stopFirst()
+	if _, ok := <-first; ok {
+		t.Errorf("the channel of the stopped subscriber should be closed")
+	}
+	publishProps(t, mb, 21.0)
+	if next(t, second) == nil {
+		t.Errorf("the subscriber should still receive the props after another one stopped")
+	}
+	stopSecond()
+	if len(mb.handlers) != 0 {
+		t.Errorf("the topics should be unsubscribed after all subscribers stopped, got %v", mb.handlers)
+	}
+}
+
+func TestSubscriptions_StopWhileSending(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ms, err := newDataManagerService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stop, err := ms.SubscribeDeviceProps("modbus", "meter", "m1", "temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < subscriptionBufferSize; i++ {
+		publishProps(t, mb, float64(i))
+	}
+
+	// the sending blocked by the full channel is given up once the subscriber stops, rather than
+	// sending on the closed channel
+	msg := newPropsMessage(t, 0.0)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		_ = mb.Publish(msg)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("the sending is still blocked after the subscriber stopped")
+	}
+}
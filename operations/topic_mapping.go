@@ -0,0 +1,207 @@
+package operations
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"strings"
+	"sync"
+)
+
+// CustomizableOperationTypes are the operation types published by the drivers without requests,
+// whose topics can be customized by models.ProductTopic.
+var CustomizableOperationTypes = map[DataOperationType]bool{
+	DataOperationTypeHealthCheck: true,
+	DataOperationTypeWatch:       true,
+	DataOperationTypeEvent:       true,
+	DataOperationTypeShadowDelta: true,
+	DataOperationTypeAlarm:       true,
+	DataOperationTypeAggregate:   true,
+}
+
+// NewTopicMapping returns an empty TopicMapping.
+func NewTopicMapping() *TopicMapping {
+	return &TopicMapping{templates: make(map[string]map[DataOperationType]*topicTemplate)}
+}
+
+// TopicMapping maps the data operations of the products into the custom topics declared by the products,
+// instead of the topics formed by the Schemas, e.g. to integrate with the legacy layouts of topics.
+// The topics are resolved when publishing or subscribing, so the subscriptions made before the custom
+// topics of a product are loaded, changed or removed keep the topics of then, they should be made again.
+type TopicMapping struct {
+	mu        sync.RWMutex
+	templates map[string]map[DataOperationType]*topicTemplate // product ID -> operation type -> template
+}
+
+// SetProduct loads the custom topics of the product, it replaces the topics loaded before.
+// The topics which may be rendered by another template, of the product or the others, are rejected,
+// since the operations received from them can't be told apart.
+func (m *TopicMapping) SetProduct(product *models.Product) error {
+	templates := make(map[DataOperationType]*topicTemplate, len(product.Topics))
+	for _, topic := range product.Topics {
+		optType := DataOperationType(topic.OptType)
+		if !CustomizableOperationTypes[optType] {
+			return fmt.Errorf("the topic of the operation type %s of the product %s can't be customized", optType, product.ID)
+		}
+		if _, ok := templates[optType]; ok {
+			return fmt.Errorf("the topic of the operation type %s of the product %s is declared more than once", optType, product.ID)
+		}
+		template := &topicTemplate{productID: product.ID, protocolID: product.Protocol, optType: optType, levels: topic.Levels()}
+		if optType == DataOperationTypeEvent && !template.hasFuncID() {
+			// unlike the properties, alarms and aggregations, the events can't be told apart by their payloads
+			return fmt.Errorf("the topic of the operation type %s of the product %s should contain the placeholder %s",
+				optType, product.ID, models.ProductTopicPlaceholderFuncID)
+		}
+		for _, other := range templates {
+			if template.overlaps(other) {
+				return fmt.Errorf("the topic %s of the operation type %s of the product %s conflicts with the one of %s",
+					template, optType, product.ID, other.optType)
+			}
+		}
+		templates[optType] = template
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for productID, others := range m.templates {
+		if productID == product.ID {
+			continue
+		}
+		for _, template := range templates {
+			for _, other := range others {
+				if template.overlaps(other) {
+					return fmt.Errorf("the topic %s of the operation type %s of the product %s conflicts with "+
+						"the one of %s of the product %s", template, template.optType, product.ID, other.optType, productID)
+				}
+			}
+		}
+	}
+	if len(templates) == 0 {
+		delete(m.templates, product.ID)
+		return nil
+	}
+	m.templates[product.ID] = templates
+	return nil
+}
+
+// RemoveProduct unloads the custom topics of the product.
+func (m *TopicMapping) RemoveProduct(productID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.templates, productID)
+}
+
+// template returns the template of the operation type of the product if any.
+func (m *TopicMapping) template(productID string, optType DataOperationType) (*topicTemplate, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	template, ok := m.templates[productID][optType]
+	return template, ok
+}
+
+// filters returns the templates of the operation type of the product, or all products of the protocol
+// if the product ID is the TopicSingleLevelWildcard. The products without the protocol are of any protocol.
+func (m *TopicMapping) filters(protocolID, productID string, optType DataOperationType) []*topicTemplate {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var templates []*topicTemplate
+	for id, products := range m.templates {
+		if productID != TopicSingleLevelWildcard && productID != id {
+			continue
+		}
+		template, ok := products[optType]
+		if !ok {
+			continue
+		}
+		if protocolID != TopicSingleLevelWildcard && template.protocolID != "" && template.protocolID != protocolID {
+			continue
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+type topicTemplate struct {
+	productID  string
+	protocolID string
+	optType    DataOperationType
+	levels     []string
+}
+
+// hasFuncID returns whether the template has the placeholder of the func ID.
+func (t *topicTemplate) hasFuncID() bool {
+	for _, level := range t.levels {
+		if level == models.ProductTopicPlaceholderFuncID {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *topicTemplate) String() string {
+	return strings.Join(t.levels, TopicLevelSeparator)
+}
+
+// overlaps returns whether a topic may be rendered by both templates, which can't be told apart then.
+func (t *topicTemplate) overlaps(other *topicTemplate) bool {
+	if len(t.levels) != len(other.levels) {
+		return false
+	}
+	for i, level := range t.levels {
+		if isPlaceholder(level) || isPlaceholder(other.levels[i]) {
+			continue
+		}
+		if level != other.levels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isPlaceholder(level string) bool {
+	return level == models.ProductTopicPlaceholderDeviceID || level == models.ProductTopicPlaceholderFuncID
+}
+
+// filter renders the template into a topic, or a topic filter if the IDs are TopicSingleLevelWildcard.
+func (t *topicTemplate) filter(deviceID string, funcID models.ProductFuncID) string {
+	levels := make([]string, len(t.levels))
+	for i, level := range t.levels {
+		switch level {
+		case models.ProductTopicPlaceholderDeviceID:
+			levels[i] = deviceID
+		case models.ProductTopicPlaceholderFuncID:
+			levels[i] = funcID
+		default:
+			levels[i] = level
+		}
+	}
+	return strings.Join(levels, TopicLevelSeparator)
+}
+
+// parse extracts the device ID and the func ID from the topic rendered by the template,
+// the func ID is TopicSingleLevelWildcard if the template has no placeholder of it.
+func (t *topicTemplate) parse(topic string) (deviceID string, funcID models.ProductFuncID, err error) {
+	levels := strings.Split(topic, TopicLevelSeparator)
+	if len(levels) != len(t.levels) {
+		return "", "", fmt.Errorf("the topic %s doesn't match the template %s", topic, t)
+	}
+	funcID = TopicSingleLevelWildcard
+	for i, level := range t.levels {
+		switch level {
+		case models.ProductTopicPlaceholderDeviceID:
+			deviceID = levels[i]
+		case models.ProductTopicPlaceholderFuncID:
+			funcID = levels[i]
+		default:
+			if level != levels[i] {
+				return "", "", fmt.Errorf("the topic %s doesn't match the template %s", topic, t)
+			}
+		}
+	}
+	return deviceID, funcID, nil
+}
@@ -0,0 +1,207 @@
+package operations
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"strings"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, ch <-chan interface{}) map[models.ProductPropertyID]*models.DeviceData {
+	select {
+	case v := <-ch:
+		return v.(map[models.ProductPropertyID]*models.DeviceData)
+	case <-time.After(time.Second):
+		t.Fatalf("no properties are received")
+		return nil
+	}
+}
+
+func TestCustomTopics(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	dc, err := newDataDriverClient(mb, nil, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newDataManagerService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &models.Product{ID: "legacy", Topics: []*models.ProductTopic{
+		{Topic: "plant/meters/{device_id}/{func_id}", OptType: string(DataOperationTypeWatch)},
+	}}
+	for _, c := range []interface{ SetProductTopics(*models.Product) error }{dc, ms} {
+		if err := c.SetProductTopics(legacy); err != nil {
+			t.Fatalf("fail to set the topics: %s", err.Error())
+		}
+	}
+
+	device, stopDevice, err := ms.SubscribeDeviceProps("modbus", "legacy", "m1", TopicSingleLevelWildcard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopDevice()
+	all, stopAll, err := ms.SubscribeDeviceProps("modbus", TopicSingleLevelWildcard, TopicSingleLevelWildcard, TopicSingleLevelWildcard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopAll()
+
+	voltage, _ := models.NewDeviceData("voltage", models.PropertyValueTypeFloat, 220.5)
+	props := map[models.ProductPropertyID]*models.DeviceData{"voltage": voltage}
+	if err = dc.PublishDeviceProps("modbus", "legacy", "m1", "voltage", props); err != nil {
+		t.Fatal(err)
+	}
+	if err = dc.PublishDeviceProps("modbus", "standard", "s1", "voltage", props); err != nil {
+		t.Fatal(err)
+	}
+
+	if mb.published[0] != "plant/meters/m1/voltage" {
+		t.Errorf("the properties of the legacy product should be published to the custom topic, got %s", mb.published[0])
+	}
+	if !strings.HasPrefix(mb.published[1], string(OperationCategoryData)) {
+		t.Errorf("the properties of the standard product should be published to the standard topic, got %s", mb.published[1])
+	}
+	if v := receive(t, device)["voltage"]; v == nil || v.Value != 220.5 {
+		t.Errorf("unexpected properties of the device: %+v", v)
+	}
+	receive(t, all)
+	receive(t, all)
+
+	dc.RemoveProductTopics("legacy")
+	if err = dc.PublishDeviceProps("modbus", "legacy", "m1", "voltage", props); err != nil {
+		t.Fatal(err)
+	}
+	if topic := mb.published[2]; !strings.HasPrefix(topic, string(OperationCategoryData)) {
+		t.Errorf("the standard topic should be used after the custom topics are removed, got %s", topic)
+	}
+}
+
+func TestTopicMappingInvalid(t *testing.T) {
+	m := NewTopicMapping()
+	if err := m.SetProduct(&models.Product{ID: "a", Topics: []*models.ProductTopic{
+		{Topic: "a/{device_id}", OptType: string(DataOperationTypeRead)},
+	}}); err == nil {
+		t.Errorf("the topic of the read operation shouldn't be customized")
+	}
+	if err := m.SetProduct(&models.Product{ID: "a", Topics: []*models.ProductTopic{
+		{Topic: "meters/{device_id}/{func_id}", OptType: string(DataOperationTypeWatch)},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetProduct(&models.Product{ID: "b", Topics: []*models.ProductTopic{
+		{Topic: "meters/{device_id}/{func_id}", OptType: string(DataOperationTypeWatch)},
+	}}); err == nil {
+		t.Errorf("the same topic of different products should conflict")
+	}
+	if err := m.SetProduct(&models.Product{ID: "b", Topics: []*models.ProductTopic{
+		{Topic: "meters/{device_id}/alarms", OptType: string(DataOperationTypeAlarm)},
+	}}); err == nil {
+		t.Errorf("the topics of different operation types of different products should conflict if they overlap")
+	}
+	if err := m.SetProduct(&models.Product{ID: "c", Topics: []*models.ProductTopic{
+		{Topic: "valves/{device_id}/{func_id}", OptType: string(DataOperationTypeWatch)},
+		{Topic: "valves/{device_id}/{func_id}", OptType: string(DataOperationTypeEvent)},
+	}}); err == nil {
+		t.Errorf("the same topic of different operation types of the same product should conflict")
+	}
+	if err := m.SetProduct(&models.Product{ID: "c", Topics: []*models.ProductTopic{
+		{Topic: "valves/{device_id}/props", OptType: string(DataOperationTypeWatch)},
+		{Topic: "valves/{device_id}/props", OptType: string(DataOperationTypeWatch)},
+	}}); err == nil {
+		t.Errorf("the topics of the same operation type of the product should be declared once")
+	}
+	if err := m.SetProduct(&models.Product{ID: "c", Topics: []*models.ProductTopic{
+		{Topic: "valves/{device_id}/props", OptType: string(DataOperationTypeWatch)},
+		{Topic: "valves/{device_id}/events/{func_id}", OptType: string(DataOperationTypeEvent)},
+	}}); err != nil {
+		t.Errorf("the topics told apart by the literal levels shouldn't conflict: %v", err)
+	}
+	if err := m.SetProduct(&models.Product{ID: "d", Topics: []*models.ProductTopic{
+		{Topic: "pumps/{device_id}/events", OptType: string(DataOperationTypeEvent)},
+	}}); err == nil {
+		t.Errorf("the topic of events without the func ID should be rejected")
+	}
+
+	template, _ := m.template("a", DataOperationTypeWatch)
+	if _, _, err := template.parse("meters/m1"); err == nil {
+		t.Errorf("the topic of different levels shouldn't match")
+	}
+	deviceID, funcID, err := template.parse("meters/m1/voltage")
+	if err != nil || deviceID != "m1" || funcID != "voltage" {
+		t.Errorf("unexpected device %s and func %s parsed, err: %v", deviceID, funcID, err)
+	}
+}
+
+func TestCustomTopicsWithoutFuncID(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	dc, err := newDataDriverClient(mb, nil, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := newDataManagerService(mb, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &models.Product{ID: "legacy", Protocol: "modbus", Topics: []*models.ProductTopic{
+		{Topic: "legacy/{device_id}/telemetry", OptType: string(DataOperationTypeWatch)},
+	}}
+	for _, c := range []interface{ SetProductTopics(*models.Product) error }{dc, ms} {
+		if err := c.SetProductTopics(legacy); err != nil {
+			t.Fatalf("fail to set the topics: %s", err.Error())
+		}
+	}
+
+	// the subscriptions of different properties share the topic, but only receive their own properties
+	voltage, stopVoltage, err := ms.SubscribeDeviceProps("modbus", "legacy", "m1", "voltage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopVoltage()
+	current, stopCurrent, err := ms.SubscribeDeviceProps("modbus", "legacy", "m1", "current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopCurrent()
+	for _, property := range []models.ProductPropertyID{"voltage", "current"} {
+		data, _ := models.NewDeviceData(property, models.PropertyValueTypeFloat, 1.5)
+		if err = dc.PublishDeviceProps("modbus", "legacy", "m1", property,
+			map[models.ProductPropertyID]*models.DeviceData{property: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if props := receive(t, voltage); props["voltage"] == nil || len(props) != 1 {
+		t.Errorf("the properties received by the subscription of voltage = %v", props)
+	}
+	if props := receive(t, current); props["current"] == nil || len(props) != 1 {
+		t.Errorf("the properties received by the subscription of current = %v", props)
+	}
+	select {
+	case v := <-voltage:
+		t.Errorf("the subscription of voltage receives other properties: %v", v)
+	default:
+	}
+
+	// the custom topics of the products of other protocols are not subscribed
+	_, stopOther, err := ms.SubscribeDeviceProps("opcua", TopicSingleLevelWildcard, TopicSingleLevelWildcard, "voltage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopOther()
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if len(mb.handlers) != 2 {
+		t.Errorf("the topics subscribed = %d, want the custom topic and the standard one of opcua", len(mb.handlers))
+	}
+}
@@ -0,0 +1,211 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileSuffix = ".log"
+	fileLayout = "20060102" // a file per day
+)
+
+// NewFileStorage returns a Storage appending the records as JSON lines into the files of the directory,
+// the records of a device are split into a file per day, named by <dir>/<device ID>/<yyyyMMdd>.log.
+func NewFileStorage(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStorage{dir: dir}, nil
+}
+
+type fileStorage struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func (f *fileStorage) Write(records []*Record) error {
+	files := make(map[string][]*Record)
+	for _, r := range records {
+		if r.Data == nil {
+			continue
+		}
+		if r.Data.Ts.IsZero() {
+			r.Data.Ts = time.Now()
+		}
+		path := f.path(r.DeviceID, r.Data.Ts)
+		files[path] = append(files[path], r)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for path, records := range files {
+		if err := appendFile(path, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fileStorage) Query(query *Query) ([]*Record, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	days, err := f.days(query.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for _, day := range days {
+		if !query.Start.IsZero() && !day.Add(24*time.Hour).After(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && !day.Before(query.End) {
+			continue
+		}
+		// the records of a day may be appended out of order, but they are all before the ones of the next day,
+		// so the scanning stops after the day reaching the limit
+		sorted := len(records)
+		if records, err = scanFile(f.path(query.DeviceID, day), query, records); err != nil {
+			return nil, err
+		}
+		daily := records[sorted:]
+		sort.SliceStable(daily, func(i, j int) bool {
+			return daily[i].Data.Ts.Before(daily[j].Data.Ts)
+		})
+		if query.Limit > 0 && len(records) >= query.Limit {
+			return records[:query.Limit], nil
+		}
+	}
+	return records, nil
+}
+
+func (f *fileStorage) Delete(deviceID string, before time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if before.IsZero() {
+		return os.RemoveAll(filepath.Join(f.dir, url.PathEscape(deviceID)))
+	}
+	days, err := f.days(deviceID)
+	if err != nil {
+		return err
+	}
+	// only the files of the days which end before the time are removed
+	for _, day := range days {
+		if day.Add(24 * time.Hour).After(before) {
+			break
+		}
+		if err = os.Remove(f.path(deviceID, day)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fileStorage) Devices() ([]string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	infos, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	deviceIDs := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		deviceID, err := url.PathUnescape(info.Name())
+		if err != nil {
+			continue
+		}
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	return deviceIDs, nil
+}
+
+func (f *fileStorage) Close() error {
+	return nil
+}
+
+func (f *fileStorage) path(deviceID string, ts time.Time) string {
+	return filepath.Join(f.dir, url.PathEscape(deviceID), ts.UTC().Format(fileLayout)+fileSuffix)
+}
+
+// days returns the days having records of the device in order.
+func (f *fileStorage) days(deviceID string) ([]time.Time, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(f.dir, url.PathEscape(deviceID)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	days := make([]time.Time, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), fileSuffix) {
+			continue
+		}
+		day, err := time.Parse(fileLayout, strings.TrimSuffix(info.Name(), fileSuffix))
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	return days, nil
+}
+
+func appendFile(path string, records []*Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, r := range records {
+		if err = encoder.Encode(r); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func scanFile(path string, query *Query, records []*Record) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		r := new(Record)
+		if err = json.Unmarshal(scanner.Bytes(), r); err != nil || r.Data == nil {
+			// skip the line truncated by crashing
+			continue
+		}
+		if query.Match(r) && query.Contains(r.Data.Ts) {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
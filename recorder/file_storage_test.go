@@ -0,0 +1,67 @@
+package recorder
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func newRecord(propertyID models.ProductPropertyID, value float64, ts time.Time) *Record {
+	return &Record{ProductID: "product", DeviceID: "device", PropertyID: propertyID,
+		Data: &models.DeviceData{Name: propertyID, Type: models.PropertyValueTypeFloat, Value: value, Ts: ts}}
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage() error = %v", err)
+	}
+
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err = storage.Write([]*Record{
+		newRecord("temperature", 20, day.Add(23*time.Hour)),
+		newRecord("humidity", 50, day.Add(23*time.Hour)),
+		newRecord("temperature", 21, day.Add(25*time.Hour)),
+		newRecord("temperature", 22, day.Add(49*time.Hour)),
+	}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	records, err := storage.Query(&Query{DeviceID: "device", PropertyIDs: []models.ProductPropertyID{"temperature"},
+		Start: day.Add(12 * time.Hour), End: day.Add(48 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Data.Value != 20.0 || records[1].Data.Value != 21.0 {
+		t.Errorf("Query() = %v, want the temperatures 20 and 21", records)
+	}
+
+	// the records appended out of order are still returned in order within the limit
+	if err = storage.Write([]*Record{newRecord("temperature", 19, day.Add(22*time.Hour))}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	records, err = storage.Query(&Query{DeviceID: "device", PropertyIDs: []models.ProductPropertyID{"temperature"}, Limit: 2})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Data.Value != 19.0 || records[1].Data.Value != 20.0 {
+		t.Errorf("Query() with the limit = %v, want the temperatures 19 and 20", records)
+	}
+	if records, _ = storage.Query(&Query{DeviceID: "device", ProductID: "other"}); len(records) != 0 {
+		t.Errorf("Query() the records of another product = %v, want none", records)
+	}
+
+	if err = storage.Delete("device", day.Add(48*time.Hour)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if records, _ = storage.Query(&Query{DeviceID: "device"}); len(records) != 1 {
+		t.Errorf("the number of the records after deleting = %d, want 1", len(records))
+	}
+}
@@ -0,0 +1,206 @@
+package recorder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultPageSize = 1000
+	maxPageSize     = 10000
+)
+
+// cursor locates the next page by the timestamp of the last point and
+// the number of the points at the timestamp which have been returned.
+type cursor struct {
+	Ts   time.Time `json:"ts"`
+	Skip int       `json:"skip"`
+}
+
+func (c *cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := new(cursor)
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ServeHistory serves the history queries requested by the manager.
+func (r *Recorder) ServeHistory(hs operations.HistoryService) error {
+	return hs.QueryHandler(r.QueryHistory)
+}
+
+// QueryHistory returns a page of the recorded data of the device, which is downsampled if the Interval is specified.
+func (r *Recorder) QueryHistory(productID, deviceID string, query *operations.HistoryQuery) (*operations.HistoryResult, error) {
+	q := &Query{
+		DeviceID:    deviceID,
+		ProductID:   productID,
+		PropertyIDs: query.PropertyIDs,
+		EventID:     query.EventID,
+		Start:       query.Start,
+		End:         query.End,
+	}
+	skip := 0
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err == nil && c.Skip < 0 {
+			err = fmt.Errorf("the skip %d should not be negative", c.Skip)
+		}
+		if err != nil {
+			return nil, errors.BadRequest.Cause(err, "invalid cursor: %s", query.Cursor)
+		}
+		q.Start, skip = c.Ts, c.Skip
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	} else if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if query.Interval == "" {
+		// the points skipped, the page and one more to tell whether there is a next page,
+		// all records within the range are required by downsampling though
+		q.Limit = skip + limit + 1
+	}
+	records, err := r.storage.Query(q)
+	if err != nil {
+		return nil, errors.Internal.Cause(err, "fail to query the records of the device[%s]", deviceID)
+	}
+	var points []*operations.HistoryPoint
+	if query.Interval == "" {
+		points = make([]*operations.HistoryPoint, 0, len(records))
+		for _, record := range records {
+			points = append(points, &operations.HistoryPoint{PropertyID: record.PropertyID, Data: record.Data})
+		}
+	} else {
+		interval, err := time.ParseDuration(query.Interval)
+		if err != nil || interval <= 0 {
+			return nil, errors.BadRequest.Error("invalid interval: %s", query.Interval)
+		}
+		fn := query.Aggregation
+		if fn == "" {
+			fn = models.AggregateFunctionAvg
+		}
+		if points, err = downsample(productID, records, interval, fn); err != nil {
+			return nil, err
+		}
+	}
+	return paginate(points, q.Start, skip, limit), nil
+}
+
+// paginate returns the points after skipping the ones returned by the previous pages.
+func paginate(points []*operations.HistoryPoint, start time.Time, skip, limit int) *operations.HistoryResult {
+	offset := 0
+	for offset < len(points) && skip > 0 && points[offset].Data.Ts.Equal(start) {
+		offset++
+		skip--
+	}
+	points = points[offset:]
+	result := &operations.HistoryResult{Points: points}
+	if len(points) <= limit {
+		return result
+	}
+
+	result.Points = points[:limit]
+	last := result.Points[limit-1].Data.Ts
+	c := &cursor{Ts: last}
+	for i := limit - 1; i >= 0 && result.Points[i].Data.Ts.Equal(last); i-- {
+		c.Skip++
+	}
+	if last.Equal(start) {
+		// all points of the page are at the start, the points skipped before should be skipped again
+		c.Skip += offset
+	}
+	result.NextCursor = c.encode()
+	return result
+}
+
+type bucket struct {
+	propertyID models.ProductPropertyID
+	start      time.Time
+}
+
+// downsample aggregates the numeric records of each property within each interval,
+// the timestamp of the aggregated value is the start of the interval.
+func downsample(productID string, records []*Record, interval time.Duration,
+	fn models.AggregateFunction) ([]*operations.HistoryPoint, error) {
+	switch fn {
+	case models.AggregateFunctionMin, models.AggregateFunctionMax, models.AggregateFunctionAvg,
+		models.AggregateFunctionCount, models.AggregateFunctionLast:
+	default:
+		return nil, errors.BadRequest.Error("unsupported aggregation: %s", fn)
+	}
+
+	type state struct {
+		min, max, sum, last float64
+		count               int
+		quality             models.DataQuality
+	}
+	states := make(map[bucket]*state)
+	for _, record := range records {
+		if record.ProductID != productID || record.Data.IsBad() {
+			continue
+		}
+		v, err := record.Data.NumericValue()
+		if err != nil {
+			continue
+		}
+		key := bucket{propertyID: record.PropertyID, start: record.Data.Ts.Truncate(interval)}
+		st, ok := states[key]
+		if !ok {
+			st = &state{min: v, max: v, quality: models.DataQualityGood}
+			states[key] = st
+		}
+		st.min, st.max = math.Min(st.min, v), math.Max(st.max, v)
+		st.sum += v
+		st.count++
+		st.last = v // the records are in the order of their timestamps
+		st.quality = models.WorseDataQuality(st.quality, record.Data.GetQuality())
+	}
+
+	points := make([]*operations.HistoryPoint, 0, len(states))
+	for key, st := range states {
+		var v float64
+		switch fn {
+		case models.AggregateFunctionMin:
+			v = st.min
+		case models.AggregateFunctionMax:
+			v = st.max
+		case models.AggregateFunctionAvg:
+			v = st.sum / float64(st.count)
+		case models.AggregateFunctionCount:
+			v = float64(st.count)
+		case models.AggregateFunctionLast:
+			v = st.last
+		}
+		points = append(points, &operations.HistoryPoint{
+			PropertyID: key.propertyID,
+			Data: &models.DeviceData{Name: key.propertyID, Type: models.PropertyValueTypeFloat, Value: v,
+				Ts: key.start, Quality: st.quality},
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if !points[i].Data.Ts.Equal(points[j].Data.Ts) {
+			return points[i].Data.Ts.Before(points[j].Data.Ts)
+		}
+		return points[i].PropertyID < points[j].PropertyID
+	})
+	return points, nil
+}
@@ -0,0 +1,69 @@
+package recorder
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecorder_QueryHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder("protocol", nil, storage, lg)
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var records []*Record
+	for i := 0; i < 10; i++ {
+		ts := start.Add(time.Duration(i) * 10 * time.Second)
+		records = append(records, newRecord("temperature", float64(i), ts), newRecord("humidity", float64(i*10), ts))
+	}
+	if err = storage.Write(records); err != nil {
+		t.Fatal(err)
+	}
+
+	// page through the raw points, the page boundary splits the points at the same timestamp
+	query := &operations.HistoryQuery{Start: start, End: start.Add(time.Hour), Limit: 3}
+	var points []*operations.HistoryPoint
+	for {
+		result, err := r.QueryHistory("product", "device", query)
+		if err != nil {
+			t.Fatalf("QueryHistory() error = %v", err)
+		}
+		points = append(points, result.Points...)
+		if result.NextCursor == "" {
+			break
+		}
+		query.Cursor = result.NextCursor
+	}
+	if len(points) != 20 {
+		t.Errorf("the number of the paged points = %d, want 20", len(points))
+	}
+
+	result, err := r.QueryHistory("product", "device", &operations.HistoryQuery{
+		PropertyIDs: []models.ProductPropertyID{"temperature"},
+		Start:       start, End: start.Add(time.Hour),
+		Interval: "30s", Aggregation: models.AggregateFunctionMax,
+	})
+	if err != nil {
+		t.Fatalf("QueryHistory() error = %v", err)
+	}
+	if len(result.Points) != 4 || result.Points[0].Data.Value != 2.0 || result.Points[3].Data.Value != 9.0 {
+		t.Errorf("the downsampled points = %v, want the maximums 2, 5, 8 and 9", result.Points)
+	}
+}
@@ -0,0 +1,215 @@
+package recorder
+
+import (
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sync"
+	"time"
+)
+
+// NewRecorder returns a Recorder persisting the data of the devices of the protocol into the storage,
+// use operations.TopicSingleLevelWildcard as the protocolID to record the devices of all protocols.
+// The ms should be shared with the other consumers of the same devices, e.g. the aggregate.Aggregator,
+// so that their subscriptions share one handler of the MessageBus, see operations.DataManagerService.
+func NewRecorder(protocolID string, ms operations.DataManagerService, storage Storage, lg *logger.Logger) *Recorder {
+	return &Recorder{
+		protocolID:    protocolID,
+		ms:            ms,
+		storage:       storage,
+		products:      make(map[string]*models.Product),
+		devices:       make(map[string]*models.Device),
+		subscriptions: make(map[string][]func()),
+		lg:            lg,
+	}
+}
+
+// Recorder follows the metadata of devices, and records the properties and events of the devices
+// whose Recording is set. The events are recorded only if the product of the device is known.
+type Recorder struct {
+	protocolID string
+	ms         operations.DataManagerService
+	storage    Storage
+
+	mu            sync.Mutex
+	products      map[string]*models.Product
+	devices       map[string]*models.Device
+	subscriptions map[string][]func() // device ID -> stop the subscriptions
+
+	lg *logger.Logger
+}
+
+// Serve follows the metadata published by the manager to the drivers.
+func (r *Recorder) Serve(mds operations.MetaDriverService) error {
+	if err := mds.InitializeDriverHandler(r.protocolID, r.Init); err != nil {
+		return err
+	}
+	if err := mds.MutateProductHandler(r.protocolID, r.UpdateProduct, r.DeleteProduct); err != nil {
+		return err
+	}
+	return mds.MutateDeviceHandler(r.protocolID, r.UpdateDevice, r.DeleteDevice)
+}
+
+// Init loads the full metadata, e.g. fetched from the manager on startup.
+func (r *Recorder) Init(products []*models.Product, devices []*models.Device) error {
+	for _, product := range products {
+		if err := r.UpdateProduct(product); err != nil {
+			return err
+		}
+	}
+	for _, device := range devices {
+		if err := r.UpdateDevice(device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateProduct records the product, the recording devices of it are re-subscribed to follow the changes of events.
+func (r *Recorder) UpdateProduct(product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var diff *models.ProductDiff
+	if previous, ok := r.products[product.ID]; ok {
+		var err error
+		if diff, err = models.DiffProducts(previous, product); err != nil {
+			return err
+		}
+	}
+	r.products[product.ID] = product
+	if diff != nil && !diff.Changed(models.ProductFuncKindEvent) {
+		return nil
+	}
+	for _, device := range r.devices {
+		if device.ProductID != product.ID || !device.Recording {
+			continue
+		}
+		r.unsubscribe(device.ID)
+		if err := r.subscribe(device); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) DeleteProduct(productID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.products, productID)
+	return nil
+}
+
+// UpdateDevice starts or stops recording the device as its Recording toggles,
+// the recording device is re-subscribed if it is moved to another product.
+func (r *Recorder) UpdateDevice(device *models.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.devices[device.ID]
+	r.devices[device.ID] = device
+	if ok && previous.Recording == device.Recording &&
+		(!device.Recording || previous.ProductID == device.ProductID) {
+		return nil
+	}
+
+	r.unsubscribe(device.ID)
+	if !device.Recording {
+		r.lg.Infof("stop recording the device[%s]", device.ID)
+		return nil
+	}
+	r.lg.Infof("start recording the device[%s]", device.ID)
+	return r.subscribe(device)
+}
+
+// DeleteDevice stops recording the device, the records are kept in the storage.
+func (r *Recorder) DeleteDevice(deviceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unsubscribe(deviceID)
+	delete(r.devices, deviceID)
+	return nil
+}
+
+// Query returns the records selected by the query.
+func (r *Recorder) Query(query *Query) ([]*Record, error) {
+	return r.storage.Query(query)
+}
+
+// Purge removes the records of all devices in the storage before the time, e.g. to keep the records of recent days,
+// the devices deleted or no longer recorded are purged as well.
+func (r *Recorder) Purge(before time.Time) error {
+	deviceIDs, err := r.storage.Devices()
+	if err != nil {
+		return err
+	}
+	for _, deviceID := range deviceIDs {
+		if err = r.storage.Delete(deviceID, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops recording all devices and closes the storage.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	for deviceID := range r.subscriptions {
+		r.unsubscribe(deviceID)
+	}
+	r.mu.Unlock()
+	return r.storage.Close()
+}
+
+func (r *Recorder) subscribe(device *models.Device) error {
+	bus, stop, err := r.ms.SubscribeDeviceProps(r.protocolID, device.ProductID, device.ID,
+		operations.TopicSingleLevelWildcard)
+	if err != nil {
+		return err
+	}
+	r.subscriptions[device.ID] = append(r.subscriptions[device.ID], stop)
+	go r.record(device, "", bus)
+
+	product, ok := r.products[device.ProductID]
+	if !ok {
+		return nil
+	}
+	for _, event := range product.Events {
+		bus, stop, err = r.ms.SubscribeDeviceEvent(r.protocolID, device.ProductID, device.ID, event.Id)
+		if err != nil {
+			r.unsubscribe(device.ID)
+			return err
+		}
+		r.subscriptions[device.ID] = append(r.subscriptions[device.ID], stop)
+		go r.record(device, event.Id, bus)
+	}
+	return nil
+}
+
+func (r *Recorder) unsubscribe(deviceID string) {
+	for _, stop := range r.subscriptions[deviceID] {
+		stop()
+	}
+	delete(r.subscriptions, deviceID)
+}
+
+// record writes the data received from the bus until it is closed.
+func (r *Recorder) record(device *models.Device, eventID models.ProductEventID, bus <-chan interface{}) {
+	for v := range bus {
+		props, ok := v.(map[models.ProductPropertyID]*models.DeviceData)
+		if !ok {
+			continue
+		}
+		records := make([]*Record, 0, len(props))
+		for id, data := range props {
+			records = append(records, &Record{
+				ProductID:  device.ProductID,
+				DeviceID:   device.ID,
+				EventID:    eventID,
+				PropertyID: id,
+				Data:       data,
+			})
+		}
+		if err := r.storage.Write(records); err != nil {
+			r.lg.WithError(err).Errorf("fail to record the data of the device[%s]", device.ID)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package recorder
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"testing"
+	"time"
+)
+
+// fakeService records the products of the devices whose properties are being subscribed.
+type fakeService struct {
+	operations.DataManagerService
+
+	subscribed map[string]string // device ID -> product ID
+}
+
+func (f *fakeService) SubscribeDeviceProps(protocolID, productID, deviceID string,
+	propertyID models.ProductPropertyID) (<-chan interface{}, func(), error) {
+	f.subscribed[deviceID] = productID
+	bus := make(chan interface{})
+	return bus, func() {
+		delete(f.subscribed, deviceID)
+		close(bus)
+	}, nil
+}
+
+func TestRecorder_UpdateDevice(t *testing.T) {
+	ms := &fakeService{subscribed: make(map[string]string)}
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder("protocol", ms, storage, lg)
+	defer r.Close()
+
+	if err = r.UpdateDevice(&models.Device{ID: "device", ProductID: "a", Recording: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.UpdateDevice(&models.Device{ID: "device", ProductID: "b", Recording: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ms.subscribed["device"] != "b" {
+		t.Errorf("the device moved to the product b is subscribed by the product %q", ms.subscribed["device"])
+	}
+	if err = r.UpdateDevice(&models.Device{ID: "device", ProductID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ms.subscribed["device"]; ok {
+		t.Errorf("the device stopped recording is still subscribed")
+	}
+}
+
+func TestRecorder_UpdateProduct(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder("protocol", &fakeService{subscribed: make(map[string]string)}, storage, lg)
+	defer r.Close()
+
+	current := &models.Product{ID: "product", Version: 2}
+	if err = r.UpdateProduct(current); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.UpdateProduct(&models.Product{ID: "product", Version: 1}); err == nil {
+		t.Errorf("the older revision of the product should be rejected")
+	}
+	if r.products["product"] != current {
+		t.Errorf("the rejected revision of the product is recorded")
+	}
+}
+
+func TestRecorder_Purge(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder("protocol", &fakeService{subscribed: make(map[string]string)}, storage, lg)
+	defer r.Close()
+
+	day := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleted := newRecord("temperature", 20, day)
+	deleted.DeviceID = "line/1"
+	if err = storage.Write([]*Record{deleted, newRecord("temperature", 21, day)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.UpdateDevice(&models.Device{ID: "line/1", ProductID: "product", Recording: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.DeleteDevice("line/1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the records of the deleted device and the device never known are purged as well
+	if err = r.Purge(day.Add(48 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, deviceID := range []string{"line/1", "device"} {
+		if records, _ := storage.Query(&Query{DeviceID: deviceID}); len(records) != 0 {
+			t.Errorf("the records of the device %s after purged = %v, want none", deviceID, records)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package recorder
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"time"
+)
+
+// Record is a value of a property of a device, or a field of an event if the EventID is not empty.
+type Record struct {
+	ProductID  string                   `json:"product_id"`
+	DeviceID   string                   `json:"device_id"`
+	EventID    models.ProductEventID    `json:"event_id,omitempty"`
+	PropertyID models.ProductPropertyID `json:"property_id"`
+	Data       *models.DeviceData       `json:"data"`
+}
+
+// Query selects the records of a device within the time range [Start, End).
+type Query struct {
+	DeviceID string
+	// ProductID selects the records of the product, which the device may be moved from, all are selected if it is empty.
+	ProductID string
+	// PropertyIDs selects the properties or the fields of events, all are selected if it is empty.
+	PropertyIDs []models.ProductPropertyID
+	// EventID selects the fields of the event, the properties are selected if it is empty.
+	EventID models.ProductEventID
+	Start   time.Time // unlimited if it is zero
+	End     time.Time // unlimited if it is zero
+	Limit   int       // the maximum number of records, unlimited if it isn't positive
+}
+
+// Match returns whether the record is selected by the query, except the time range.
+func (q *Query) Match(r *Record) bool {
+	if r.DeviceID != q.DeviceID || r.EventID != q.EventID {
+		return false
+	}
+	if q.ProductID != "" && r.ProductID != q.ProductID {
+		return false
+	}
+	if len(q.PropertyIDs) == 0 {
+		return true
+	}
+	for _, id := range q.PropertyIDs {
+		if id == r.PropertyID {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether the ts is within the time range.
+func (q *Query) Contains(ts time.Time) bool {
+	return (q.Start.IsZero() || !ts.Before(q.Start)) && (q.End.IsZero() || ts.Before(q.End))
+}
+
+// Storage persists the records, it could be implemented by a time-series database.
+type Storage interface {
+	// Write appends the records.
+	Write(records []*Record) error
+	// Query returns the records selected by the query in the order of their timestamps.
+	Query(query *Query) ([]*Record, error)
+	// Delete removes the records of the device, or the records before the time if it is not zero.
+	Delete(deviceID string, before time.Time) error
+	// Devices returns the IDs of the devices having records, including the ones no longer recorded.
+	Devices() ([]string, error)
+	Close() error
+}
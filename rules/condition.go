@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"time"
+)
+
+// conditionState records the observations of a condition.
+type conditionState struct {
+	last   *models.DeviceData
+	rate   float64
+	ready  bool      // whether there are enough observations to evaluate, 2 observations are required by rate
+	passed bool      // the result of the latest comparison
+	since  time.Time // when the comparison became passed
+}
+
+// observe updates the state by the new data of the source, the data with bad quality is ignored.
+func (c *Condition) observe(st *conditionState, data *models.DeviceData) {
+	if data == nil || data.IsBad() {
+		return
+	}
+	ts := data.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	var passed bool
+	if c.Rate {
+		value, err := data.NumericValue()
+		if err != nil {
+			return
+		}
+		if st.last == nil {
+			st.last = &models.DeviceData{Name: data.Name, Type: data.Type, Value: data.Value, Ts: ts}
+			return
+		}
+		last, _ := st.last.NumericValue()
+		elapsed := ts.Sub(st.last.Ts).Seconds()
+		if elapsed <= 0 {
+			return
+		}
+		st.rate = (value - last) / elapsed
+		passed = compare(&models.DeviceData{Type: models.PropertyValueTypeFloat, Value: st.rate}, c.Op, c.Value)
+	} else {
+		passed = compare(data, c.Op, c.Value)
+	}
+	st.last = &models.DeviceData{Name: data.Name, Type: data.Type, Value: data.Value, Ts: ts}
+	st.ready = true
+
+	if passed && !st.passed {
+		st.since = ts
+	}
+	st.passed = passed
+}
+
+// satisfied returns whether the comparison has kept passed for the required duration.
+func (c *Condition) satisfied(st *conditionState, now time.Time) bool {
+	return st.ready && st.passed && now.Sub(st.since) >= c.duration
+}
+
+// compare compares the numbers by their values, and others by their string formats.
+func compare(data *models.DeviceData, op Operator, target interface{}) bool {
+	v, err := data.NumericValue()
+	t, ok := numericValue(target)
+	if err == nil && ok {
+		switch op {
+		case OperatorGT:
+			return v > t
+		case OperatorGE:
+			return v >= t
+		case OperatorLT:
+			return v < t
+		case OperatorLE:
+			return v <= t
+		case OperatorEQ:
+			return v == t
+		case OperatorNE:
+			return v != t
+		}
+		return false
+	}
+
+	switch op {
+	case OperatorEQ:
+		return fmt.Sprintf("%v", data.Value) == fmt.Sprintf("%v", target)
+	case OperatorNE:
+		return fmt.Sprintf("%v", data.Value) != fmt.Sprintf("%v", target)
+	default:
+		return false
+	}
+}
+
+// numericValue returns the value as a number if it is one, like the DeviceData.NumericValue.
+func numericValue(v interface{}) (float64, bool) {
+	n, err := (&models.DeviceData{Type: models.PropertyValueTypeFloat, Value: v}).NumericValue()
+	return n, err == nil
+}
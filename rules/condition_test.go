@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"testing"
+	"time"
+)
+
+func newData(value interface{}, ts time.Time) *models.DeviceData {
+	return &models.DeviceData{Name: "temperature", Type: models.PropertyValueTypeFloat, Value: value, Ts: ts}
+}
+
+func TestCondition_Threshold(t *testing.T) {
+	now := time.Now()
+	c := &Condition{Source: Source{"p", "p", "d", "temperature"}, Op: OperatorGT, Value: 80, For: "30s"}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	st := new(conditionState)
+	c.observe(st, newData(85.0, now))
+	if c.satisfied(st, now.Add(10*time.Second)) {
+		t.Errorf("satisfied() before the duration = true, want false")
+	}
+	if !c.satisfied(st, now.Add(30*time.Second)) {
+		t.Errorf("satisfied() after the duration = false, want true")
+	}
+	c.observe(st, newData(75.0, now.Add(40*time.Second)))
+	if c.satisfied(st, now.Add(time.Minute)) {
+		t.Errorf("satisfied() after dropping below the threshold = true, want false")
+	}
+}
+
+func TestCondition_Rate(t *testing.T) {
+	now := time.Now()
+	c := &Condition{Source: Source{"p", "p", "d", "temperature"}, Op: OperatorGE, Value: 0.5, Rate: true}
+	if err := c.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	st := new(conditionState)
+	c.observe(st, newData(int64(20), now))
+	if c.satisfied(st, now) {
+		t.Errorf("satisfied() with only one observation = true, want false")
+	}
+	c.observe(st, newData(int64(30), now.Add(10*time.Second)))
+	if !c.satisfied(st, now.Add(10*time.Second)) {
+		t.Errorf("satisfied() with the rate 1/s = false, want true")
+	}
+	c.observe(st, newData(int64(32), now.Add(20*time.Second)))
+	if c.satisfied(st, now.Add(20*time.Second)) {
+		t.Errorf("satisfied() with the rate 0.2/s = true, want false")
+	}
+}
+
+func TestRuleState_Join(t *testing.T) {
+	now := time.Now()
+	hot := &Condition{Source: Source{"p", "p", "d1", "temperature"}, Op: OperatorGT, Value: 80}
+	wet := &Condition{Source: Source{"p", "p", "d2", "humidity"}, Op: OperatorGT, Value: 60}
+	open := &Condition{Source: Source{"p", "p", "d3", "door"}, Op: OperatorEQ, Value: "open"}
+	r := &Rule{ID: "r", When: &Group{All: []*Condition{hot}, Any: []*Condition{wet, open}},
+		Then: []*Action{{Type: ActionTypeCall, Target: Target{"p", "p", "d1", "fan.start"}}}}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	st := &ruleState{rule: r, conditions: map[*Condition]*conditionState{
+		hot: new(conditionState), wet: new(conditionState), open: new(conditionState),
+	}}
+
+	hot.observe(st.conditions[hot], newData(85.0, now))
+	if st.satisfied(now) {
+		t.Errorf("satisfied() without any of the Any = true, want false")
+	}
+	open.observe(st.conditions[open], &models.DeviceData{Name: "door", Value: "open", Ts: now})
+	if !st.satisfied(now) {
+		t.Errorf("satisfied() with all of the All and one of the Any = false, want true")
+	}
+}
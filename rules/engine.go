@@ -0,0 +1,323 @@
+package rules
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// evaluateInterval is the interval of evaluating the conditions with durations without new data.
+const evaluateInterval = time.Second
+
+// NewEngine returns an Engine which subscribes the device properties through the DataManagerService,
+// writes properties and calls methods through the DataManagerClient, and publishes events through the DataDriverClient.
+func NewEngine(ms operations.DataManagerService, mc operations.DataManagerClient, dc operations.DataDriverClient,
+	lg *logger.Logger) *Engine {
+	return &Engine{
+		ms:            ms,
+		mc:            mc,
+		dc:            dc,
+		subscriptions: make(map[deviceKey]func()),
+		lg:            lg,
+	}
+}
+
+// Engine evaluates the rules over the incoming device data, and triggers their actions.
+type Engine struct {
+	ms operations.DataManagerService
+	mc operations.DataManagerClient
+	dc operations.DataDriverClient
+
+	mu            sync.Mutex
+	rules         []*ruleState
+	subscriptions map[deviceKey]func() // device -> stop the subscription
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lg *logger.Logger
+}
+
+type deviceKey struct {
+	protocol, product, device string
+}
+
+type ruleState struct {
+	rule       *Rule
+	conditions map[*Condition]*conditionState
+	active     bool
+	firedAt    time.Time
+}
+
+// Load validates the rules and replaces the current ones, the current rules are kept if any of the rules is invalid.
+func (e *Engine) Load(rules *Rules) error {
+	states := make([]*ruleState, 0, len(rules.Rules))
+	ids := make(map[string]bool)
+	for _, r := range rules.Rules {
+		if err := r.Validate(); err != nil {
+			return errors.BadRequest.Cause(err, "")
+		}
+		if ids[r.ID] {
+			return errors.BadRequest.Error("duplicated rule: %s", r.ID)
+		}
+		ids[r.ID] = true
+		if !r.Enabled {
+			continue
+		}
+		st := &ruleState{rule: r, conditions: make(map[*Condition]*conditionState)}
+		for _, c := range r.When.conditions() {
+			st.conditions[c] = new(conditionState)
+		}
+		states = append(states, st)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the states of the unchanged rules are carried over, so that reloading neither resets their cooldowns
+	// and durations, nor fires the rules being satisfied again
+	previous := make(map[string]*ruleState, len(e.rules))
+	for _, st := range e.rules {
+		previous[st.rule.ID] = st
+	}
+	for i, st := range states {
+		if prev, ok := previous[st.rule.ID]; ok && reflect.DeepEqual(prev.rule, st.rule) {
+			states[i] = prev
+		}
+	}
+	e.rules = states
+	if e.stop != nil {
+		e.resubscribe()
+	}
+	return nil
+}
+
+// LoadFile loads the rules from the YAML file.
+func (e *Engine) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Configuration.Cause(err, "fail to read the rule file: %s", path)
+	}
+	rules := new(Rules)
+	if err = yaml.Unmarshal(data, rules); err != nil {
+		return errors.Configuration.Cause(err, "fail to parse the rule file: %s", path)
+	}
+	return e.Load(rules)
+}
+
+// Start subscribes the sources of the rules, and starts evaluating them.
+func (e *Engine) Start() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop != nil {
+		return nil
+	}
+	e.stop = make(chan struct{})
+	e.resubscribe()
+	e.wg.Add(1)
+	go e.loop(e.stop)
+	return nil
+}
+
+// Stop unsubscribes all sources and stops evaluating the rules.
+func (e *Engine) Stop() error {
+	e.mu.Lock()
+	if e.stop == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	close(e.stop)
+	e.stop = nil
+	for key, stop := range e.subscriptions {
+		stop()
+		delete(e.subscriptions, key)
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	return nil
+}
+
+// WatchFile reloads the rule file whenever it is modified until the Engine is stopped,
+// the current rules are kept if the modified file is invalid.
+func (e *Engine) WatchFile(path string, interval time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Configuration.Cause(err, "fail to watch the rule file: %s", path)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stop := e.stop
+	if stop == nil {
+		return errors.Internal.Error("the rule engine is not started")
+	}
+
+	e.wg.Add(1)
+	go func(modTime time.Time) {
+		defer e.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || !info.ModTime().After(modTime) {
+					continue
+				}
+				modTime = info.ModTime()
+				if err = e.LoadFile(path); err != nil {
+					e.lg.WithError(err).Errorf("fail to reload the rule file, keep the current rules")
+					continue
+				}
+				e.lg.Infof("the rule file %s has been reloaded", path)
+			}
+		}
+	}(info.ModTime())
+	return nil
+}
+
+// Feed evaluates the rules with the properties of the device,
+// it is called automatically for the subscribed properties, and can also be called by the driver directly.
+// The properties fed before the Engine is started or after it is stopped are observed without firing any rule.
+func (e *Engine) Feed(protocolID, productID, deviceID string, props map[models.ProductPropertyID]*models.DeviceData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, st := range e.rules {
+		for c, cst := range st.conditions {
+			s := c.Source
+			if s.Protocol != protocolID || s.Product != productID || s.Device != deviceID {
+				continue
+			}
+			if data, ok := props[s.Property]; ok {
+				c.observe(cst, data)
+			}
+		}
+	}
+	e.evaluate(time.Now())
+}
+
+func (e *Engine) loop(stop <-chan struct{}) {
+	defer e.wg.Done()
+	ticker := time.NewTicker(evaluateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			e.mu.Lock()
+			e.evaluate(now)
+			e.mu.Unlock()
+		}
+	}
+}
+
+// evaluate must be called with the lock held.
+func (e *Engine) evaluate(now time.Time) {
+	if e.stop == nil {
+		return
+	}
+	for _, st := range e.rules {
+		satisfied := st.satisfied(now)
+		if !satisfied {
+			st.active = false
+			continue
+		}
+		if st.active || now.Sub(st.firedAt) < st.rule.cooldown {
+			continue
+		}
+		st.active, st.firedAt = true, now
+		e.wg.Add(1)
+		go e.fire(st.rule)
+	}
+}
+
+func (st *ruleState) satisfied(now time.Time) bool {
+	for _, c := range st.rule.When.All {
+		if !c.satisfied(st.conditions[c], now) {
+			return false
+		}
+	}
+	if len(st.rule.When.Any) == 0 {
+		return true
+	}
+	for _, c := range st.rule.When.Any {
+		if c.satisfied(st.conditions[c], now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) fire(r *Rule) {
+	defer e.wg.Done()
+	e.lg.Infof("the rule %s is triggered", r.ID)
+	for _, a := range r.Then {
+		if err := e.execute(a); err != nil {
+			e.lg.WithError(err).Errorf("fail to execute the %s action of the rule %s", a.Type, r.ID)
+		}
+	}
+}
+
+func (e *Engine) execute(a *Action) error {
+	values, err := a.NewDeviceData()
+	if err != nil {
+		return err
+	}
+	t := a.Target
+	switch a.Type {
+	case ActionTypeWrite:
+		return e.mc.Write(t.Protocol, t.Product, t.Device, t.Func, values)
+	case ActionTypeCall:
+		_, err = e.mc.Call(t.Protocol, t.Product, t.Device, t.Func, values)
+		return err
+	case ActionTypeEvent:
+		return e.dc.PublishDeviceEvent(t.Protocol, t.Product, t.Device, t.Func, values)
+	default:
+		return errors.BadRequest.Error("unsupported action type: %s", a.Type)
+	}
+}
+
+// resubscribe subscribes the properties of devices referenced by the rules, and unsubscribes the others.
+// It must be called with the lock held.
+func (e *Engine) resubscribe() {
+	required := make(map[deviceKey]bool)
+	for _, st := range e.rules {
+		for c := range st.conditions {
+			required[deviceKey{c.Source.Protocol, c.Source.Product, c.Source.Device}] = true
+		}
+	}
+	for key, stop := range e.subscriptions {
+		if !required[key] {
+			stop()
+			delete(e.subscriptions, key)
+		}
+	}
+	for key := range required {
+		if _, ok := e.subscriptions[key]; ok {
+			continue
+		}
+		bus, stop, err := e.ms.SubscribeDeviceProps(key.protocol, key.product, key.device,
+			operations.TopicSingleLevelWildcard)
+		if err != nil {
+			e.lg.WithError(err).Errorf("fail to subscribe the properties of the device[%s]", key.device)
+			continue
+		}
+		e.subscriptions[key] = stop
+		go func(key deviceKey) {
+			for v := range bus {
+				if props, ok := v.(map[models.ProductPropertyID]*models.DeviceData); ok {
+					e.Feed(key.protocol, key.product, key.device, props)
+				}
+			}
+		}(key)
+	}
+}
@@ -0,0 +1,248 @@
+package rules
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// service hands out a channel for the properties of each device subscribed.
+type service struct {
+	operations.DataManagerService
+
+	mu    sync.Mutex
+	buses map[string]chan interface{} // device ID -> the properties
+}
+
+func (s *service) SubscribeDeviceProps(protocolID, productID, deviceID string,
+	propertyID models.ProductPropertyID) (<-chan interface{}, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bus := make(chan interface{}, 10)
+	s.buses[deviceID] = bus
+	return bus, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.buses, deviceID)
+		close(bus)
+	}, nil
+}
+
+func (s *service) subscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	devices := make([]string, 0, len(s.buses))
+	for device := range s.buses {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+func (s *service) publish(deviceID string, property models.ProductPropertyID, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buses[deviceID] <- map[models.ProductPropertyID]*models.DeviceData{
+		property: {Name: property, Type: models.PropertyValueTypeFloat, Value: value, Ts: time.Now()},
+	}
+}
+
+// client records the actions executed by the rules.
+type client struct {
+	operations.DataManagerClient
+	operations.DataDriverClient
+
+	actions chan string
+}
+
+func (c *client) Write(protocolID, productID, deviceID string, propertyID models.ProductPropertyID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	c.actions <- "write " + deviceID + "/" + string(propertyID)
+	return nil
+}
+
+func (c *client) Call(protocolID, productID, deviceID string, methodID models.ProductMethodID,
+	ins map[string]*models.DeviceData) (map[string]*models.DeviceData, error) {
+	c.actions <- "call " + deviceID + "/" + string(methodID)
+	return map[string]*models.DeviceData{}, nil
+}
+
+func (c *client) PublishDeviceEvent(protocolID, productID, deviceID string, eventID models.ProductEventID,
+	props map[models.ProductPropertyID]*models.DeviceData) error {
+	c.actions <- "event " + deviceID + "/" + string(eventID)
+	return nil
+}
+
+func (c *client) expect(t *testing.T, action string) {
+	t.Helper()
+	select {
+	case got := <-c.actions:
+		if got != action {
+			t.Errorf("the action = %s, want %s", got, action)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the action %s is not executed", action)
+	}
+}
+
+func (c *client) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-c.actions:
+		t.Errorf("the action %s is executed, want none", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+const (
+	overheat = `
+  - id: overheat
+    enabled: true
+    cooldown: 1h
+    when:
+      all:
+        - source: {protocol: modbus, product: meter, device: m1, property: temperature}
+          op: ">"
+          value: 80
+    then:
+      - type: call
+        target: {protocol: modbus, product: meter, device: fan, func: start}
+        values: {speed: 3}
+`
+	flood = `
+  - id: flood
+    enabled: true
+    when:
+      any:
+        - source: {protocol: modbus, product: meter, device: m2, property: level}
+          op: ">="
+          value: 1.5
+    then:
+      - type: event
+        target: {protocol: modbus, product: meter, device: m2, func: flooded}
+        values: {level: 1.5}
+`
+)
+
+func writeRules(t *testing.T, path string, rules ...string) {
+	data := "rules:\n"
+	for _, rule := range rules {
+		data += rule
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newEngine(t *testing.T) (*Engine, *service, *client) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := &service{buses: make(map[string]chan interface{})}
+	c := &client{actions: make(chan string, 10)}
+	return NewEngine(ms, c, c, lg), ms, c
+}
+
+func TestEngine(t *testing.T) {
+	e, ms, c := newEngine(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, overheat)
+	if err := e.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if devices := ms.subscribed(); len(devices) != 1 || devices[0] != "m1" {
+		t.Fatalf("the devices subscribed = %v, want m1", devices)
+	}
+
+	ms.publish("m1", "temperature", 85.0)
+	c.expect(t, "call fan/start")
+	ms.publish("m1", "temperature", 86.0)
+	c.expectNone(t)
+
+	// reloading keeps the state of the unchanged rule, so it is not fired again within the cooldown
+	writeRules(t, path, overheat, flood)
+	if err := e.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	ms.publish("m1", "temperature", 87.0)
+	c.expectNone(t)
+	if devices := ms.subscribed(); len(devices) != 2 || devices[1] != "m2" {
+		t.Fatalf("the devices subscribed after reloaded = %v, want m1 and m2", devices)
+	}
+	ms.publish("m2", "level", 2.0)
+	c.expect(t, "event m2/flooded")
+
+	// the devices no longer referenced are unsubscribed
+	writeRules(t, path, flood)
+	if err := e.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if devices := ms.subscribed(); len(devices) != 1 || devices[0] != "m2" {
+		t.Errorf("the devices subscribed after the rule removed = %v, want m2", devices)
+	}
+	// the invalid rules are rejected and the current ones are kept
+	writeRules(t, path, "  - id: flood\n")
+	if err := e.LoadFile(path); err == nil {
+		t.Errorf("the invalid rules should be rejected")
+	}
+
+	if err := e.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if devices := ms.subscribed(); len(devices) != 0 {
+		t.Errorf("the devices subscribed after stopped = %v, want none", devices)
+	}
+	// the properties fed after stopped don't fire any rule
+	e.Feed("modbus", "meter", "m2", map[models.ProductPropertyID]*models.DeviceData{
+		"level": {Name: "level", Type: models.PropertyValueTypeFloat, Value: 0.0, Ts: time.Now()},
+	})
+	e.Feed("modbus", "meter", "m2", map[models.ProductPropertyID]*models.DeviceData{
+		"level": {Name: "level", Type: models.PropertyValueTypeFloat, Value: 3.0, Ts: time.Now()},
+	})
+	c.expectNone(t)
+}
+
+func TestEngine_WatchFile(t *testing.T) {
+	e, ms, _ := newEngine(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, overheat)
+	if err := e.WatchFile(path, 10*time.Millisecond); err == nil {
+		t.Errorf("the file should not be watched before the engine is started")
+	}
+	if err := e.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+	if err := e.WatchFile(path, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	writeRules(t, path, flood)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if devices := ms.subscribed(); len(devices) == 1 && devices[0] == "m2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the modified rule file is not reloaded, the devices subscribed = %v", ms.subscribed())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package rules
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"time"
+)
+
+type (
+	Operator   = string
+	ActionType = string
+)
+
+const (
+	OperatorGT Operator = ">"
+	OperatorGE Operator = ">="
+	OperatorLT Operator = "<"
+	OperatorLE Operator = "<="
+	OperatorEQ Operator = "=="
+	OperatorNE Operator = "!="
+
+	ActionTypeWrite ActionType = "write"
+	ActionTypeCall  ActionType = "call"
+	ActionTypeEvent ActionType = "event"
+)
+
+// Rules is the root of a rule file.
+type Rules struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule triggers the actions once its condition becomes satisfied.
+type Rule struct {
+	ID      string `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	// Cooldown is the minimum duration between two triggers, e.g. "1m".
+	Cooldown string    `json:"cooldown" yaml:"cooldown"`
+	When     *Group    `json:"when" yaml:"when"`
+	Then     []*Action `json:"then" yaml:"then"`
+
+	cooldown time.Duration
+}
+
+// Group combines the conditions, it is satisfied if all of the All and any of the Any are satisfied,
+// so that the conditions across devices can be joined.
+type Group struct {
+	All []*Condition `json:"all" yaml:"all"`
+	Any []*Condition `json:"any" yaml:"any"`
+}
+
+// Source indicates a property of a device.
+type Source struct {
+	Protocol string                   `json:"protocol" yaml:"protocol"`
+	Product  string                   `json:"product" yaml:"product"`
+	Device   string                   `json:"device" yaml:"device"`
+	Property models.ProductPropertyID `json:"property" yaml:"property"`
+}
+
+func (s Source) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s", s.Protocol, s.Product, s.Device, s.Property)
+}
+
+// Condition compares the value of the source with the Value. The value is compared as a number only if
+// the Type of the data is int, uint or float, see models.DeviceData.NumericValue, otherwise only "==" and "!="
+// are supported by comparing the string formats, and the Rate is never satisfied.
+type Condition struct {
+	Source Source      `json:"source" yaml:"source"`
+	Op     Operator    `json:"op" yaml:"op"`
+	Value  interface{} `json:"value" yaml:"value"`
+	// Rate indicates comparing the rate of change per second instead of the value itself.
+	Rate bool `json:"rate" yaml:"rate"`
+	// For is the duration the comparison must keep satisfied, e.g. "30s".
+	For string `json:"for" yaml:"for"`
+
+	duration time.Duration
+}
+
+// Target indicates a functionality of a device.
+type Target struct {
+	Protocol string               `json:"protocol" yaml:"protocol"`
+	Product  string               `json:"product" yaml:"product"`
+	Device   string               `json:"device" yaml:"device"`
+	Func     models.ProductFuncID `json:"func" yaml:"func"`
+}
+
+// Action writes the properties, calls the method or publishes the event of the target.
+type Action struct {
+	Type   ActionType `json:"type" yaml:"type"`
+	Target Target     `json:"target" yaml:"target"`
+	// Values are the properties to write, the ins of the method or the outs of the event,
+	// their types are inferred from the values, e.g. 3 is an int and 3.0 is a float.
+	Values map[string]interface{} `json:"values" yaml:"values"`
+}
+
+// Validate checks the rule and parses its durations.
+func (r *Rule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("the id of the rule is required")
+	}
+	if r.Cooldown != "" {
+		d, err := time.ParseDuration(r.Cooldown)
+		if err != nil {
+			return fmt.Errorf("invalid cooldown of the rule %s: %s", r.ID, err.Error())
+		}
+		r.cooldown = d
+	}
+	if r.When == nil || len(r.When.All)+len(r.When.Any) == 0 {
+		return fmt.Errorf("the conditions of the rule %s are required", r.ID)
+	}
+	for _, c := range r.When.conditions() {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("invalid condition of the rule %s: %s", r.ID, err.Error())
+		}
+	}
+	if len(r.Then) == 0 {
+		return fmt.Errorf("the actions of the rule %s are required", r.ID)
+	}
+	for _, a := range r.Then {
+		switch a.Type {
+		case ActionTypeWrite, ActionTypeCall, ActionTypeEvent:
+		default:
+			return fmt.Errorf("unsupported action type of the rule %s: %s", r.ID, a.Type)
+		}
+		if a.Target.Protocol == "" || a.Target.Product == "" || a.Target.Device == "" || a.Target.Func == "" {
+			return fmt.Errorf("the protocol, product, device and func of the action of the rule %s are required", r.ID)
+		}
+	}
+	return nil
+}
+
+func (g *Group) conditions() []*Condition {
+	return append(append([]*Condition{}, g.All...), g.Any...)
+}
+
+func (c *Condition) validate() error {
+	if c.Source.Protocol == "" || c.Source.Product == "" || c.Source.Device == "" || c.Source.Property == "" {
+		return fmt.Errorf("the protocol, product, device and property of the source are required")
+	}
+	switch c.Op {
+	case OperatorGT, OperatorGE, OperatorLT, OperatorLE:
+		if _, ok := numericValue(c.Value); !ok {
+			return fmt.Errorf("the value of %s %s should be a number", c.Source, c.Op)
+		}
+	case OperatorEQ, OperatorNE:
+		if c.Rate {
+			if _, ok := numericValue(c.Value); !ok {
+				return fmt.Errorf("the value of the rate of %s should be a number", c.Source)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported operator: %s", c.Op)
+	}
+	if c.For != "" {
+		d, err := time.ParseDuration(c.For)
+		if err != nil {
+			return fmt.Errorf("invalid duration of %s: %s", c.Source, err.Error())
+		}
+		c.duration = d
+	}
+	return nil
+}
+
+// NewDeviceData converts the values of an action into DeviceData.
+func (a *Action) NewDeviceData() (map[models.ProductPropertyID]*models.DeviceData, error) {
+	values := make(map[models.ProductPropertyID]*models.DeviceData, len(a.Values))
+	for name, v := range a.Values {
+		var valueType models.PropertyValueType
+		switch x := v.(type) {
+		case int:
+			valueType, v = models.PropertyValueTypeInt, int64(x)
+		case int64:
+			valueType = models.PropertyValueTypeInt
+		case float64:
+			valueType = models.PropertyValueTypeFloat
+		case bool:
+			valueType = models.PropertyValueTypeBool
+		case string:
+			valueType = models.PropertyValueTypeString
+		default:
+			return nil, fmt.Errorf("unsupported type of the value %s: %T", name, v)
+		}
+		data, err := models.NewDeviceData(name, valueType, v)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = data
+	}
+	return values, nil
+}
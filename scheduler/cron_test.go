@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	// 2022-01-07 is a Friday
+	from := time.Date(2022, 1, 7, 8, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"Every minute", "* * * * *", time.Date(2022, 1, 7, 8, 31, 0, 0, time.UTC)},
+		{"Every 10 minutes", "*/10 * * * *", time.Date(2022, 1, 7, 8, 40, 0, 0, time.UTC)},
+		{"Every weekday at 07:00", "0 7 * * MON-FRI", time.Date(2022, 1, 10, 7, 0, 0, 0, time.UTC)},
+		{"Every Sunday written in 7", "0 0 * * 7", time.Date(2022, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{"The first day of every quarter", "0 0 1 */3 *", time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"Either the 15th or Mondays", "30 9 15 * 1", time.Date(2022, 1, 10, 9, 30, 0, 0, time.UTC)},
+		{"Lists and ranges", "5,35 8-9 * * *", time.Date(2022, 1, 7, 8, 35, 0, 0, time.UTC)},
+		{"Never", "0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron() error = %v", err)
+			}
+			if got := c.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * FOO *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) error = nil, want an error", expr)
+		}
+	}
+}
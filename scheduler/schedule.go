@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"time"
+)
+
+type Action = string
+
+const (
+	ActionRead     Action = "read"
+	ActionHardRead Action = "hard-read"
+	ActionWrite    Action = "write"
+	ActionCall     Action = "call"
+)
+
+// Schedule executes an operation against the device by a cron expression or at intervals.
+type Schedule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Cron is the cron expression, see ParseCron for the syntax, e.g. "0 7 * * MON-FRI".
+	Cron string `json:"cron,omitempty"`
+	// Interval is the duration between two executions, e.g. "10m", it is ignored if the Cron is set.
+	Interval string `json:"interval,omitempty"`
+
+	ProtocolID string               `json:"protocol_id"`
+	ProductID  string               `json:"product_id"`
+	DeviceID   string               `json:"device_id"`
+	FuncID     models.ProductFuncID `json:"func_id"`
+	Action     Action               `json:"action"`
+	// Values are the properties to write, or the ins of the method to call.
+	Values map[models.ProductPropertyID]*models.DeviceData `json:"values,omitempty"`
+
+	LastRun *Run      `json:"last_run,omitempty"`
+	NextRun time.Time `json:"next_run"`
+}
+
+// Run records an execution of a schedule.
+type Run struct {
+	StartedAt time.Time `json:"started_at"`
+	// DurationMs is how long the execution took in milliseconds.
+	DurationMs int64                                           `json:"duration_ms"`
+	Outs       map[models.ProductPropertyID]*models.DeviceData `json:"outs,omitempty"`
+	Error      string                                          `json:"error,omitempty"`
+}
+
+// Validate checks the schedule, and returns a function to calculate the next activation.
+func (s *Schedule) Validate() (next func(t time.Time) time.Time, err error) {
+	if s.ID == "" {
+		return nil, fmt.Errorf("the id of the schedule is required")
+	}
+	if s.ProtocolID == "" || s.ProductID == "" || s.DeviceID == "" || s.FuncID == "" {
+		return nil, fmt.Errorf("the protocol, product, device and func of the schedule %s are required", s.ID)
+	}
+	switch s.Action {
+	case ActionRead, ActionHardRead, ActionWrite, ActionCall:
+	default:
+		return nil, fmt.Errorf("unsupported action of the schedule %s: %s", s.ID, s.Action)
+	}
+
+	if s.Cron != "" {
+		c, err := ParseCron(s.Cron)
+		if err != nil {
+			return nil, err
+		}
+		return c.Next, nil
+	}
+	if s.Interval != "" {
+		interval, err := time.ParseDuration(s.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval of the schedule %s: %s", s.ID, err.Error())
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("the interval of the schedule %s should not be less than 1s", s.ID)
+		}
+		return func(t time.Time) time.Time {
+			return t.Add(interval)
+		}, nil
+	}
+	return nil, fmt.Errorf("either the cron or the interval of the schedule %s is required", s.ID)
+}
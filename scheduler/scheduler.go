@@ -0,0 +1,281 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxIdle is the maximum duration to sleep if there is no schedule to execute.
+const maxIdle = time.Minute
+
+// NewScheduler returns a Scheduler executing the schedules loaded from the store through the DataManagerClient.
+func NewScheduler(mc operations.DataManagerClient, store Store, lg *logger.Logger) (*Scheduler, error) {
+	s := &Scheduler{
+		mc:      mc,
+		store:   store,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+		lg:      lg,
+	}
+	schedules, err := store.Load()
+	if err != nil {
+		return nil, errors.Internal.Cause(err, "fail to load the schedules")
+	}
+	now := time.Now()
+	for _, schedule := range schedules {
+		next, err := schedule.Validate()
+		if err != nil {
+			// the invalid schedule is kept without being executed, so that it won't be lost by the next save
+			lg.WithError(err).Errorf("the invalid schedule %s won't be executed until it is fixed", schedule.ID)
+			schedule.NextRun = time.Time{}
+			s.entries[schedule.ID] = &entry{schedule: schedule}
+			continue
+		}
+		// the runs missed while stopping are skipped
+		schedule.NextRun = next(now)
+		s.entries[schedule.ID] = &entry{schedule: schedule, next: next}
+	}
+	return s, nil
+}
+
+// Scheduler executes the operations of schedules at their activations, and records their last runs.
+type Scheduler struct {
+	mc    operations.DataManagerClient
+	store Store
+
+	mu      sync.Mutex
+	entries map[string]*entry // schedule ID -> entry
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lg *logger.Logger
+}
+
+type entry struct {
+	schedule *Schedule
+	next     func(t time.Time) time.Time
+	running  bool
+}
+
+// Start starts executing the schedules in background.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return nil
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go s.loop(s.stop)
+	return nil
+}
+
+// Stop stops executing the schedules, and waits for the running ones to finish.
+func (s *Scheduler) Stop() error {
+	s.mu.Lock()
+	if s.stop == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	close(s.stop)
+	s.stop = nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Put adds the schedule, or replaces the existing one with the same ID.
+func (s *Scheduler) Put(schedule *Schedule) error {
+	next, err := schedule.Validate()
+	if err != nil {
+		return errors.BadRequest.Cause(err, "")
+	}
+	schedule, err = clone(schedule)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if old, ok := s.entries[schedule.ID]; ok && schedule.LastRun == nil {
+		schedule.LastRun = old.schedule.LastRun
+	}
+	schedule.NextRun = next(time.Now())
+	s.entries[schedule.ID] = &entry{schedule: schedule, next: next}
+	err = s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+// Remove deletes the schedule, the running execution of it won't be interrupted.
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return errors.NotFound.Error("the schedule %s is not found", id)
+	}
+	delete(s.entries, id)
+	return s.save()
+}
+
+// Get returns a copy of the schedule.
+func (s *Scheduler) Get(id string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, errors.NotFound.Error("the schedule %s is not found", id)
+	}
+	return clone(e.schedule)
+}
+
+// List returns copies of all schedules ordered by their IDs.
+func (s *Scheduler) List() ([]*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := make([]*Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedule, err := clone(e.schedule)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules, nil
+}
+
+func (s *Scheduler) loop(stop <-chan struct{}) {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(s.dispatch(time.Now()))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch executes the due schedules, and returns the duration until the next activation.
+func (s *Scheduler) dispatch(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idle := maxIdle
+	for _, e := range s.entries {
+		if !e.schedule.Enabled || e.schedule.NextRun.IsZero() {
+			continue
+		}
+		if !e.schedule.NextRun.After(now) {
+			e.schedule.NextRun = e.next(now)
+			if e.running {
+				s.lg.Warnf("the last run of the schedule %s is still running, skip this one", e.schedule.ID)
+			} else {
+				e.running = true
+				s.wg.Add(1)
+				go s.run(e, *e.schedule)
+			}
+		}
+		if !e.schedule.NextRun.IsZero() {
+			if d := e.schedule.NextRun.Sub(now); d < idle {
+				idle = d
+			}
+		}
+	}
+	return idle
+}
+
+// run executes the operation of the schedule, the snapshot is a copy of the schedule when it is activated.
+func (s *Scheduler) run(e *entry, snapshot Schedule) {
+	defer s.wg.Done()
+
+	run := &Run{StartedAt: time.Now()}
+	outs, err := s.execute(&snapshot)
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	if err != nil {
+		run.Error = err.Error()
+		s.lg.WithError(err).Errorf("fail to execute the schedule %s", snapshot.ID)
+	} else {
+		run.Outs = outs
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.running = false
+	if current, ok := s.entries[snapshot.ID]; !ok || current != e {
+		return // the schedule has been removed or replaced
+	}
+	e.schedule.LastRun = run
+	if err = s.save(); err != nil {
+		s.lg.WithError(err).Errorf("fail to save the last run of the schedule %s", snapshot.ID)
+	}
+}
+
+func (s *Scheduler) execute(schedule *Schedule) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	switch schedule.Action {
+	case ActionRead:
+		return s.mc.Read(schedule.ProtocolID, schedule.ProductID, schedule.DeviceID, schedule.FuncID)
+	case ActionHardRead:
+		return s.mc.HardRead(schedule.ProtocolID, schedule.ProductID, schedule.DeviceID, schedule.FuncID)
+	case ActionWrite:
+		return nil, s.mc.Write(schedule.ProtocolID, schedule.ProductID, schedule.DeviceID, schedule.FuncID, schedule.Values)
+	case ActionCall:
+		return s.mc.Call(schedule.ProtocolID, schedule.ProductID, schedule.DeviceID, schedule.FuncID, schedule.Values)
+	default:
+		return nil, errors.BadRequest.Error("unsupported action of the schedule %s: %s", schedule.ID, schedule.Action)
+	}
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// save must be called with the lock held.
+func (s *Scheduler) save() error {
+	schedules := make([]*Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, e.schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	if err := s.store.Save(schedules); err != nil {
+		return errors.Internal.Cause(err, "fail to save the schedules")
+	}
+	return nil
+}
+
+func clone(schedule *Schedule) (*Schedule, error) {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return nil, errors.Internal.Cause(err, "fail to copy the schedule")
+	}
+	c := new(Schedule)
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, errors.Internal.Cause(err, "fail to copy the schedule")
+	}
+	return c, nil
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// client reads the temperature of the devices, and fails to read others.
+type client struct {
+	operations.DataManagerClient
+
+	mu    sync.Mutex
+	reads []string
+}
+
+func (c *client) Read(protocolID, productID, deviceID string,
+	propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads = append(c.reads, deviceID+"/"+propertyID)
+	if propertyID != "temperature" {
+		return nil, fmt.Errorf("the property %s is not found", propertyID)
+	}
+	return map[models.ProductPropertyID]*models.DeviceData{
+		propertyID: {Name: propertyID, Type: models.PropertyValueTypeFloat, Value: 21.5},
+	}, nil
+}
+
+func newScheduler(t *testing.T, path string) (*Scheduler, *client) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new(client)
+	s, err := NewScheduler(c, store, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func newSchedule(id string, property models.ProductPropertyID) *Schedule {
+	return &Schedule{ID: id, Enabled: true, Interval: "1m", ProtocolID: "modbus", ProductID: "meter",
+		DeviceID: "m1", FuncID: property, Action: ActionRead}
+}
+
+func TestScheduler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, c := newScheduler(t, path)
+	if err := s.Put(newSchedule("s1", "temperature")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(newSchedule("s2", "humidity")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(&Schedule{ID: "s3", Action: ActionRead}); err == nil {
+		t.Errorf("Put() the invalid schedule should be rejected")
+	}
+
+	// the due schedules are executed and their last runs are recorded
+	s.dispatch(time.Now().Add(2 * time.Minute))
+	s.wg.Wait()
+	if len(c.reads) != 2 {
+		t.Errorf("the reads = %v, want m1/temperature and m1/humidity", c.reads)
+	}
+	s1, err := s.Get("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.LastRun == nil || s1.LastRun.Error != "" || s1.LastRun.Outs["temperature"].Value != 21.5 {
+		t.Errorf("the last run of s1 = %+v, want the temperature read", s1.LastRun)
+	}
+	s2, err := s.Get("s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.LastRun == nil || s2.LastRun.Error == "" {
+		t.Errorf("the last run of s2 = %+v, want the error of the read", s2.LastRun)
+	}
+
+	// replacing keeps the last run, and removing deletes the schedule
+	replaced := newSchedule("s1", "temperature")
+	replaced.Interval = "5m"
+	if err = s.Put(replaced); err != nil {
+		t.Fatal(err)
+	}
+	if s1, err = s.Get("s1"); err != nil || s1.Interval != "5m" || s1.LastRun == nil {
+		t.Errorf("Get() the replaced schedule = %+v, %v, want the new interval with the last run", s1, err)
+	}
+	if err = s.Remove("s2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Remove("s2"); err == nil {
+		t.Errorf("Remove() the removed schedule should fail")
+	}
+
+	// the schedules and their last runs survive restarting
+	restarted, _ := newScheduler(t, path)
+	schedules, err := restarted.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].ID != "s1" || schedules[0].Interval != "5m" ||
+		schedules[0].LastRun == nil || schedules[0].LastRun.Outs["temperature"].Value != 21.5 {
+		t.Errorf("List() after restarted = %+v, want s1 with its last run", schedules)
+	}
+	if schedules[0].NextRun.IsZero() {
+		t.Errorf("the next run of s1 after restarted should be calculated")
+	}
+}
+
+func TestScheduler_KeepInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := newSchedule("s1", "temperature")
+	invalid.Interval = "1ms"
+	if err = store.Save([]*Schedule{invalid}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, c := newScheduler(t, path)
+	s.dispatch(time.Now().Add(time.Hour))
+	s.wg.Wait()
+	if len(c.reads) != 0 {
+		t.Errorf("the invalid schedule is executed: %v", c.reads)
+	}
+	// saving the other schedules doesn't lose the invalid one
+	if err = s.Put(newSchedule("s2", "temperature")); err != nil {
+		t.Fatal(err)
+	}
+	schedules, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 2 || schedules[0].ID != "s1" || schedules[0].Interval != "1ms" {
+		t.Errorf("the schedules saved = %+v, want the invalid s1 kept", schedules)
+	}
+}
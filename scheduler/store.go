@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Store persists the schedules together with their last runs, so that they survive restarting.
+type Store interface {
+	Load() ([]*Schedule, error)
+	Save(schedules []*Schedule) error
+}
+
+// NewFileStore returns a Store which saves all schedules into a JSON file.
+func NewFileStore(path string) (Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{path: path}, nil
+}
+
+type fileStore struct {
+	path string
+}
+
+func (f *fileStore) Load() ([]*Schedule, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return []*Schedule{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	schedules := make([]*Schedule, 0)
+	if err = json.Unmarshal(data, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (f *fileStore) Save(schedules []*Schedule) error {
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(f.path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.path+".tmp", f.path)
+}
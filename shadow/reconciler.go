@@ -0,0 +1,252 @@
+package shadow
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sync"
+)
+
+// NewReconciler returns a Reconciler maintaining the shadows of devices belonging to the protocol.
+func NewReconciler(protocolID string, dc operations.DataDriverClient, store Store, lg *logger.Logger) (*Reconciler, error) {
+	r := &Reconciler{
+		protocolID: protocolID,
+		dc:         dc,
+		store:      store,
+		shadows:    make(map[string]*models.DeviceShadow),
+		twins:      make(map[string]models.DeviceTwin),
+		states:     make(map[string]models.State),
+		reconciles: make(map[string]bool),
+		lg:         lg,
+	}
+	shadows, err := store.Load()
+	if err != nil {
+		return nil, errors.Driver.Cause(err, "fail to load the device shadows")
+	}
+	for _, shadow := range shadows {
+		r.shadows[shadow.DeviceID] = shadow
+	}
+	return r, nil
+}
+
+// Reconciler applies the outstanding desired values to the devices once they are connected,
+// and publishes the deltas between the desired and reported states.
+type Reconciler struct {
+	protocolID string
+	dc         operations.DataDriverClient
+	store      Store
+
+	mu      sync.Mutex
+	shadows map[string]*models.DeviceShadow // device ID -> shadow
+	twins   map[string]models.DeviceTwin    // device ID -> twin
+	states  map[string]models.State         // device ID -> state
+	// reconciles are the devices being reconciled, the value is whether to reconcile again after finishing,
+	// so that the desired values are not written concurrently by the patches and the connections.
+	reconciles map[string]bool
+
+	lg *logger.Logger
+}
+
+// Serve registers the handlers of shadow operations requested by the manager.
+func (r *Reconciler) Serve(ds operations.DataDriverService) error {
+	if err := ds.ShadowGetHandler(r.protocolID, r.Get); err != nil {
+		return err
+	}
+	return ds.ShadowPatchHandler(r.protocolID, r.Patch)
+}
+
+// Attach binds the twin of the device, the desired values will be applied through it.
+func (r *Reconciler) Attach(device *models.Device, twin models.DeviceTwin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.twins[device.ID] = twin
+	if _, ok := r.shadows[device.ID]; !ok {
+		r.shadows[device.ID] = models.NewDeviceShadow(device.ProductID, device.ID)
+	}
+}
+
+// Detach unbinds the twin of the device, the shadow is kept until Remove is called.
+func (r *Reconciler) Detach(deviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.twins, deviceID)
+	delete(r.states, deviceID)
+}
+
+// Remove deletes the shadow of the device, it should be called after the device is deleted.
+func (r *Reconciler) Remove(deviceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.twins, deviceID)
+	delete(r.states, deviceID)
+	delete(r.shadows, deviceID)
+	return r.store.Delete(deviceID)
+}
+
+// Get returns a copy of the shadow of the device.
+func (r *Reconciler) Get(productID, deviceID string) (*models.DeviceShadow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shadow, err := r.shadow(productID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return clone(shadow)
+}
+
+// Patch changes the desired state of the device, and applies it in the background if the device is connected,
+// so that the handler of the request isn't blocked by writing the device. An empty patch changes nothing.
+func (r *Reconciler) Patch(productID, deviceID string, patch *operations.ShadowPatch) (*models.DeviceShadow, error) {
+	r.mu.Lock()
+	shadow, err := r.shadow(productID, deviceID)
+	if err != nil {
+		r.mu.Unlock()
+		return nil, err
+	}
+	if patch.Version != 0 && patch.Version != shadow.Version {
+		r.mu.Unlock()
+		return nil, errors.Conflict.Error("the version of the shadow of the device[%s] is %d, but the patch is based on %d",
+			deviceID, shadow.Version, patch.Version)
+	}
+	if len(patch.Desired) == 0 {
+		defer r.mu.Unlock()
+		return clone(shadow)
+	}
+	shadow.PatchDesired(patch.Desired)
+	if err = r.store.Save(shadow); err != nil {
+		r.mu.Unlock()
+		return nil, errors.Driver.Cause(err, "fail to save the shadow of the device[%s]", deviceID)
+	}
+	connected := r.states[deviceID] == models.DeviceStateConnected
+	patched, err := clone(shadow)
+	r.mu.Unlock()
+
+	if connected {
+		go r.reconcile(deviceID)
+	} else {
+		r.publishDelta(deviceID)
+	}
+	return patched, err
+}
+
+// Report records the values reported by the device, e.g. the properties published by watching.
+func (r *Reconciler) Report(deviceID string, props map[models.ProductPropertyID]*models.DeviceData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shadow, ok := r.shadows[deviceID]
+	if !ok {
+		return
+	}
+	shadow.Report(props)
+	if err := r.store.Save(shadow); err != nil {
+		r.lg.WithError(err).Errorf("fail to save the shadow of the device[%s]", deviceID)
+	}
+}
+
+// UpdateState records the state of the device, the outstanding desired values will be applied
+// once the device becomes connected.
+func (r *Reconciler) UpdateState(deviceID string, state models.State) {
+	r.mu.Lock()
+	previous := r.states[deviceID]
+	r.states[deviceID] = state
+	r.mu.Unlock()
+
+	if state == models.DeviceStateConnected && previous != models.DeviceStateConnected {
+		r.reconcile(deviceID)
+	}
+}
+
+// reconcile writes the delta values to the device one by one,
+// the values failed to write are kept in the delta and will be retried on the next reconciliation.
+// If the device is being reconciled, it will be reconciled again by the running one instead.
+func (r *Reconciler) reconcile(deviceID string) {
+	r.mu.Lock()
+	if _, running := r.reconciles[deviceID]; running {
+		r.reconciles[deviceID] = true
+		r.mu.Unlock()
+		return
+	}
+	r.reconciles[deviceID] = false
+	r.mu.Unlock()
+
+	for {
+		r.apply(deviceID)
+		r.mu.Lock()
+		again := r.reconciles[deviceID]
+		if !again {
+			delete(r.reconciles, deviceID)
+			r.mu.Unlock()
+			break
+		}
+		r.reconciles[deviceID] = false
+		r.mu.Unlock()
+	}
+	r.publishDelta(deviceID)
+}
+
+// apply writes the delta values to the device.
+func (r *Reconciler) apply(deviceID string) {
+	r.mu.Lock()
+	shadow, ok := r.shadows[deviceID]
+	twin, bound := r.twins[deviceID]
+	if !ok || !bound {
+		r.mu.Unlock()
+		return
+	}
+	delta := shadow.Delta()
+	r.mu.Unlock()
+
+	written := make(map[models.ProductPropertyID]*models.DeviceData, len(delta))
+	for propertyID, value := range delta {
+		values := map[models.ProductPropertyID]*models.DeviceData{propertyID: value}
+		if err := twin.Write(propertyID, values); err != nil {
+			r.lg.WithError(err).Warnf("fail to apply the desired property[%s] of the device[%s]", propertyID, deviceID)
+			continue
+		}
+		written[propertyID] = value
+	}
+	// the values written are reported at once, so that the shadow is saved only once
+	if len(written) != 0 {
+		r.Report(deviceID, written)
+	}
+}
+
+func (r *Reconciler) publishDelta(deviceID string) {
+	r.mu.Lock()
+	shadow, ok := r.shadows[deviceID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	productID, delta := shadow.ProductID, shadow.Delta()
+	r.mu.Unlock()
+
+	if err := r.dc.PublishShadowDelta(r.protocolID, productID, deviceID, delta); err != nil {
+		r.lg.WithError(err).Errorf("fail to publish the shadow delta of the device[%s]", deviceID)
+	}
+}
+
+func (r *Reconciler) shadow(productID, deviceID string) (*models.DeviceShadow, error) {
+	shadow, ok := r.shadows[deviceID]
+	if !ok {
+		return nil, errors.NotFound.Error("the shadow of the device[%s] is not found", deviceID)
+	}
+	if shadow.ProductID != productID {
+		return nil, errors.BadRequest.Error("the device[%s] doesn't belong to the product[%s]", deviceID, productID)
+	}
+	return shadow, nil
+}
+
+func clone(shadow *models.DeviceShadow) (*models.DeviceShadow, error) {
+	data, err := json.Marshal(shadow)
+	if err != nil {
+		return nil, errors.Internal.Cause(err, "fail to copy the shadow")
+	}
+	c := new(models.DeviceShadow)
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, errors.Internal.Cause(err, "fail to copy the shadow")
+	}
+	return c, nil
+}
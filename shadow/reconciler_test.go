@@ -0,0 +1,253 @@
+package shadow
+
+import (
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/operations"
+	"sync"
+	"testing"
+	"time"
+)
+
+// twin records the properties written, the writes are blocked until the release is closed if it is not nil.
+type twin struct {
+	models.DeviceTwin
+
+	release chan struct{}
+
+	mu      sync.Mutex
+	written []models.ProductPropertyID
+}
+
+func (w *twin) Write(propertyID models.ProductPropertyID, values map[models.ProductPropertyID]*models.DeviceData) error {
+	if w.release != nil {
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = append(w.written, propertyID)
+	return nil
+}
+
+func (w *twin) writes() []models.ProductPropertyID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]models.ProductPropertyID(nil), w.written...)
+}
+
+// client records the shadow deltas published.
+type client struct {
+	operations.DataDriverClient
+
+	mu     sync.Mutex
+	deltas []map[models.ProductPropertyID]*models.DeviceData
+}
+
+func (c *client) PublishShadowDelta(protocolID, productID, deviceID string,
+	delta map[models.ProductPropertyID]*models.DeviceData) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deltas = append(c.deltas, delta)
+	return nil
+}
+
+func (c *client) published() []map[models.ProductPropertyID]*models.DeviceData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]map[models.ProductPropertyID]*models.DeviceData(nil), c.deltas...)
+}
+
+// store counts the shadows saved.
+type store struct {
+	Store
+
+	mu    sync.Mutex
+	saves int
+}
+
+func (s *store) Save(shadow *models.DeviceShadow) error {
+	s.mu.Lock()
+	s.saves++
+	s.mu.Unlock()
+	return s.Store.Save(shadow)
+}
+
+func (s *store) saved() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func newReconciler(t *testing.T, dir string, w *twin) (*Reconciler, *client) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := new(client)
+	r, err := NewReconciler("protocol", dc, &store{Store: fs}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Attach(&models.Device{ID: "device", ProductID: "product"}, w)
+	return r, dc
+}
+
+func desired(id models.ProductPropertyID, value interface{}) map[models.ProductPropertyID]*models.DeviceData {
+	return map[models.ProductPropertyID]*models.DeviceData{id: {Name: id, Type: models.PropertyValueTypeInt, Value: value}}
+}
+
+func TestReconciler(t *testing.T) {
+	dir := t.TempDir()
+	w := new(twin)
+	r, dc := newReconciler(t, dir, w)
+
+	shadow, err := r.Patch("product", "device", &operations.ShadowPatch{Desired: desired("speed", 3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shadow.Version != 1 || len(w.writes()) != 0 {
+		t.Fatalf("the desired value of the disconnected device is written, version = %d", shadow.Version)
+	}
+	if deltas := dc.published(); len(deltas) != 1 || deltas[0]["speed"] == nil {
+		t.Fatalf("the delta of the disconnected device = %v, want the speed", deltas)
+	}
+	if _, err = r.Patch("product", "device", &operations.ShadowPatch{Desired: desired("speed", 4), Version: 9}); err == nil {
+		t.Errorf("the patch based on a stale version should be rejected")
+	}
+	if _, err = r.Patch("other", "device", &operations.ShadowPatch{Desired: desired("speed", 4)}); err == nil {
+		t.Errorf("the patch of a different product should be rejected")
+	}
+
+	r.UpdateState("device", models.DeviceStateConnected)
+	if writes := w.writes(); len(writes) != 1 || writes[0] != "speed" {
+		t.Fatalf("the writes after connected = %v, want the speed", writes)
+	}
+	deltas := dc.published()
+	if len(deltas[len(deltas)-1]) != 0 {
+		t.Errorf("the delta after reconciled = %v, want none", deltas[len(deltas)-1])
+	}
+
+	// the reported value in another numeric type is not written again
+	r.Report("device", map[models.ProductPropertyID]*models.DeviceData{
+		"speed": {Name: "speed", Type: models.PropertyValueTypeInt, Value: float64(3)},
+	})
+	r.UpdateState("device", models.DeviceStateDisconnected)
+	r.UpdateState("device", models.DeviceStateConnected)
+	if writes := w.writes(); len(writes) != 1 {
+		t.Errorf("the writes after reconnected = %v, want nothing written again", writes)
+	}
+
+	// the shadow survives restarting the driver
+	restarted, _ := newReconciler(t, dir, new(twin))
+	shadow, err = restarted.Get("product", "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shadow.Version != 1 || shadow.Desired["speed"] == nil || shadow.Reported["speed"] == nil {
+		t.Errorf("the reloaded shadow = %+v", shadow)
+	}
+}
+
+// waitWrites waits until the count of properties are written, and the device isn't being reconciled.
+func waitWrites(t *testing.T, r *Reconciler, w *twin, count int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		r.mu.Lock()
+		_, running := r.reconciles["device"]
+		r.mu.Unlock()
+		if !running && len(w.writes()) >= count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the writes = %v, want %d properties written", w.writes(), count)
+		}
+	}
+}
+
+func TestReconciler_Concurrent(t *testing.T) {
+	w := &twin{release: make(chan struct{})}
+	r, _ := newReconciler(t, t.TempDir(), w)
+	if _, err := r.Patch("product", "device", &operations.ShadowPatch{Desired: desired("speed", 3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	connected := make(chan struct{})
+	go func() {
+		r.UpdateState("device", models.DeviceStateConnected)
+		close(connected)
+	}()
+	// wait until the speed is being written
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		r.mu.Lock()
+		_, running := r.reconciles["device"]
+		r.mu.Unlock()
+		if running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the device isn't reconciled after connected")
+		}
+	}
+	// the patch during the reconciliation is applied by the running one
+	if _, err := r.Patch("product", "device", &operations.ShadowPatch{Desired: desired("mode", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	close(w.release)
+	<-connected
+	waitWrites(t, r, w, 2)
+
+	writes := w.writes()
+	if len(writes) != 2 || writes[0] != "speed" || writes[1] != "mode" {
+		t.Errorf("the writes = %v, want the speed and the mode once", writes)
+	}
+}
+
+func TestReconciler_PatchConnected(t *testing.T) {
+	w := &twin{release: make(chan struct{})}
+	r, dc := newReconciler(t, t.TempDir(), w)
+	r.UpdateState("device", models.DeviceStateConnected)
+
+	shadow, err := r.Patch("product", "device", &operations.ShadowPatch{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shadow.Version != 0 || len(dc.published()) != 1 {
+		t.Errorf("the empty patch changes the shadow, version = %d", shadow.Version)
+	}
+
+	// the patch returns without waiting for the writes of the connected device
+	patched := make(chan *models.DeviceShadow)
+	go func() {
+		shadow, err := r.Patch("product", "device", &operations.ShadowPatch{Desired: map[models.ProductPropertyID]*models.DeviceData{
+			"speed": {Name: "speed", Type: models.PropertyValueTypeInt, Value: 3},
+			"mode":  {Name: "mode", Type: models.PropertyValueTypeInt, Value: 1},
+		}})
+		if err != nil {
+			t.Error(err)
+		}
+		patched <- shadow
+	}()
+	select {
+	case shadow = <-patched:
+	case <-time.After(time.Second):
+		t.Fatalf("the patch is blocked by writing the device")
+	}
+	if shadow.Version != 1 || len(shadow.Delta()) != 2 {
+		t.Errorf("the patched shadow = %+v, want the speed and the mode outstanding", shadow)
+	}
+
+	close(w.release)
+	waitWrites(t, r, w, 2)
+	// the values written are reported by one save after the patch
+	if saves := r.store.(*store).saved(); saves != 2 {
+		t.Errorf("the shadow is saved %d times, want 2", saves)
+	}
+	if deltas := dc.published(); len(deltas[len(deltas)-1]) != 0 {
+		t.Errorf("the delta after reconciled = %v, want none", deltas[len(deltas)-1])
+	}
+}
@@ -0,0 +1,77 @@
+package shadow
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const fileSuffix = ".json"
+
+// Store persists the device shadows, so that the desired states survive restarting the driver.
+type Store interface {
+	Load() ([]*models.DeviceShadow, error)
+	Save(shadow *models.DeviceShadow) error
+	Delete(deviceID string) error
+}
+
+// NewFileStore returns a Store which saves every shadow as a JSON file in the directory.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+func (f *fileStore) Load() ([]*models.DeviceShadow, error) {
+	infos, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	shadows := make([]*models.DeviceShadow, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), fileSuffix) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(f.dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		shadow := new(models.DeviceShadow)
+		if err = json.Unmarshal(data, shadow); err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, shadow)
+	}
+	return shadows, nil
+}
+
+func (f *fileStore) Save(shadow *models.DeviceShadow) error {
+	data, err := json.Marshal(shadow)
+	if err != nil {
+		return err
+	}
+	path := f.path(shadow.DeviceID)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (f *fileStore) Delete(deviceID string) error {
+	if err := os.Remove(f.path(deviceID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *fileStore) path(deviceID string) string {
+	return filepath.Join(f.dir, deviceID+fileSuffix)
+}
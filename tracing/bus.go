@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"github.com/thingio/edge-device-std/operations"
+)
+
+// NewMessageBus decorates the MessageBus to propagate the trace context across the operations,
+// it records spans around publishing, calling and handling messages.
+// The decorated MessageBus can be passed to the constructors of clients and services in operations directly.
+func NewMessageBus(mb bus.MessageBus, tracer *Tracer) bus.MessageBus {
+	return &tracedMessageBus{MessageBus: mb, tracer: tracer}
+}
+
+// TracerOf returns the Tracer of the MessageBus decorated by NewMessageBus, e.g. created by msgbus.NewMessageBus
+// with the tracing enabled, so that the twins can be decorated by the same Tracer, see NewDeviceTwin.
+// A disabled Tracer is returned if the MessageBus isn't decorated.
+func TracerOf(mb bus.MessageBus, lg *logger.Logger) *Tracer {
+	if traced, ok := mb.(*tracedMessageBus); ok {
+		return traced.tracer
+	}
+	return NewTracerWithExporter(nopExporter{}, false, lg)
+}
+
+type tracedMessageBus struct {
+	bus.MessageBus
+
+	tracer *Tracer
+}
+
+func (t *tracedMessageBus) Publish(msg *message.Message) error {
+	attrs := topicAttributes(msg)
+	// the response published by a handler inherits the trace context of the request, see Subscribe
+	parent, _ := Extract(msg)
+	span := t.startSpan("publish", SpanKindProducer, parent, attrs)
+	t.tracer.Inject(span.Context, msg)
+
+	err := t.MessageBus.Publish(msg)
+	span.Finish(err)
+	return err
+}
+
+func (t *tracedMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	return t.MessageBus.Subscribe(func(msg *message.Message) {
+		attrs := topicAttributes(msg)
+		parent, _ := Extract(msg)
+		span := t.startSpan("handle", SpanKindConsumer, parent, attrs)
+		defer span.Finish(nil)
+
+		// the handler sees the handle span as the trace context of the message, so that
+		// the responses and the twin calls of the handler are correlated with it
+		handled := &message.Message{Topic: msg.Topic, Payload: msg.Payload, Header: make(map[string]string, len(msg.Header))}
+		for k, v := range msg.Header {
+			handled.Header[k] = v
+		}
+		t.tracer.Inject(span.Context, handled)
+		handler(handled)
+	}, topics...)
+}
+
+func (t *tracedMessageBus) Call(request *message.Message, rspTpc, errTpc string) (*message.Message, error) {
+	attrs := topicAttributes(request)
+	parent, _ := Extract(request)
+	span := t.startSpan("call", SpanKindClient, parent, attrs)
+	t.tracer.Inject(span.Context, request)
+
+	response, err := t.MessageBus.Call(request, rspTpc, errTpc)
+	span.Finish(err)
+	return response, err
+}
+
+func (t *tracedMessageBus) startSpan(action string, kind SpanKind, parent SpanContext, attrs map[string]string) *Span {
+	name := action
+	if optType := attrs[AttributeOptType]; optType != "" {
+		name += " " + optType
+	}
+	span := t.tracer.StartSpan(name, kind, parent)
+	for k, v := range attrs {
+		span.SetAttribute(k, v)
+	}
+	return span
+}
+
+// topicAttributes extracts the attributes of spans from the topic of the message.
+func topicAttributes(msg *message.Message) map[string]string {
+	attrs := map[string]string{AttributeTopic: msg.Topic}
+	topic, err := operations.ParseTopic(msg)
+	if err != nil {
+		return attrs
+	}
+	for key, attr := range map[operations.TopicTagKey]string{
+		operations.TopicTagKeyProtocolID: AttributeProtocolID,
+		operations.TopicTagKeyProductID:  AttributeProductID,
+		operations.TopicTagKeyDeviceID:   AttributeDeviceID,
+		operations.TopicTagKeyFuncID:     AttributeFuncID,
+		operations.TopicTagKeyOptType:    AttributeOptType,
+		operations.TopicTagKeyReqID:      AttributeReqID,
+	} {
+		if value, ok := topic.TagValue(key); ok {
+			attrs[attr] = value
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/msgbus/bus"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"github.com/thingio/edge-device-std/operations"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMessageBus records the messages published, and delivers them to the handlers whose topic filters match.
+type fakeMessageBus struct {
+	bus.MessageBus
+
+	published []*message.Message
+	handlers  map[string]message.Handler
+}
+
+func (f *fakeMessageBus) Publish(msg *message.Message) error {
+	f.published = append(f.published, msg)
+	for filter, handler := range f.handlers {
+		if match(filter, msg.Topic) {
+			handler(msg)
+		}
+	}
+	return nil
+}
+
+func (f *fakeMessageBus) Subscribe(handler message.Handler, topics ...string) error {
+	for _, topic := range topics {
+		f.handlers[topic] = handler
+	}
+	return nil
+}
+
+func match(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	if len(fs) != len(ts) {
+		return false
+	}
+	for i := range fs {
+		if fs[i] != "+" && fs[i] != ts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	spans map[string]*Span // name -> span
+}
+
+func (r *recorder) Export(span *Span) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans[span.Name] = span
+	return nil
+}
+
+func (r *recorder) Close() error {
+	return nil
+}
+
+type twin struct {
+	models.DeviceTwin
+}
+
+func (t *twin) Read(propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	return map[models.ProductPropertyID]*models.DeviceData{}, nil
+}
+
+func newRequest(t *testing.T) *message.Message {
+	msg, err := operations.NewDataOperation(operations.OperationModeDown, "modbus", "meter", "m1", "temperature",
+		operations.DataOperationTypeRead, "r1").ToMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMessageBus(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := &recorder{spans: make(map[string]*Span)}
+	tracer := NewTracerWithExporter(exporter, true, lg)
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	ds, err := operations.NewDriverService(NewMessageBus(mb, tracer), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := &models.Device{ID: "m1", ProductID: "meter"}
+	traced := NewDeviceTwin(new(twin), device, tracer)
+	if err = ds.ReadContextHandler("modbus", func(ctx context.Context, productID, deviceID string,
+		propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+		parent, ok := SpanContextFromContext(ctx)
+		if !ok {
+			t.Errorf("the ctx of the handler doesn't carry the trace context")
+		}
+		return WithParent(traced, parent).Read(propertyID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := newRequest(t)
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	Inject(sc, request)
+	if err = mb.Publish(request); err != nil {
+		t.Fatal(err)
+	}
+
+	handle, read, publish := exporter.spans["handle READ"], exporter.spans["twin read"], exporter.spans["publish READ"]
+	if handle == nil || read == nil || publish == nil {
+		t.Fatalf("the spans exported = %v", exporter.spans)
+	}
+	if handle.TraceID != sc.TraceID.String() || handle.ParentID != sc.SpanID.String() {
+		t.Errorf("the handle span isn't a child of the request")
+	}
+	if read.ParentID != handle.SpanID || publish.ParentID != handle.SpanID {
+		t.Errorf("the twin read and the response aren't children of the handle span")
+	}
+	response, ok := Extract(mb.published[len(mb.published)-1])
+	if !ok || response.SpanID.String() != publish.SpanID {
+		t.Errorf("the response doesn't carry the publish span")
+	}
+}
+
+func TestMessageBusDisabled(t *testing.T) {
+	lg, err := logger.NewLogger(&config.LogOptions{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer, err := NewTracer(&config.TracingOptions{Enabled: false}, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := &fakeMessageBus{handlers: make(map[string]message.Handler)}
+	traced := NewMessageBus(mb, tracer)
+
+	if err = traced.Publish(newRequest(t)); err != nil {
+		t.Fatal(err)
+	}
+	if TracerOf(traced, lg) != tracer || TracerOf(mb, lg).Enabled() {
+		t.Errorf("the tracer of the bus isn't the one decorating it")
+	}
+	if header := mb.published[0].Header; len(header) != 0 {
+		t.Errorf("the header is injected while the tracing is disabled: %v", header)
+	}
+
+	// the trace context received from the peers is passed through
+	request := newRequest(t)
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	Inject(sc, request)
+	if err = traced.Publish(request); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Extract(mb.published[1]); got != sc {
+		t.Errorf("the trace context = %v, want %v", got, sc)
+	}
+}
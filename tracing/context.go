@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"github.com/thingio/edge-device-std/operations"
+	"strings"
+)
+
+const (
+	// HeaderTraceParent is the header key of the W3C trace context.
+	// See https://www.w3.org/TR/trace-context/#traceparent-header for more details.
+	HeaderTraceParent = "traceparent"
+
+	traceParentVersion = "00"
+	traceFlagSampled   = "01"
+	traceFlagNone      = "00"
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the part of a span which will be propagated across the message bus.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (c SpanContext) IsValid() bool {
+	return c.TraceID.IsValid() && c.SpanID.IsValid()
+}
+
+// TraceParent formats the SpanContext as the value of the traceparent header,
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func (c SpanContext) TraceParent() string {
+	flags := traceFlagNone
+	if c.Sampled {
+		flags = traceFlagSampled
+	}
+	return strings.Join([]string{traceParentVersion, c.TraceID.String(), c.SpanID.String(), flags}, "-")
+}
+
+// ParseTraceParent parses the value of the traceparent header into a SpanContext.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent: %s", value)
+	}
+	if parts[0] != traceParentVersion {
+		return sc, fmt.Errorf("unsupported version of the traceparent: %s", parts[0])
+	}
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil {
+		return sc, fmt.Errorf("invalid trace-id of the traceparent: %s", err.Error())
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil {
+		return sc, fmt.Errorf("invalid parent-id of the traceparent: %s", err.Error())
+	}
+	flags := make([]byte, 1)
+	if err := decodeHex(parts[3], flags); err != nil {
+		return sc, fmt.Errorf("invalid trace-flags of the traceparent: %s", err.Error())
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent: %s, all zero IDs are not allowed", value)
+	}
+	return sc, nil
+}
+
+// Inject writes the SpanContext into the header of the message.
+// Use Tracer.Inject instead to skip the injection if the tracing is disabled.
+func Inject(sc SpanContext, msg *message.Message) {
+	if !sc.IsValid() || msg == nil {
+		return
+	}
+	msg.SetHeader(HeaderTraceParent, sc.TraceParent())
+}
+
+// Extract reads the SpanContext from the header of the message,
+// ok will be false if the message doesn't carry a valid trace context.
+func Extract(msg *message.Message) (sc SpanContext, ok bool) {
+	if msg == nil {
+		return sc, false
+	}
+	value := msg.GetHeader(HeaderTraceParent)
+	if value == "" {
+		return sc, false
+	}
+	sc, err := ParseTraceParent(value)
+	if err != nil {
+		return sc, false
+	}
+	return sc, true
+}
+
+// SpanContextFromContext reads the SpanContext from the header carried by the ctx passed to the
+// context handlers of operations.DataDriverService, ok will be false if the request doesn't carry a valid one.
+func SpanContextFromContext(ctx context.Context) (sc SpanContext, ok bool) {
+	return Extract(&message.Message{Header: operations.HeaderFromContext(ctx)})
+}
+
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) {
+		return fmt.Errorf("the length of %s should be %d", s, hex.EncodedLen(len(dst)))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
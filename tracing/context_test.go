@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		sampled bool
+		wantErr bool
+	}{
+		{"Parse a sampled traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"Parse a traceparent without sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"Parse a traceparent with unsupported version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"Parse a traceparent with all zero trace-id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, true},
+		{"Parse a traceparent with short parent-id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", false, true},
+		{"Parse an empty traceparent", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceParent(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceParent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("ParseTraceParent() sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+			if got := sc.TraceParent(); got != tt.value {
+				t.Errorf("TraceParent() = %v, want %v", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestInjectAndExtract(t *testing.T) {
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	payload := []byte(`{"temperature":{"name":"temperature"}}`)
+	msg := &message.Message{Topic: "DATA/v1/DOWN/p/p/d/f/READ/r", Payload: payload}
+	Inject(sc, msg)
+
+	frame, err := msg.Encode()
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := message.Decode(msg.Topic, frame)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if string(decoded.Payload) != string(payload) {
+		t.Errorf("Decode() payload = %s, want %s", decoded.Payload, payload)
+	}
+	got, ok := Extract(decoded)
+	if !ok {
+		t.Fatalf("Extract() ok = false, want true")
+	}
+	if got != sc {
+		t.Errorf("Extract() = %v, want %v", got, sc)
+	}
+
+	if _, ok = Extract(&message.Message{Topic: msg.Topic, Payload: payload}); ok {
+		t.Errorf("Extract() from a message without header ok = true, want false")
+	}
+}
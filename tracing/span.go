@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+type SpanKind = string
+
+const (
+	SpanKindInternal SpanKind = "internal"
+	SpanKindProducer SpanKind = "producer" // publishing a message to the bus
+	SpanKindConsumer SpanKind = "consumer" // handling a message received from the bus
+	SpanKindClient   SpanKind = "client"   // calling the peer through the bus, or calling the real device
+)
+
+const (
+	AttributeTopic      = "msgbus.topic"
+	AttributeProtocolID = "edge.protocol_id"
+	AttributeProductID  = "edge.product_id"
+	AttributeDeviceID   = "edge.device_id"
+	AttributeFuncID     = "edge.func_id"
+	AttributeOptType    = "edge.opt_type"
+	AttributeReqID      = "edge.req_id"
+)
+
+// Span records a timed operation, e.g. a bus publish, a handler execution or a twin call.
+type Span struct {
+	mu sync.Mutex
+
+	Context    SpanContext       `json:"-"`
+	TraceID    string            `json:"trace_id"`
+	SpanID     string            `json:"span_id"`
+	ParentID   string            `json:"parent_id,omitempty"`
+	Name       string            `json:"name"`
+	Kind       SpanKind          `json:"kind"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+
+	tracer *Tracer
+	ended  bool
+}
+
+// SetAttribute attaches a key-value pair to the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil || value == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]string)
+	}
+	s.Attributes[key] = value
+}
+
+// Finish ends the span and hands it over to the exporter, the err will be recorded if it is not nil.
+// Finishing a span more than once is a no-op.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	if s.Context.Sampled {
+		s.tracer.export(s)
+	}
+}
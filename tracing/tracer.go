@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/config"
+	"github.com/thingio/edge-device-std/logger"
+	"github.com/thingio/edge-device-std/msgbus/message"
+	"time"
+)
+
+func NewTracer(opts *config.TracingOptions, lg *logger.Logger) (*Tracer, error) {
+	if opts == nil || !opts.Enabled {
+		return NewTracerWithExporter(nopExporter{}, false, lg), nil
+	}
+
+	var exporter Exporter
+	switch opts.Exporter {
+	case config.TracingExporterTypeFile, "":
+		fe, err := NewFileExporter(opts.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fe
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter type: %s", opts.Exporter)
+	}
+	return NewTracerWithExporter(exporter, true, lg), nil
+}
+
+func NewTracerWithExporter(exporter Exporter, enabled bool, lg *logger.Logger) *Tracer {
+	return &Tracer{
+		enabled:  enabled,
+		exporter: exporter,
+		lg:       lg,
+	}
+}
+
+// Tracer creates spans and hands the finished ones over to the Exporter.
+type Tracer struct {
+	enabled  bool
+	exporter Exporter
+
+	lg *logger.Logger
+}
+
+func (t *Tracer) Enabled() bool {
+	return t.enabled
+}
+
+// StartSpan starts a new span as a child of the parent, or a new trace will be started if the parent is invalid.
+func (t *Tracer) StartSpan(name string, kind SpanKind, parent SpanContext) *Span {
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		SpanID:  newSpanID(),
+		Sampled: t.enabled,
+	}
+	span := &Span{
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+		tracer: t,
+	}
+	if parent.IsValid() {
+		sc.Sampled = t.enabled && parent.Sampled
+		span.ParentID = parent.SpanID.String()
+	} else {
+		sc.TraceID = newTraceID()
+	}
+	span.Context = sc
+	span.TraceID = sc.TraceID.String()
+	span.SpanID = sc.SpanID.String()
+	return span
+}
+
+// Inject writes the SpanContext into the header of the message, it is a no-op if the tracing is disabled,
+// so that the trace context received from the peers is passed through as it is.
+func (t *Tracer) Inject(sc SpanContext, msg *message.Message) {
+	if !t.enabled {
+		return
+	}
+	Inject(sc, msg)
+}
+
+// Close flushes and closes the Exporter.
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+func (t *Tracer) export(span *Span) {
+	if err := t.exporter.Export(span); err != nil {
+		t.lg.WithError(err).Errorf("fail to export the span: %s", span.Name)
+	}
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"github.com/thingio/edge-device-std/models"
+)
+
+// NewDeviceTwin decorates the DeviceTwin to record spans around the calls with the real device.
+// The spans start new traces, use WithParent to correlate them with the request being handled.
+func NewDeviceTwin(twin models.DeviceTwin, device *models.Device, tracer *Tracer) models.DeviceTwin {
+	return &tracedDeviceTwin{DeviceTwin: twin, device: device, tracer: tracer}
+}
+
+// WithParent returns a copy of the twin decorated by NewDeviceTwin whose spans are children of the parent,
+// the twin is returned as it is if it isn't decorated. It is usually called in the context handlers registered by
+// operations.DataDriverService on a traced MessageBus, e.g.
+//
+//	parent, _ := tracing.SpanContextFromContext(ctx)
+//	props, err := tracing.WithParent(twin, parent).Read(propertyID)
+func WithParent(twin models.DeviceTwin, parent SpanContext) models.DeviceTwin {
+	traced, ok := twin.(*tracedDeviceTwin)
+	if !ok {
+		return twin
+	}
+	child := *traced
+	child.parent = parent
+	return &child
+}
+
+type tracedDeviceTwin struct {
+	models.DeviceTwin
+
+	device *models.Device
+	tracer *Tracer
+	parent SpanContext
+}
+
+func (t *tracedDeviceTwin) Start(ctx context.Context) (err error) {
+	span := t.startSpan("twin start", "")
+	defer func() { span.Finish(err) }()
+	return t.DeviceTwin.Start(ctx)
+}
+
+func (t *tracedDeviceTwin) Stop(force bool) (err error) {
+	span := t.startSpan("twin stop", "")
+	defer func() { span.Finish(err) }()
+	return t.DeviceTwin.Stop(force)
+}
+
+func (t *tracedDeviceTwin) Read(propertyID models.ProductPropertyID) (
+	props map[models.ProductPropertyID]*models.DeviceData, err error) {
+	span := t.startSpan("twin read", propertyID)
+	defer func() { span.Finish(err) }()
+	return t.DeviceTwin.Read(propertyID)
+}
+
+func (t *tracedDeviceTwin) Write(propertyID models.ProductPropertyID,
+	values map[models.ProductPropertyID]*models.DeviceData) (err error) {
+	span := t.startSpan("twin write", propertyID)
+	defer func() { span.Finish(err) }()
+	return t.DeviceTwin.Write(propertyID, values)
+}
+
+func (t *tracedDeviceTwin) Call(methodID models.ProductMethodID, ins map[models.ProductPropertyID]*models.DeviceData) (
+	outs map[models.ProductPropertyID]*models.DeviceData, err error) {
+	span := t.startSpan("twin call", methodID)
+	defer func() { span.Finish(err) }()
+	return t.DeviceTwin.Call(methodID, ins)
+}
+
+func (t *tracedDeviceTwin) startSpan(name string, funcID models.ProductFuncID) *Span {
+	span := t.tracer.StartSpan(name, SpanKindClient, t.parent)
+	span.SetAttribute(AttributeProductID, t.device.ProductID)
+	span.SetAttribute(AttributeDeviceID, t.device.ID)
+	span.SetAttribute(AttributeFuncID, funcID)
+	return span
+}
@@ -0,0 +1,332 @@
+package transform
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/units"
+	"math"
+	"strconv"
+)
+
+// The standard keys of ProductProperty.AuxProps declaring the transformation between
+// the raw value of the device and the engineering value of the property.
+const (
+	AuxKeyBitOffset = "bit_offset" // the offset of the lowest bit to extract from the raw value
+	AuxKeyBitLength = "bit_length" // the number of bits to extract from the raw value
+	AuxKeyScale     = "scale"      // engineering = raw * scale + offset
+	AuxKeyOffset    = "offset"     // engineering = raw * scale + offset
+	AuxKeyRawUnit   = "raw_unit"   // the unit of the raw value, it will be converted into the Unit of the property
+	AuxKeyMin       = "min"        // the engineering value will be clamped into [min, max]
+	AuxKeyMax       = "max"        // the engineering value will be clamped into [min, max]
+	AuxKeyRawType   = "raw_type"   // the type of the raw value written to the device, the FieldType by default
+)
+
+// step is a reversible transformation of the pipeline.
+type step interface {
+	read(v float64) (float64, error)
+	write(v float64) (float64, error)
+}
+
+// NewPipeline returns the pipeline declared by the AuxProps of the property, the units are looked up
+// in the registry, and units.Default is used if it is nil.
+func NewPipeline(property *models.ProductProperty, registry *units.Registry) (*Pipeline, error) {
+	if registry == nil {
+		registry = units.Default
+	}
+	p := &Pipeline{property: property, rawType: property.FieldType}
+	aux := property.AuxProps
+
+	if aux[AuxKeyBitOffset] != "" || aux[AuxKeyBitLength] != "" {
+		offset, err := parseUint(aux, AuxKeyBitOffset, 0)
+		if err != nil {
+			return nil, err
+		}
+		length, err := parseUint(aux, AuxKeyBitLength, 1)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 || offset+length > 64 {
+			return nil, fmt.Errorf("the bits [%d, %d) to extract are out of the range [0, 64)", offset, offset+length)
+		}
+		p.bits = &bitsStep{offset: offset, length: length}
+		p.rawType = models.PropertyValueTypeUint
+	}
+	if aux[AuxKeyScale] != "" || aux[AuxKeyOffset] != "" {
+		scale, err := parseFloat(aux, AuxKeyScale, 1)
+		if err != nil {
+			return nil, err
+		}
+		if scale == 0 {
+			return nil, fmt.Errorf("the %s should not be zero", AuxKeyScale)
+		}
+		offset, err := parseFloat(aux, AuxKeyOffset, 0)
+		if err != nil {
+			return nil, err
+		}
+		p.steps = append(p.steps, &linearStep{scale: scale, offset: offset})
+	}
+	if raw := aux[AuxKeyRawUnit]; raw != "" && raw != property.Unit {
+		if !registry.Convertible(raw, property.Unit) {
+			return nil, fmt.Errorf("the %s %s can't be converted into the unit %s", AuxKeyRawUnit, raw, property.Unit)
+		}
+		p.steps = append(p.steps, &unitStep{registry: registry, from: raw, to: property.Unit})
+	}
+	if aux[AuxKeyMin] != "" || aux[AuxKeyMax] != "" {
+		min, err := parseFloat(aux, AuxKeyMin, math.Inf(-1))
+		if err != nil {
+			return nil, err
+		}
+		max, err := parseFloat(aux, AuxKeyMax, math.Inf(1))
+		if err != nil {
+			return nil, err
+		}
+		if min > max {
+			return nil, fmt.Errorf("the %s should not be greater than the %s", AuxKeyMin, AuxKeyMax)
+		}
+		p.steps = append(p.steps, &clampStep{min: min, max: max})
+	}
+	if rawType := aux[AuxKeyRawType]; rawType != "" {
+		switch rawType {
+		case models.PropertyValueTypeInt, models.PropertyValueTypeUint, models.PropertyValueTypeFloat:
+		default:
+			return nil, fmt.Errorf("unsupported %s: %s", AuxKeyRawType, rawType)
+		}
+		p.rawType = rawType
+	}
+	return p, nil
+}
+
+// Pipeline transforms the raw value read from the device into the engineering value of the property,
+// and the engineering value to write back into the raw value. The steps are applied in the order of
+// bit extraction, scaling and offset, unit conversion and clamping on read, and reversely on write.
+//
+// The bit extraction can't be fully reversed, the written raw value contains only the extracted bits
+// which are shifted to their position, the driver is responsible to merge it with the other bits.
+type Pipeline struct {
+	property *models.ProductProperty
+	bits     *bitsStep // the bits are extracted from the integer raw value, so it isn't a step of floats
+	steps    []step
+	rawType  models.PropertyValueType
+}
+
+// Empty returns whether the raw value equals to the engineering value.
+func (p *Pipeline) Empty() bool {
+	return p.bits == nil && len(p.steps) == 0
+}
+
+// Read transforms the raw value into the engineering value.
+func (p *Pipeline) Read(raw *models.DeviceData) (*models.DeviceData, error) {
+	if p.Empty() {
+		return raw, nil
+	}
+	var v float64
+	var err error
+	if p.bits != nil {
+		var bits uint64
+		if bits, err = integerBits(raw); err != nil {
+			return nil, fmt.Errorf("fail to extract the bits of the property %s: %s", p.property.Id, err.Error())
+		}
+		v = float64(p.bits.read(bits))
+	} else if v, err = raw.NumericValue(); err != nil {
+		return nil, err
+	}
+	var clamped bool
+	for _, s := range p.steps {
+		before := v
+		if v, err = s.read(v); err != nil {
+			return nil, fmt.Errorf("fail to transform the raw value of the property %s: %s", p.property.Id, err.Error())
+		}
+		if _, ok := s.(*clampStep); ok && v != before {
+			clamped = true
+		}
+	}
+	value, err := newDeviceData(raw, p.property.FieldType, v)
+	if err != nil {
+		return nil, err
+	}
+	if clamped {
+		value.Quality = models.WorseDataQuality(value.Quality, models.DataQualityUncertainEUExceeded)
+	}
+	return value, nil
+}
+
+// Write transforms the engineering value into the raw value.
+func (p *Pipeline) Write(value *models.DeviceData) (*models.DeviceData, error) {
+	if p.Empty() {
+		return value, nil
+	}
+	v, err := value.NumericValue()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(p.steps) - 1; i >= 0; i-- {
+		if v, err = p.steps[i].write(v); err != nil {
+			return nil, fmt.Errorf("fail to transform the value of the property %s: %s", p.property.Id, err.Error())
+		}
+	}
+	if p.bits == nil {
+		return newDeviceData(value, p.rawType, v)
+	}
+	bits, err := p.bits.write(v)
+	if err != nil {
+		return nil, fmt.Errorf("fail to transform the value of the property %s: %s", p.property.Id, err.Error())
+	}
+	cp := *value
+	cp.Type = p.rawType
+	switch p.rawType {
+	case models.PropertyValueTypeInt:
+		cp.Value = int64(bits)
+	case models.PropertyValueTypeFloat:
+		cp.Value = float64(bits)
+	default:
+		cp.Value = bits
+	}
+	return &cp, nil
+}
+
+type bitsStep struct {
+	offset, length uint64
+}
+
+func (s *bitsStep) mask() uint64 {
+	if s.length == 64 {
+		return math.MaxUint64
+	}
+	return 1<<s.length - 1
+}
+
+func (s *bitsStep) read(bits uint64) uint64 {
+	return (bits >> s.offset) & s.mask()
+}
+
+func (s *bitsStep) write(v float64) (uint64, error) {
+	v = math.Round(v)
+	if v < 0 || v > float64(s.mask()) {
+		return 0, fmt.Errorf("the value %v can't be stored in %d bits", v, s.length)
+	}
+	return uint64(v) << s.offset, nil
+}
+
+// integerBits returns the bits of the integer raw value, the negative ones are in two's complement.
+// The floats are accepted only if they are integral, e.g. decoded from JSON.
+func integerBits(raw *models.DeviceData) (uint64, error) {
+	switch v := raw.Value.(type) {
+	case int:
+		return uint64(v), nil
+	case int8:
+		return uint64(v), nil
+	case int16:
+		return uint64(v), nil
+	case int32:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case float32:
+		return floatBits(float64(v))
+	case float64:
+		return floatBits(v)
+	default:
+		return 0, fmt.Errorf("the bits can't be extracted from %v", raw.Value)
+	}
+}
+
+func floatBits(v float64) (uint64, error) {
+	switch {
+	case v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxUint64:
+		return 0, fmt.Errorf("the bits can't be extracted from %v", v)
+	case v < 0:
+		return uint64(int64(v)), nil
+	default:
+		return uint64(v), nil
+	}
+}
+
+type linearStep struct {
+	scale, offset float64
+}
+
+func (s *linearStep) read(v float64) (float64, error) {
+	return v*s.scale + s.offset, nil
+}
+
+func (s *linearStep) write(v float64) (float64, error) {
+	return (v - s.offset) / s.scale, nil
+}
+
+type unitStep struct {
+	registry *units.Registry
+	from, to string
+}
+
+func (s *unitStep) read(v float64) (float64, error) {
+	return s.registry.Convert(v, s.from, s.to)
+}
+
+func (s *unitStep) write(v float64) (float64, error) {
+	return s.registry.Convert(v, s.to, s.from)
+}
+
+type clampStep struct {
+	min, max float64
+}
+
+func (s *clampStep) read(v float64) (float64, error) {
+	return math.Min(math.Max(v, s.min), s.max), nil
+}
+
+func (s *clampStep) write(v float64) (float64, error) {
+	return math.Min(math.Max(v, s.min), s.max), nil
+}
+
+// newDeviceData returns a copy of the data with the value converted into the type, the metadata is kept.
+func newDeviceData(data *models.DeviceData, valueType models.PropertyValueType, v float64) (*models.DeviceData, error) {
+	var value interface{}
+	switch valueType {
+	case models.PropertyValueTypeInt:
+		value = int64(math.Round(v))
+	case models.PropertyValueTypeUint:
+		if v < 0 {
+			return nil, fmt.Errorf("the value %v should not be negative", v)
+		}
+		value = uint64(math.Round(v))
+	case models.PropertyValueTypeFloat:
+		value = v
+	default:
+		return nil, fmt.Errorf("the value %v can't be converted into the type %s", v, valueType)
+	}
+	cp := *data
+	cp.Type, cp.Value = valueType, value
+	return &cp, nil
+}
+
+func parseUint(aux map[string]string, key string, def uint64) (uint64, error) {
+	if aux[key] == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseUint(aux[key], 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %s", key, aux[key], err.Error())
+	}
+	return v, nil
+}
+
+func parseFloat(aux map[string]string, key string, def float64) (float64, error) {
+	if aux[key] == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(aux[key], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %s", key, aux[key], err.Error())
+	}
+	return v, nil
+}
@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"github.com/thingio/edge-device-std/models"
+	"math"
+	"testing"
+)
+
+func TestPipeline_ReadWrite(t *testing.T) {
+	property := &models.ProductProperty{Id: "temperature", FieldType: models.PropertyValueTypeFloat, Unit: "°F",
+		AuxProps: map[string]string{
+			AuxKeyBitOffset: "4", AuxKeyBitLength: "12", AuxKeyScale: "0.1", AuxKeyOffset: "-40",
+			AuxKeyRawUnit: "°C", AuxKeyMax: "200",
+		},
+	}
+	p, err := NewPipeline(property, nil)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	// 0x28A = 650 -> 65.0 - 40 = 25°C = 77°F
+	raw := &models.DeviceData{Name: "temperature", Type: models.PropertyValueTypeUint, Value: uint64(0x28A<<4 | 0xF)}
+	value, err := p.Read(raw)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if v := value.Value.(float64); math.Abs(v-77) > 1e-9 {
+		t.Errorf("Read() = %v, want 77", v)
+	}
+
+	written, err := p.Write(value)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if v := written.Value.(uint64); v != 0x28A<<4 {
+		t.Errorf("Write() = %#x, want %#x", v, 0x28A<<4)
+	}
+
+	// clamped to 200°F, which is 93.33°C -> 1333 in 12 bits
+	written, err = p.Write(&models.DeviceData{Name: "temperature", Type: models.PropertyValueTypeFloat, Value: 300.0})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if v := written.Value.(uint64); v != 1333<<4 {
+		t.Errorf("Write() the clamped value = %d, want %d", v>>4, 1333)
+	}
+}
+
+func TestPipeline_Bits(t *testing.T) {
+	property := &models.ProductProperty{Id: "flag", FieldType: models.PropertyValueTypeUint,
+		AuxProps: map[string]string{AuxKeyBitOffset: "0", AuxKeyBitLength: "4"},
+	}
+	p, err := NewPipeline(property, nil)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		raw  interface{}
+		want uint64
+	}{
+		// the low bits are lost if the raw value is converted into float64 before extracting
+		{"Extract the low bits of large uint64", uint64(0xA000000000000001), 0x1},
+		{"Extract the low bits of large int64", int64(0x7000000000000003), 0x3},
+		{"Extract the low bits of negative int64", int64(-1), 0xF},
+		{"Extract the low bits of integral float64", float64(13), 0xD},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := p.Read(&models.DeviceData{Name: "flag", Type: models.PropertyValueTypeUint, Value: tt.raw})
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if v := value.Value.(uint64); v != tt.want {
+				t.Errorf("Read() = %#x, want %#x", v, tt.want)
+			}
+		})
+	}
+	if _, err = p.Read(&models.DeviceData{Name: "flag", Type: models.PropertyValueTypeFloat, Value: 1.5}); err == nil {
+		t.Errorf("Read() the fractional raw value error = nil, want an error")
+	}
+
+	property.AuxProps[AuxKeyBitOffset] = "60"
+	if p, err = NewPipeline(property, nil); err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+	written, err := p.Write(&models.DeviceData{Name: "flag", Type: models.PropertyValueTypeUint, Value: uint64(0xF)})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if v := written.Value.(uint64); v != 0xF000000000000000 {
+		t.Errorf("Write() = %#x, want %#x", v, uint64(0xF000000000000000))
+	}
+}
+
+func TestNewPipeline_Invalid(t *testing.T) {
+	for _, aux := range []map[string]string{
+		{AuxKeyScale: "0"},
+		{AuxKeyBitOffset: "60", AuxKeyBitLength: "8"},
+		{AuxKeyRawUnit: "kWh"},
+		{AuxKeyMin: "10", AuxKeyMax: "0"},
+		{AuxKeyOffset: "abc"},
+	} {
+		property := &models.ProductProperty{Id: "p", FieldType: models.PropertyValueTypeFloat, Unit: "V", AuxProps: aux}
+		if _, err := NewPipeline(property, nil); err == nil {
+			t.Errorf("NewPipeline(%v) error = nil, want an error", aux)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/units"
+)
+
+// NewTransformer returns a Transformer of the properties of the product.
+func NewTransformer(product *models.Product, registry *units.Registry) (*Transformer, error) {
+	t := &Transformer{pipelines: make(map[models.ProductPropertyID]*Pipeline)}
+	for _, property := range product.Properties {
+		pipeline, err := NewPipeline(property, registry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transformation of the property %s of the product %s: %s",
+				property.Id, product.ID, err.Error())
+		}
+		if !pipeline.Empty() {
+			t.pipelines[property.Id] = pipeline
+		}
+	}
+	return t, nil
+}
+
+// Transformer applies the pipelines of the properties of a product.
+type Transformer struct {
+	pipelines map[models.ProductPropertyID]*Pipeline
+}
+
+// Empty returns whether none of the properties need to be transformed.
+func (t *Transformer) Empty() bool {
+	return len(t.pipelines) == 0
+}
+
+// Read returns the engineering values of the raw values read from the device.
+func (t *Transformer) Read(props map[models.ProductPropertyID]*models.DeviceData) (
+	map[models.ProductPropertyID]*models.DeviceData, error) {
+	return t.apply(props, (*Pipeline).Read)
+}
+
+// Write returns the raw values to write into the device.
+func (t *Transformer) Write(props map[models.ProductPropertyID]*models.DeviceData) (
+	map[models.ProductPropertyID]*models.DeviceData, error) {
+	return t.apply(props, (*Pipeline).Write)
+}
+
+func (t *Transformer) apply(props map[models.ProductPropertyID]*models.DeviceData,
+	fn func(p *Pipeline, data *models.DeviceData) (*models.DeviceData, error)) (
+	map[models.ProductPropertyID]*models.DeviceData, error) {
+	results := make(map[models.ProductPropertyID]*models.DeviceData, len(props))
+	for id, data := range props {
+		pipeline, ok := t.pipelines[id]
+		if !ok || data == nil {
+			results[id] = data
+			continue
+		}
+		result, err := fn(pipeline, data)
+		if err != nil {
+			return nil, err
+		}
+		results[id] = result
+	}
+	return results, nil
+}
+
+// ConvertUnit converts the numeric value between the units registered in the registry,
+// units.Default is used if it is nil. It's useful for the managers to show the values in the preferred units.
+func ConvertUnit(data *models.DeviceData, from, to string, registry *units.Registry) (*models.DeviceData, error) {
+	if registry == nil {
+		registry = units.Default
+	}
+	v, err := data.NumericValue()
+	if err != nil {
+		return nil, err
+	}
+	if v, err = registry.Convert(v, from, to); err != nil {
+		return nil, err
+	}
+	cp := *data
+	cp.Type, cp.Value = models.PropertyValueTypeFloat, v
+	return &cp, nil
+}
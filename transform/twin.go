@@ -0,0 +1,43 @@
+package transform
+
+import (
+	"github.com/thingio/edge-device-std/errors"
+	"github.com/thingio/edge-device-std/models"
+)
+
+// NewDeviceTwin decorates the DeviceTwin to read and write the engineering values,
+// while the decorated twin reads and writes the raw values of the real device.
+func NewDeviceTwin(twin models.DeviceTwin, device *models.Device, transformer *Transformer) models.DeviceTwin {
+	if transformer.Empty() {
+		return twin
+	}
+	return &transformedDeviceTwin{DeviceTwin: twin, device: device, transformer: transformer}
+}
+
+type transformedDeviceTwin struct {
+	models.DeviceTwin
+
+	device      *models.Device
+	transformer *Transformer
+}
+
+func (t *transformedDeviceTwin) Read(propertyID models.ProductPropertyID) (map[models.ProductPropertyID]*models.DeviceData, error) {
+	raw, err := t.DeviceTwin.Read(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	props, err := t.transformer.Read(raw)
+	if err != nil {
+		return nil, errors.Driver.Cause(err, "fail to transform the property[%s] read from the device[%s]", propertyID, t.device.ID)
+	}
+	return props, nil
+}
+
+func (t *transformedDeviceTwin) Write(propertyID models.ProductPropertyID,
+	values map[models.ProductPropertyID]*models.DeviceData) error {
+	raw, err := t.transformer.Write(values)
+	if err != nil {
+		return errors.BadRequest.Cause(err, "fail to transform the property[%s] written to the device[%s]", propertyID, t.device.ID)
+	}
+	return t.DeviceTwin.Write(propertyID, raw)
+}
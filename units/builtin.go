@@ -0,0 +1,90 @@
+package units
+
+const (
+	QuantityLength      = "length"
+	QuantityMass        = "mass"
+	QuantityTime        = "time"
+	QuantityTemperature = "temperature"
+	QuantityPressure    = "pressure"
+	QuantityEnergy      = "energy"
+	QuantityPower       = "power"
+	QuantityVoltage     = "voltage"
+	QuantityCurrent     = "current"
+	QuantityFrequency   = "frequency"
+	QuantityVolume      = "volume"
+	QuantityFlow        = "flow"
+	QuantityRatio       = "ratio"
+)
+
+// builtin are the common units registered into the Default registry, the first unit of each quantity is the base.
+var builtin = []struct {
+	unit    *Unit
+	aliases []string
+}{
+	{&Unit{Symbol: "m", Name: "metre", Quantity: QuantityLength, Factor: 1}, nil},
+	{&Unit{Symbol: "km", Name: "kilometre", Quantity: QuantityLength, Factor: 1000}, nil},
+	{&Unit{Symbol: "cm", Name: "centimetre", Quantity: QuantityLength, Factor: 0.01}, nil},
+	{&Unit{Symbol: "mm", Name: "millimetre", Quantity: QuantityLength, Factor: 0.001}, nil},
+	{&Unit{Symbol: "in", Name: "inch", Quantity: QuantityLength, Factor: 0.0254}, nil},
+	{&Unit{Symbol: "ft", Name: "foot", Quantity: QuantityLength, Factor: 0.3048}, nil},
+
+	{&Unit{Symbol: "kg", Name: "kilogram", Quantity: QuantityMass, Factor: 1}, nil},
+	{&Unit{Symbol: "g", Name: "gram", Quantity: QuantityMass, Factor: 0.001}, nil},
+	{&Unit{Symbol: "t", Name: "tonne", Quantity: QuantityMass, Factor: 1000}, nil},
+	{&Unit{Symbol: "lb", Name: "pound", Quantity: QuantityMass, Factor: 0.45359237}, nil},
+
+	{&Unit{Symbol: "s", Name: "second", Quantity: QuantityTime, Factor: 1}, nil},
+	{&Unit{Symbol: "ms", Name: "millisecond", Quantity: QuantityTime, Factor: 0.001}, nil},
+	{&Unit{Symbol: "min", Name: "minute", Quantity: QuantityTime, Factor: 60}, nil},
+	{&Unit{Symbol: "h", Name: "hour", Quantity: QuantityTime, Factor: 3600}, nil},
+
+	{&Unit{Symbol: "K", Name: "kelvin", Quantity: QuantityTemperature, Factor: 1}, nil},
+	{&Unit{Symbol: "°C", Name: "degree Celsius", Quantity: QuantityTemperature, Factor: 1, Offset: 273.15}, []string{"℃", "degC"}},
+	{&Unit{Symbol: "°F", Name: "degree Fahrenheit", Quantity: QuantityTemperature, Factor: 5.0 / 9, Offset: 273.15 - 32*5.0/9}, []string{"℉", "degF"}},
+
+	{&Unit{Symbol: "Pa", Name: "pascal", Quantity: QuantityPressure, Factor: 1}, nil},
+	{&Unit{Symbol: "kPa", Name: "kilopascal", Quantity: QuantityPressure, Factor: 1e3}, nil},
+	{&Unit{Symbol: "MPa", Name: "megapascal", Quantity: QuantityPressure, Factor: 1e6}, nil},
+	{&Unit{Symbol: "bar", Name: "bar", Quantity: QuantityPressure, Factor: 1e5}, nil},
+	{&Unit{Symbol: "psi", Name: "pound per square inch", Quantity: QuantityPressure, Factor: 6894.757293168}, nil},
+
+	{&Unit{Symbol: "J", Name: "joule", Quantity: QuantityEnergy, Factor: 1}, nil},
+	{&Unit{Symbol: "kJ", Name: "kilojoule", Quantity: QuantityEnergy, Factor: 1e3}, nil},
+	{&Unit{Symbol: "Wh", Name: "watt hour", Quantity: QuantityEnergy, Factor: 3600}, nil},
+	{&Unit{Symbol: "kWh", Name: "kilowatt hour", Quantity: QuantityEnergy, Factor: 3.6e6}, nil},
+
+	{&Unit{Symbol: "W", Name: "watt", Quantity: QuantityPower, Factor: 1}, nil},
+	{&Unit{Symbol: "kW", Name: "kilowatt", Quantity: QuantityPower, Factor: 1e3}, nil},
+	{&Unit{Symbol: "MW", Name: "megawatt", Quantity: QuantityPower, Factor: 1e6}, nil},
+
+	{&Unit{Symbol: "V", Name: "volt", Quantity: QuantityVoltage, Factor: 1}, nil},
+	{&Unit{Symbol: "mV", Name: "millivolt", Quantity: QuantityVoltage, Factor: 1e-3}, nil},
+	{&Unit{Symbol: "kV", Name: "kilovolt", Quantity: QuantityVoltage, Factor: 1e3}, nil},
+
+	{&Unit{Symbol: "A", Name: "ampere", Quantity: QuantityCurrent, Factor: 1}, nil},
+	{&Unit{Symbol: "mA", Name: "milliampere", Quantity: QuantityCurrent, Factor: 1e-3}, nil},
+
+	{&Unit{Symbol: "Hz", Name: "hertz", Quantity: QuantityFrequency, Factor: 1}, nil},
+	{&Unit{Symbol: "kHz", Name: "kilohertz", Quantity: QuantityFrequency, Factor: 1e3}, nil},
+	{&Unit{Symbol: "rpm", Name: "revolution per minute", Quantity: QuantityFrequency, Factor: 1.0 / 60}, nil},
+
+	{&Unit{Symbol: "m3", Name: "cubic metre", Quantity: QuantityVolume, Factor: 1}, []string{"m³"}},
+	{&Unit{Symbol: "L", Name: "litre", Quantity: QuantityVolume, Factor: 1e-3}, []string{"l"}},
+	{&Unit{Symbol: "mL", Name: "millilitre", Quantity: QuantityVolume, Factor: 1e-6}, []string{"ml"}},
+
+	{&Unit{Symbol: "m3/s", Name: "cubic metre per second", Quantity: QuantityFlow, Factor: 1}, []string{"m³/s"}},
+	{&Unit{Symbol: "m3/h", Name: "cubic metre per hour", Quantity: QuantityFlow, Factor: 1.0 / 3600}, []string{"m³/h"}},
+	{&Unit{Symbol: "L/min", Name: "litre per minute", Quantity: QuantityFlow, Factor: 1e-3 / 60}, nil},
+
+	{&Unit{Symbol: "1", Name: "ratio", Quantity: QuantityRatio, Factor: 1}, nil},
+	{&Unit{Symbol: "%", Name: "percent", Quantity: QuantityRatio, Factor: 0.01}, nil},
+	{&Unit{Symbol: "‰", Name: "per mille", Quantity: QuantityRatio, Factor: 0.001}, nil},
+}
+
+func init() {
+	for _, b := range builtin {
+		if err := Default.Register(b.unit, b.aliases...); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package units
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Unit is a unit of measurement which can be converted linearly into the base unit of its quantity,
+// i.e. base = value * Factor + Offset.
+type Unit struct {
+	Symbol   string  `json:"symbol"`   // e.g. "kWh"
+	Name     string  `json:"name"`     // e.g. "kilowatt hour"
+	Quantity string  `json:"quantity"` // e.g. "energy", only the units of the same quantity can be converted
+	Factor   float64 `json:"factor"`
+	Offset   float64 `json:"offset"`
+}
+
+func (u *Unit) toBase(value float64) float64 {
+	return value*u.Factor + u.Offset
+}
+
+func (u *Unit) fromBase(value float64) float64 {
+	return (value - u.Offset) / u.Factor
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{units: make(map[string]*Unit)}
+}
+
+// Registry is a set of units indexed by their symbols and aliases.
+type Registry struct {
+	mu    sync.RWMutex
+	units map[string]*Unit
+}
+
+// Register adds the unit into the registry, it can also be looked up by the aliases.
+func (r *Registry) Register(unit *Unit, aliases ...string) error {
+	if unit.Symbol == "" || unit.Quantity == "" {
+		return fmt.Errorf("both the symbol and the quantity of the unit are required")
+	}
+	if unit.Factor == 0 {
+		return fmt.Errorf("the factor of the unit %s should not be zero", unit.Symbol)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, symbol := range append([]string{unit.Symbol}, aliases...) {
+		if _, ok := r.units[symbol]; ok {
+			return fmt.Errorf("the unit %s has been registered", symbol)
+		}
+	}
+	for _, symbol := range append([]string{unit.Symbol}, aliases...) {
+		r.units[symbol] = unit
+	}
+	return nil
+}
+
+// Lookup returns the unit by its symbol or alias.
+func (r *Registry) Lookup(symbol string) (*Unit, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	unit, ok := r.units[symbol]
+	return unit, ok
+}
+
+// Convertible returns whether the values can be converted between the units.
+func (r *Registry) Convertible(from, to string) bool {
+	f, ok := r.Lookup(from)
+	if !ok {
+		return false
+	}
+	t, ok := r.Lookup(to)
+	return ok && f.Quantity == t.Quantity
+}
+
+// Convert converts the value from one unit into another of the same quantity.
+func (r *Registry) Convert(value float64, from, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	f, ok := r.Lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", from)
+	}
+	t, ok := r.Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", to)
+	}
+	if f.Quantity != t.Quantity {
+		return 0, fmt.Errorf("fail to convert %s(%s) into %s(%s)", f.Symbol, f.Quantity, t.Symbol, t.Quantity)
+	}
+	return t.fromBase(f.toBase(value)), nil
+}
+
+// Default is the registry of the common units.
+var Default = NewRegistry()
+
+// Register adds the unit into the Default registry.
+func Register(unit *Unit, aliases ...string) error {
+	return Default.Register(unit, aliases...)
+}
+
+// Convert converts the value between the units registered in the Default registry.
+func Convert(value float64, from, to string) (float64, error) {
+	return Default.Convert(value, from, to)
+}
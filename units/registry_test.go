@@ -0,0 +1,32 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{100, "°C", "°F", 212},
+		{32, "degF", "K", 273.15},
+		{1.5, "kWh", "J", 5.4e6},
+		{50, "%", "1", 0.5},
+		{3600, "m3/h", "m3/s", 1},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("Convert(%v, %s, %s) error = %v", tt.value, tt.from, tt.to, err)
+		}
+		if math.Abs(got-tt.want) > 1e-9*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+	if _, err := Convert(1, "V", "A"); err == nil {
+		t.Errorf("Convert(V, A) error = nil, want an error")
+	}
+}
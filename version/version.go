@@ -3,6 +3,7 @@ package version
 type Version string
 
 const (
-	MetaVersion Version = "v1"
-	DataVersion Version = "v1"
+	MetaVersion    Version = "v1"
+	DataVersion    Version = "v1"
+	HistoryVersion Version = "v1"
 )
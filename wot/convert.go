@@ -0,0 +1,379 @@
+package wot
+
+import (
+	"fmt"
+	"github.com/thingio/edge-device-std/models"
+	"github.com/thingio/edge-device-std/transform"
+	"github.com/thingio/edge-device-std/units"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// IDPrefix is the prefix of the IDs of the TDs exported from the products.
+	IDPrefix = "urn:thingio:product:"
+
+	securityNoSec = "nosec_sc"
+	uriVariable   = "device_id"
+
+	// the IDs of the fields imported from the data which are not objects
+	inputFieldID  = "input"
+	outputFieldID = "output"
+	dataFieldID   = "data"
+)
+
+// Export converts the product into a TD describing its devices served by the gateway.Handler,
+// the ID of the device is the URI variable device_id of the forms.
+//
+// The fields of the product which TD can't express, i.e. the intervals, the report modes,
+// the expressions and the auxiliary properties, are kept by the terms prefixed with thingio,
+// the protocol is kept by thingio:protocol, and the topics, the alarms and the aggregations are not exported.
+func Export(product *models.Product) *ThingDescription {
+	td := &ThingDescription{
+		Context:             []interface{}{ContextTDv11, map[string]string{ContextPrefix: ContextURI}},
+		ID:                  IDPrefix + product.ID,
+		Title:               product.Name,
+		Description:         product.Desc,
+		SecurityDefinitions: map[string]*SecurityScheme{securityNoSec: {Scheme: "nosec"}},
+		Security:            securityNoSec,
+		Protocol:            product.Protocol,
+		Properties:          make(map[string]*PropertyAffordance, len(product.Properties)),
+		Actions:             make(map[string]*ActionAffordance, len(product.Methods)),
+		Events:              make(map[string]*EventAffordance, len(product.Events)),
+	}
+	prefix := fmt.Sprintf("/protocols/%s/products/%s/devices/{%s}", product.Protocol, product.ID, uriVariable)
+	variables := map[string]*DataSchema{uriVariable: {Type: TypeString, Title: "the ID of the device"}}
+
+	for _, property := range product.Properties {
+		href := fmt.Sprintf("%s/props/%s", prefix, property.Id)
+		affordance := &PropertyAffordance{
+			DataSchema: *exportField(property.Name, property.Desc, property.FieldType),
+			Observable: true,
+			Forms: []*Form{
+				{Href: href, ContentType: "application/json", Op: "readproperty", HTVMethod: "GET"},
+				{Href: href + "/stream", ContentType: "text/event-stream", Op: "observeproperty", Subprotocol: "sse"},
+			},
+			URIVariables: variables,
+			Interval:     property.Interval,
+			ReportMode:   property.ReportMode,
+			Expression:   property.Expression,
+			AuxProps:     property.AuxProps,
+		}
+		affordance.Unit = property.Unit
+		affordance.ReadOnly = !property.Writeable
+		if property.Writeable {
+			affordance.Forms = append(affordance.Forms,
+				&Form{Href: href, ContentType: "application/json", Op: "writeproperty", HTVMethod: "PUT"})
+		}
+		if min, err := strconv.ParseFloat(property.AuxProps[transform.AuxKeyMin], 64); err == nil {
+			affordance.Minimum = &min
+		}
+		if max, err := strconv.ParseFloat(property.AuxProps[transform.AuxKeyMax], 64); err == nil {
+			affordance.Maximum = &max
+		}
+		td.Properties[property.Id] = affordance
+	}
+	for _, method := range product.Methods {
+		td.Actions[method.Id] = &ActionAffordance{
+			Title:       method.Name,
+			Description: method.Desc,
+			Input:       exportFields(method.Ins),
+			Output:      exportFields(method.Outs),
+			Forms: []*Form{{Href: fmt.Sprintf("%s/methods/%s", prefix, method.Id),
+				ContentType: "application/json", Op: "invokeaction", HTVMethod: "POST"}},
+			URIVariables: variables,
+			AuxProps:     method.AuxProps,
+		}
+	}
+	for _, event := range product.Events {
+		td.Events[event.Id] = &EventAffordance{
+			Title:       event.Name,
+			Description: event.Desc,
+			Data:        exportFields(event.Outs),
+			Forms: []*Form{{Href: fmt.Sprintf("%s/events/%s/stream", prefix, event.Id),
+				ContentType: "text/event-stream", Op: "subscribeevent", Subprotocol: "sse"}},
+			URIVariables: variables,
+			AuxProps:     event.AuxProps,
+		}
+	}
+	return td
+}
+
+func exportField(name, desc string, fieldType models.PropertyValueType) *DataSchema {
+	schema := &DataSchema{Title: name, Description: desc, FieldType: fieldType}
+	switch fieldType {
+	case models.PropertyValueTypeInt:
+		schema.Type = TypeInteger
+	case models.PropertyValueTypeUint:
+		zero := 0.0
+		schema.Type = TypeInteger
+		schema.Minimum = &zero
+	case models.PropertyValueTypeFloat:
+		schema.Type = TypeNumber
+	case models.PropertyValueTypeBool:
+		schema.Type = TypeBoolean
+	default:
+		schema.Type = TypeString
+	}
+	return schema
+}
+
+// exportFields converts the fields into an object whose properties are all required,
+// the order of the fields is kept by the order of the required.
+func exportFields(fields []*models.ProductField) *DataSchema {
+	if len(fields) == 0 {
+		return nil
+	}
+	schema := &DataSchema{Type: TypeObject, Properties: make(map[string]*DataSchema, len(fields))}
+	for _, field := range fields {
+		schema.Properties[field.Id] = exportField(field.Name, field.Desc, field.FieldType)
+		schema.Required = append(schema.Required, field.Id)
+	}
+	return schema
+}
+
+// Import converts the TD into a product, and returns the warnings about the constructs which can't be converted
+// exactly. The mapping of the constructs not supported by the product is:
+//
+//   - the data of the types object, array or null, or without a type, are imported as strings of JSON
+//   - the enums of the data are dropped, and the bounds of the properties become the clamping
+//     auxiliary properties, see transform.AuxKeyMin and transform.AuxKeyMax
+//   - the write-only properties are imported as writeable ones
+//   - the inputs, the outputs of the actions and the data of the events which are not objects are imported
+//     as a single field named input, output and data respectively
+//   - the units unknown by units.Default are kept as they are
+//   - the security, the forms and the links are dropped, the devices are accessed by the drivers
+//
+// The properties, the methods and the events are sorted by their IDs.
+func Import(td *ThingDescription) (*models.Product, []string, error) {
+	if !supportedContext(td.Context) {
+		return nil, nil, fmt.Errorf("the context of the thing description should include %s or %s", ContextTDv1, ContextTDv11)
+	}
+	id := td.ID
+	if strings.HasPrefix(id, IDPrefix) {
+		id = strings.TrimPrefix(id, IDPrefix)
+	} else if idx := strings.LastIndexAny(id, ":/"); idx >= 0 {
+		id = id[idx+1:]
+	}
+	if id == "" {
+		return nil, nil, fmt.Errorf("the id of the thing description %s is required", td.Title)
+	}
+
+	i := &importer{}
+	product := &models.Product{
+		ID:       id,
+		Name:     td.Title,
+		Desc:     td.Description,
+		Protocol: td.Protocol,
+	}
+	for _, name := range sortedKeys(td.Properties) {
+		product.Properties = append(product.Properties, i.importProperty(name, td.Properties[name]))
+	}
+	for _, name := range sortedKeys(td.Actions) {
+		action := td.Actions[name]
+		product.Methods = append(product.Methods, &models.ProductMethod{
+			Id:       name,
+			Name:     action.Title,
+			Desc:     action.Description,
+			Ins:      i.importFields("the input of the action "+name, inputFieldID, action.Input),
+			Outs:     i.importFields("the output of the action "+name, outputFieldID, action.Output),
+			AuxProps: action.AuxProps,
+		})
+	}
+	for _, name := range sortedKeys(td.Events) {
+		event := td.Events[name]
+		product.Events = append(product.Events, &models.ProductEvent{
+			Id:       name,
+			Name:     event.Title,
+			Desc:     event.Description,
+			Outs:     i.importFields("the data of the event "+name, dataFieldID, event.Data),
+			AuxProps: event.AuxProps,
+		})
+	}
+
+	for _, name := range sortedKeys(td.SecurityDefinitions) {
+		if scheme := td.SecurityDefinitions[name]; scheme.Scheme != "nosec" {
+			i.warnf("the security scheme %s(%s) is dropped", name, scheme.Scheme)
+		}
+	}
+	if len(td.Links) > 0 {
+		i.warnf("the links are dropped")
+	}
+	if err := product.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return product, i.warnings, nil
+}
+
+type importer struct {
+	warnings []string
+}
+
+func (i *importer) warnf(format string, args ...interface{}) {
+	i.warnings = append(i.warnings, fmt.Sprintf(format, args...))
+}
+
+func (i *importer) importProperty(name string, affordance *PropertyAffordance) *models.ProductProperty {
+	what := "the property " + name
+	property := &models.ProductProperty{
+		Id:         name,
+		Name:       affordance.Title,
+		Desc:       affordance.Description,
+		FieldType:  i.importType(what, &affordance.DataSchema),
+		Unit:       i.importUnit(what, affordance.Unit),
+		Writeable:  !affordance.ReadOnly,
+		Interval:   affordance.Interval,
+		ReportMode: affordance.ReportMode,
+		Expression: affordance.Expression,
+		AuxProps:   affordance.AuxProps,
+	}
+	if affordance.WriteOnly {
+		i.warnf("%s is write-only, it's imported as a writeable one", what)
+	}
+
+	bounds := map[string]*float64{transform.AuxKeyMin: affordance.Minimum, transform.AuxKeyMax: affordance.Maximum}
+	if property.FieldType == models.PropertyValueTypeUint && affordance.Minimum != nil && *affordance.Minimum == 0 {
+		delete(bounds, transform.AuxKeyMin) // it's implied by the type
+	}
+	for key, bound := range bounds {
+		if bound == nil || property.FieldType == models.PropertyValueTypeString {
+			continue
+		}
+		if _, ok := property.AuxProps[key]; ok {
+			continue
+		}
+		if property.AuxProps == nil {
+			property.AuxProps = make(map[string]string)
+		}
+		property.AuxProps[key] = strconv.FormatFloat(*bound, 'f', -1, 64)
+	}
+	return property
+}
+
+// importFields converts the object into the fields, or a single field named by the id if it's not an object.
+func (i *importer) importFields(what, id string, schema *DataSchema) []*models.ProductField {
+	if schema == nil {
+		return nil
+	}
+	if schema.Type != TypeObject || len(schema.Properties) == 0 {
+		i.warnf("%s is not an object, it's imported as the field %s", what, id)
+		return []*models.ProductField{{
+			Id:        id,
+			Name:      schema.Title,
+			Desc:      schema.Description,
+			FieldType: i.importType(what, schema),
+		}}
+	}
+
+	// the required fields come first in their order, and then the others sorted by their IDs
+	ids := make([]string, 0, len(schema.Properties))
+	seen := make(map[string]bool, len(schema.Properties))
+	for _, name := range schema.Required {
+		if _, ok := schema.Properties[name]; ok && !seen[name] {
+			seen[name] = true
+			ids = append(ids, name)
+		}
+	}
+	for _, name := range sortedKeys(schema.Properties) {
+		if !seen[name] {
+			ids = append(ids, name)
+		}
+	}
+
+	fields := make([]*models.ProductField, 0, len(ids))
+	for _, name := range ids {
+		field := schema.Properties[name]
+		fields = append(fields, &models.ProductField{
+			Id:        name,
+			Name:      field.Title,
+			Desc:      field.Description,
+			FieldType: i.importType(fmt.Sprintf("the field %s of %s", name, what), field),
+		})
+	}
+	return fields
+}
+
+func (i *importer) importType(what string, schema *DataSchema) models.PropertyValueType {
+	if len(schema.Enum) > 0 {
+		i.warnf("the enum of %s is dropped", what)
+	}
+	switch schema.FieldType {
+	case models.PropertyValueTypeInt, models.PropertyValueTypeUint, models.PropertyValueTypeFloat,
+		models.PropertyValueTypeBool, models.PropertyValueTypeString:
+		return schema.FieldType
+	}
+	switch schema.Type {
+	case TypeInteger:
+		if schema.Minimum != nil && *schema.Minimum >= 0 {
+			return models.PropertyValueTypeUint
+		}
+		return models.PropertyValueTypeInt
+	case TypeNumber:
+		return models.PropertyValueTypeFloat
+	case TypeBoolean:
+		return models.PropertyValueTypeBool
+	case TypeString:
+		return models.PropertyValueTypeString
+	default:
+		t := schema.Type
+		if t == "" {
+			t = "undefined"
+		}
+		i.warnf("the type of %s is %s, it's imported as a string of JSON", what, t)
+		return models.PropertyValueTypeString
+	}
+}
+
+func (i *importer) importUnit(what, unit string) string {
+	if unit == "" {
+		return ""
+	}
+	if u, ok := units.Default.Lookup(unit); ok {
+		return u.Symbol
+	}
+	i.warnf("the unit %s of %s is unknown, it's kept as it is", unit, what)
+	return unit
+}
+
+func supportedContext(context interface{}) bool {
+	switch c := context.(type) {
+	case string:
+		return c == ContextTDv1 || c == ContextTDv11
+	case []interface{}:
+		for _, item := range c {
+			if supportedContext(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]*PropertyAffordance:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]*ActionAffordance:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]*EventAffordance:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]*DataSchema:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[string]*SecurityScheme:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
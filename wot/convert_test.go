@@ -0,0 +1,180 @@
+package wot
+
+import (
+	"encoding/json"
+	"github.com/thingio/edge-device-std/models"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, name string) *ThingDescription {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("fail to read %s: %s", name, err.Error())
+	}
+	td := new(ThingDescription)
+	if err = json.Unmarshal(data, td); err != nil {
+		t.Fatalf("fail to unmarshal %s: %s", name, err.Error())
+	}
+	return td
+}
+
+func contains(warnings []string, substr string) bool {
+	for _, warning := range warnings {
+		if strings.Contains(warning, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImportLamp(t *testing.T) {
+	product, warnings, err := Import(load(t, "lamp.td.json"))
+	if err != nil {
+		t.Fatalf("fail to import: %s", err.Error())
+	}
+	if product.ID != "32473-WoTLamp-1234" || product.Name != "MyLampThing" {
+		t.Errorf("unexpected product %s(%s)", product.Name, product.ID)
+	}
+	if len(product.Properties) != 1 || product.Properties[0].FieldType != models.PropertyValueTypeString ||
+		!product.Properties[0].Writeable {
+		t.Errorf("the status should be a writeable string, got %+v", product.Properties[0])
+	}
+	if len(product.Methods) != 1 || product.Methods[0].Ins != nil || product.Methods[0].Outs != nil {
+		t.Errorf("the toggle should have no inputs or outputs")
+	}
+	outs := product.Events[0].Outs
+	if len(outs) != 1 || outs[0].Id != dataFieldID || outs[0].FieldType != models.PropertyValueTypeString {
+		t.Errorf("the data of overheating should be imported as the field data, got %+v", outs)
+	}
+	for _, expect := range []string{"enum of the property status", "security scheme basic_sc", "event overheating is not an object"} {
+		if !contains(warnings, expect) {
+			t.Errorf("the warning about %s is missing in %v", expect, warnings)
+		}
+	}
+}
+
+func TestImportSensor(t *testing.T) {
+	product, warnings, err := Import(load(t, "sensor.td.json"))
+	if err != nil {
+		t.Fatalf("fail to import: %s", err.Error())
+	}
+	if product.ID != "env-sensor" {
+		t.Errorf("the id should be env-sensor, got %s", product.ID)
+	}
+	properties := make(map[string]*models.ProductProperty)
+	for _, property := range product.Properties {
+		properties[property.Id] = property
+	}
+
+	temperature := properties["temperature"]
+	if temperature.FieldType != models.PropertyValueTypeFloat || temperature.Unit != "°C" || temperature.Writeable {
+		t.Errorf("unexpected temperature %+v", temperature)
+	}
+	if temperature.AuxProps["min"] != "-40" || temperature.AuxProps["max"] != "85" {
+		t.Errorf("the bounds of temperature should be clamping, got %v", temperature.AuxProps)
+	}
+	humidity := properties["humidity"]
+	if humidity.FieldType != models.PropertyValueTypeUint || humidity.Unit != "om:percent" {
+		t.Errorf("unexpected humidity %+v", humidity)
+	}
+	if _, ok := humidity.AuxProps["min"]; ok || humidity.AuxProps["max"] != "100" {
+		t.Errorf("the bounds of humidity should be only max, got %v", humidity.AuxProps)
+	}
+	if properties["thresholds"].FieldType != models.PropertyValueTypeString {
+		t.Errorf("the array should be imported as a string")
+	}
+	if !properties["secret"].Writeable {
+		t.Errorf("the write-only property should be writeable")
+	}
+
+	calibrate := product.Methods[0]
+	ids := make([]string, len(calibrate.Ins))
+	for i, in := range calibrate.Ins {
+		ids[i] = in.Id
+	}
+	if !reflect.DeepEqual(ids, []string{"reference", "offset", "persist"}) {
+		t.Errorf("the inputs should be the required ones first, got %v", ids)
+	}
+	if len(calibrate.Outs) != 1 || calibrate.Outs[0].Id != outputFieldID || calibrate.Outs[0].FieldType != models.PropertyValueTypeBool {
+		t.Errorf("the output should be imported as the field output, got %+v", calibrate.Outs)
+	}
+	for _, expect := range []string{"unit om:percent", "property thresholds is array", "secret is write-only", "links"} {
+		if !contains(warnings, expect) {
+			t.Errorf("the warning about %s is missing in %v", expect, warnings)
+		}
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	if _, _, err := Import(&ThingDescription{Context: "https://example.com/context", ID: "a"}); err == nil {
+		t.Errorf("the context should be checked")
+	}
+	if _, _, err := Import(&ThingDescription{Context: ContextTDv1}); err == nil {
+		t.Errorf("the id should be required")
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	product := &models.Product{
+		ID:       "meter",
+		Name:     "Meter",
+		Desc:     "Power meter",
+		Protocol: "modbus",
+		Properties: []*models.ProductProperty{
+			{Id: "count", Name: "Count", FieldType: models.PropertyValueTypeUint, Interval: "5s", ReportMode: "periodical"},
+			{Id: "current", Name: "Current", FieldType: models.PropertyValueTypeFloat, Unit: "A",
+				AuxProps: map[string]string{"address": "40002", "min": "0", "max": "63"}},
+			{Id: "offset", Name: "Offset", FieldType: models.PropertyValueTypeInt, Writeable: true,
+				AuxProps: map[string]string{"min": "0"}},
+			{Id: "power", Name: "Power", FieldType: models.PropertyValueTypeFloat, Unit: "W", Expression: "voltage * current"},
+			{Id: "switch", Name: "Switch", FieldType: models.PropertyValueTypeBool, Writeable: true},
+			{Id: "voltage", Name: "Voltage", FieldType: models.PropertyValueTypeFloat, Unit: "V"},
+		},
+		Events: []*models.ProductEvent{
+			{Id: "overload", Name: "Overload", Outs: []*models.ProductField{
+				{Id: "current", Name: "Current", FieldType: models.PropertyValueTypeFloat},
+				{Id: "at", Name: "At", FieldType: models.PropertyValueTypeString},
+			}},
+		},
+		Methods: []*models.ProductMethod{
+			{Id: "reset", Name: "Reset", Desc: "reset the counter", Ins: []*models.ProductField{
+				{Id: "delay", Name: "Delay", FieldType: models.PropertyValueTypeInt},
+			}, Outs: []*models.ProductField{
+				{Id: "ok", Name: "OK", FieldType: models.PropertyValueTypeBool},
+			}, AuxProps: map[string]string{"function": "0x06"}},
+		},
+	}
+
+	td := Export(product)
+	if td.Properties["switch"].ReadOnly || !td.Properties["voltage"].ReadOnly {
+		t.Errorf("the read-only flags are inconsistent with the writeable flags")
+	}
+	if *td.Properties["current"].Maximum != 63 {
+		t.Errorf("the maximum of current should be 63")
+	}
+	data, err := json.Marshal(td)
+	if err != nil {
+		t.Fatalf("fail to marshal the thing description: %s", err.Error())
+	}
+	imported := new(ThingDescription)
+	if err = json.Unmarshal(data, imported); err != nil {
+		t.Fatalf("fail to unmarshal the thing description: %s", err.Error())
+	}
+
+	result, warnings, err := Import(imported)
+	if err != nil {
+		t.Fatalf("fail to import: %s", err.Error())
+	}
+	if len(warnings) != 0 {
+		t.Errorf("no warnings are expected, got %v", warnings)
+	}
+	if !reflect.DeepEqual(result, product) {
+		expect, _ := json.Marshal(product)
+		got, _ := json.Marshal(result)
+		t.Errorf("expect %s, got %s", expect, got)
+	}
+}
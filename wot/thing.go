@@ -0,0 +1,104 @@
+package wot
+
+const (
+	ContextTDv1  = "https://www.w3.org/2019/wot/td/v1"
+	ContextTDv11 = "https://www.w3.org/2022/wot/td/v1.1"
+
+	// ContextPrefix is the prefix of the terms extending the TD to keep the fields of models.Product.
+	ContextPrefix = "thingio"
+	ContextURI    = "https://github.com/thingio/edge-device-std/wot#"
+
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+// ThingDescription is a W3C WoT Thing Description, only the terms used by the conversion are defined.
+type ThingDescription struct {
+	Context             interface{}                    `json:"@context"` // a string, or an array of strings and prefix maps
+	Type                interface{}                    `json:"@type,omitempty"`
+	ID                  string                         `json:"id,omitempty"`
+	Title               string                         `json:"title"`
+	Description         string                         `json:"description,omitempty"`
+	Base                string                         `json:"base,omitempty"`
+	SecurityDefinitions map[string]*SecurityScheme     `json:"securityDefinitions"`
+	Security            interface{}                    `json:"security"` // a string or an array of strings
+	Properties          map[string]*PropertyAffordance `json:"properties,omitempty"`
+	Actions             map[string]*ActionAffordance   `json:"actions,omitempty"`
+	Events              map[string]*EventAffordance    `json:"events,omitempty"`
+	Links               []map[string]interface{}       `json:"links,omitempty"`
+	Forms               []*Form                        `json:"forms,omitempty"`
+	Protocol            string                         `json:"thingio:protocol,omitempty"`
+}
+
+type SecurityScheme struct {
+	Scheme string `json:"scheme"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// DataSchema describes the data of the affordances.
+type DataSchema struct {
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Unit        string                 `json:"unit,omitempty"`
+	Enum        []interface{}          `json:"enum,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	ReadOnly    bool                   `json:"readOnly,omitempty"`
+	WriteOnly   bool                   `json:"writeOnly,omitempty"`
+	Properties  map[string]*DataSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       interface{}            `json:"items,omitempty"`
+	OneOf       []*DataSchema          `json:"oneOf,omitempty"`
+
+	FieldType string `json:"thingio:fieldType,omitempty"` // the models.PropertyValueType, which can't be told by the type
+}
+
+type PropertyAffordance struct {
+	DataSchema
+	Observable   bool                   `json:"observable,omitempty"`
+	Forms        []*Form                `json:"forms"`
+	URIVariables map[string]*DataSchema `json:"uriVariables,omitempty"`
+
+	Interval   string            `json:"thingio:interval,omitempty"`
+	ReportMode string            `json:"thingio:reportMode,omitempty"`
+	Expression string            `json:"thingio:expression,omitempty"`
+	AuxProps   map[string]string `json:"thingio:auxProps,omitempty"`
+}
+
+type ActionAffordance struct {
+	Title        string                 `json:"title,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	Input        *DataSchema            `json:"input,omitempty"`
+	Output       *DataSchema            `json:"output,omitempty"`
+	Safe         bool                   `json:"safe,omitempty"`
+	Idempotent   bool                   `json:"idempotent,omitempty"`
+	Forms        []*Form                `json:"forms"`
+	URIVariables map[string]*DataSchema `json:"uriVariables,omitempty"`
+
+	AuxProps map[string]string `json:"thingio:auxProps,omitempty"`
+}
+
+type EventAffordance struct {
+	Title        string                 `json:"title,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	Data         *DataSchema            `json:"data,omitempty"`
+	Forms        []*Form                `json:"forms"`
+	URIVariables map[string]*DataSchema `json:"uriVariables,omitempty"`
+
+	AuxProps map[string]string `json:"thingio:auxProps,omitempty"`
+}
+
+type Form struct {
+	Href        string      `json:"href"`
+	ContentType string      `json:"contentType,omitempty"`
+	Op          interface{} `json:"op,omitempty"` // a string or an array of strings
+	Subprotocol string      `json:"subprotocol,omitempty"`
+	HTVMethod   string      `json:"htv:methodName,omitempty"`
+}